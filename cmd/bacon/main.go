@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+var centerFlag = flag.Int("center", 4724, "TMDb id of the actor to measure from (default Kevin Bacon)")
+var maxDepthFlag = flag.Int("max-depth", 8, "furthest distance numbered; actors beyond it get no bacon_number")
+var batchSizeFlag = flag.Int("batch-size", 1000, "actors written per transaction")
+
+func main() {
+	flag.Parse()
+
+	if *centerFlag < 1 {
+		log.Fatalln("-center must be a positive TMDb id")
+	}
+	if *maxDepthFlag < 1 || *maxDepthFlag > graph.MaxDistancesDepth {
+		log.Fatalf("-max-depth must be between 1 and %d", graph.MaxDistancesDepth)
+	}
+	if *batchSizeFlag < 1 {
+		log.Fatalln("-batch-size must be at least 1")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalln("Error loading config:", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := graph.NewDriver(ctx, *cfg)
+	if err != nil {
+		log.Fatalln("Error connecting to neo4j:", err)
+	}
+	defer db.Close(context.Background())
+
+	center, err := db.GetActorByID(ctx, *centerFlag)
+	if err != nil {
+		log.Fatalln("Error looking up center:", err)
+	}
+	if center == nil {
+		log.Fatalf("No actor %d in the graph", *centerFlag)
+	}
+
+	log.Printf("Measuring distances from %s (%d), up to %d hops", center.Name, center.TmdbID, *maxDepthFlag)
+	start := time.Now()
+	distances, err := db.DistancesFrom(ctx, center.TmdbID, *maxDepthFlag)
+	if err != nil {
+		log.Fatalln("Error measuring distances:", err)
+	}
+	counts := make(map[int]int)
+	furthest := 0
+	for _, n := range distances {
+		counts[n]++
+		furthest = max(furthest, n)
+	}
+	for n := 1; n <= furthest; n++ {
+		log.Printf("  %d: %d actors", n, counts[n])
+	}
+	log.Printf("Reached %d actors in %s", len(distances)-1, time.Since(start).Round(time.Second))
+
+	written, err := db.SetBaconNumbers(ctx, center.TmdbID, distances, *batchSizeFlag, func(n int) {
+		if n%(*batchSizeFlag*50) == 0 {
+			log.Printf("Wrote %d bacon numbers", n)
+		}
+	})
+	if err != nil {
+		// Numbers from the previous run are already gone; running again
+		// writes a complete set.
+		log.Fatalf("Error writing bacon numbers after %d actors: %v", written, err)
+	}
+	log.Printf("Done: %d actors numbered, furthest %d hops", written, furthest)
+}
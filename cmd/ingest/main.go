@@ -1,51 +1,177 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"io"
+	"log/slog"
 	"math"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	"github.com/mark-c-hall/degrees-of-separation/internal/debug"
 	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/ingest"
+	"github.com/mark-c-hall/degrees-of-separation/internal/logging"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/internal/telemetry"
 	"github.com/mark-c-hall/degrees-of-separation/internal/tmdb"
+	"github.com/mark-c-hall/degrees-of-separation/web"
 )
 
 var pagesFlag = flag.Int("pages", 100, "number of movie api pages to consume (around 20 results per page)")
 var maxCastFlag = flag.Int("max-cast", 20, "top k billed actors from a movie")
 var resumeFlag = flag.Bool("resume", false, "bool to resume from previous movie api page")
 var allFlag = flag.Bool("all", false, "consume all available pages (overrides -pages)")
+var startPageFlag = flag.Int("start-page", 0, "movie api page to start from (overrides -resume)")
+var maxMoviesFlag = flag.Int("max-movies", 0, "stop after ingesting this many movies, regardless of page boundaries (0 means no cap)")
+var dryRunFlag = flag.Bool("dry-run", false, "fetch pages and casts but log what would be written instead of touching neo4j")
+var movieTimeoutFlag = flag.Duration("movie-timeout", 2*time.Minute, "give up on a movie's cast fetch and write after this long and move on")
+var castStrategyFlag = flag.String("cast-strategy", string(tmdb.CastByOrder), "which actors -max-cast keeps: order (billing) or popularity")
+var logFormatFlag = flag.String("log-format", "", "log output format: text or json (default $LOG_FORMAT, else text)")
+var progressAddrFlag = flag.String("progress-addr", "", "serve live progress at http://<addr>/admin/ingest while ingesting (empty disables)")
+var minYearFlag = flag.Int("min-year", 0, "only ingest movies released in or after this year (0 means no lower bound)")
+var maxYearFlag = flag.Int("max-year", 0, "only ingest movies released in or before this year (0 means no upper bound)")
+var decadeFlag = flag.Int("decade", 0, "only ingest movies from the decade starting this year, e.g. 1990 (shorthand for -min-year/-max-year)")
+var configFlag = flag.String("config", "", "YAML config file; environment variables override its settings (default $CONFIG_FILE)")
+var compactEdgesFlag = flag.Bool("compact-edges", false, "fold per-movie costar edges into one edge per actor pair, then exit (run before setting NEO4J_COMPACT_EDGES=true)")
 
 func main() {
 	flag.Parse()
 
-	cfg, err := config.Load()
+	// The logger's level and format come from the config, so a config that
+	// fails to load is reported with the defaults.
+	cfg, cfgErr := config.LoadIngest(*configFlag)
+	var logCfg config.LogConfig
+	if cfg != nil {
+		logCfg = cfg.Log
+	}
+	logger, err := newLogger(os.Stdout, *logFormatFlag, logCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+	if cfgErr != nil {
+		for _, problem := range config.Problems(cfgErr) {
+			logger.Error("error loading config", "error", problem)
+		}
+		os.Exit(1)
+	}
+
+	if *startPageFlag < 0 {
+		fatal(logger, "-start-page must be a positive page number")
+	}
+	if *maxMoviesFlag < 0 {
+		fatal(logger, "-max-movies must not be negative")
+	}
+	if *movieTimeoutFlag <= 0 {
+		fatal(logger, "-movie-timeout must be positive")
+	}
+	if *dryRunFlag && *resumeFlag && *startPageFlag == 0 {
+		fatal(logger, "-resume reads state from neo4j and can't be used with -dry-run; use -start-page instead")
+	}
+	if *dryRunFlag && *compactEdgesFlag {
+		fatal(logger, "-compact-edges rewrites neo4j and can't be used with -dry-run")
+	}
+
+	years, err := parseYearRange(*minYearFlag, *maxYearFlag, *decadeFlag)
+	if err != nil {
+		fatal(logger, "invalid year filter", "error", err)
+	}
+
+	castStrategy, err := tmdb.ParseCastStrategy(*castStrategyFlag)
 	if err != nil {
-		log.Fatalln("Error loading config:", err)
+		fatal(logger, "invalid -cast-strategy", "error", err)
 	}
 
+	logger.Info("effective config", "config", cfg.Redacted())
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	client := tmdb.NewClient(*cfg)
+	otelShutdown, err := telemetry.Setup(ctx, "degrees-of-separation-ingest", cfg.Telemetry)
+	if err != nil {
+		fatal(logger, "error setting up telemetry", "error", err)
+	}
+	defer func() {
+		if err := otelShutdown(context.Background()); err != nil {
+			logger.Warn("OTel shutdown did not complete cleanly", "error", err)
+		}
+	}()
+	tracer := otel.Tracer("degrees-of-separation/ingest")
 
-	db, err := graph.NewDriver(ctx, *cfg)
+	// otelhttp gives each TMDb call a client span under the movie's span.
+	client, err := tmdb.NewClientWithTransport(*cfg, otelhttp.NewTransport(http.DefaultTransport))
 	if err != nil {
-		log.Fatalln("Error connecting to neo4j:", err)
+		fatal(logger, "error creating tmdb client", "error", err)
+	}
+	client.CastStrategy = castStrategy
+	client.Logger = logger
+
+	// db stays nil in dry-run mode; every use below is guarded by dryRunFlag.
+	var db *graph.Driver
+	if *dryRunFlag {
+		logger.Info("dry run: neo4j will not be contacted")
+	} else {
+		db, err = graph.NewDriver(ctx, *cfg)
+		if err != nil {
+			fatal(logger, "error connecting to neo4j", "error", err)
+		}
+		defer db.Close(context.Background())
+	}
+
+	if *compactEdgesFlag {
+		folded, err := db.CompactCostarEdges(ctx)
+		if err != nil {
+			fatal(logger, "error compacting costar edges", "error", err)
+		}
+		logger.Info("compacted costar edges", "edges_folded", folded)
+		return
 	}
-	defer db.Close(context.Background())
 
 	firstPage := 1
-	if *resumeFlag {
+	skipThrough := -1 // index of the last movie on firstPage already ingested by a previous run
+	switch {
+	case *startPageFlag > 0:
+		firstPage = *startPageFlag
+		if *resumeFlag {
+			logger.Info("starting ingest", "page", firstPage, "reason", "-start-page overrides -resume")
+		} else {
+			logger.Info("starting ingest", "page", firstPage, "reason", "-start-page")
+		}
+	case *resumeFlag:
 		lastPage, err := db.GetLastIngestedPage(ctx)
 		if err != nil {
-			log.Fatalln("Error reading last ingested page:", err)
+			fatal(logger, "error reading last ingested page", "error", err)
 		}
 		firstPage = lastPage + 1
-		log.Printf("Resuming from page %d", firstPage)
+		moviePage, movieIndex, ok, err := db.GetLastIngestedMovie(ctx)
+		if err != nil {
+			fatal(logger, "error reading last ingested movie", "error", err)
+		}
+		if ok && moviePage == firstPage {
+			skipThrough = movieIndex
+			logger.Info("resuming ingest mid-page", "page", firstPage, "after_movie", movieIndex+1)
+		} else {
+			logger.Info("resuming ingest", "page", firstPage, "last_ingested_page", lastPage)
+		}
+	default:
+		logger.Info("starting ingest", "page", firstPage, "reason", "default")
 	}
 
 	lastPage := *pagesFlag
@@ -53,55 +179,334 @@ func main() {
 		lastPage = math.MaxInt
 	}
 	if firstPage > lastPage {
-		log.Printf("Nothing to do: first page %d > last page %d", firstPage, lastPage)
+		logger.Info("nothing to do", "first_page", firstPage, "last_page", lastPage)
 		return
 	}
 
+	// events stays nil without -progress-addr, which makes publishing a no-op.
+	var events *ingest.Broker
+	if *progressAddrFlag != "" {
+		events = ingest.NewBroker()
+		stopProgress, err := serveProgress(*progressAddrFlag, events)
+		if err != nil {
+			fatal(logger, "error starting progress server", "error", err)
+		}
+		defer stopProgress()
+		logger.Info("serving ingest progress", "url", "http://"+*progressAddrFlag+"/admin/ingest")
+	}
+
+	// Ingest has no public listener, so debug endpoints need their own.
+	if cfg.Server.DebugEndpoints && cfg.Server.DebugAddr != "" {
+		stopDebug, err := serveDebug(cfg.Server.DebugAddr, logger)
+		if err != nil {
+			fatal(logger, "error starting debug server", "error", err)
+		}
+		defer stopDebug()
+		logger.Info("serving debug endpoints", "url", "http://"+cfg.Server.DebugAddr+"/debug/vars")
+	}
+
+	var movieCount, edgeCount, timedOut int
+	actorsSeen := make(map[int]bool)
+
+	// On interrupt, record the last movie that made it into the graph so
+	// -resume skips it instead of redoing the whole page. Registered after
+	// db.Close, so it runs while the driver is still open.
+	var done progress
+	if !*dryRunFlag {
+		defer func() {
+			if ctx.Err() != nil {
+				done.flush(logger, db)
+			}
+		}()
+	}
+
+pages:
 	for page := firstPage; page <= lastPage; page++ {
 		if ctx.Err() != nil {
-			log.Println("Interrupted, stopping ingest")
+			logger.Info("interrupted, stopping ingest", "page", page)
 			break
 		}
 
 		totalPages, movies, err := client.GetPopularMovies(ctx, page)
 		if err != nil {
-			log.Printf("Error fetching popular movies page %d, skipping: %v", page, err)
+			events.Publish(ingest.Event{Kind: ingest.EventError, Page: page, Error: err.Error()})
+			// With -all the page count is the only end there is, so until a
+			// page has returned it, skipping on could run forever.
+			if lastPage == math.MaxInt {
+				logger.Error("error fetching popular movies before the page count is known, stopping", "page", page, "error", err)
+				return
+			}
+			logger.Error("error fetching popular movies, skipping page", "page", page, "error", err)
 			continue
 		}
+		if page > totalPages {
+			if page == firstPage {
+				logger.Error("start page is past the last available page", "page", firstPage, "total_pages", totalPages)
+				return
+			}
+			logger.Info("reached the last available page", "page", page-1, "total_pages", totalPages)
+			break
+		}
 		if totalPages < lastPage {
 			lastPage = totalPages
 		}
 
-		log.Printf("Processing page %d/%d", page, lastPage)
+		logger.Info("processing page", "page", page, "total_pages", lastPage)
+		events.Publish(ingest.Event{Kind: ingest.EventPage, Page: page, FirstPage: firstPage, LastPage: lastPage})
 
 		for i, movie := range movies {
-			log.Printf("  Movie %d/%d: %q (%d)", i+1, len(movies), movie.Title, movie.Year)
+			if page == firstPage && i <= skipThrough {
+				continue
+			}
+			if !years.contains(movie.Year) {
+				logger.Debug("skipping movie outside year range", "movie_id", movie.TmdbID, "title", movie.Title, "year", movie.Year)
+				continue
+			}
+			logger.Info("processing movie", "page", page, "position", i+1, "page_size", len(movies), "movie_id", movie.TmdbID, "title", movie.Title, "year", movie.Year)
+
+			// Each movie gets its own deadline. When ctx is done the run was
+			// interrupted and everything stops; when only movieCtx is done this
+			// movie was too slow and is skipped.
+			movieCtx, cancelTimeout := context.WithTimeout(ctx, *movieTimeoutFlag)
+			movieCtx, span := tracer.Start(movieCtx, "ingest.movie", trace.WithAttributes(
+				attribute.Int("movie.id", movie.TmdbID),
+				attribute.Int("movie.page", page),
+			))
+			cancel := func() {
+				span.End()
+				cancelTimeout()
+			}
 
-			cast, err := client.GetMovieCast(ctx, movie.TmdbID, *maxCastFlag)
+			cast, err := client.GetMovieCast(movieCtx, movie.TmdbID, *maxCastFlag)
 			if err != nil {
+				cancel()
 				if ctx.Err() != nil {
 					break
 				}
-				log.Printf("Error fetching cast for %q (tmdb=%d), skipping: %v", movie.Title, movie.TmdbID, err)
+				if errors.Is(movieCtx.Err(), context.DeadlineExceeded) {
+					timedOut++
+					logger.Warn("timed out fetching cast, skipping movie", "movie_id", movie.TmdbID, "title", movie.Title, "timeout", *movieTimeoutFlag)
+					events.Publish(movieError(page, movie, "timed out fetching cast"))
+					continue
+				}
+				logger.Error("error fetching cast, skipping movie", "movie_id", movie.TmdbID, "title", movie.Title, "error", err)
+				events.Publish(movieError(page, movie, err.Error()))
 				continue
 			}
 
-			log.Printf("    Ingesting %d actors and costar edges", len(cast))
+			pairs := len(cast) * (len(cast) - 1) / 2
 
-			if err := db.IngestMovieCast(ctx, movie, cast); err != nil {
-				if ctx.Err() != nil {
-					break
+			if *dryRunFlag {
+				names := make([]string, len(cast))
+				for i, a := range cast {
+					names[i] = a.Name
+				}
+				logger.Info("would ingest cast", "movie_id", movie.TmdbID, "title", movie.Title, "actors", len(cast), "edges", pairs, "cast", strings.Join(names, ", "))
+			} else {
+				if err := db.IngestMovieCast(movieCtx, movie, cast); err != nil {
+					cancel()
+					if ctx.Err() != nil {
+						break
+					}
+					if errors.Is(movieCtx.Err(), context.DeadlineExceeded) {
+						timedOut++
+						logger.Warn("timed out ingesting cast, skipping movie", "movie_id", movie.TmdbID, "title", movie.Title, "timeout", *movieTimeoutFlag)
+						events.Publish(movieError(page, movie, "timed out ingesting cast"))
+						continue
+					}
+					logger.Error("error ingesting cast", "movie_id", movie.TmdbID, "title", movie.Title, "error", err)
+					events.Publish(movieError(page, movie, err.Error()))
+					continue
 				}
-				log.Printf("Error ingesting cast for %q: %v", movie.Title, err)
+				logger.Info("ingested cast", "movie_id", movie.TmdbID, "title", movie.Title, "actors_ingested", len(cast), "edges", pairs)
+			}
+			cancel()
+
+			done = progress{page: page, index: i, pageLen: len(movies)}
+			movieCount++
+			events.Publish(ingest.Event{
+				Kind:     ingest.EventMovie,
+				Page:     page,
+				Position: i + 1,
+				PageSize: len(movies),
+				MovieID:  movie.TmdbID,
+				Title:    movie.Title,
+			})
+			edgeCount += pairs
+			for _, a := range cast {
+				actorsSeen[a.TmdbID] = true
+			}
+
+			if *maxMoviesFlag > 0 && movieCount >= *maxMoviesFlag {
+				logger.Info("reached -max-movies cap", "max_movies", *maxMoviesFlag, "page", page, "position", i+1, "page_size", len(movies))
+				if !*dryRunFlag {
+					saveProgress(ctx, logger, db, page, i, len(movies))
+				}
+				break pages
 			}
 		}
 
-		if ctx.Err() == nil {
+		if ctx.Err() == nil && !*dryRunFlag {
 			if err := db.SetLastIngestedPage(ctx, page); err != nil {
-				log.Printf("Error saving ingest state for page %d: %v", page, err)
+				logger.Error("error saving ingest state", "page", page, "error", err)
 			}
 		}
 	}
 
-	log.Println("Ingest complete")
+	events.Publish(ingest.Event{Kind: ingest.EventDone, Movies: movieCount})
+	if *dryRunFlag {
+		logger.Info("dry run complete", "movies", movieCount, "actors", len(actorsSeen), "edges", edgeCount, "timed_out", timedOut)
+		return
+	}
+	if ctx.Err() == nil {
+		if err := db.SetLastIngestedAt(ctx, time.Now()); err != nil {
+			logger.Error("error saving ingest completion time", "error", err)
+		}
+	}
+	logger.Info("ingest complete", "movies", movieCount, "actors", len(actorsSeen), "edges", edgeCount, "timed_out", timedOut)
+}
+
+// flushTimeout bounds the state write made on the way out of an interrupted
+// run, which can't use the already cancelled signal context.
+const flushTimeout = 10 * time.Second
+
+// stateStore is the part of graph.Driver that records ingest progress.
+type stateStore interface {
+	SetLastIngestedPage(ctx context.Context, page int) error
+	SetLastIngestedMovie(ctx context.Context, page, index int) error
+}
+
+// progress is the position of the last movie ingested. The zero value means
+// nothing has been ingested yet this run.
+type progress struct {
+	page, index, pageLen int
+}
+
+// flush saves p on a fresh context so an interrupted run still records where
+// it stopped. It does nothing if no movie was ingested.
+func (p progress) flush(logger *slog.Logger, db stateStore) {
+	if p.page == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	logger.Info("saving ingest state before exit", "page", p.page, "position", p.index+1, "page_size", p.pageLen)
+	saveProgress(ctx, logger, db, p.page, p.index, p.pageLen)
+}
+
+// saveProgress records how far ingest got when stopping after movie index i
+// of a page. A finished page is saved as such; otherwise the movie position is
+// saved so -resume can pick up mid-page.
+func saveProgress(ctx context.Context, logger *slog.Logger, db stateStore, page, i, pageLen int) {
+	if i == pageLen-1 {
+		if err := db.SetLastIngestedPage(ctx, page); err != nil {
+			logger.Error("error saving ingest state", "page", page, "error", err)
+		}
+		return
+	}
+	if err := db.SetLastIngestedMovie(ctx, page, i); err != nil {
+		logger.Error("error saving ingest state", "page", page, "position", i+1, "error", err)
+	}
+}
+
+// movieError is the progress event for a movie that was skipped.
+func movieError(page int, movie models.Movie, msg string) ingest.Event {
+	return ingest.Event{Kind: ingest.EventError, Page: page, MovieID: movie.TmdbID, Title: movie.Title, Error: msg}
+}
+
+// yearRange limits ingest to movies released between min and max inclusive.
+// A zero bound is open.
+type yearRange struct {
+	min, max int
+}
+
+// parseYearRange builds the range from -min-year and -max-year, or from
+// -decade, which can't be combined with them.
+func parseYearRange(minYear, maxYear, decade int) (yearRange, error) {
+	if minYear < 0 || maxYear < 0 {
+		return yearRange{}, errors.New("-min-year and -max-year must not be negative")
+	}
+	if decade != 0 {
+		if minYear != 0 || maxYear != 0 {
+			return yearRange{}, errors.New("-decade can't be combined with -min-year or -max-year")
+		}
+		if decade < 0 || decade%10 != 0 {
+			return yearRange{}, fmt.Errorf("-decade must be the first year of a decade, like 1990, got %d", decade)
+		}
+		return yearRange{min: decade, max: decade + 9}, nil
+	}
+	if maxYear != 0 && minYear > maxYear {
+		return yearRange{}, fmt.Errorf("-min-year %d is after -max-year %d", minYear, maxYear)
+	}
+	return yearRange{min: minYear, max: maxYear}, nil
+}
+
+// contains reports whether a movie from year should be ingested. With either
+// bound set, movies without a release year are left out, since they can't be
+// placed in the range.
+func (y yearRange) contains(year int) bool {
+	if y.min == 0 && y.max == 0 {
+		return true
+	}
+	if year <= 0 {
+		return false
+	}
+	return (y.min == 0 || year >= y.min) && (y.max == 0 || year <= y.max)
+}
+
+// serveProgress serves the progress page for events on addr in the
+// background. The returned func shuts the server down, closing the broker so
+// open event streams end instead of holding the shutdown up.
+func serveProgress(addr string, events *ingest.Broker) (func(), error) {
+	handler, err := ingest.NewAdminHandler(events, web.FS)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %w", addr, err)
+	}
+
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	srv.RegisterOnShutdown(events.Close)
+	go srv.Serve(ln)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
+
+// serveDebug serves pprof and expvar on addr until the returned func is
+// called, so TMDb request counts can be watched mid-run.
+func serveDebug(addr string, logger *slog.Logger) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %w", addr, err)
+	}
+
+	srv := &http.Server{Handler: debug.Handler(logger), ReadHeaderTimeout: 5 * time.Second}
+	go srv.Serve(ln)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
+
+// newLogger builds the ingest logger at cfg's level. The -log-format flag
+// wins over LOG_FORMAT, and text is the default for interactive runs.
+func newLogger(w io.Writer, flagFormat string, cfg config.LogConfig) (*slog.Logger, error) {
+	level := new(slog.LevelVar)
+	level.Set(cfg.Level)
+	return logging.New(w, cmp.Or(flagFormat, cfg.Format, "text"), level)
+}
+
+// fatal logs msg at error level and exits, standing in for log.Fatal.
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+)
+
+type savedState struct {
+	page, index int
+	moviePage   bool
+}
+
+// mockStore records state writes, failing any made on a context that is
+// already done the way the real driver would.
+type mockStore struct {
+	saved []savedState
+}
+
+func (m *mockStore) SetLastIngestedPage(ctx context.Context, page int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.saved = append(m.saved, savedState{page: page, index: -1})
+	return nil
+}
+
+func (m *mockStore) SetLastIngestedMovie(ctx context.Context, page, index int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.saved = append(m.saved, savedState{page: page, index: index, moviePage: true})
+	return nil
+}
+
+func TestProgressFlush(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name string
+		done progress
+		want []savedState
+	}{
+		{"mid page", progress{page: 3, index: 7, pageLen: 20}, []savedState{{page: 3, index: 7, moviePage: true}}},
+		{"last movie of page", progress{page: 3, index: 19, pageLen: 20}, []savedState{{page: 3, index: -1}}},
+		{"nothing ingested", progress{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStore{}
+			tt.done.flush(logger, store)
+
+			if len(store.saved) != len(tt.want) {
+				t.Fatalf("expected %d state writes, got %d: %+v", len(tt.want), len(store.saved), store.saved)
+			}
+			for i := range tt.want {
+				if store.saved[i] != tt.want[i] {
+					t.Errorf("write %d: expected %+v, got %+v", i, tt.want[i], store.saved[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseYearRange(t *testing.T) {
+	tests := []struct {
+		name                     string
+		minYear, maxYear, decade int
+		want                     yearRange
+		wantErr                  bool
+	}{
+		{"no filter", 0, 0, 0, yearRange{}, false},
+		{"bounds", 1980, 1995, 0, yearRange{min: 1980, max: 1995}, false},
+		{"open upper bound", 2000, 0, 0, yearRange{min: 2000}, false},
+		{"decade", 0, 0, 1990, yearRange{min: 1990, max: 1999}, false},
+		{"decade mid-decade", 0, 0, 1994, yearRange{}, true},
+		{"decade with bounds", 1980, 0, 1990, yearRange{}, true},
+		{"inverted bounds", 2000, 1990, 0, yearRange{}, true},
+		{"negative bound", -1, 0, 0, yearRange{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYearRange(tt.minYear, tt.maxYear, tt.decade)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestYearRangeContains(t *testing.T) {
+	nineties := yearRange{min: 1990, max: 1999}
+	since2000 := yearRange{min: 2000}
+	tests := []struct {
+		name  string
+		years yearRange
+		year  int
+		want  bool
+	}{
+		{"no filter keeps undated", yearRange{}, 0, true},
+		{"no filter keeps any year", yearRange{}, 1931, true},
+		{"first year", nineties, 1990, true},
+		{"last year", nineties, 1999, true},
+		{"before", nineties, 1989, false},
+		{"after", nineties, 2000, false},
+		{"filter drops undated", nineties, 0, false},
+		{"open upper bound", since2000, 2024, true},
+		{"open upper bound, before", since2000, 1999, false},
+	}
+	for _, tt := range tests {
+		if got := tt.years.contains(tt.year); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		flagFormat string
+		cfg        config.LogConfig
+		wantJSON   bool
+	}{
+		{"text by default", "", config.LogConfig{}, false},
+		{"format from config", "", config.LogConfig{Format: "json"}, true},
+		{"flag over config", "text", config.LogConfig{Format: "json"}, false},
+		{"json flag", "json", config.LogConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger, err := newLogger(&out, tt.flagFormat, tt.cfg)
+			if err != nil {
+				t.Fatalf("newLogger failed: %v", err)
+			}
+			logger.Info("ingested cast", "movie_id", 550, "actors_ingested", 12)
+
+			var record map[string]any
+			isJSON := json.Unmarshal(out.Bytes(), &record) == nil
+			if isJSON != tt.wantJSON {
+				t.Fatalf("expected JSON %v, got %q", tt.wantJSON, out.String())
+			}
+			if !isJSON && !strings.Contains(out.String(), "movie_id=550 actors_ingested=12") {
+				t.Errorf("expected key=value fields, got %q", out.String())
+			}
+			if isJSON && record["movie_id"] != float64(550) {
+				t.Errorf("expected movie_id as a field, got %v", record)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	logger, _ := newLogger(&out, "", config.LogConfig{Level: slog.LevelWarn})
+	logger.Info("ingested cast")
+	if out.Len() != 0 {
+		t.Errorf("expected info dropped at warn level, got %q", out.String())
+	}
+	if _, err := newLogger(&out, "xml", config.LogConfig{}); err == nil {
+		t.Error("expected an error for an unknown -log-format")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+var confirmFlag = flag.Bool("confirm", false, "required: acknowledge that every actor, edge and ingest checkpoint will be deleted")
+var batchSizeFlag = flag.Int("batch-size", 10000, "nodes deleted per transaction")
+var schemaFlag = flag.Bool("schema", false, "re-run schema migrations after clearing, so the graph is ready for ingest")
+
+func main() {
+	flag.Parse()
+
+	if *batchSizeFlag < 1 {
+		log.Fatalln("-batch-size must be at least 1")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalln("Error loading config:", err)
+	}
+
+	if !*confirmFlag {
+		log.Fatalf("Refusing to wipe %s without -confirm", cfg.DB.URI)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := graph.NewDriver(ctx, *cfg)
+	if err != nil {
+		log.Fatalln("Error connecting to neo4j:", err)
+	}
+	defer db.Close(context.Background())
+
+	log.Printf("Clearing graph at %s in batches of %d", cfg.DB.URI, *batchSizeFlag)
+	deleted, err := db.ClearGraph(ctx, *batchSizeFlag, func(n int64) {
+		log.Printf("Deleted %d nodes", n)
+	})
+	if err != nil {
+		// Each batch commits on its own, so an interrupted reset leaves a
+		// partly cleared graph; running it again finishes the job.
+		log.Fatalf("Error clearing graph after %d nodes: %v", deleted, err)
+	}
+	log.Printf("Graph cleared: %d nodes deleted", deleted)
+
+	if *schemaFlag {
+		if err := db.SetupSchema(ctx); err != nil {
+			log.Fatalln("Error setting up schema:", err)
+		}
+		log.Println("Schema migrations applied")
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
+	"flag"
 	"log"
 	"log/slog"
 	"net/http"
@@ -9,72 +12,257 @@ import (
 	"os/signal"
 	"syscall"
 
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
 	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	"github.com/mark-c-hall/degrees-of-separation/internal/debug"
 	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
 	"github.com/mark-c-hall/degrees-of-separation/internal/handler"
+	"github.com/mark-c-hall/degrees-of-separation/internal/listen"
+	"github.com/mark-c-hall/degrees-of-separation/internal/logging"
+	"github.com/mark-c-hall/degrees-of-separation/internal/metrics"
 	"github.com/mark-c-hall/degrees-of-separation/internal/telemetry"
+	"github.com/mark-c-hall/degrees-of-separation/internal/tlsutil"
+	"github.com/mark-c-hall/degrees-of-separation/internal/tmdb"
+	"github.com/mark-c-hall/degrees-of-separation/internal/version"
 	"github.com/mark-c-hall/degrees-of-separation/web"
 )
 
+var configFlag = flag.String("config", "", "YAML config file; environment variables override its settings (default $CONFIG_FILE)")
+
 func main() {
-	cfg, err := config.Load()
+	flag.Parse()
+
+	cfg, err := config.LoadServer(*configFlag)
 	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+		for _, problem := range config.Problems(err) {
+			log.Printf("failed to load config: %v", problem)
+		}
+		os.Exit(1)
 	}
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	// The level is a LevelVar so a SIGHUP reload can change it without a
+	// restart.
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.Log.Level)
+	logger, err := logging.New(os.Stdout, cmp.Or(cfg.Log.Format, "json"), logLevel)
+	if err != nil {
+		log.Fatalf("failed to set up logging: %v", err)
+	}
+	slog.SetDefault(logger)
+	build := version.Get()
+	logger.Info("starting server", "version", build.Version, "commit", build.Commit, "build_date", build.Date)
+	logger.Info("effective config", "config", cfg.Redacted())
 
 	ctx := context.Background()
-	otelShutdown, err := telemetry.Setup(ctx)
+	otelShutdown, err := telemetry.Setup(ctx, "degrees-of-separation", cfg.Telemetry)
 	if err != nil {
-		log.Fatalf("failed to set up telemetry: %v", err)
+		fatal(logger, "failed to set up telemetry", "err", err)
 	}
 
-	d, err := graph.NewDriver(ctx, *cfg)
+	// Neo4j may still be starting; Connect retries within the configured
+	// budget before giving up.
+	d, err := graph.Connect(ctx, *cfg, logger)
 	if err != nil {
-		log.Fatalf("failed to initialize neo4j driver: %v", err)
+		fatal(logger, "failed to connect to neo4j and set up schema", "err", err)
+	}
+	d.SetLogger(logger)
+
+	// Index creation returns before the index is populated; serving before
+	// then means search comes back empty.
+	schemaCtx, cancelSchema := context.WithTimeout(ctx, cfg.DB.SchemaTimeout)
+	err = d.AwaitSchema(schemaCtx)
+	cancelSchema()
+	if err != nil {
+		fatal(logger, "schema indexes did not come online", "timeout", cfg.DB.SchemaTimeout, "err", err)
+	}
+
+	m := metrics.New()
+	d.SetQueryHook(m.ObserveQuery)
+
+	// TMDb is only needed for admin re-ingests, so the server runs without a
+	// token and those requests are refused.
+	var tm *tmdb.Client
+	if cfg.Client.APIToken != "" {
+		if tm, err = tmdb.NewClientWithTransport(*cfg, otelhttp.NewTransport(http.DefaultTransport)); err != nil {
+			fatal(logger, "failed to create tmdb client", "err", err)
+		}
+		tm.Logger = logger
 	}
 
-	if err := d.SetupSchema(ctx); err != nil {
-		log.Fatalf("failed to set up schema: %v", err)
+	h, err := handler.NewHandler(d, tm, web.FS, cfg.Server, logger, m)
+	if err != nil {
+		fatal(logger, "failed to initialize handler", "err", err)
 	}
 
-	h, err := handler.NewHandler(d, web.FS, cfg.Server, logger)
+	l, err := listen.Listen(listen.Config{
+		Network:    cfg.Server.Network,
+		Addr:       cfg.Server.Addr,
+		SocketMode: cfg.Server.SocketMode,
+	})
 	if err != nil {
-		log.Fatalf("failed to initialize handler: %v", err)
+		fatal(logger, "failed to listen", "err", err)
 	}
 
 	srv := http.Server{
-		Addr:         cfg.Server.Addr,
 		Handler:      h,
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
+	// With TLS the certificate comes from a loader, so SIGHUP can rotate it
+	// without dropping connections.
+	var certs *tlsutil.CertLoader
+	var redirectSrv *http.Server
+	if cfg.Server.TLSEnabled() {
+		certs, err = tlsutil.NewCertLoader(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		if err != nil {
+			fatal(logger, "failed to load tls certificate", "err", err)
+		}
+		srv.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate, MinVersion: tls.VersionTLS12}
+
+		if cfg.Server.TLSRedirectHTTP {
+			redirectSrv = &http.Server{
+				Addr:        cfg.Server.TLSRedirectAddr,
+				Handler:     tlsutil.RedirectHandler(cfg.Server.Addr),
+				ReadTimeout: cfg.Server.ReadTimeout,
+				IdleTimeout: cfg.Server.IdleTimeout,
+			}
+		}
+	}
+
+	var metricsSrv *http.Server
+	if cfg.Server.MetricsAddr != "" {
+		metricsSrv = &http.Server{
+			Addr:        cfg.Server.MetricsAddr,
+			Handler:     m.Handler(),
+			ReadTimeout: cfg.Server.ReadTimeout,
+		}
+	}
+
+	var debugSrv *http.Server
+	if cfg.Server.DebugEndpoints && cfg.Server.DebugAddr != "" {
+		debugSrv = &http.Server{
+			Addr:        cfg.Server.DebugAddr,
+			Handler:     debug.Handler(logger),
+			ReadTimeout: cfg.Server.ReadTimeout,
+		}
+	}
+
 	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	go h.MonitorDB(sigCtx)
+
+	// Shutdown closes the listener, which for a unix socket also removes the
+	// socket file.
+	go func() {
+		var err error
+		if certs != nil {
+			logger.Info("server listening", "network", l.Addr().Network(), "addr", l.Addr().String(), "tls", true)
+			err = srv.ServeTLS(l, "", "")
+		} else {
+			logger.Info("server listening", "network", l.Addr().Network(), "addr", l.Addr().String())
+			err = srv.Serve(l)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal(logger, "server error", "err", err)
+		}
+	}()
+
+	if redirectSrv != nil {
+		go func() {
+			logger.Info("redirecting http to https", "addr", cfg.Server.TLSRedirectAddr)
+			if err := redirectSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal(logger, "redirect server error", "err", err)
+			}
+		}()
+	}
+
+	// SIGHUP re-reads the configuration, applying the settings that can
+	// change without a restart, and with TLS the certificate too.
+	watcher := config.NewWatcher(*configFlag, cfg, logger, func(c *config.Config) {
+		logLevel.Set(c.Log.Level)
+		h.Reload(c.Server)
+	})
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
 	go func() {
-		log.Printf("server listening on %s", cfg.Server.Addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+		for range hup {
+			if err := watcher.Reload(); err != nil {
+				for _, problem := range config.Problems(err) {
+					logger.Error("config reload failed, keeping the current settings", "err", problem)
+				}
+			}
+			if certs == nil {
+				continue
+			}
+			if err := certs.Reload(); err != nil {
+				logger.Error("tls certificate reload failed, keeping the current one", "err", err)
+				continue
+			}
+			logger.Info("tls certificate reloaded")
 		}
 	}()
 
+	if metricsSrv != nil {
+		go func() {
+			logger.Info("metrics listening", "addr", cfg.Server.MetricsAddr)
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal(logger, "metrics server error", "err", err)
+			}
+		}()
+	}
+
+	if debugSrv != nil {
+		go func() {
+			logger.Info("debug endpoints listening", "addr", cfg.Server.DebugAddr)
+			if err := debugSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal(logger, "debug server error", "err", err)
+			}
+		}()
+	}
+
 	<-sigCtx.Done()
-	log.Println("shutdown signal received")
+	logger.Info("shutdown signal received")
 
 	timeoutCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(timeoutCtx); err != nil {
-		log.Printf("shutdown did not complete cleanly: %v", err)
+		logger.Warn("shutdown did not complete cleanly", "err", err)
+	}
+	h.Close()
+
+	if redirectSrv != nil {
+		if err := redirectSrv.Shutdown(timeoutCtx); err != nil {
+			logger.Warn("redirect shutdown did not complete cleanly", "err", err)
+		}
+	}
+
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(timeoutCtx); err != nil {
+			logger.Warn("metrics shutdown did not complete cleanly", "err", err)
+		}
+	}
+
+	if debugSrv != nil {
+		if err := debugSrv.Shutdown(timeoutCtx); err != nil {
+			logger.Warn("debug shutdown did not complete cleanly", "err", err)
+		}
 	}
 
 	if err := otelShutdown(timeoutCtx); err != nil {
-		log.Printf("OTel shutdown did not complete cleanly: %v", err)
+		logger.Warn("OTel shutdown did not complete cleanly", "err", err)
 	}
 
-	log.Println("server stopped")
+	logger.Info("server stopped")
+}
+
+// fatal logs msg at error level and exits, standing in for log.Fatal.
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
 }
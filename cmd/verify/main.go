@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+var samplesFlag = flag.Int("samples", 100, "number of random actor pairs to measure")
+var maxHopsFlag = flag.Int("max-hops", 12, "give up on a pair after this many hops and count it as unreachable")
+
+func main() {
+	flag.Parse()
+
+	if *samplesFlag < 1 {
+		log.Fatalln("-samples must be at least 1")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalln("Error loading config:", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := graph.NewDriver(ctx, *cfg)
+	if err != nil {
+		log.Fatalln("Error connecting to neo4j:", err)
+	}
+	defer db.Close(context.Background())
+
+	dist := make(map[int]int)
+	measured := 0
+	for i := range *samplesFlag {
+		if ctx.Err() != nil {
+			log.Printf("Interrupted after %d samples", measured)
+			break
+		}
+
+		a, b, err := db.GetRandomConnectedPair(ctx)
+		if errors.Is(err, graph.ErrNotEnoughActors) {
+			log.Fatalln("Graph needs at least two connected actors; run ingest first")
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			log.Fatalln("Error picking random pair:", err)
+		}
+
+		hops, err := db.Distance(ctx, a, b, *maxHopsFlag)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			log.Fatalln("Error computing distance:", err)
+		}
+
+		dist[hops]++
+		measured++
+		if (i+1)%10 == 0 {
+			log.Printf("Measured %d/%d pairs", i+1, *samplesFlag)
+		}
+	}
+
+	if measured == 0 {
+		log.Println("No pairs measured")
+		return
+	}
+
+	printSummary(dist, measured)
+}
+
+// printSummary writes the distance histogram followed by the max degree seen
+// and the share of reachable pairs within six degrees.
+func printSummary(dist map[int]int, measured int) {
+	var degrees []int
+	reachable, withinSix, maxDeg, total := 0, 0, 0, 0
+	for deg, n := range dist {
+		if deg == graph.Unconnected {
+			continue
+		}
+		degrees = append(degrees, deg)
+		reachable += n
+		total += deg * n
+		maxDeg = max(maxDeg, deg)
+		if deg <= 6 {
+			withinSix += n
+		}
+	}
+	slices.Sort(degrees)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Degrees\tPairs\tShare")
+	for _, deg := range degrees {
+		fmt.Fprintf(w, "%d\t%d\t%.1f%%\n", deg, dist[deg], pct(dist[deg], measured))
+	}
+	if n := dist[graph.Unconnected]; n > 0 {
+		fmt.Fprintf(w, ">%d\t%d\t%.1f%%\n", *maxHopsFlag, n, pct(n, measured))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Pairs measured\t%d\n", measured)
+	fmt.Fprintf(w, "Reachable\t%d\n", reachable)
+	if reachable > 0 {
+		fmt.Fprintf(w, "Max degrees\t%d\n", maxDeg)
+		fmt.Fprintf(w, "Mean degrees\t%.2f\n", float64(total)/float64(reachable))
+		fmt.Fprintf(w, "Within six degrees\t%.1f%% of reachable\n", pct(withinSix, reachable))
+	}
+	w.Flush()
+}
+
+func pct(n, of int) float64 {
+	return 100 * float64(n) / float64(of)
+}
@@ -1,9 +1,11 @@
 package config
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
@@ -11,201 +13,763 @@ import (
 )
 
 type ClientConfig struct {
-	APIToken    string
-	Timeout     time.Duration
-	Limit       int
-	Burst       int
+	APIToken string `secret:"true"`
+	Timeout  time.Duration
+	Limit    int
+	Burst    int
+	// DetailLimit and DetailBurst give per-movie and per-person calls their
+	// own limiter; a zero DetailLimit keeps them on Limit and Burst.
+	DetailLimit int
+	DetailBurst int
 	MaxRetries  int
 	BaseBackoff time.Duration
+	// BaseURL and APIVersion point the client somewhere other than TMDb's
+	// API, like a caching proxy or a mock for load tests; empty means
+	// TMDb's own.
+	BaseURL    string
+	APIVersion string
 }
 
 type DBConfig struct {
 	URI  string
 	User string
-	Pass string
+	Pass string `secret:"true"`
+	// CompactEdges stores one COSTARRED edge per actor pair listing every
+	// shared movie, instead of one edge per movie.
+	CompactEdges bool
+	// SchemaTimeout bounds how long the server waits at startup for the
+	// schema indexes to come online.
+	SchemaTimeout time.Duration
+	// SlowQuery is the duration past which a query is logged; zero turns
+	// slow-query logging off.
+	SlowQuery time.Duration
+	// StartupRetries is how many more times the server tries to connect and
+	// set up the schema when Neo4j isn't ready at startup, waiting
+	// StartupBackoff before the first retry and twice as long each time after.
+	StartupRetries int
+	StartupBackoff time.Duration
+	// SearchAnalyzer is the Lucene analyzer the actor name fulltext index is
+	// built with; the server rebuilds the index at startup when it changes.
+	SearchAnalyzer string
+	// SearchMinScore drops actor search matches whose fulltext score is
+	// below it. Zero keeps every match.
+	SearchMinScore float64
+}
+
+// RoutePolicy is the rate limit for one route: PerSec tokens refill per
+// second into a bucket of Burst, and each request takes Cost tokens.
+type RoutePolicy struct {
+	PerSec float64
+	Burst  int
+	Cost   int
+}
+
+// Token is a static bearer token. Name, when given, is logged in place of the
+// secret.
+type Token struct {
+	Name   string
+	Secret string `secret:"true"`
 }
 
 type ServerConfig struct {
+	// Network is "tcp", with Addr a host:port, or "unix", with Addr a socket
+	// path. SocketMode sets a unix socket's permissions.
+	Network         string
 	Addr            string
+	SocketMode      os.FileMode
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	IdleTimeout     time.Duration
 	ShutdownTimeout time.Duration
 	RequestTimeout  time.Duration
-	CORSOrigin      string
+	// PathQueryTimeout bounds /degrees path queries, which can run far longer
+	// than anything else; it should be below RequestTimeout.
+	PathQueryTimeout time.Duration
+	// CORSOrigins is empty when CORS is disabled.
+	CORSOrigins     []string
+	CORSMethods     []string
+	CORSHeaders     []string
+	CORSCredentials bool
+	CORSMaxAge      time.Duration
 	RateLimitPerSec float64
 	RateBurst       int
+	RateRoutes      map[string]RoutePolicy
+	// RateMaxClients caps the clients the rate limiter tracks; 0 is unbounded.
+	RateMaxClients int
+	TrustedProxies []netip.Prefix
+	// TrustUnixPeers believes the forwarding headers of connections over a
+	// unix socket, which have no address to match TrustedProxies against.
+	// It is set by listing "unix" in TRUSTED_PROXIES.
+	TrustUnixPeers bool
+	MaxQueryLen    int
+	// SearchLimit is how many actors a search returns by default;
+	// /api/v1/search may ask for up to SearchMaxLimit.
+	SearchLimit    int
+	SearchMaxLimit int
+	MaxURLLen      int
+	MaxBodyBytes   int64
+	LogSampleRate  int
+	SlowRequest    time.Duration
+	MetricsAddr    string
+	// DebugEndpoints serves pprof and expvar under /debug/ on DebugAddr, a
+	// listener of their own that is never the public one.
+	DebugEndpoints bool
+	DebugAddr      string
+	Compress       bool
+	// RequireNonEmptyGraph keeps /readyz failing until the graph holds at
+	// least one actor.
+	RequireNonEmptyGraph bool
+	// DevMode re-reads templates from web/ on disk on every render.
+	DevMode bool
+	// AvailabilityPoll is how often the server probes Neo4j, and so how
+	// quickly it stops serving 503s once an outage ends.
+	AvailabilityPoll time.Duration
+	// APITokens, when set, are required on /api/v1. AdminTokens are always
+	// required on /admin, which is closed while the list is empty.
+	APITokens   []Token
+	AdminTokens []Token
+	// ImageBaseURL is TMDb's image CDN, without a size. ThumbnailSize is
+	// the size for search results and ImageSize for actor photos and movie
+	// posters, each one TMDb offers, like "w92" or "original".
+	ImageBaseURL  string
+	ThumbnailSize string
+	ImageSize     string
+	// BasePath mounts the site under a path prefix such as "/degrees", for
+	// embedding behind another site. It has a leading slash and no trailing
+	// one; empty serves from the root.
+	BasePath string
+	// SiteURL is the scheme and host, e.g. "https://degrees.example.com",
+	// that sitemap and robots.txt links are built on. Empty takes them from
+	// each request.
+	SiteURL string
+	// RobotsDisallow lists the paths, under BasePath, that robots.txt asks
+	// crawlers to skip.
+	RobotsDisallow []string
+	// SitemapSize is how many of the best-connected actors' profiles
+	// /sitemap.xml lists.
+	SitemapSize int
+	// StatsCacheTTL is how long the stats fragment's counts are reused
+	// before the graph is asked again; 0 asks on every request.
+	StatsCacheTTL time.Duration
+	// TLSCertFile and TLSKeyFile make the server terminate TLS itself. They
+	// are set together or not at all.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSRedirectHTTP serves plain HTTP on TLSRedirectAddr, redirecting every
+	// request to https.
+	TLSRedirectHTTP bool
+	TLSRedirectAddr string
+	// HSTSMaxAge is the Strict-Transport-Security max-age sent while serving
+	// TLS; 0 sends no header.
+	HSTSMaxAge time.Duration
+}
+
+// TLSEnabled reports whether the server terminates TLS itself.
+func (c ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != ""
+}
+
+// TelemetryConfig controls the OTel SDK. While Enabled is false the global
+// tracer and meter providers stay no-ops, so spans cost next to nothing.
+type TelemetryConfig struct {
+	Enabled bool
+	// Endpoint is the OTLP/HTTP collector; empty writes traces to stdout.
+	Endpoint string
+}
+
+// LogConfig shapes what the binaries log.
+type LogConfig struct {
+	// Level drops records below it.
+	Level slog.Level
+	// Format is "json" or "text". Empty leaves it to the binary: json for
+	// the server, text for ingest's interactive runs.
+	Format string
 }
 
 type Config struct {
-	Client ClientConfig
-	DB     DBConfig
-	Server ServerConfig
+	Client    ClientConfig
+	DB        DBConfig
+	Server    ServerConfig
+	Telemetry TelemetryConfig
+	Log       LogConfig
 }
 
+// Load is LoadFrom with no -config flag: the file, if any, is named by
+// CONFIG_FILE.
 func Load() (*Config, error) {
+	return LoadFrom("")
+}
+
+// LoadFrom loads the configuration for the commands that only talk to
+// Neo4j, like bacon, reset and verify: the Neo4j settings are required and
+// only they are validated. LoadServer and LoadIngest are the same for the
+// server and the ingester.
+func LoadFrom(path string) (*Config, error) {
+	return load(path, profile{name: "this command"})
+}
+
+// LoadServer loads the configuration for the server, which needs Neo4j and
+// validates the server's settings too. A TMDb token is optional; when one is
+// set, the TMDb client settings are validated as well.
+func LoadServer(path string) (*Config, error) {
+	return load(path, profile{name: "the server", server: true})
+}
+
+// LoadIngest loads the configuration for the ingester, which needs Neo4j and
+// a TMDb token. The server's settings are left unchecked.
+func LoadIngest(path string) (*Config, error) {
+	return load(path, profile{name: "ingest", tmdb: true})
+}
+
+// profile is what a binary needs from the configuration.
+type profile struct {
+	name   string // the binary, for naming it in problems
+	tmdb   bool   // requires a TMDb token
+	server bool   // serves HTTP
+}
+
+// load reads the configuration from the environment, falling back to the
+// YAML file at path, or at CONFIG_FILE when path is empty, and then to the
+// defaults. Variables from .env count as environment. Either way every
+// setting is named by its environment variable; see readConfigFile for the
+// file's layout.
+//
+// Every setting is parsed whichever binary is loading, so one file or
+// environment serves them all, and a value that doesn't parse fails the
+// load on its own. Once everything parses, the settings prof requires are
+// checked and the sections it uses validated, and every problem is reported
+// together.
+func load(path string, prof profile) (*Config, error) {
 	if err := loadDotEnv(".env"); err != nil {
 		log.Printf("warning: could not load .env: %v", err)
 	}
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	s, err := readConfigFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
 
 	cfg := Config{}
 
-	cfg.Client.APIToken = os.Getenv("TMDB_API_TOKEN")
+	apiToken, err := s.getEnvSecret("TMDB_API_TOKEN")
+	if err != nil {
+		return nil, fmt.Errorf("invalid tmdb api token: %w", err)
+	}
+	cfg.Client.APIToken = apiToken
 
-	duration, err := getEnvTimeDefault("HTTP_CLIENT_TIMEOUT", "30s")
+	duration, err := s.getEnvTimeDefault("HTTP_CLIENT_TIMEOUT", "30s")
 	if err != nil {
 		return nil, fmt.Errorf("invalid timeout: %w", err)
 	}
 	cfg.Client.Timeout = duration
 
-	limit, err := getEnvIntDefault("TMDB_RATE_LIMIT", "4") // Defaults to 4 reqs/s (40 reqs per 10s)
+	limit, err := s.getEnvIntDefault("TMDB_RATE_LIMIT", "4") // Defaults to 4 reqs/s (40 reqs per 10s)
 	if err != nil {
 		return nil, fmt.Errorf("invalid rate limit: %w", err)
 	}
 	cfg.Client.Limit = limit
 
-	burst, err := getEnvIntDefault("TMDB_BURST_AMOUNT", "5")
+	burst, err := s.getEnvIntDefault("TMDB_BURST_AMOUNT", "5")
 	if err != nil {
 		return nil, fmt.Errorf("invalid burst amount: %w", err)
 	}
 	cfg.Client.Burst = burst
 
-	maxRetries, err := getEnvIntDefault("TMDB_MAX_RETRIES", "3")
+	detailLimit, err := s.getEnvIntDefault("TMDB_DETAIL_RATE_LIMIT", "0")
+	if err != nil {
+		return nil, fmt.Errorf("invalid detail rate limit: %w", err)
+	}
+	cfg.Client.DetailLimit = detailLimit
+
+	detailBurst, err := s.getEnvIntDefault("TMDB_DETAIL_BURST_AMOUNT", "10")
+	if err != nil {
+		return nil, fmt.Errorf("invalid detail burst amount: %w", err)
+	}
+	cfg.Client.DetailBurst = detailBurst
+
+	maxRetries, err := s.getEnvIntDefault("TMDB_MAX_RETRIES", "3")
 	if err != nil {
 		return nil, fmt.Errorf("invalid max retries: %w", err)
 	}
 	cfg.Client.MaxRetries = maxRetries
 
-	baseBackoff, err := getEnvTimeDefault("TMDB_BASE_BACKOFF", "1s")
+	baseBackoff, err := s.getEnvTimeDefault("TMDB_BASE_BACKOFF", "1s")
 	if err != nil {
 		return nil, fmt.Errorf("invalid base backoff: %w", err)
 	}
 	cfg.Client.BaseBackoff = baseBackoff
 
-	uri, err := getEnvString("NEO4J_URI")
+	baseURL, err := s.getEnvStringDefault("TMDB_BASE_URL", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid tmdb base url: %w", err)
+	}
+	cfg.Client.BaseURL = baseURL
+
+	apiVersion, err := s.getEnvStringDefault("TMDB_API_VERSION", "")
 	if err != nil {
-		return nil, fmt.Errorf("missing env: %w", err)
+		return nil, fmt.Errorf("invalid tmdb api version: %w", err)
+	}
+	cfg.Client.APIVersion = apiVersion
+
+	uri, err := s.getEnvStringDefault("NEO4J_URI", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid neo4j uri: %w", err)
 	}
 	cfg.DB.URI = uri
 
-	user, err := getEnvString("NEO4J_USER")
+	user, err := s.getEnvStringDefault("NEO4J_USER", "")
 	if err != nil {
-		return nil, fmt.Errorf("missing env: %w", err)
+		return nil, fmt.Errorf("invalid neo4j user: %w", err)
 	}
 	cfg.DB.User = user
 
-	pass, err := getEnvString("NEO4J_PASSWORD")
+	pass, err := s.getEnvSecret("NEO4J_PASSWORD")
 	if err != nil {
-		return nil, fmt.Errorf("missing env: %w", err)
+		return nil, fmt.Errorf("invalid neo4j password: %w", err)
 	}
 	cfg.DB.Pass = pass
 
-	port, err := getEnvStringDefault("PORT", "8080")
+	compactEdges, err := s.getEnvBoolDefault("NEO4J_COMPACT_EDGES", "false")
+	if err != nil {
+		return nil, fmt.Errorf("invalid compact edges: %w", err)
+	}
+	cfg.DB.CompactEdges = compactEdges
+
+	schemaTimeout, err := s.getEnvTimeDefault("NEO4J_SCHEMA_TIMEOUT", "60s")
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema timeout: %w", err)
+	}
+	cfg.DB.SchemaTimeout = schemaTimeout
+
+	slowQuery, err := s.getEnvTimeDefault("NEO4J_SLOW_QUERY", "500ms")
+	if err != nil {
+		return nil, fmt.Errorf("invalid slow query threshold: %w", err)
+	}
+	cfg.DB.SlowQuery = slowQuery
+
+	startupRetries, err := s.getEnvIntDefault("STARTUP_DB_RETRIES", "5")
+	if err != nil {
+		return nil, fmt.Errorf("invalid startup db retries: %w", err)
+	}
+	cfg.DB.StartupRetries = startupRetries
+
+	startupBackoff, err := s.getEnvTimeDefault("STARTUP_DB_BACKOFF", "1s")
+	if err != nil {
+		return nil, fmt.Errorf("invalid startup db backoff: %w", err)
+	}
+	cfg.DB.StartupBackoff = startupBackoff
+
+	searchAnalyzer, err := s.getEnvStringDefault("NEO4J_SEARCH_ANALYZER", "whitespace")
+	if err != nil {
+		return nil, fmt.Errorf("invalid search analyzer: %w", err)
+	}
+	cfg.DB.SearchAnalyzer = searchAnalyzer
+
+	searchMinScore, err := s.getEnvFloatDefault("SEARCH_MIN_SCORE", "0")
+	if err != nil {
+		return nil, fmt.Errorf("invalid search min score: %w", err)
+	}
+	cfg.DB.SearchMinScore = searchMinScore
+
+	otelEnabled, err := s.getEnvBoolDefault("OTEL_ENABLED", "false")
+	if err != nil {
+		return nil, fmt.Errorf("invalid otel enabled: %w", err)
+	}
+	cfg.Telemetry.Enabled = otelEnabled
+
+	// The OTLP exporters read this variable themselves; it is kept here to
+	// pick between OTLP and stdout.
+	otelEndpoint, err := s.getEnvStringDefault("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid otel exporter endpoint: %w", err)
+	}
+	cfg.Telemetry.Endpoint = otelEndpoint
+
+	logLevel, err := s.getEnvLevelDefault("LOG_LEVEL", "info")
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level: %w", err)
+	}
+	cfg.Log.Level = logLevel
+
+	logFormat, err := s.getEnvStringDefault("LOG_FORMAT", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid log format: %w", err)
+	}
+	cfg.Log.Format = logFormat
+
+	port, err := s.getEnvStringDefault("PORT", "8080")
 	if err != nil {
 		return nil, fmt.Errorf("invalid port: %w", err)
 	}
-	cfg.Server.Addr = ":" + port
+	listenNetwork, err := s.getEnvStringDefault("LISTEN_NETWORK", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen network: %w", err)
+	}
+	listenAddr, err := s.getEnvStringDefault("LISTEN_ADDR", ":"+port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen addr: %w", err)
+	}
+	if cfg.Server.Network, cfg.Server.Addr, err = parseListenAddr(listenNetwork, listenAddr); err != nil {
+		return nil, fmt.Errorf("invalid listen addr: %w", err)
+	}
+	socketMode, err := s.getEnvStringDefault("LISTEN_SOCKET_MODE", "0660")
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen socket mode: %w", err)
+	}
+	mode, err := strconv.ParseUint(socketMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return nil, fmt.Errorf("invalid listen socket mode: %q is not an octal permission like 0660", socketMode)
+	}
+	cfg.Server.SocketMode = os.FileMode(mode)
 
-	readTimeout, err := getEnvTimeDefault("SERVER_READ_TIMEOUT", "5s")
+	readTimeout, err := s.getEnvTimeDefault("SERVER_READ_TIMEOUT", "5s")
 	if err != nil {
 		return nil, fmt.Errorf("invalid read timeout: %w", err)
 	}
 	cfg.Server.ReadTimeout = readTimeout
 
-	writeTimeout, err := getEnvTimeDefault("SERVER_WRITE_TIMEOUT", "10s")
+	writeTimeout, err := s.getEnvTimeDefault("SERVER_WRITE_TIMEOUT", "10s")
 	if err != nil {
 		return nil, fmt.Errorf("invalid write timeout: %w", err)
 	}
 	cfg.Server.WriteTimeout = writeTimeout
 
-	idleTimeout, err := getEnvTimeDefault("SERVER_IDLE_TIMEOUT", "120s")
+	idleTimeout, err := s.getEnvTimeDefault("SERVER_IDLE_TIMEOUT", "120s")
 	if err != nil {
 		return nil, fmt.Errorf("invalid idle timeout: %w", err)
 	}
 	cfg.Server.IdleTimeout = idleTimeout
 
-	shutdownTimeout, err := getEnvTimeDefault("SERVER_SHUTDOWN_TIMEOUT", "10s")
+	shutdownTimeout, err := s.getEnvTimeDefault("SERVER_SHUTDOWN_TIMEOUT", "10s")
 	if err != nil {
 		return nil, fmt.Errorf("invalid shutdown timeout: %w", err)
 	}
 	cfg.Server.ShutdownTimeout = shutdownTimeout
 
-	requestTimeout, err := getEnvTimeDefault("REQUEST_TIMEOUT", "10s")
+	requestTimeout, err := s.getEnvTimeDefault("REQUEST_TIMEOUT", "10s")
 	if err != nil {
 		return nil, fmt.Errorf("invalid request timeout: %w", err)
 	}
 	cfg.Server.RequestTimeout = requestTimeout
 
-	corsOrigin, err := getEnvStringDefault("CORS_ALLOWED_ORIGIN", "*")
+	pathQueryTimeout, err := s.getEnvTimeDefault("PATH_QUERY_TIMEOUT", "5s")
+	if err != nil {
+		return nil, fmt.Errorf("invalid path query timeout: %w", err)
+	}
+	cfg.Server.PathQueryTimeout = pathQueryTimeout
+
+	// Set but empty disables CORS entirely. CORS_ALLOWED_ORIGIN is the
+	// variable's old name, still read when the new one is unset.
+	corsOriginsKey := "CORS_ALLOWED_ORIGINS"
+	if _, ok := s.lookup(corsOriginsKey); !ok {
+		corsOriginsKey = "CORS_ALLOWED_ORIGIN"
+	}
+	corsOrigins, err := s.getEnvListDefault(corsOriginsKey, "*")
 	if err != nil {
 		return nil, fmt.Errorf("invalid cors origin: %w", err)
 	}
-	cfg.Server.CORSOrigin = corsOrigin
+	cfg.Server.CORSOrigins = corsOrigins
+
+	corsMethods, err := s.getEnvListDefault("CORS_ALLOWED_METHODS", "GET,POST,OPTIONS")
+	if err != nil {
+		return nil, fmt.Errorf("invalid cors methods: %w", err)
+	}
+	cfg.Server.CORSMethods = corsMethods
 
-	rateLimitPerSec, err := getEnvFloatDefault("RATE_LIMIT_PER_SEC", "0.5")
+	corsHeaders, err := s.getEnvListDefault("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,HX-Request,HX-Target,HX-Trigger,X-Request-ID")
+	if err != nil {
+		return nil, fmt.Errorf("invalid cors headers: %w", err)
+	}
+	cfg.Server.CORSHeaders = corsHeaders
+
+	corsCredentials, err := s.getEnvBoolDefault("CORS_ALLOW_CREDENTIALS", "false")
+	if err != nil {
+		return nil, fmt.Errorf("invalid cors allow credentials: %w", err)
+	}
+	cfg.Server.CORSCredentials = corsCredentials
+
+	corsMaxAge, err := s.getEnvTimeDefault("CORS_MAX_AGE", "10m")
+	if err != nil {
+		return nil, fmt.Errorf("invalid cors max age: %w", err)
+	}
+	cfg.Server.CORSMaxAge = corsMaxAge
+
+	rateLimitPerSec, err := s.getEnvFloatDefault("RATE_LIMIT_PER_SEC", "0.5")
 	if err != nil {
 		return nil, fmt.Errorf("invalid rate limit: %w", err)
 	}
 	cfg.Server.RateLimitPerSec = rateLimitPerSec
 
-	rateBurst, err := getEnvIntDefault("RATE_BURST", "5")
+	rateBurst, err := s.getEnvIntDefault("RATE_BURST", "5")
 	if err != nil {
 		return nil, fmt.Errorf("invalid rate burst: %w", err)
 	}
 	cfg.Server.RateBurst = rateBurst
 
-	return &cfg, nil
-}
+	// Routes not listed share the RATE_LIMIT_PER_SEC/RATE_BURST bucket at cost 1.
+	rateRoutes, err := s.getEnvRoutePoliciesDefault("RATE_LIMIT_ROUTES", "/degrees=0.5:6:3,/degrees/export=0.5:6:3,/api/v1/path/graph=0.5:6:3,/api/v1/paths=0.5:6:3,/daily/reveal=0.5:6:3,/surprise=0.5:6:3,/stats/leaderboard=1:10:2,/actor/{id}/network=0.5:6:3,/search=2:20:1")
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit routes: %w", err)
+	}
+	cfg.Server.RateRoutes = rateRoutes
 
-// loadDotEnv reads a .env file and sets any variable not already present in
-// the environment. It silently does nothing if the file doesn't exist.
-func loadDotEnv(path string) error {
-	f, err := os.Open(path)
-	if os.IsNotExist(err) {
-		return nil
+	rateMaxClients, err := s.getEnvIntDefault("RATE_LIMIT_MAX_CLIENTS", "100000")
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit max clients: %w", err)
 	}
+	cfg.Server.RateMaxClients = rateMaxClients
+
+	// Empty trusts no proxy: forwarding headers are ignored and RemoteAddr is the client.
+	trustedProxies, trustUnix, err := s.getEnvProxiesDefault("TRUSTED_PROXIES", "")
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
 	}
-	defer f.Close()
+	cfg.Server.TrustedProxies = trustedProxies
+	cfg.Server.TrustUnixPeers = trustUnix
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		key, value, ok := strings.Cut(line, "=")
-		if !ok {
-			continue
+	maxQueryLen, err := s.getEnvIntDefault("SEARCH_MAX_QUERY_LEN", "100")
+	if err != nil {
+		return nil, fmt.Errorf("invalid search max query length: %w", err)
+	}
+	cfg.Server.MaxQueryLen = maxQueryLen
+
+	searchLimit, err := s.getEnvIntDefault("SEARCH_LIMIT", "15")
+	if err != nil {
+		return nil, fmt.Errorf("invalid search limit: %w", err)
+	}
+	cfg.Server.SearchLimit = searchLimit
+
+	searchMaxLimit, err := s.getEnvIntDefault("SEARCH_MAX_LIMIT", "50")
+	if err != nil {
+		return nil, fmt.Errorf("invalid search max limit: %w", err)
+	}
+	cfg.Server.SearchMaxLimit = searchMaxLimit
+
+	// Empty serves /metrics on the main listener; set e.g. ":9090" to bind it separately.
+	metricsAddr, err := s.getEnvStringDefault("METRICS_ADDR", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics addr: %w", err)
+	}
+	cfg.Server.MetricsAddr = metricsAddr
+
+	debugEndpoints, err := s.getEnvBoolDefault("DEBUG_ENDPOINTS", "false")
+	if err != nil {
+		return nil, fmt.Errorf("invalid debug endpoints: %w", err)
+	}
+	cfg.Server.DebugEndpoints = debugEndpoints
+
+	// /debug/ only ever has its own listener, on loopback unless told otherwise.
+	debugAddr, err := s.getEnvStringDefault("DEBUG_ADDR", "localhost:6060")
+	if err != nil {
+		return nil, fmt.Errorf("invalid debug addr: %w", err)
+	}
+	cfg.Server.DebugAddr = debugAddr
+
+	compress, err := s.getEnvBoolDefault("COMPRESS_RESPONSES", "true")
+	if err != nil {
+		return nil, fmt.Errorf("invalid compress responses: %w", err)
+	}
+	cfg.Server.Compress = compress
+
+	maxURLLen, err := s.getEnvIntDefault("MAX_URL_LENGTH", "2048")
+	if err != nil {
+		return nil, fmt.Errorf("invalid max url length: %w", err)
+	}
+	cfg.Server.MaxURLLen = maxURLLen
+
+	maxBodyBytes, err := s.getEnvIntDefault("MAX_BODY_BYTES", "1048576")
+	if err != nil {
+		return nil, fmt.Errorf("invalid max body bytes: %w", err)
+	}
+	cfg.Server.MaxBodyBytes = int64(maxBodyBytes)
+
+	logSampleRate, err := s.getEnvIntDefault("LOG_SAMPLE_RATE", "1")
+	if err != nil {
+		return nil, fmt.Errorf("invalid log sample rate: %w", err)
+	}
+	cfg.Server.LogSampleRate = logSampleRate
+
+	slowRequestMS, err := s.getEnvIntDefault("SLOW_REQUEST_MS", "1000")
+	if err != nil {
+		return nil, fmt.Errorf("invalid slow request threshold: %w", err)
+	}
+	cfg.Server.SlowRequest = time.Duration(slowRequestMS) * time.Millisecond
+
+	requireNonEmpty, err := s.getEnvBoolDefault("REQUIRE_NONEMPTY_GRAPH", "false")
+	if err != nil {
+		return nil, fmt.Errorf("invalid require nonempty graph: %w", err)
+	}
+	cfg.Server.RequireNonEmptyGraph = requireNonEmpty
+
+	devMode, err := s.getEnvBoolDefault("DEV_MODE", "false")
+	if err != nil {
+		return nil, fmt.Errorf("invalid dev mode: %w", err)
+	}
+	cfg.Server.DevMode = devMode
+
+	availabilityPoll, err := s.getEnvTimeDefault("NEO4J_AVAILABILITY_POLL", "5s")
+	if err != nil {
+		return nil, fmt.Errorf("invalid neo4j availability poll: %w", err)
+	}
+	cfg.Server.AvailabilityPoll = availabilityPoll
+
+	apiTokens, err := s.getEnvTokens("API_TOKENS")
+	if err != nil {
+		return nil, fmt.Errorf("invalid api tokens: %w", err)
+	}
+	cfg.Server.APITokens = apiTokens
+
+	adminTokens, err := s.getEnvTokens("ADMIN_TOKENS")
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin tokens: %w", err)
+	}
+	cfg.Server.AdminTokens = adminTokens
+
+	imageBaseURL, err := s.getEnvStringDefault("TMDB_IMAGE_BASE_URL", "https://image.tmdb.org/t/p")
+	if err != nil {
+		return nil, fmt.Errorf("invalid tmdb image base url: %w", err)
+	}
+	// The base used to carry the thumbnail size on the end; such a value
+	// still works, with its size taken as the thumbnail default.
+	thumbnailSize := "w92"
+	if i := strings.LastIndex(imageBaseURL, "/"); i >= 0 && imageSizePattern.MatchString(imageBaseURL[i+1:]) {
+		imageBaseURL, thumbnailSize = imageBaseURL[:i], imageBaseURL[i+1:]
+	}
+	cfg.Server.ImageBaseURL = imageBaseURL
+
+	thumbnailSize, err = s.getEnvStringDefault("TMDB_THUMBNAIL_SIZE", thumbnailSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tmdb thumbnail size: %w", err)
+	}
+	cfg.Server.ThumbnailSize = thumbnailSize
+
+	imageSize, err := s.getEnvStringDefault("TMDB_IMAGE_SIZE", "w185")
+	if err != nil {
+		return nil, fmt.Errorf("invalid tmdb image size: %w", err)
+	}
+	cfg.Server.ImageSize = imageSize
+
+	basePath, err := s.getEnvStringDefault("BASE_PATH", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid base path: %w", err)
+	}
+	if cfg.Server.BasePath, err = cleanBasePath(basePath); err != nil {
+		return nil, fmt.Errorf("invalid base path: %w", err)
+	}
+
+	siteURL, err := s.getEnvStringDefault("SITE_URL", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid site url: %w", err)
+	}
+	cfg.Server.SiteURL = strings.TrimSuffix(siteURL, "/")
+
+	robotsDisallow, err := s.getEnvListDefault("ROBOTS_DISALLOW", "/degrees,/search,/api/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid robots disallow list: %w", err)
+	}
+	cfg.Server.RobotsDisallow = robotsDisallow
+
+	sitemapSize, err := s.getEnvIntDefault("SITEMAP_SIZE", "1000")
+	if err != nil {
+		return nil, fmt.Errorf("invalid sitemap size: %w", err)
+	}
+	cfg.Server.SitemapSize = sitemapSize
+
+	statsCacheTTL, err := s.getEnvTimeDefault("STATS_CACHE_TTL", "0s")
+	if err != nil {
+		return nil, fmt.Errorf("invalid stats cache ttl: %w", err)
+	}
+	cfg.Server.StatsCacheTTL = statsCacheTTL
+
+	tlsCertFile, err := s.getEnvStringDefault("TLS_CERT_FILE", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls cert file: %w", err)
+	}
+	tlsKeyFile, err := s.getEnvStringDefault("TLS_KEY_FILE", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls key file: %w", err)
+	}
+	cfg.Server.TLSCertFile = tlsCertFile
+	cfg.Server.TLSKeyFile = tlsKeyFile
+
+	tlsRedirect, err := s.getEnvBoolDefault("TLS_REDIRECT_HTTP", "false")
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls redirect: %w", err)
+	}
+	cfg.Server.TLSRedirectHTTP = tlsRedirect
+
+	tlsRedirectAddr, err := s.getEnvStringDefault("TLS_REDIRECT_ADDR", ":80")
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls redirect addr: %w", err)
+	}
+	cfg.Server.TLSRedirectAddr = tlsRedirectAddr
+
+	hstsMaxAge, err := s.getEnvTimeDefault("HSTS_MAX_AGE", "8760h")
+	if err != nil {
+		return nil, fmt.Errorf("invalid hsts max age: %w", err)
+	}
+	cfg.Server.HSTSMaxAge = hstsMaxAge
+
+	if err := s.checkUnread(); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	if err := errors.Join(append(cfg.require(prof), cfg.check(prof)...)...); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// parseListenAddr works out the network for addr. A unix:// URL, e.g.
+// "unix:///var/run/degrees.sock", means a unix socket at its path; otherwise
+// network, defaulting to tcp, says how to read addr.
+func parseListenAddr(network, addr string) (string, string, error) {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		if network != "" && network != "unix" {
+			return "", "", fmt.Errorf("%q is a unix socket but LISTEN_NETWORK is %s", addr, network)
 		}
-		key = strings.TrimSpace(key)
-		value = strings.TrimSpace(value)
-		if os.Getenv(key) == "" {
-			os.Setenv(key, value)
+		network, addr = "unix", path
+	}
+	switch network {
+	case "", "tcp":
+		return "tcp", addr, nil
+	case "unix":
+		if addr == "" {
+			return "", "", fmt.Errorf("a unix socket needs a path")
 		}
+		return "unix", addr, nil
+	default:
+		return "", "", fmt.Errorf("network must be tcp or unix, got %q", network)
 	}
-	return scanner.Err()
 }
 
-func getEnvString(key string) (string, error) {
-	result := os.Getenv(key)
-	if result == "" {
-		return "", fmt.Errorf("%s not defined", key)
+// cleanBasePath normalises a BASE_PATH to "" or "/prefix": a lone "/" is the
+// root and a trailing slash is dropped.
+func cleanBasePath(p string) (string, error) {
+	p = strings.TrimRight(strings.TrimSpace(p), "/")
+	if p == "" {
+		return "", nil
 	}
-	return result, nil
+	if !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("%q must start with /", p)
+	}
+	if strings.ContainsAny(p, "?#{} ") {
+		return "", fmt.Errorf("%q must be a plain path", p)
+	}
+	return p, nil
 }
 
-func getEnvStringDefault(key, defaultValue string) (string, error) {
-	result := os.Getenv(key)
+func (s *settings) getEnvStringDefault(key, defaultValue string) (string, error) {
+	result := s.getenv(key)
 	if result == "" {
 		result = defaultValue
 	}
 	return result, nil
 }
 
-func getEnvTimeDefault(key, defaultValue string) (time.Duration, error) {
-	result := os.Getenv(key)
+func (s *settings) getEnvTimeDefault(key, defaultValue string) (time.Duration, error) {
+	result := s.getenv(key)
 	if result == "" {
 		result = defaultValue
 	}
@@ -217,8 +781,8 @@ func getEnvTimeDefault(key, defaultValue string) (time.Duration, error) {
 	return duration, nil
 }
 
-func getEnvIntDefault(key, defaultValue string) (int, error) {
-	result := os.Getenv(key)
+func (s *settings) getEnvIntDefault(key, defaultValue string) (int, error) {
+	result := s.getenv(key)
 	if result == "" {
 		result = defaultValue
 	}
@@ -229,8 +793,8 @@ func getEnvIntDefault(key, defaultValue string) (int, error) {
 	return value, nil
 }
 
-func getEnvFloatDefault(key, defaultValue string) (float64, error) {
-	result := os.Getenv(key)
+func (s *settings) getEnvFloatDefault(key, defaultValue string) (float64, error) {
+	result := s.getenv(key)
 	if result == "" {
 		result = defaultValue
 	}
@@ -240,3 +804,181 @@ func getEnvFloatDefault(key, defaultValue string) (float64, error) {
 	}
 	return value, nil
 }
+
+func (s *settings) getEnvBoolDefault(key, defaultValue string) (bool, error) {
+	result := s.getenv(key)
+	if result == "" {
+		result = defaultValue
+	}
+	value, err := strconv.ParseBool(result)
+	if err != nil {
+		return false, fmt.Errorf("error parsing env: %w", err)
+	}
+	return value, nil
+}
+
+// getEnvLevelDefault parses a slog level name: debug, info, warn or error,
+// in any case.
+func (s *settings) getEnvLevelDefault(key, defaultValue string) (slog.Level, error) {
+	result := s.getenv(key)
+	if result == "" {
+		result = defaultValue
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(result)); err != nil {
+		return 0, fmt.Errorf("error parsing env: %w", err)
+	}
+	return level, nil
+}
+
+// getEnvRoutePoliciesDefault parses a comma-separated list of
+// route=perSec:burst:cost entries, e.g. "/degrees=0.5:6:3,/search=2:20:1".
+func (s *settings) getEnvRoutePoliciesDefault(key, defaultValue string) (map[string]RoutePolicy, error) {
+	result := s.getenv(key)
+	if result == "" {
+		result = defaultValue
+	}
+
+	policies := make(map[string]RoutePolicy)
+	for entry := range strings.SplitSeq(result, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, spec, ok := strings.Cut(entry, "=")
+		fields := strings.Split(spec, ":")
+		if !ok || route == "" || len(fields) != 3 {
+			return nil, fmt.Errorf("error parsing env: %q is not route=perSec:burst:cost", entry)
+		}
+		perSec, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing env: %w", err)
+		}
+		burst, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing env: %w", err)
+		}
+		cost, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing env: %w", err)
+		}
+		// A cost above the burst could never be paid, locking the route.
+		if cost < 1 || cost > burst {
+			return nil, fmt.Errorf("error parsing env: %s cost %d must be between 1 and its burst %d", route, cost, burst)
+		}
+		policies[route] = RoutePolicy{PerSec: perSec, Burst: burst, Cost: cost}
+	}
+	return policies, nil
+}
+
+// getEnvListDefault parses a comma-separated list, trimming each entry. Unlike
+// the other helpers, a variable that is set but empty yields an empty list
+// rather than the default, so a list can be switched off.
+func (s *settings) getEnvListDefault(key, defaultValue string) ([]string, error) {
+	result, ok := s.lookup(key)
+	if !ok {
+		result = defaultValue
+	}
+
+	var list []string
+	for entry := range strings.SplitSeq(result, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list, nil
+}
+
+// getEnvSecret reads a secret from key or, for Docker and Kubernetes secret
+// mounts, from the file named by key_FILE, trimmed of surrounding space. The
+// file keeps the secret out of the environment, where docker inspect and
+// process listings would show it. Setting both, or naming an empty file, is
+// an error.
+func (s *settings) getEnvSecret(key string) (string, error) {
+	value, path := s.getenv(key), s.getenv(key+"_FILE")
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("set %s or %s_FILE, not both", key, key)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s_FILE: %w", key, err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("%s_FILE %s is empty", key, path)
+	}
+	return secret, nil
+}
+
+// getEnvTokens reads bearer tokens from key, a comma-separated list, and from
+// the file named by key_FILE, one per line with # comments. Each entry is
+// "name:secret" or a bare secret.
+func (s *settings) getEnvTokens(key string) ([]Token, error) {
+	entries := strings.Split(s.getenv(key), ",")
+	if path := s.getenv(key + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading token file: %w", err)
+		}
+		for line := range strings.Lines(string(data)) {
+			if line = strings.TrimSpace(line); !strings.HasPrefix(line, "#") {
+				entries = append(entries, line)
+			}
+		}
+	}
+
+	var tokens []Token
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, secret, ok := strings.Cut(entry, ":")
+		if !ok {
+			name, secret = "", entry
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("token %q has an empty secret", name)
+		}
+		tokens = append(tokens, Token{Name: name, Secret: secret})
+	}
+	return tokens, nil
+}
+
+// getEnvProxiesDefault parses a comma-separated list of CIDR ranges. A bare
+// address is taken as a single-host range, and "unix" sets unix instead of
+// naming a range.
+func (s *settings) getEnvProxiesDefault(key, defaultValue string) (prefixes []netip.Prefix, unix bool, err error) {
+	result := s.getenv(key)
+	if result == "" {
+		result = defaultValue
+	}
+
+	for entry := range strings.SplitSeq(result, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "unix" {
+			unix = true
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, false, fmt.Errorf("error parsing env: %w", err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, false, fmt.Errorf("error parsing env: %w", err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, unix, nil
+}
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"log/slog"
+	"maps"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requireNeo4jEnv sets the variables Load can't do without.
+func requireNeo4jEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("NEO4J_URI", "neo4j://from-env:7687")
+	t.Setenv("NEO4J_USER", "neo4j")
+	t.Setenv("NEO4J_PASSWORD", "from-env")
+}
+
+// clearFileSettings empties the variables the fixture sets, so values from
+// the test's own environment can't mask the file. Empty counts as unset.
+func clearFileSettings(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{"CONFIG_FILE", "NEO4J_URI", "NEO4J_USER", "NEO4J_PASSWORD", "NEO4J_PASSWORD_FILE",
+		"TMDB_API_TOKEN", "TMDB_API_TOKEN_FILE", "PATH_QUERY_TIMEOUT", "LISTEN_SOCKET_MODE", "SEARCH_LIMIT",
+		"TRUSTED_PROXIES", "RATE_LIMIT_ROUTES", "LOG_LEVEL", "LOG_FORMAT", "TMDB_IMAGE_BASE_URL",
+		"TMDB_THUMBNAIL_SIZE", "TMDB_IMAGE_SIZE", "TMDB_BASE_URL", "TMDB_API_VERSION"} {
+		t.Setenv(key, "")
+	}
+	// An empty list variable means an empty list, so this one is unset.
+	unsetEnv(t, "CORS_ALLOWED_ORIGINS")
+}
+
+func unsetEnv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "") // restores the old value after the test
+	os.Unsetenv(key)
+}
+
+func TestLoadFrom_Defaults(t *testing.T) {
+	clearFileSettings(t)
+	requireNeo4jEnv(t)
+
+	cfg, err := LoadFrom("")
+	if err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	if cfg.Server.PathQueryTimeout != 5*time.Second || cfg.Server.SearchLimit != 15 || cfg.Server.SocketMode != 0o660 {
+		t.Errorf("expected the default path timeout, search limit and socket mode, got %s, %d, %v",
+			cfg.Server.PathQueryTimeout, cfg.Server.SearchLimit, cfg.Server.SocketMode)
+	}
+	if !slices.Equal(cfg.Server.CORSOrigins, []string{"*"}) || len(cfg.Server.TrustedProxies) != 0 {
+		t.Errorf("expected CORS open to all and no trusted proxies, got %v and %v", cfg.Server.CORSOrigins, cfg.Server.TrustedProxies)
+	}
+	if p := cfg.Server.RateRoutes["/degrees"]; p != (RoutePolicy{PerSec: 0.5, Burst: 6, Cost: 3}) || len(cfg.Server.RateRoutes) != 9 {
+		t.Errorf("expected the default route policies, got %v", cfg.Server.RateRoutes)
+	}
+}
+
+func TestLoadFrom_LogSettings(t *testing.T) {
+	clearFileSettings(t)
+	requireNeo4jEnv(t)
+
+	cfg, err := LoadFrom("")
+	if err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	if cfg.Log.Level != slog.LevelInfo || cfg.Log.Format != "" {
+		t.Errorf("expected info and the binary's own format by default, got %v and %q", cfg.Log.Level, cfg.Log.Format)
+	}
+
+	t.Setenv("LOG_LEVEL", "DEBUG")
+	t.Setenv("LOG_FORMAT", "text")
+	if cfg, err = LoadFrom(""); err != nil || cfg.Log.Level != slog.LevelDebug || cfg.Log.Format != "text" {
+		t.Errorf("expected debug text logs, got %+v, %v", cfg, err)
+	}
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	if _, err := LoadFrom(""); err == nil || !strings.Contains(err.Error(), "invalid log level") {
+		t.Errorf("expected an error for an unknown level, got %v", err)
+	}
+}
+
+func TestLoadFrom_ImageSettings(t *testing.T) {
+	clearFileSettings(t)
+	requireNeo4jEnv(t)
+
+	tests := []struct {
+		name, base, thumbnail string
+		wantBase, wantThumb   string
+	}{
+		{"defaults", "", "", "https://image.tmdb.org/t/p", "w92"},
+		{"base with a size", "https://image.tmdb.org/t/p/w45", "", "https://image.tmdb.org/t/p", "w45"},
+		{"size set over the base's", "https://cdn.example.com/t/p/w45", "w154", "https://cdn.example.com/t/p", "w154"},
+		{"base without a size", "https://cdn.example.com/images", "", "https://cdn.example.com/images", "w92"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TMDB_IMAGE_BASE_URL", tt.base)
+			t.Setenv("TMDB_THUMBNAIL_SIZE", tt.thumbnail)
+			cfg, err := LoadServer("")
+			if err != nil {
+				t.Fatalf("LoadServer failed: %v", err)
+			}
+			if cfg.Server.ImageBaseURL != tt.wantBase || cfg.Server.ThumbnailSize != tt.wantThumb || cfg.Server.ImageSize != "w185" {
+				t.Errorf("expected %s with %s thumbnails and w185 images, got %s, %s and %s", tt.wantBase, tt.wantThumb,
+					cfg.Server.ImageBaseURL, cfg.Server.ThumbnailSize, cfg.Server.ImageSize)
+			}
+		})
+	}
+}
+
+func TestLoadFrom_TrustedProxiesUnix(t *testing.T) {
+	clearFileSettings(t)
+	requireNeo4jEnv(t)
+	t.Setenv("TRUSTED_PROXIES", "unix, 10.0.0.0/8")
+
+	cfg, err := LoadFrom("")
+	if err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	want := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	if !cfg.Server.TrustUnixPeers || !slices.Equal(cfg.Server.TrustedProxies, want) {
+		t.Errorf("expected unix peers and %v trusted, got %v and %v", want, cfg.Server.TrustUnixPeers, cfg.Server.TrustedProxies)
+	}
+}
+
+func TestLoadFrom_FileOverridesDefaults(t *testing.T) {
+	clearFileSettings(t)
+
+	cfg, err := LoadFrom("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	if cfg.DB.URI != "neo4j://from-file:7687" || cfg.DB.User != "neo4j" || cfg.DB.Pass != "from-file" {
+		t.Errorf("expected the file's neo4j settings, got %+v", cfg.DB)
+	}
+	if cfg.Server.PathQueryTimeout != 3*time.Second || cfg.Server.SearchLimit != 20 || cfg.Server.SocketMode != 0o600 {
+		t.Errorf("expected the file's path timeout, search limit and socket mode, got %s, %d, %v",
+			cfg.Server.PathQueryTimeout, cfg.Server.SearchLimit, cfg.Server.SocketMode)
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com"}; !slices.Equal(cfg.Server.CORSOrigins, want) {
+		t.Errorf("expected CORS origins %v, got %v", want, cfg.Server.CORSOrigins)
+	}
+	wantProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.1/32")}
+	if !slices.Equal(cfg.Server.TrustedProxies, wantProxies) {
+		t.Errorf("expected trusted proxies %v, got %v", wantProxies, cfg.Server.TrustedProxies)
+	}
+	wantRoutes := map[string]RoutePolicy{
+		"/degrees": {PerSec: 0.25, Burst: 4, Cost: 2},
+		"/search":  {PerSec: 2, Burst: 20, Cost: 1},
+	}
+	if !maps.Equal(cfg.Server.RateRoutes, wantRoutes) {
+		t.Errorf("expected route policies %v, got %v", wantRoutes, cfg.Server.RateRoutes)
+	}
+	// Anything the file leaves out keeps its default.
+	if cfg.Server.SearchMaxLimit != 50 || cfg.Server.RequestTimeout != 10*time.Second {
+		t.Errorf("expected defaults for settings the file omits, got %d and %s", cfg.Server.SearchMaxLimit, cfg.Server.RequestTimeout)
+	}
+}
+
+func TestLoadFrom_EnvOverridesFile(t *testing.T) {
+	clearFileSettings(t)
+	t.Setenv("NEO4J_PASSWORD", "from-env")
+	t.Setenv("PATH_QUERY_TIMEOUT", "7s")
+	t.Setenv("RATE_LIMIT_ROUTES", "/degrees=1:2:1")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "") // set but empty turns CORS off, file or not
+
+	cfg, err := LoadFrom("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	if cfg.DB.Pass != "from-env" || cfg.DB.URI != "neo4j://from-file:7687" {
+		t.Errorf("expected the env password over the file's uri, got %+v", cfg.DB)
+	}
+	if cfg.Server.PathQueryTimeout != 7*time.Second || cfg.Server.SearchLimit != 20 {
+		t.Errorf("expected the env path timeout and the file's search limit, got %s and %d", cfg.Server.PathQueryTimeout, cfg.Server.SearchLimit)
+	}
+	if want := map[string]RoutePolicy{"/degrees": {PerSec: 1, Burst: 2, Cost: 1}}; !maps.Equal(cfg.Server.RateRoutes, want) {
+		t.Errorf("expected the env route policies to replace the file's, got %v", cfg.Server.RateRoutes)
+	}
+	if len(cfg.Server.CORSOrigins) != 0 {
+		t.Errorf("expected CORS off, got %v", cfg.Server.CORSOrigins)
+	}
+}
+
+func TestLoadFrom_ConfigFileEnv(t *testing.T) {
+	clearFileSettings(t)
+	t.Setenv("CONFIG_FILE", "testdata/config.yaml")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.DB.URI != "neo4j://from-file:7687" {
+		t.Errorf("expected CONFIG_FILE read, got %q", cfg.DB.URI)
+	}
+
+	// A -config path wins over CONFIG_FILE.
+	t.Setenv("CONFIG_FILE", "testdata/missing.yaml")
+	if _, err := LoadFrom("testdata/config.yaml"); err != nil {
+		t.Errorf("expected the given path used instead of CONFIG_FILE, got %v", err)
+	}
+}
+
+func TestLoadFrom_FileErrors(t *testing.T) {
+	clearFileSettings(t)
+	requireNeo4jEnv(t)
+
+	_, err := LoadFrom("testdata/unknown.yaml")
+	if err == nil || !strings.Contains(err.Error(), "search_limt") {
+		t.Errorf("expected the misspelt setting reported, got %v", err)
+	}
+	if _, err := LoadFrom("testdata/missing.yaml"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	bad := t.TempDir() + "/bad.yaml"
+	os.WriteFile(bad, []byte("rate_limit_routes:\n  /degrees: {burst: 4}\n"), 0o600)
+	if _, err := LoadFrom(bad); err == nil || !strings.Contains(err.Error(), "per_sec") {
+		t.Errorf("expected an incomplete route policy rejected, got %v", err)
+	}
+}
+
+// writeSecret writes contents to a file in a fresh temp dir and returns its
+// path.
+func writeSecret(t *testing.T, contents string) string {
+	t.Helper()
+	path := t.TempDir() + "/secret"
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFrom_SecretFiles(t *testing.T) {
+	clearFileSettings(t)
+	requireNeo4jEnv(t)
+	t.Setenv("NEO4J_PASSWORD", "")
+	t.Setenv("NEO4J_PASSWORD_FILE", writeSecret(t, "from-file\n"))
+	t.Setenv("TMDB_API_TOKEN_FILE", writeSecret(t, "  tmdb-token  "))
+
+	cfg, err := LoadFrom("")
+	if err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	if cfg.DB.Pass != "from-file" || cfg.Client.APIToken != "tmdb-token" {
+		t.Errorf("expected the trimmed file contents, got %q and %q", cfg.DB.Pass, cfg.Client.APIToken)
+	}
+}
+
+func TestLoadFrom_SecretFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"both set", map[string]string{"NEO4J_PASSWORD": "from-env", "NEO4J_PASSWORD_FILE": "secret"}, "set NEO4J_PASSWORD or NEO4J_PASSWORD_FILE, not both"},
+		{"both set for tmdb", map[string]string{"TMDB_API_TOKEN": "from-env", "TMDB_API_TOKEN_FILE": "secret"}, "set TMDB_API_TOKEN or TMDB_API_TOKEN_FILE, not both"},
+		{"missing file", map[string]string{"NEO4J_PASSWORD": "", "NEO4J_PASSWORD_FILE": "missing"}, "error reading NEO4J_PASSWORD_FILE"},
+		{"empty file", map[string]string{"TMDB_API_TOKEN_FILE": "empty"}, "TMDB_API_TOKEN_FILE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearFileSettings(t)
+			requireNeo4jEnv(t)
+			for key, value := range tt.env {
+				switch value {
+				case "secret":
+					value = writeSecret(t, "from-file")
+				case "empty":
+					value = writeSecret(t, "\n")
+				case "missing":
+					value = t.TempDir() + "/missing"
+				}
+				t.Setenv(key, value)
+			}
+
+			_, err := LoadFrom("")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
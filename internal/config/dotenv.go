@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// dotEnvKey is what a .env file may name: a shell variable name.
+var dotEnvKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// loadDotEnv reads a .env file and sets any variable not already present in
+// the environment. It silently does nothing if the file doesn't exist. A
+// malformed line is reported by its line number, and the lines around it are
+// still loaded.
+func loadDotEnv(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var errs []error
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		key, value, ok, err := parseDotEnvLine(scanner.Text())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s line %d: %w", path, n, err))
+			continue
+		}
+		if ok && os.Getenv(key) == "" {
+			os.Setenv(key, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// parseDotEnvLine reads one line of a .env file the way a shell would source
+// it: an optional "export " prefix, then KEY=value. A value in double quotes
+// may hold \n, \t, \" and \\ escapes; one in single quotes is taken as is.
+// Outside quotes a # at the start of the value or after a space begins a
+// comment, so "p@ss#word" needs no quoting but "abc # note" is just "abc".
+// ok is false for a blank or comment line.
+func parseDotEnvLine(line string) (key, value string, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false, nil
+	}
+	if rest, found := strings.CutPrefix(line, "export"); found && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+		line = strings.TrimSpace(rest)
+	}
+
+	key, raw, found := strings.Cut(line, "=")
+	key = strings.TrimSpace(key)
+	if !found {
+		return "", "", false, fmt.Errorf("expected KEY=value, got %q", line)
+	}
+	if !dotEnvKey.MatchString(key) {
+		return "", "", false, fmt.Errorf("%q is not a valid variable name", key)
+	}
+
+	raw = strings.TrimSpace(raw)
+	var rest string
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		value, rest, err = unquoteDouble(raw[1:])
+	case strings.HasPrefix(raw, "'"):
+		var closed bool
+		value, rest, closed = strings.Cut(raw[1:], "'")
+		if !closed {
+			err = errors.New("unterminated single quote")
+		}
+	default:
+		return key, stripComment(raw), true, nil
+	}
+	if err != nil {
+		return "", "", false, fmt.Errorf("%s: %w", key, err)
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", "", false, fmt.Errorf("%s: unexpected %q after the closing quote", key, rest)
+	}
+	return key, value, true, nil
+}
+
+// unquoteDouble reads a double-quoted value up to its closing quote, with the
+// opening one already dropped, and returns what follows it too.
+func unquoteDouble(s string) (value, rest string, err error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			if i+1 == len(s) {
+				return "", "", errors.New("unterminated double quote")
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\':
+				b.WriteByte(s[i])
+			default:
+				// Not an escape this reads, so keep it as written.
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", errors.New("unterminated double quote")
+}
+
+// stripComment drops an inline comment from an unquoted value: everything
+// from a # that starts the value or follows a space or tab.
+func stripComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t') {
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return s
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDotEnvLine(t *testing.T) {
+	tests := []struct {
+		line      string
+		key, want string
+		ok        bool
+		wantErr   string
+	}{
+		{line: "", ok: false},
+		{line: "   # a comment", ok: false},
+		{line: "KEY=value", key: "KEY", want: "value", ok: true},
+		{line: "  KEY = value  ", key: "KEY", want: "value", ok: true},
+		{line: "export KEY=value", key: "KEY", want: "value", ok: true},
+		{line: "export\tKEY=value", key: "KEY", want: "value", ok: true},
+		{line: "EXPORTED=value", key: "EXPORTED", want: "value", ok: true},
+		{line: "KEY=a=b", key: "KEY", want: "a=b", ok: true},
+		{line: "KEY=", key: "KEY", want: "", ok: true},
+		{line: `KEY=""`, key: "KEY", want: "", ok: true},
+		{line: `KEY="p@ss#word"`, key: "KEY", want: "p@ss#word", ok: true},
+		{line: `KEY="a b" # note`, key: "KEY", want: "a b", ok: true},
+		{line: `KEY="a\nb\tc"`, key: "KEY", want: "a\nb\tc", ok: true},
+		{line: `KEY="say \"hi\" \\ \d"`, key: "KEY", want: `say "hi" \ \d`, ok: true},
+		{line: `KEY='a\nb # c'`, key: "KEY", want: `a\nb # c`, ok: true},
+		{line: "KEY=abc # note", key: "KEY", want: "abc", ok: true},
+		{line: "KEY=abc\t# note", key: "KEY", want: "abc", ok: true},
+		{line: "KEY=abc#def", key: "KEY", want: "abc#def", ok: true},
+		{line: "KEY=# only a comment", key: "KEY", want: "", ok: true},
+		{line: "just words", wantErr: "expected KEY=value"},
+		{line: "export", wantErr: "expected KEY=value"},
+		{line: "=value", wantErr: "not a valid variable name"},
+		{line: "MY KEY=value", wantErr: "not a valid variable name"},
+		{line: "1KEY=value", wantErr: "not a valid variable name"},
+		{line: `KEY="open`, wantErr: "KEY: unterminated double quote"},
+		{line: `KEY="open\"`, wantErr: "unterminated double quote"},
+		{line: `KEY='open`, wantErr: "unterminated single quote"},
+		{line: `KEY="a" b`, wantErr: `unexpected "b" after the closing quote`},
+	}
+	for _, tt := range tests {
+		key, value, ok, err := parseDotEnvLine(tt.line)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: expected an error mentioning %q, got %v", tt.line, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.line, err)
+			continue
+		}
+		if key != tt.key || value != tt.want || ok != tt.ok {
+			t.Errorf("%q: expected %q=%q (%v), got %q=%q (%v)", tt.line, tt.key, tt.want, tt.ok, key, value, ok)
+		}
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	want := map[string]string{
+		"PLAIN":          "value",
+		"EXPORTED":       "from-export",
+		"SPACED":         "padded value",
+		"DOUBLE":         "p@ss#word",
+		"SINGLE":         `single "quoted"`,
+		"SINGLE_LITERAL": `a\nb # not a comment`,
+		"ESCAPES":        "line one\nline two\t\"quoted\" \\ \\q",
+		"INLINE":         "abc",
+		"HASH_IN_VALUE":  "abc#def",
+		"QUOTED_COMMENT": "x y",
+		"EMPTY":          "",
+		"EMPTY_QUOTES":   "",
+		"KEPT":           "from-env",
+		"AFTER_ERRORS":   "still loaded",
+	}
+	for key := range want {
+		t.Setenv(key, "")
+	}
+	t.Setenv("KEPT", "from-env")
+	for _, key := range []string{"UNTERMINATED", "TRAILING"} {
+		unsetEnv(t, key)
+	}
+
+	err := loadDotEnv(filepath.Join("testdata", "fixture.env"))
+	for key, value := range want {
+		if got := os.Getenv(key); got != value {
+			t.Errorf("%s: expected %q, got %q", key, value, got)
+		}
+	}
+	for _, key := range []string{"UNTERMINATED", "TRAILING"} {
+		if _, ok := os.LookupEnv(key); ok {
+			t.Errorf("%s: expected a malformed line to set nothing", key)
+		}
+	}
+
+	problems := Problems(err)
+	if len(problems) != 4 {
+		t.Fatalf("expected four malformed lines reported, got %v", err)
+	}
+	for i, line := range []string{"line 16:", "line 17:", "line 18:", "line 19:"} {
+		if !strings.Contains(problems[i].Error(), line) {
+			t.Errorf("problem %d: expected %s, got %v", i, line, problems[i])
+		}
+	}
+
+	if err := loadDotEnv(filepath.Join("testdata", "missing.env")); err != nil {
+		t.Errorf("expected a missing file to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// settings looks settings up by their environment variable names: the
+// environment first, then the config file. It remembers which names it was
+// asked for, so a file key that no setting reads, most likely a typo, can be
+// reported instead of silently ignored.
+type settings struct {
+	file map[string]string
+	read map[string]bool
+}
+
+// getenv returns the variable key, or its value from the file when the
+// variable is unset or empty.
+func (s *settings) getenv(key string) string {
+	fv, _ := s.fromFile(key)
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fv
+}
+
+// lookup is getenv for settings where set but empty means something: a
+// variable that is set, even to "", hides the file's value.
+func (s *settings) lookup(key string) (string, bool) {
+	fv, fok := s.fromFile(key)
+	if v, ok := os.LookupEnv(key); ok {
+		return v, true
+	}
+	return fv, fok
+}
+
+func (s *settings) fromFile(key string) (string, bool) {
+	if s.read == nil {
+		s.read = make(map[string]bool)
+	}
+	s.read[key] = true
+	v, ok := s.file[key]
+	return v, ok
+}
+
+// checkUnread reports the file keys that no setting read.
+func (s *settings) checkUnread() error {
+	var unknown []string
+	for key := range s.file {
+		if !s.read[key] {
+			unknown = append(unknown, strings.ToLower(key))
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("unknown settings: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// readConfigFile reads the YAML config file at path; an empty path means no
+// file. The file is a flat mapping keyed by the environment variable names,
+// in any case, so .env.example documents both:
+//
+//	neo4j_uri: neo4j://localhost:7687
+//	path_query_timeout: 5s
+//	trusted_proxies: [10.0.0.0/8, 192.168.0.0/16]
+//	rate_limit_routes:
+//	  /degrees: {per_sec: 0.5, burst: 6, cost: 3}
+//	  /search: "2:20:1"
+//
+// Each value becomes the string its variable would hold: scalars are taken as
+// written, so 0660 stays octal; lists are joined with commas; and a
+// rate_limit_routes mapping becomes route=perSec:burst:cost entries. Parsing
+// and validation are then the same as for the environment.
+func readConfigFile(path string) (*settings, error) {
+	s := &settings{}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	s.file = make(map[string]string, len(doc))
+	for key, node := range doc {
+		value, err := flattenSetting(&node)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %s: %w", path, key, err)
+		}
+		s.file[strings.ToUpper(key)] = value
+	}
+	return s, nil
+}
+
+// flattenSetting renders a YAML value in its environment variable's format.
+func flattenSetting(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, len(node.Content))
+		for i, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("line %d: list items must be plain values", item.Line)
+			}
+			items[i] = item.Value
+		}
+		return strings.Join(items, ","), nil
+	case yaml.MappingNode:
+		return flattenRoutes(node)
+	default:
+		return "", fmt.Errorf("line %d: unsupported value", node.Line)
+	}
+}
+
+// flattenRoutes renders a route → policy mapping as route=perSec:burst:cost
+// entries. A policy is that string or a mapping of per_sec, burst and cost.
+func flattenRoutes(node *yaml.Node) (string, error) {
+	var entries []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		route, policy := node.Content[i].Value, node.Content[i+1]
+		if policy.Kind == yaml.ScalarNode {
+			entries = append(entries, route+"="+policy.Value)
+			continue
+		}
+		var p struct {
+			PerSec *string `yaml:"per_sec"`
+			Burst  *string `yaml:"burst"`
+			Cost   *string `yaml:"cost"`
+		}
+		if err := policy.Decode(&p); err != nil || p.PerSec == nil || p.Burst == nil || p.Cost == nil {
+			return "", fmt.Errorf("line %d: %s needs per_sec, burst and cost", policy.Line, route)
+		}
+		entries = append(entries, fmt.Sprintf("%s=%s:%s:%s", route, *p.PerSec, *p.Burst, *p.Cost))
+	}
+	return strings.Join(entries, ","), nil
+}
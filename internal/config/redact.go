@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// redactedMask replaces a secret that is set. An unset one stays "", so the
+// summary still shows whether it was configured.
+const redactedMask = "***"
+
+// Redacted returns every setting for logging, keyed by field name with each
+// section a nested map, and every field tagged secret:"true" masked. Masking
+// by tag rather than by name means a new credential only has to be tagged to
+// stay out of the logs. Durations, file modes and address prefixes are given
+// as their usual strings.
+func (c *Config) Redacted() map[string]any {
+	return redactStruct(reflect.ValueOf(*c))
+}
+
+func redactStruct(v reflect.Value) map[string]any {
+	out := make(map[string]any, v.NumField())
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Tag.Get("secret") == "true" {
+			out[field.Name] = ""
+			if !v.Field(i).IsZero() {
+				out[field.Name] = redactedMask
+			}
+			continue
+		}
+		out[field.Name] = redactValue(v.Field(i))
+	}
+	return out
+}
+
+func redactValue(v reflect.Value) any {
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return redactStruct(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range v.Len() {
+			out[i] = redactValue(v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			out[fmt.Sprint(iter.Key())] = redactValue(iter.Value())
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
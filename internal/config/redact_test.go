@@ -0,0 +1,87 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedacted(t *testing.T) {
+	cfg := defaultConfig(t)
+	cfg.DB.Pass = "neo4j-password-value"
+	cfg.Client.APIToken = "tmdb-token-value"
+	cfg.Server.APITokens = []Token{{Name: "ci", Secret: "api-secret-value"}}
+	cfg.Server.AdminTokens = []Token{{Secret: "admin-secret-value"}}
+	secrets := []string{"neo4j-password-value", "tmdb-token-value", "api-secret-value", "admin-secret-value"}
+
+	for _, h := range []struct {
+		name string
+		new  func(*bytes.Buffer) slog.Handler
+	}{
+		{"json", func(b *bytes.Buffer) slog.Handler { return slog.NewJSONHandler(b, nil) }},
+		{"text", func(b *bytes.Buffer) slog.Handler { return slog.NewTextHandler(b, nil) }},
+	} {
+		var out bytes.Buffer
+		slog.New(h.new(&out)).Info("effective config", "config", cfg.Redacted())
+		logged := out.String()
+		for _, secret := range secrets {
+			if strings.Contains(logged, secret) {
+				t.Errorf("%s: secret %q logged\n%s", h.name, secret, logged)
+			}
+		}
+		for _, want := range []string{cfg.DB.URI, "ci", "5s", redactedMask} {
+			if !strings.Contains(logged, want) {
+				t.Errorf("%s: expected %q in the summary\n%s", h.name, want, logged)
+			}
+		}
+	}
+
+	redacted := cfg.Redacted()
+	db := redacted["DB"].(map[string]any)
+	if db["Pass"] != redactedMask || db["User"] != "neo4j" {
+		t.Errorf("expected the password masked and the user shown, got %v", db)
+	}
+	tokens := redacted["Server"].(map[string]any)["APITokens"].([]any)
+	if token := tokens[0].(map[string]any); token["Name"] != "ci" || token["Secret"] != redactedMask {
+		t.Errorf("expected the token's name shown and secret masked, got %v", token)
+	}
+
+	cfg.Client.APIToken = ""
+	if client := cfg.Redacted()["Client"].(map[string]any); client["APIToken"] != "" {
+		t.Errorf("expected an unset secret left empty, got %v", client["APIToken"])
+	}
+}
+
+// TestSecretFieldsTagged catches a credential added without the secret tag,
+// which would leave it in the startup log. Fields that only look like one,
+// such as a path to a key file, are listed as exceptions.
+func TestSecretFieldsTagged(t *testing.T) {
+	looksSecret := regexp.MustCompile(`(?i)pass|secret|token|key|credential`)
+	notSecret := map[string]bool{
+		"ServerConfig.APITokens":       true, // []Token, whose Secret is tagged
+		"ServerConfig.AdminTokens":     true,
+		"ServerConfig.TLSKeyFile":      true, // a path
+		"ServerConfig.CORSCredentials": true, // a CORS flag
+	}
+
+	var check func(reflect.Type)
+	check = func(typ reflect.Type) {
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			name := typ.Name() + "." + field.Name
+			if field.Type.Kind() == reflect.Struct && field.Type.PkgPath() == typ.PkgPath() {
+				check(field.Type)
+			}
+			if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+				check(field.Type.Elem())
+			}
+			if looksSecret.MatchString(field.Name) && !notSecret[name] && field.Tag.Get("secret") != "true" {
+				t.Errorf("%s looks like a credential but isn't tagged secret:\"true\"", name)
+			}
+		}
+	}
+	check(reflect.TypeFor[Config]())
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// neo4jSchemes are the URI schemes the Neo4j driver dials: routing (neo4j)
+// or direct (bolt), each plain, TLS (+s) or TLS without verification (+ssc).
+var neo4jSchemes = []string{"neo4j", "neo4j+s", "neo4j+ssc", "bolt", "bolt+s", "bolt+ssc"}
+
+// imageSizePattern matches the image sizes TMDb serves: a width, a height,
+// or the original upload.
+var imageSizePattern = regexp.MustCompile(`^([wh][0-9]+|original)$`)
+
+// analyzerPattern matches a Lucene analyzer name as Neo4j lists them, like
+// "whitespace" or "standard-folding".
+var analyzerPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// Validate checks that the settings make sense on their own and together. It
+// reports every problem it finds, joined with errors.Join and each naming
+// its variable, so one run lists everything to fix; Problems splits them
+// back out. It checks every section; the Load functions check just the
+// sections their binary uses, once every value has parsed.
+func (c *Config) Validate() error {
+	return errors.Join(c.check(profile{tmdb: true, server: true})...)
+}
+
+// require reports each setting prof can't run without that is unset, naming
+// the binary that needs it.
+func (c *Config) require(prof profile) problems {
+	var p problems
+	p.required("NEO4J_URI", c.DB.URI, prof.name)
+	p.required("NEO4J_USER", c.DB.User, prof.name)
+	p.required("NEO4J_PASSWORD (or NEO4J_PASSWORD_FILE)", c.DB.Pass, prof.name)
+	if prof.tmdb {
+		p.required("TMDB_API_TOKEN (or TMDB_API_TOKEN_FILE)", c.Client.APIToken, prof.name)
+	}
+	return p
+}
+
+// check validates the sections prof uses. The TMDb client's settings count
+// for the server whenever a token is set, since it builds a client then.
+func (c *Config) check(prof profile) problems {
+	var p problems
+	c.checkDB(&p)
+	if f := c.Log.Format; f != "" && f != "json" && f != "text" {
+		p.addf("LOG_FORMAT must be json or text, got %q", f)
+	}
+	if prof.tmdb || (prof.server && c.Client.APIToken != "") {
+		c.checkClient(&p)
+	}
+	if prof.server {
+		c.checkServer(&p)
+	}
+	return p
+}
+
+func (c *Config) checkDB(p *problems) {
+	// An unset URI is require's to report.
+	if u, err := url.Parse(c.DB.URI); c.DB.URI != "" && (err != nil || !slices.Contains(neo4jSchemes, u.Scheme) || u.Host == "") {
+		p.addf("NEO4J_URI must be a neo4j:// or bolt:// URL, optionally +s or +ssc, got %q", c.DB.URI)
+	}
+	p.positive("NEO4J_SCHEMA_TIMEOUT", c.DB.SchemaTimeout)
+	p.notNegative("NEO4J_SLOW_QUERY", c.DB.SlowQuery)
+	p.atLeast("STARTUP_DB_RETRIES", c.DB.StartupRetries, 0)
+	p.positive("STARTUP_DB_BACKOFF", c.DB.StartupBackoff)
+	if !analyzerPattern.MatchString(c.DB.SearchAnalyzer) {
+		p.addf("NEO4J_SEARCH_ANALYZER must be an analyzer name like whitespace or standard-folding, got %q", c.DB.SearchAnalyzer)
+	}
+	if c.DB.SearchMinScore < 0 {
+		p.addf("SEARCH_MIN_SCORE must not be negative, got %g", c.DB.SearchMinScore)
+	}
+}
+
+func (c *Config) checkClient(p *problems) {
+	p.positive("HTTP_CLIENT_TIMEOUT", c.Client.Timeout)
+	p.atLeast("TMDB_RATE_LIMIT", c.Client.Limit, 1)
+	p.atLeast("TMDB_BURST_AMOUNT", c.Client.Burst, 1)
+	p.atLeast("TMDB_DETAIL_RATE_LIMIT", c.Client.DetailLimit, 0)
+	if c.Client.DetailLimit > 0 {
+		p.atLeast("TMDB_DETAIL_BURST_AMOUNT", c.Client.DetailBurst, 1)
+	}
+	// It counts attempts, so at least one request is made.
+	p.atLeast("TMDB_MAX_RETRIES", c.Client.MaxRetries, 1)
+	p.positive("TMDB_BASE_BACKOFF", c.Client.BaseBackoff)
+	if u, err := url.Parse(c.Client.BaseURL); c.Client.BaseURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		p.addf("TMDB_BASE_URL must be an http:// or https:// URL, got %q", c.Client.BaseURL)
+	}
+}
+
+func (c *Config) checkServer(p *problems) {
+	s := c.Server
+	p.positive("NEO4J_AVAILABILITY_POLL", s.AvailabilityPoll)
+	if s.Network == "tcp" {
+		p.port("LISTEN_ADDR (or PORT)", s.Addr)
+	}
+	if s.MetricsAddr != "" {
+		p.port("METRICS_ADDR", s.MetricsAddr)
+	}
+	if s.DebugAddr != "" {
+		p.port("DEBUG_ADDR", s.DebugAddr)
+	} else if s.DebugEndpoints {
+		p.addf("DEBUG_ADDR must be set when DEBUG_ENDPOINTS is on; the debug endpoints are never served on the main listener")
+	}
+	// Zero turns these timeouts off, as it does for http.Server.
+	p.notNegative("SERVER_READ_TIMEOUT", s.ReadTimeout)
+	p.notNegative("SERVER_WRITE_TIMEOUT", s.WriteTimeout)
+	p.notNegative("SERVER_IDLE_TIMEOUT", s.IdleTimeout)
+	p.positive("SERVER_SHUTDOWN_TIMEOUT", s.ShutdownTimeout)
+	p.positive("REQUEST_TIMEOUT", s.RequestTimeout)
+	p.notNegative("PATH_QUERY_TIMEOUT", s.PathQueryTimeout)
+	p.notNegative("SLOW_REQUEST_MS", s.SlowRequest)
+
+	if s.CORSCredentials && slices.Contains(s.CORSOrigins, "*") {
+		p.addf("CORS_ALLOW_CREDENTIALS needs explicit CORS_ALLOWED_ORIGINS, not *")
+	}
+	p.notNegative("CORS_MAX_AGE", s.CORSMaxAge)
+
+	if s.RateLimitPerSec <= 0 {
+		p.addf("RATE_LIMIT_PER_SEC must be positive, got %g", s.RateLimitPerSec)
+	}
+	p.atLeast("RATE_BURST", s.RateBurst, 1)
+	for _, route := range slices.Sorted(maps.Keys(s.RateRoutes)) {
+		if policy := s.RateRoutes[route]; policy.PerSec <= 0 {
+			p.addf("RATE_LIMIT_ROUTES: %s must refill at a positive rate, got %g", route, policy.PerSec)
+		}
+	}
+	p.atLeast("RATE_LIMIT_MAX_CLIENTS", s.RateMaxClients, 0)
+
+	p.atLeast("SEARCH_MAX_QUERY_LEN", s.MaxQueryLen, 1)
+	p.atLeast("SEARCH_LIMIT", s.SearchLimit, 1)
+	if s.SearchMaxLimit < s.SearchLimit {
+		p.addf("SEARCH_MAX_LIMIT must be at least SEARCH_LIMIT (%d), got %d", s.SearchLimit, s.SearchMaxLimit)
+	}
+	p.atLeast("MAX_URL_LENGTH", s.MaxURLLen, 0)
+	if s.MaxBodyBytes < 0 {
+		p.addf("MAX_BODY_BYTES must not be negative, got %d", s.MaxBodyBytes)
+	}
+	p.atLeast("LOG_SAMPLE_RATE", s.LogSampleRate, 1)
+	p.atLeast("SITEMAP_SIZE", s.SitemapSize, 0)
+	p.notNegative("STATS_CACHE_TTL", s.StatsCacheTTL)
+
+	if u, err := url.Parse(s.ImageBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		p.addf("TMDB_IMAGE_BASE_URL must be an http:// or https:// URL, got %q", s.ImageBaseURL)
+	}
+	p.imageSize("TMDB_THUMBNAIL_SIZE", s.ThumbnailSize)
+	p.imageSize("TMDB_IMAGE_SIZE", s.ImageSize)
+
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		p.addf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if s.TLSRedirectHTTP {
+		if s.TLSCertFile == "" {
+			p.addf("TLS_REDIRECT_HTTP needs TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		p.port("TLS_REDIRECT_ADDR", s.TLSRedirectAddr)
+	}
+	p.notNegative("HSTS_MAX_AGE", s.HSTSMaxAge)
+}
+
+// Problems splits an error from Load or Validate into the problems it
+// reports, one per element; an error that isn't a list is returned alone.
+func Problems(err error) []error {
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// problems collects what require and check find.
+type problems []error
+
+func (p *problems) addf(format string, args ...any) {
+	*p = append(*p, fmt.Errorf(format, args...))
+}
+
+func (p *problems) required(name, value, binary string) {
+	if value == "" {
+		p.addf("%s is not set; %s needs it", name, binary)
+	}
+}
+
+func (p *problems) positive(name string, d time.Duration) {
+	if d <= 0 {
+		p.addf("%s must be positive, got %s", name, d)
+	}
+}
+
+func (p *problems) notNegative(name string, d time.Duration) {
+	if d < 0 {
+		p.addf("%s must not be negative, got %s", name, d)
+	}
+}
+
+func (p *problems) atLeast(name string, n, least int) {
+	if n < least {
+		p.addf("%s must be at least %d, got %d", name, least, n)
+	}
+}
+
+func (p *problems) imageSize(name, size string) {
+	if !imageSizePattern.MatchString(size) {
+		p.addf("%s must be a TMDb image size like w185 or original, got %q", name, size)
+	}
+}
+
+// port checks that addr is host:port with a port from 1 to 65535. The host
+// may be empty, for every interface.
+func (p *problems) port(name, addr string) {
+	_, port, err := net.SplitHostPort(addr)
+	if n, perr := strconv.Atoi(port); err != nil || perr != nil || n < 1 || n > 65535 {
+		p.addf("%s must be host:port with a port from 1 to 65535, got %q", name, addr)
+	}
+}
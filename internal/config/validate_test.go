@@ -0,0 +1,202 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// defaultConfig is what Load gives with only the required variables set.
+func defaultConfig(t *testing.T) *Config {
+	t.Helper()
+	clearFileSettings(t)
+	requireNeo4jEnv(t)
+	cfg, err := LoadFrom("")
+	if err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   string // "" for valid
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"bolt uri", func(c *Config) { c.DB.URI = "bolt+s://db.example.com:7687" }, ""},
+		{"http uri", func(c *Config) { c.DB.URI = "http://localhost:7474" }, "NEO4J_URI"},
+		{"uri without host", func(c *Config) { c.DB.URI = "neo4j://" }, "NEO4J_URI"},
+		{"bare host uri", func(c *Config) { c.DB.URI = "localhost:7687" }, "NEO4J_URI"},
+		{"zero schema timeout", func(c *Config) { c.DB.SchemaTimeout = 0 }, "NEO4J_SCHEMA_TIMEOUT must be positive"},
+		{"slow query off", func(c *Config) { c.DB.SlowQuery = 0 }, ""},
+		{"negative slow query", func(c *Config) { c.DB.SlowQuery = -time.Second }, "NEO4J_SLOW_QUERY must not be negative"},
+		{"zero availability poll", func(c *Config) { c.Server.AvailabilityPoll = 0 }, "NEO4J_AVAILABILITY_POLL"},
+		{"no startup retries", func(c *Config) { c.DB.StartupRetries = 0 }, ""},
+		{"negative startup retries", func(c *Config) { c.DB.StartupRetries = -1 }, "STARTUP_DB_RETRIES"},
+		{"zero startup backoff", func(c *Config) { c.DB.StartupBackoff = 0 }, "STARTUP_DB_BACKOFF"},
+		{"standard analyzer", func(c *Config) { c.DB.SearchAnalyzer = "standard-folding" }, ""},
+		{"quoted analyzer", func(c *Config) { c.DB.SearchAnalyzer = "standard'}" }, "NEO4J_SEARCH_ANALYZER"},
+		{"search min score", func(c *Config) { c.DB.SearchMinScore = 0.5 }, ""},
+		{"negative search min score", func(c *Config) { c.DB.SearchMinScore = -1 }, "SEARCH_MIN_SCORE"},
+		{"zero client timeout", func(c *Config) { c.Client.Timeout = 0 }, "HTTP_CLIENT_TIMEOUT"},
+		{"zero tmdb rate", func(c *Config) { c.Client.Limit = 0 }, "TMDB_RATE_LIMIT must be at least 1"},
+		{"zero tmdb burst", func(c *Config) { c.Client.Burst = 0 }, "TMDB_BURST_AMOUNT"},
+		{"detail limiter without burst", func(c *Config) { c.Client.DetailLimit, c.Client.DetailBurst = 2, 0 }, "TMDB_DETAIL_BURST_AMOUNT"},
+		{"negative retries", func(c *Config) { c.Client.MaxRetries = -1 }, "TMDB_MAX_RETRIES"},
+		{"zero retries", func(c *Config) { c.Client.MaxRetries = 0 }, "TMDB_MAX_RETRIES must be at least 1"},
+		{"zero backoff", func(c *Config) { c.Client.BaseBackoff = 0 }, "TMDB_BASE_BACKOFF"},
+		{"tmdb proxy", func(c *Config) { c.Client.BaseURL = "http://localhost:8080/tmdb/" }, ""},
+		{"tmdb base without scheme", func(c *Config) { c.Client.BaseURL = "localhost:8080" }, "TMDB_BASE_URL"},
+		{"port too high", func(c *Config) { c.Server.Addr = ":70000" }, "LISTEN_ADDR (or PORT)"},
+		{"port zero", func(c *Config) { c.Server.Addr = "localhost:0" }, "LISTEN_ADDR (or PORT)"},
+		{"no port", func(c *Config) { c.Server.Addr = "localhost" }, "LISTEN_ADDR (or PORT)"},
+		{"unix socket", func(c *Config) { c.Server.Network, c.Server.Addr = "unix", "/run/degrees.sock" }, ""},
+		{"bad metrics addr", func(c *Config) { c.Server.MetricsAddr = "9090" }, "METRICS_ADDR"},
+		{"bad debug addr", func(c *Config) { c.Server.DebugAddr = "localhost:http" }, "DEBUG_ADDR"},
+		{"debug endpoints without an addr", func(c *Config) { c.Server.DebugEndpoints, c.Server.DebugAddr = true, "" }, "DEBUG_ADDR"},
+		{"server timeouts off", func(c *Config) { c.Server.ReadTimeout, c.Server.WriteTimeout, c.Server.IdleTimeout = 0, 0, 0 }, ""},
+		{"negative write timeout", func(c *Config) { c.Server.WriteTimeout = -time.Second }, "SERVER_WRITE_TIMEOUT must not be negative"},
+		{"path timeout off", func(c *Config) { c.Server.PathQueryTimeout = 0 }, ""},
+		{"negative path timeout", func(c *Config) { c.Server.PathQueryTimeout = -time.Second }, "PATH_QUERY_TIMEOUT"},
+		{"cors credentials with any origin", func(c *Config) { c.Server.CORSCredentials = true }, "CORS_ALLOW_CREDENTIALS"},
+		{"cors credentials with origins", func(c *Config) {
+			c.Server.CORSCredentials, c.Server.CORSOrigins = true, []string{"https://example.com"}
+		}, ""},
+		{"zero rate limit", func(c *Config) { c.Server.RateLimitPerSec = 0 }, "RATE_LIMIT_PER_SEC must be positive"},
+		{"zero rate burst", func(c *Config) { c.Server.RateBurst = 0 }, "RATE_BURST"},
+		{"zero route rate", func(c *Config) { c.Server.RateRoutes["/search"] = RoutePolicy{Burst: 5, Cost: 1} }, "RATE_LIMIT_ROUTES: /search"},
+		{"negative max clients", func(c *Config) { c.Server.RateMaxClients = -1 }, "RATE_LIMIT_MAX_CLIENTS"},
+		{"zero query length", func(c *Config) { c.Server.MaxQueryLen = 0 }, "SEARCH_MAX_QUERY_LEN"},
+		{"zero search limit", func(c *Config) { c.Server.SearchLimit = 0 }, "SEARCH_LIMIT must be at least 1"},
+		{"max limit under limit", func(c *Config) { c.Server.SearchMaxLimit = 10 }, "SEARCH_MAX_LIMIT must be at least SEARCH_LIMIT (15)"},
+		{"url limit off", func(c *Config) { c.Server.MaxURLLen, c.Server.MaxBodyBytes = 0, 0 }, ""},
+		{"negative body limit", func(c *Config) { c.Server.MaxBodyBytes = -1 }, "MAX_BODY_BYTES"},
+		{"zero log sample rate", func(c *Config) { c.Server.LogSampleRate = 0 }, "LOG_SAMPLE_RATE"},
+		{"negative sitemap", func(c *Config) { c.Server.SitemapSize = -1 }, "SITEMAP_SIZE"},
+		{"negative stats cache ttl", func(c *Config) { c.Server.StatsCacheTTL = -time.Second }, "STATS_CACHE_TTL"},
+		{"tls cert without key", func(c *Config) { c.Server.TLSCertFile = "cert.pem" }, "TLS_CERT_FILE and TLS_KEY_FILE"},
+		{"tls key without cert", func(c *Config) { c.Server.TLSKeyFile = "key.pem" }, "TLS_CERT_FILE and TLS_KEY_FILE"},
+		{"tls", func(c *Config) { c.Server.TLSCertFile, c.Server.TLSKeyFile = "cert.pem", "key.pem" }, ""},
+		{"redirect without tls", func(c *Config) { c.Server.TLSRedirectHTTP = true }, "TLS_REDIRECT_HTTP needs"},
+		{"redirect to a bad addr", func(c *Config) {
+			c.Server.TLSCertFile, c.Server.TLSKeyFile = "cert.pem", "key.pem"
+			c.Server.TLSRedirectHTTP, c.Server.TLSRedirectAddr = true, ":99999"
+		}, "TLS_REDIRECT_ADDR"},
+		{"image base without scheme", func(c *Config) { c.Server.ImageBaseURL = "image.tmdb.org/t/p" }, "TMDB_IMAGE_BASE_URL"},
+		{"bad image size", func(c *Config) { c.Server.ImageSize = "185" }, "TMDB_IMAGE_SIZE must be a TMDb image size"},
+		{"original thumbnails", func(c *Config) { c.Server.ThumbnailSize = "original" }, ""},
+		{"text logs", func(c *Config) { c.Log.Format = "text" }, ""},
+		{"xml logs", func(c *Config) { c.Log.Format = "xml" }, "LOG_FORMAT must be json or text"},
+		{"negative hsts", func(c *Config) { c.Server.HSTSMaxAge = -time.Hour }, "HSTS_MAX_AGE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig(t)
+			tt.change(cfg)
+			err := cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("expected no problems, got %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("expected a problem mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadFrom_ReportsEveryProblem(t *testing.T) {
+	clearFileSettings(t)
+	requireNeo4jEnv(t)
+	t.Setenv("NEO4J_URI", "http://localhost:7474")
+	t.Setenv("TLS_CERT_FILE", "cert.pem")
+	t.Setenv("SEARCH_LIMIT", "0")
+
+	_, err := LoadServer("")
+	problems := Problems(err)
+	if len(problems) != 3 {
+		t.Fatalf("expected three problems, got %d: %v", len(problems), err)
+	}
+	for i, want := range []string{"NEO4J_URI", "SEARCH_LIMIT", "TLS_CERT_FILE"} {
+		if !strings.Contains(problems[i].Error(), want) {
+			t.Errorf("problem %d: expected %s, got %v", i, want, problems[i])
+		}
+	}
+
+	// A value that doesn't parse is reported on its own.
+	t.Setenv("SEARCH_LIMIT", "lots")
+	if _, err := LoadServer(""); len(Problems(err)) != 1 {
+		t.Errorf("expected just the parse error, got %v", err)
+	}
+}
+
+func TestLoad_Profiles(t *testing.T) {
+	load := map[string]func(string) (*Config, error){
+		"server": LoadServer,
+		"ingest": LoadIngest,
+		"tools":  LoadFrom,
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		want map[string][]string // problems by binary; absent loads cleanly
+	}{
+		{"nothing set", map[string]string{}, map[string][]string{
+			"server": {"NEO4J_URI is not set; the server needs it", "NEO4J_USER", "NEO4J_PASSWORD"},
+			"ingest": {"NEO4J_URI is not set; ingest needs it", "NEO4J_USER", "NEO4J_PASSWORD", "TMDB_API_TOKEN (or TMDB_API_TOKEN_FILE) is not set; ingest needs it"},
+			"tools":  {"NEO4J_URI", "NEO4J_USER", "NEO4J_PASSWORD"},
+		}},
+		{"neo4j only", neo4jEnv(nil), map[string][]string{
+			"ingest": {"TMDB_API_TOKEN"},
+		}},
+		{"bad server setting", neo4jEnv(map[string]string{"TMDB_API_TOKEN": "token", "SEARCH_LIMIT": "0"}), map[string][]string{
+			"server": {"SEARCH_LIMIT"},
+		}},
+		{"bad tmdb setting without a token", neo4jEnv(map[string]string{"TMDB_RATE_LIMIT": "0"}), map[string][]string{
+			"ingest": {"TMDB_API_TOKEN", "TMDB_RATE_LIMIT"},
+		}},
+		{"bad tmdb setting with a token", neo4jEnv(map[string]string{"TMDB_API_TOKEN": "token", "TMDB_RATE_LIMIT": "0"}), map[string][]string{
+			"server": {"TMDB_RATE_LIMIT"},
+			"ingest": {"TMDB_RATE_LIMIT"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearFileSettings(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			for binary, loadFn := range load {
+				_, err := loadFn("")
+				want := tt.want[binary]
+				if len(want) == 0 {
+					if err != nil {
+						t.Errorf("%s: expected a clean load, got %v", binary, err)
+					}
+					continue
+				}
+				problems := Problems(err)
+				if err == nil || len(problems) != len(want) {
+					t.Errorf("%s: expected %d problems, got %v", binary, len(want), err)
+					continue
+				}
+				for i, w := range want {
+					if !strings.Contains(problems[i].Error(), w) {
+						t.Errorf("%s: problem %d: expected %q, got %v", binary, i, w, problems[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+// neo4jEnv is env plus the Neo4j settings every binary needs.
+func neo4jEnv(env map[string]string) map[string]string {
+	out := map[string]string{"NEO4J_URI": "neo4j://localhost:7687", "NEO4J_USER": "neo4j", "NEO4J_PASSWORD": "secret"}
+	for key, value := range env {
+		out[key] = value
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"log/slog"
+	"reflect"
+	"sync"
+)
+
+// Watcher reloads the server's configuration while it runs, on SIGHUP. Only
+// the settings copied by reloadable take effect: the rate limits, the log
+// level, the stats cache TTL and the CORS origins. A change to anything else
+// is logged as needing a restart and otherwise ignored.
+//
+// The environment of a running process doesn't change, so in practice a
+// reload picks up edits to the config file. Variables from .env were put in
+// the environment at startup and stay as they were.
+type Watcher struct {
+	path   string
+	logger *slog.Logger
+	apply  func(*Config)
+
+	mu      sync.Mutex
+	current Config
+}
+
+// NewWatcher returns a Watcher for the configuration current was loaded
+// from, path being the -config flag as LoadServer was given it. apply is
+// called with the new configuration on every reload that loads; it must
+// only act on the reloadable settings.
+func NewWatcher(path string, current *Config, logger *slog.Logger, apply func(*Config)) *Watcher {
+	return &Watcher{
+		path:    path,
+		logger:  logger,
+		apply:   apply,
+		current: *current,
+	}
+}
+
+// Reload loads the configuration again and applies it. A configuration that
+// fails to load or validate is not applied, so the server keeps running on
+// the settings it has, and the error is returned.
+func (w *Watcher) Reload() error {
+	next, err := LoadServer(w.path)
+	if err != nil {
+		return err
+	}
+	w.Apply(next)
+	return nil
+}
+
+// Apply takes the reloadable settings from next, warns about any other
+// setting that differs from the running configuration, and passes the
+// result to the apply func.
+func (w *Watcher) Apply(next *Config) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	merged := w.current
+	reloadable(&merged, next)
+	if changed := changedFields(reflect.ValueOf(merged), reflect.ValueOf(*next), ""); len(changed) > 0 {
+		w.logger.Warn("config changes need a restart to take effect", "settings", changed)
+	}
+	reloaded := changedFields(reflect.ValueOf(w.current), reflect.ValueOf(merged), "")
+
+	w.current = merged
+	w.apply(&merged)
+	w.logger.Info("config reloaded", "changed", reloaded)
+}
+
+// reloadable copies the settings that can change without a restart from src
+// to dst.
+func reloadable(dst, src *Config) {
+	dst.Log.Level = src.Log.Level
+	dst.Server.RateLimitPerSec = src.Server.RateLimitPerSec
+	dst.Server.RateBurst = src.Server.RateBurst
+	dst.Server.RateRoutes = src.Server.RateRoutes
+	dst.Server.StatsCacheTTL = src.Server.StatsCacheTTL
+	dst.Server.CORSOrigins = src.Server.CORSOrigins
+}
+
+// changedFields names the fields, as Section.Field, whose values differ
+// between a and b. Secrets are compared like everything else; only their
+// names are returned.
+func changedFields(a, b reflect.Value, prefix string) []string {
+	var changed []string
+	for i := range a.NumField() {
+		field := a.Type().Field(i)
+		name := prefix + field.Name
+		if field.Type.Kind() == reflect.Struct && field.Type.PkgPath() == a.Type().PkgPath() {
+			changed = append(changed, changedFields(a.Field(i), b.Field(i), name+".")...)
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcher_Apply(t *testing.T) {
+	cfg := defaultConfig(t)
+	var out bytes.Buffer
+	var applied *Config
+	w := NewWatcher("", cfg, slog.New(slog.NewTextHandler(&out, nil)), func(c *Config) { applied = c })
+
+	next := *cfg
+	next.Log.Level = slog.LevelDebug
+	next.Server.RateBurst = cfg.Server.RateBurst + 10
+	next.Server.StatsCacheTTL = time.Minute
+	next.Server.CORSOrigins = []string{"https://a.example.com"}
+	next.Server.Addr = ":9999"
+	next.DB.Pass = "rotated"
+	w.Apply(&next)
+
+	if applied == nil {
+		t.Fatal("expected the apply func to be called")
+	}
+	if applied.Log.Level != slog.LevelDebug || applied.Server.RateBurst != next.Server.RateBurst ||
+		applied.Server.StatsCacheTTL != time.Minute || !slices.Equal(applied.Server.CORSOrigins, next.Server.CORSOrigins) {
+		t.Errorf("expected the reloadable settings applied, got %+v", applied.Server)
+	}
+	if applied.Server.Addr != cfg.Server.Addr || applied.DB.Pass != cfg.DB.Pass {
+		t.Errorf("expected the address and password kept, got %q and %q", applied.Server.Addr, applied.DB.Pass)
+	}
+
+	logged := out.String()
+	if !strings.Contains(logged, "need a restart") || !strings.Contains(logged, "Server.Addr") || !strings.Contains(logged, "DB.Pass") {
+		t.Errorf("expected a restart warning naming the address and password\n%s", logged)
+	}
+	if strings.Contains(logged, "rotated") {
+		t.Errorf("expected the new password kept out of the log\n%s", logged)
+	}
+	if !strings.Contains(logged, "Server.RateBurst") {
+		t.Errorf("expected the reloaded settings logged\n%s", logged)
+	}
+
+	// Reapplying what's in effect changes nothing and warns about nothing
+	// new.
+	out.Reset()
+	merged := *applied
+	w.Apply(&merged)
+	if strings.Contains(out.String(), "need a restart") {
+		t.Errorf("expected no warning for an unchanged config\n%s", out.String())
+	}
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	clearFileSettings(t)
+	for _, key := range []string{"RATE_BURST", "PORT", "LISTEN_ADDR"} {
+		t.Setenv(key, "")
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(body string) {
+		t.Helper()
+		base := "neo4j_uri: neo4j://localhost:7687\nneo4j_user: neo4j\nneo4j_password: secret\n"
+		if err := os.WriteFile(path, []byte(base+body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("rate_burst: 5\n")
+	cfg, err := LoadServer(path)
+	if err != nil {
+		t.Fatalf("LoadServer failed: %v", err)
+	}
+	var bursts []int
+	w := NewWatcher(path, cfg, slog.New(slog.DiscardHandler), func(c *Config) { bursts = append(bursts, c.Server.RateBurst) })
+
+	write("rate_burst: 8\n")
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	write("rate_burst: 0\n")
+	if err := w.Reload(); err == nil || !strings.Contains(err.Error(), "RATE_BURST") {
+		t.Errorf("expected an invalid burst refused, got %v", err)
+	}
+	if !slices.Equal(bursts, []int{8}) {
+		t.Errorf("expected only the valid reload applied, got %v", bursts)
+	}
+}
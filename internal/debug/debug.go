@@ -0,0 +1,36 @@
+// Package debug serves the runtime diagnostics under /debug/: net/http/pprof
+// profiles and expvar counters. It is meant for an operator's port, never
+// the public listener's rate-limited and access-logged stack.
+package debug
+
+import (
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// Handler serves /debug/pprof/ and /debug/vars. Each request is logged at
+// debug level, so profiling leaves no trace in production logs unless asked.
+func Handler(logger *slog.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		mux.ServeHTTP(w, r)
+		logger.DebugContext(r.Context(), "debug request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote_addr", r.RemoteAddr,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
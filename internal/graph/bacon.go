@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// baconMetaKey is the Meta node recording whose distances bacon_number holds.
+const baconMetaKey = "bacon"
+
+// MaxDistancesDepth bounds DistancesFrom. Nearly every connected actor is
+// within six or seven hops of a well-known one; the few chains longer than
+// this are left unnumbered rather than walked to the end.
+const MaxDistancesDepth = 12
+
+// distancesChunkSize caps the ids sent in one frontier query. Deep levels of
+// a whole-graph search can hold hundreds of thousands of actors.
+const distancesChunkSize = 10000
+
+// DistancesFrom returns the co-star distance from the actor center to every
+// actor within maxDepth hops, keyed by TMDb id, with center itself at 0.
+// Actors further away or unconnected are absent.
+//
+// It is the breadth-first search behind ReachCounts run over the whole
+// graph, so it holds every reached id in memory and takes minutes on a full
+// dataset: it is for batch jobs such as cmd/bacon, not requests.
+func (d *Driver) DistancesFrom(ctx context.Context, center, maxDepth int) (_ map[int]int, err error) {
+	if maxDepth < 1 || maxDepth > MaxDistancesDepth {
+		return nil, fmt.Errorf("distance depth must be between 1 and %d, got %d", MaxDistancesDepth, maxDepth)
+	}
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "DistancesFrom", expandFrontierCypher,
+		attribute.Int("actor_id", center),
+		attribute.Int("max_depth", maxDepth),
+	)
+	defer func() {
+		d.observe(ctx, "DistancesFrom", start, err)
+		span.End()
+	}()
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	distances := map[int]int{center: 0}
+	seen := map[int64]bool{int64(center): true}
+	frontier := []int64{int64(center)}
+	for depth := 1; depth <= maxDepth && len(frontier) > 0; depth++ {
+		var next []int64
+		for chunk := range slices.Chunk(frontier, distancesChunkSize) {
+			reached, err := expandFrontier(ctx, session, chunk, seen)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return nil, fmt.Errorf("error expanding distance level %d: %w", depth, err)
+			}
+			next = append(next, reached...)
+		}
+		for _, id := range next {
+			distances[int(id)] = depth
+		}
+		frontier = next
+	}
+
+	span.SetAttributes(attribute.Int("result.reached", len(distances)-1))
+	return distances, nil
+}
+
+// SetBaconNumbers stores distances, as from DistancesFrom, in each actor's
+// bacon_number property, writing batchSize actors per transaction, and
+// records center in the bacon Meta node. Numbers from an earlier run are
+// removed first, so actors no longer within reach don't keep a stale one;
+// until the writes finish, lookups find no number rather than an old one.
+// progress, if non-nil, is called with the running total after each batch.
+func (d *Driver) SetBaconNumbers(ctx context.Context, center int, distances map[int]int, batchSize int, progress func(written int)) (int, error) {
+	if batchSize < 1 {
+		return 0, fmt.Errorf("error setting bacon numbers: batch size must be at least 1, got %d", batchSize)
+	}
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+
+	// CALL ... IN TRANSACTIONS only runs in an auto-commit transaction.
+	result, err := session.Run(ctx, `
+		MATCH (a:Actor) WHERE a.bacon_number IS NOT NULL
+		CALL (a) { REMOVE a.bacon_number } IN TRANSACTIONS OF $batch ROWS`,
+		map[string]any{"batch": batchSize})
+	if err == nil {
+		_, err = result.Consume(ctx)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error clearing bacon numbers: %w", err)
+	}
+
+	// Sorted, so an interrupted run has written a predictable prefix.
+	ids := slices.Sorted(maps.Keys(distances))
+	written := 0
+	for batch := range slices.Chunk(ids, batchSize) {
+		rows := make([]map[string]any, len(batch))
+		for i, id := range batch {
+			rows[i] = map[string]any{"id": id, "n": distances[id]}
+		}
+		_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+			_, err := tx.Run(ctx, `
+				UNWIND $rows AS row
+				MATCH (a:Actor {tmdb_id: row.id})
+				SET a.bacon_number = row.n`,
+				map[string]any{"rows": rows})
+			return nil, err
+		})
+		if err != nil {
+			return written, fmt.Errorf("error setting bacon numbers: %w", err)
+		}
+		written += len(batch)
+		if progress != nil {
+			progress(written)
+		}
+	}
+
+	err = d.SetMeta(ctx, baconMetaKey, map[string]any{"center": center, "computed_at": time.Now().UTC()})
+	return written, err
+}
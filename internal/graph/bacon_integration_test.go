@@ -0,0 +1,89 @@
+//go:build integration
+
+package graph
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+// readBaconNumbers returns every actor's bacon_number, keyed by TMDb id.
+func readBaconNumbers(t *testing.T) map[int]int {
+	t.Helper()
+	ctx := context.Background()
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, "MATCH (a:Actor) WHERE a.bacon_number IS NOT NULL RETURN a.tmdb_id AS id, a.bacon_number AS n", nil)
+	if err != nil {
+		t.Fatalf("failed to read bacon numbers: %v", err)
+	}
+	numbers := make(map[int]int)
+	for result.Next(ctx) {
+		id, _ := result.Record().Get("id")
+		n, _ := result.Record().Get("n")
+		numbers[int(id.(int64))] = int(n.(int64))
+	}
+	if err := result.Err(); err != nil {
+		t.Fatalf("failed to read bacon numbers: %v", err)
+	}
+	return numbers
+}
+
+func TestBaconNumbers(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// A chain 1-2-3-4-5 with a shortcut 1-3, and 6 on its own.
+	for id := 1; id <= 6; id++ {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)})
+	}
+	for i, pair := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {4, 5}, {1, 3}} {
+		testDriver.CreateCostarEdge(ctx, pair[0], pair[1], models.Movie{TmdbID: 100 + i, Title: fmt.Sprintf("Movie %d", i), Year: 2000})
+	}
+
+	distances, err := testDriver.DistancesFrom(ctx, 1, 2)
+	if err != nil {
+		t.Fatalf("DistancesFrom failed: %v", err)
+	}
+	if want := map[int]int{1: 0, 2: 1, 3: 1, 4: 2}; !maps.Equal(distances, want) {
+		t.Errorf("expected %v within two hops, got %v", want, distances)
+	}
+
+	distances, err = testDriver.DistancesFrom(ctx, 1, MaxDistancesDepth)
+	if err != nil {
+		t.Fatalf("DistancesFrom failed: %v", err)
+	}
+	want := map[int]int{1: 0, 2: 1, 3: 1, 4: 2, 5: 3}
+	if !maps.Equal(distances, want) {
+		t.Fatalf("expected %v, got %v", want, distances)
+	}
+
+	// An earlier run's number on the isolated actor has to go.
+	testDriver.SetBaconNumbers(ctx, 6, map[int]int{6: 0}, 10, nil)
+
+	var progress []int
+	written, err := testDriver.SetBaconNumbers(ctx, 1, distances, 2, func(n int) { progress = append(progress, n) })
+	if err != nil {
+		t.Fatalf("SetBaconNumbers failed: %v", err)
+	}
+	if written != 5 || len(progress) != 3 || progress[2] != 5 {
+		t.Errorf("expected five actors written in three batches, got %d with progress %v", written, progress)
+	}
+	if got := readBaconNumbers(t); !maps.Equal(got, want) {
+		t.Errorf("expected stored numbers %v, got %v", want, got)
+	}
+	if meta, err := testDriver.GetMeta(ctx, baconMetaKey); err != nil || meta["center"] != int64(1) {
+		t.Errorf("expected center 1 recorded, got %v, %v", meta, err)
+	}
+
+	if _, err := testDriver.DistancesFrom(ctx, 1, MaxDistancesDepth+1); err == nil {
+		t.Error("expected an error past the depth bound")
+	}
+}
@@ -0,0 +1,162 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// The graph stores COSTARRED edges in one of two models, picked by
+// config.DBConfig.CompactEdges:
+//
+//   - per movie (default): one edge per shared movie, keyed by tmdb_movie_id
+//     and carrying movie_title, year and decade.
+//   - compact: one edge per actor pair carrying parallel movie_ids, titles and
+//     years lists plus movie_count. movie_title, year and decade hold the most
+//     recent shared movie, so path queries read a representative movie for each hop
+//     the same way in both models.
+//
+// Both keep the canonical low-to-high id direction. Switching an existing
+// graph to compact needs CompactCostarEdges first; the write queries below
+// assume every edge is already in the configured model.
+
+// perMovieEdgeCypher upserts one edge per row of $pairs, each a map of idA,
+// idB (idA < idB), movieID, title, year and decade. A null decade (unknown
+// year) leaves the property unset.
+const perMovieEdgeCypher = `
+	UNWIND $pairs AS p
+	MATCH (a:Actor {tmdb_id: p.idA}), (b:Actor {tmdb_id: p.idB})
+	MERGE (a)-[r:COSTARRED {tmdb_movie_id: p.movieID}]->(b)
+	SET r.movie_title = p.title, r.year = p.year, r.decade = p.decade`
+
+// compactAppendCypher follows a MERGE of r and appends movie p to it unless
+// the edge already lists it, so re-ingesting a movie changes nothing.
+const compactAppendCypher = `
+	ON CREATE SET r.movie_ids = [], r.titles = [], r.years = []
+	WITH r, p
+	WHERE NOT p.movieID IN r.movie_ids
+	SET r.movie_ids = r.movie_ids + p.movieID,
+	    r.titles = r.titles + p.title,
+	    r.years = r.years + p.year
+	SET r.movie_count = size(r.movie_ids)
+	FOREACH (_ IN CASE WHEN r.year IS NULL OR p.year >= r.year THEN [1] ELSE [] END |
+	  SET r.movie_title = p.title, r.year = p.year, r.decade = p.decade)`
+
+// compactEdgeCypher is perMovieEdgeCypher for the compact model.
+const compactEdgeCypher = `
+	UNWIND $pairs AS p
+	MATCH (a:Actor {tmdb_id: p.idA}), (b:Actor {tmdb_id: p.idB})
+	MERGE (a)-[r:COSTARRED]->(b)` + compactAppendCypher
+
+// compactMergeCypher re-points a duplicate actor's compact edges at keep,
+// appending each movie to keep's edge with the same co-star.
+const compactMergeCypher = `
+	MATCH (keep:Actor {tmdb_id: $keepID}), (dup:Actor {tmdb_id: $mergeID})
+	MATCH (dup)-[old:COSTARRED]-(other:Actor)
+	WHERE other <> keep
+	UNWIND range(0, size(old.movie_ids) - 1) AS i
+	WITH keep, other, {movieID: old.movie_ids[i], title: old.titles[i], year: old.years[i],
+	                   decade: CASE WHEN old.years[i] > 0 THEN old.years[i] - old.years[i] % 10 END} AS p
+	WITH CASE WHEN keep.tmdb_id < other.tmdb_id THEN keep ELSE other END AS a,
+	     CASE WHEN keep.tmdb_id < other.tmdb_id THEN other ELSE keep END AS b, p
+	MERGE (a)-[r:COSTARRED]->(b)` + compactAppendCypher
+
+// compactDeleteMovieCypher takes movie $movie off every compact edge that
+// lists it. An edge left with no movies is deleted; the rest get their count
+// and most recent movie recomputed, ties going to the later entry as in
+// compactAppendCypher.
+const compactDeleteMovieCypher = `
+	MATCH ()-[r:COSTARRED]->()
+	WHERE $movie IN r.movie_ids
+	WITH r, [i IN range(0, size(r.movie_ids) - 1) WHERE r.movie_ids[i] <> $movie] AS keep
+	CALL (r, keep) {
+	  WITH r WHERE size(keep) = 0
+	  DELETE r
+	}
+	CALL (r, keep) {
+	  WITH r, keep WHERE size(keep) > 0
+	  SET r.movie_ids = [i IN keep | r.movie_ids[i]],
+	      r.titles = [i IN keep | r.titles[i]],
+	      r.years = [i IN keep | r.years[i]]
+	  WITH r, reduce(top = 0, i IN range(0, size(r.years) - 1) |
+	                 CASE WHEN r.years[i] >= r.years[top] THEN i ELSE top END) AS top
+	  SET r.movie_count = size(r.movie_ids),
+	      r.movie_title = r.titles[top],
+	      r.year = r.years[top],
+	      r.decade = CASE WHEN r.years[top] > 0 THEN r.years[top] - r.years[top] % 10 END
+	}
+	RETURN count(*) AS c`
+
+// edgeMoviesCypher expands the edge r into a list of {id, title, year} maps,
+// one per movie it records, in either model. A null r yields a single map of
+// nulls.
+const edgeMoviesCypher = `CASE WHEN r.movie_ids IS NULL
+		THEN [{id: r.tmdb_movie_id, title: r.movie_title, year: r.year}]
+		ELSE [i IN range(0, size(r.movie_ids) - 1) | {id: r.movie_ids[i], title: r.titles[i], year: r.years[i]}]
+		END`
+
+// edgeCypher returns the edge upsert query for the configured model.
+func (d *Driver) edgeCypher() string {
+	if d.compactEdges {
+		return compactEdgeCypher
+	}
+	return perMovieEdgeCypher
+}
+
+// CompactCostarEdges migrates per-movie edges to the compact model, folding
+// every per-movie edge between a pair into that pair's compact edge. It is
+// safe to re-run and returns the number of per-movie edges it folded. The
+// migration runs in one transaction, so very large graphs need a matching
+// Neo4j transaction memory limit.
+func (d *Driver) CompactCostarEdges(ctx context.Context) (_ int, err error) {
+	cypher := `
+		MATCH (a:Actor)-[old:COSTARRED]-(b:Actor)
+		WHERE old.movie_ids IS NULL AND a.tmdb_id < b.tmdb_id
+		WITH a, b, collect(old) AS olds
+		WITH a, b, olds,
+		     [o IN olds | {movieID: o.tmdb_movie_id, title: o.movie_title, year: o.year, decade: o.decade}] AS movies
+		FOREACH (o IN olds | DELETE o)
+		WITH a, b, movies
+		UNWIND movies AS p
+		MERGE (a)-[r:COSTARRED]->(b)` + compactAppendCypher
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "CompactCostarEdges", cypher)
+	defer func() {
+		d.observe(ctx, "CompactCostarEdges", start, err)
+		span.End()
+	}()
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+
+	folded, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		result, err := tx.Run(ctx, "MATCH ()-[r:COSTARRED]->() WHERE r.movie_ids IS NULL RETURN count(r) AS c", nil)
+		if err != nil {
+			return 0, fmt.Errorf("error counting per-movie edges: %w", err)
+		}
+		record, err := result.Single(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("error counting per-movie edges: %w", err)
+		}
+		count, _ := record.Get("c")
+
+		if _, err = tx.Run(ctx, cypher, nil); err != nil {
+			return 0, fmt.Errorf("error folding per-movie edges: %w", err)
+		}
+		n, _ := count.(int64)
+		return int(n), nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("error compacting costar edges: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("edges.folded", folded.(int)))
+	return folded.(int), nil
+}
@@ -0,0 +1,188 @@
+//go:build integration
+
+package graph
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+func useCompactEdges(t *testing.T) {
+	t.Helper()
+	testDriver.compactEdges = true
+	t.Cleanup(func() { testDriver.compactEdges = false })
+}
+
+// compactEdge is the single edge between two actors as stored in the compact
+// model.
+type compactEdge struct {
+	count    int64
+	movieIDs []any
+	titles   []any
+	title    string
+	year     int64
+}
+
+func readCompactEdge(t *testing.T, idA, idB int) compactEdge {
+	t.Helper()
+	ctx := context.Background()
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx,
+		`MATCH (:Actor {tmdb_id: $idA})-[r:COSTARRED]-(:Actor {tmdb_id: $idB})
+		 WITH collect(r) AS rs
+		 RETURN size(rs) AS c, rs[0].movie_ids AS ids, rs[0].titles AS titles,
+		        rs[0].movie_title AS title, rs[0].year AS year`,
+		map[string]any{"idA": idA, "idB": idB},
+	)
+	if err != nil {
+		t.Fatalf("verification query failed: %v", err)
+	}
+	record, err := result.Single(ctx)
+	if err != nil {
+		t.Fatalf("expected exactly one record: %v", err)
+	}
+	var e compactEdge
+	c, _ := record.Get("c")
+	ids, _ := record.Get("ids")
+	titles, _ := record.Get("titles")
+	title, _ := record.Get("title")
+	year, _ := record.Get("year")
+	e.count, _ = c.(int64)
+	e.movieIDs, _ = ids.([]any)
+	e.titles, _ = titles.([]any)
+	e.title, _ = title.(string)
+	e.year, _ = year.(int64)
+	return e
+}
+
+func TestIngestMovieCast_CompactAppendsOnReingest(t *testing.T) {
+	clearGraph(t)
+	useCompactEdges(t)
+	ctx := context.Background()
+
+	cast := []models.Actor{{TmdbID: 1, Name: "Brad Pitt"}, {TmdbID: 2, Name: "Edward Norton"}}
+	fightClub := models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999}
+	keepingTheFaith := models.Movie{TmdbID: 2103, Title: "Keeping the Faith", Year: 2000}
+
+	for _, m := range []models.Movie{fightClub, keepingTheFaith, fightClub} {
+		if err := testDriver.IngestMovieCast(ctx, m, cast); err != nil {
+			t.Fatalf("IngestMovieCast(%s) failed: %v", m.Title, err)
+		}
+	}
+	// The swapped single-edge path lands on the same edge.
+	if err := testDriver.CreateCostarEdge(ctx, 2, 1, keepingTheFaith); err != nil {
+		t.Fatalf("CreateCostarEdge failed: %v", err)
+	}
+
+	e := readCompactEdge(t, 1, 2)
+	if e.count != 1 {
+		t.Fatalf("expected 1 edge between the pair, got %d", e.count)
+	}
+	if !slices.Equal(e.movieIDs, []any{int64(550), int64(2103)}) {
+		t.Errorf("expected movie_ids [550 2103] with no duplicates, got %v", e.movieIDs)
+	}
+	if !slices.Equal(e.titles, []any{"Fight Club", "Keeping the Faith"}) {
+		t.Errorf("expected titles to line up with movie_ids, got %v", e.titles)
+	}
+	if e.title != "Keeping the Faith" || e.year != 2000 {
+		t.Errorf("expected the newest movie as representative, got %q (%d)", e.title, e.year)
+	}
+}
+
+func TestCompactEdges_Reads(t *testing.T) {
+	clearGraph(t)
+	useCompactEdges(t)
+	ctx := context.Background()
+
+	pitt := models.Actor{TmdbID: 1, Name: "Brad Pitt"}
+	norton := models.Actor{TmdbID: 2, Name: "Edward Norton"}
+	carter := models.Actor{TmdbID: 3, Name: "Helena Bonham Carter"}
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999}, []models.Actor{pitt, norton, carter})
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 2103, Title: "Keeping the Faith", Year: 2000}, []models.Actor{pitt, norton})
+
+	costars, err := testDriver.GetCostars(ctx, 1, 10)
+	if err != nil {
+		t.Fatalf("GetCostars failed: %v", err)
+	}
+	if len(costars) != 2 || costars[0].Actor.TmdbID != 2 || costars[0].SharedMovies != 2 || costars[1].SharedMovies != 1 {
+		t.Errorf("expected Norton with 2 shared movies then Carter with 1, got %+v", costars)
+	}
+
+	profile, err := testDriver.GetActor(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetActor failed: %v", err)
+	}
+	if profile.Connections != 2 || len(profile.Movies) != 2 {
+		t.Errorf("expected 2 connections and 2 movies, got %d and %+v", profile.Connections, profile.Movies)
+	}
+
+	steps, err := testDriver.ShortestPath(ctx, 2, 3)
+	if err != nil {
+		t.Fatalf("ShortestPath failed: %v", err)
+	}
+	if len(steps) != 3 || steps[1].MovieTitle != "Fight Club" {
+		t.Errorf("expected a one-hop path through Fight Club, got %+v", steps)
+	}
+}
+
+func TestCompactCostarEdges(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	cast := []models.Actor{{TmdbID: 1, Name: "Brad Pitt"}, {TmdbID: 2, Name: "Edward Norton"}}
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 2103, Title: "Keeping the Faith", Year: 2000}, cast)
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999}, cast)
+
+	folded, err := testDriver.CompactCostarEdges(ctx)
+	if err != nil {
+		t.Fatalf("CompactCostarEdges failed: %v", err)
+	}
+	if folded != 2 {
+		t.Errorf("expected 2 per-movie edges folded, got %d", folded)
+	}
+
+	e := readCompactEdge(t, 1, 2)
+	if e.count != 1 || len(e.movieIDs) != 2 {
+		t.Fatalf("expected one edge listing 2 movies, got %d edges and %v", e.count, e.movieIDs)
+	}
+	if e.title != "Keeping the Faith" {
+		t.Errorf("expected the newest movie as representative, got %q", e.title)
+	}
+
+	// Re-running finds nothing left to fold, and compact ingest keeps appending.
+	if folded, err = testDriver.CompactCostarEdges(ctx); err != nil || folded != 0 {
+		t.Errorf("expected a no-op second run, got %d, %v", folded, err)
+	}
+	useCompactEdges(t)
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 1903, Title: "The Wedding", Year: 2008}, cast)
+	if e := readCompactEdge(t, 1, 2); len(e.movieIDs) != 3 || e.year != 2008 {
+		t.Errorf("expected 3 movies with 2008 as representative, got %v (%d)", e.movieIDs, e.year)
+	}
+}
+
+func TestMergeActors_Compact(t *testing.T) {
+	clearGraph(t)
+	useCompactEdges(t)
+	ctx := context.Background()
+
+	norton := models.Actor{TmdbID: 2, Name: "Edward Norton"}
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999}, []models.Actor{{TmdbID: 1, Name: "Brad Pitt"}, norton})
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 2103, Title: "Keeping the Faith", Year: 2000}, []models.Actor{{TmdbID: 9, Name: "Brad Pitt"}, norton})
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999}, []models.Actor{{TmdbID: 9, Name: "Brad Pitt"}, norton})
+
+	if err := testDriver.MergeActors(ctx, 1, 9); err != nil {
+		t.Fatalf("MergeActors failed: %v", err)
+	}
+
+	e := readCompactEdge(t, 1, 2)
+	if e.count != 1 || !slices.Equal(e.movieIDs, []any{int64(550), int64(2103)}) {
+		t.Errorf("expected one edge listing [550 2103], got %d edges and %v", e.count, e.movieIDs)
+	}
+}
@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+)
+
+// maxStartupBackoff caps the doubling wait between Connect's attempts.
+const maxStartupBackoff = 30 * time.Second
+
+// Connect is NewDriver followed by SetupSchema, retried while Neo4j comes up.
+// Under compose or Kubernetes the database often starts a moment after the
+// server, so a failed attempt is logged and tried again up to
+// cfg.DB.StartupRetries more times, waiting cfg.DB.StartupBackoff before the
+// first retry and doubling the wait each time. It returns the last error once
+// the retries run out, or ctx's if it ends first.
+func Connect(ctx context.Context, cfg config.Config, logger *slog.Logger) (*Driver, error) {
+	backoff := cfg.DB.StartupBackoff
+	var d *Driver
+	for attempt := 1; ; attempt++ {
+		err := func() error {
+			if d == nil {
+				var err error
+				if d, err = NewDriver(ctx, cfg); err != nil {
+					return err
+				}
+			}
+			return d.SetupSchema(ctx)
+		}()
+		if err == nil {
+			logger.Info("connected to neo4j", "attempt", attempt)
+			return d, nil
+		}
+
+		if attempt > cfg.DB.StartupRetries {
+			if d != nil {
+				d.Close(ctx)
+			}
+			return nil, fmt.Errorf("neo4j not ready after %d attempts: %w", attempt, err)
+		}
+		logger.Warn("neo4j not ready, retrying", "attempt", attempt, "retry_in", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			if d != nil {
+				d.Close(context.Background())
+			}
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxStartupBackoff)
+	}
+}
@@ -0,0 +1,136 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+// ErrNoDailyPair is returned by DailyPair when none of the day's candidate
+// pairs is the right distance apart, typically because the graph is small.
+var ErrNoDailyPair = errors.New("no actor pair suitable for a daily challenge")
+
+const (
+	// dailyPool is how many of the best-connected actors a daily pair is
+	// drawn from, so both ends are names players have a chance with.
+	dailyPool = 200
+	// dailyAttempts bounds the candidate pairs measured per day.
+	dailyAttempts = 40
+	// A daily pair is between dailyMinHops and dailyMaxHops apart: closer is
+	// too easy, further is a slog.
+	dailyMinHops = 3
+	dailyMaxHops = 5
+)
+
+// DailyChallenge is the pair of actors to connect on Date.
+type DailyChallenge struct {
+	Date    time.Time // midnight UTC
+	A, B    models.Actor
+	Degrees int
+}
+
+// DailyPair picks the challenge for date's UTC day: two of the dailyPool
+// best-connected actors whose shortest path is dailyMinHops to dailyMaxHops
+// long. The candidates are shuffled by a generator seeded with the day, so
+// every call for the same day on the same graph picks the same pair. It
+// returns ErrNoDailyPair if no candidate qualifies.
+func (d *Driver) DailyPair(ctx context.Context, date time.Time) (_ *DailyChallenge, err error) {
+	day := utcDay(date)
+	cypher := `
+		MATCH (a:Actor)
+		WITH a, COUNT { (a)-[:COSTARRED]-() } AS degree
+		WHERE degree > 0
+		ORDER BY degree DESC, a.tmdb_id
+		LIMIT $pool
+		RETURN a.tmdb_id AS id, a.name AS name`
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "DailyPair", cypher, attribute.String("day", day.Format(time.DateOnly)))
+	defer func() {
+		d.observe(ctx, "DailyPair", start, err)
+		span.End()
+	}()
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, map[string]any{"pool": dailyPool})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error reading daily candidates: %w", err)
+	}
+	records, err := result.Collect(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error reading daily candidates: %w", err)
+	}
+
+	pool := make([]models.Actor, len(records))
+	for i, rec := range records {
+		id, _ := rec.Get("id")
+		name, _ := rec.Get("name")
+		actorID, _ := id.(int64)
+		actorName, _ := name.(string)
+		pool[i] = models.Actor{TmdbID: int(actorID), Name: actorName}
+	}
+
+	for _, c := range dailyCandidates(len(pool), day, dailyAttempts) {
+		a, b := pool[c[0]], pool[c[1]]
+		hops, err := d.Distance(ctx, a.TmdbID, b.TmdbID, dailyMaxHops)
+		if err != nil {
+			return nil, err
+		}
+		if hops >= dailyMinHops {
+			return &DailyChallenge{Date: day, A: a, B: b, Degrees: hops}, nil
+		}
+	}
+	return nil, ErrNoDailyPair
+}
+
+// utcDay truncates t to midnight of its UTC day.
+func utcDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// dailyCandidates returns up to count distinct pairs of indexes into a pool of
+// n actors, in an order fixed by day.
+func dailyCandidates(n int, day time.Time, count int) [][2]int {
+	if n < 2 {
+		return nil
+	}
+	seed := uint64(day.Unix())
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	// Each unordered pair is tried once; the cap on tries keeps a small pool,
+	// with fewer distinct pairs than count, from looping forever.
+	seen := make(map[[2]int]bool)
+	var pairs [][2]int
+	for range count * 4 {
+		if len(pairs) == count {
+			break
+		}
+		i := rng.IntN(n)
+		j := rng.IntN(n - 1)
+		if j >= i {
+			j++
+		}
+		key := [2]int{min(i, j), max(i, j)}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		pairs = append(pairs, [2]int{i, j})
+	}
+	return pairs
+}
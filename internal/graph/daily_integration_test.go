@@ -0,0 +1,46 @@
+//go:build integration
+
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+func TestDailyPair(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+
+	if _, err := testDriver.DailyPair(ctx, day); !errors.Is(err, ErrNoDailyPair) {
+		t.Fatalf("expected ErrNoDailyPair on an empty graph, got %v", err)
+	}
+
+	// A chain 1 - 2 - ... - 8. Ranked by degree, then id, the pool is
+	// [2 3 4 5 6 7 1 8]; the day's first candidate, indexes (2, 7), is
+	// actors 4 and 8, four hops apart.
+	for id := 1; id <= 8; id++ {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)})
+		if id > 1 {
+			testDriver.CreateCostarEdge(ctx, id-1, id, models.Movie{TmdbID: 100 + id, Title: fmt.Sprintf("Movie %d", id), Year: 2000})
+		}
+	}
+
+	for _, at := range []time.Time{day, day.Add(23 * time.Hour)} {
+		got, err := testDriver.DailyPair(ctx, at)
+		if err != nil {
+			t.Fatalf("DailyPair(%s) failed: %v", at, err)
+		}
+		if got.A.TmdbID != 4 || got.B.TmdbID != 8 || got.Degrees != 4 || got.A.Name != "Actor 4" {
+			t.Errorf("DailyPair(%s): expected Actor 4 to Actor 8 in 4, got %+v", at, got)
+		}
+		if !got.Date.Equal(day) {
+			t.Errorf("expected the challenge dated %s, got %s", day, got.Date)
+		}
+	}
+}
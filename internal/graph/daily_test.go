@@ -0,0 +1,51 @@
+package graph
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDailyCandidates(t *testing.T) {
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+
+	// Pinned: changing the generator or seed would reshuffle every past and
+	// future challenge.
+	want := [][2]int{{2, 7}, {6, 0}, {7, 1}, {0, 4}, {6, 2}}
+	if got := dailyCandidates(8, day, 5); !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if next := dailyCandidates(8, day.AddDate(0, 0, 1), 5); slices.Equal(next, want) {
+		t.Error("expected the next day to draw different pairs")
+	}
+
+	// Three actors have three pairs, however many are asked for.
+	small := dailyCandidates(3, day, 10)
+	if len(small) != 3 {
+		t.Errorf("expected all 3 pairs of a 3-actor pool, got %v", small)
+	}
+	for _, p := range small {
+		if p[0] == p[1] || p[0] > 2 || p[1] > 2 {
+			t.Errorf("invalid pair %v", p)
+		}
+	}
+
+	if got := dailyCandidates(1, day, 5); got != nil {
+		t.Errorf("expected no pairs from a single actor, got %v", got)
+	}
+}
+
+func TestUTCDay(t *testing.T) {
+	la := time.FixedZone("PDT", -7*60*60)
+	want := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	for _, in := range []time.Time{
+		time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 15, 23, 59, 59, 0, time.UTC),
+		time.Date(2026, 3, 14, 20, 0, 0, 0, la), // 03:00 UTC on the 15th
+	} {
+		if got := utcDay(in); !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("utcDay(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
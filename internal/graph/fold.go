@@ -0,0 +1,59 @@
+package graph
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldDiacritics strips combining marks, so "Penélope" becomes "Penelope".
+func foldDiacritics(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(b.String())
+}
+
+// normalizeName reduces an actor name to the form stored in normalized_name
+// and searched by the actor_name index: lowercase, without accents, with
+// apostrophes and periods dropped so "O'Brien" reads "obrien", and any other
+// punctuation turned into a space between words.
+func normalizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\'' || r == '’' || r == 'ʼ' || r == '.':
+			return -1
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return unicode.ToLower(r)
+		default:
+			return ' '
+		}
+	}, foldDiacritics(name))
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// luceneSyntax holds the characters Lucene's query parser reads as syntax.
+const luceneSyntax = `+-&|!(){}[]^"~*?:\/`
+
+// escapeLucene turns a search box query into a Lucene query for the same
+// words: folded and lowercased as a standard-folding index stores them, which
+// also keeps AND, OR and NOT from reading as operators, with every syntax
+// character backslash-escaped. It returns "" for a query with no words.
+func escapeLucene(query string) string {
+	if !strings.ContainsFunc(query, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(foldDiacritics(query)) {
+		if strings.ContainsRune(luceneSyntax, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
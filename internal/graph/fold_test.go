@@ -0,0 +1,50 @@
+package graph
+
+import "testing"
+
+func TestFoldDiacritics(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"Penélope Cruz", "Penelope Cruz"},
+		{"Zoë Saldaña", "Zoe Saldana"},
+		{"Mads Mikkelsen", "Mads Mikkelsen"},
+		{"Penélope", "Penelope"}, // decomposed e + combining acute
+		{"Ōkōchi 大河内", "Okochi 大河内"},
+	} {
+		if got := foldDiacritics(tc.in); got != tc.want {
+			t.Errorf("foldDiacritics(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"Dylan O'Brien", "dylan obrien"},
+		{"Conan O’Brien", "conan obrien"},
+		{"Robert De Niro", "robert de niro"},
+		{"J.K. Simmons", "jk simmons"},
+		{"Jean-Claude Van Damme", "jean claude van damme"},
+		{"Penélope Cruz", "penelope cruz"},
+		{"  Will   Smith (I) ", "will smith i"},
+		{"'", ""},
+	} {
+		if got := normalizeName(tc.in); got != tc.want {
+			t.Errorf("normalizeName(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestEscapeLucene(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"Schindler's List", "schindler's list"},
+		{"Amélie", "amelie"},
+		{"Fight Club AND NOT Se7en", "fight club and not se7en"},
+		{"Mission: Impossible", `mission\: impossible`},
+		{`What?! (1972) [x] "y" ~z ^2 a+b c-d e&&f g||h i/j k\l*`, `what\?\! \(1972\) \[x\] \"y\" \~z \^2 a\+b c\-d e\&\&f g\|\|h i\/j k\\l\*`},
+		{"  WALL·E  ", "wall·e"},
+		{"?!", ""},
+	} {
+		if got := escapeLucene(tc.in); got != tc.want {
+			t.Errorf("escapeLucene(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -4,8 +4,12 @@
 package graph
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -19,8 +23,17 @@ import (
 
 	"github.com/mark-c-hall/degrees-of-separation/internal/config"
 	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/internal/requestid"
 )
 
+// ErrNotEnoughActors is returned when the graph has too few connected actors
+// to pick a pair from.
+var ErrNotEnoughActors = errors.New("not enough connected actors in graph")
+
+// QueryHook is called after every instrumented query with the query name,
+// its wall-clock duration, and the error it returned (nil on success).
+type QueryHook func(name string, elapsed time.Duration, err error)
+
 // Driver wraps the Neo4j driver with OTel tracing and metrics instruments.
 type Driver struct {
 	driver        neo4j.Driver
@@ -28,6 +41,15 @@ type Driver struct {
 	queryDuration metric.Float64Histogram
 	actorsGauge   metric.Int64ObservableGauge
 	edgesGauge    metric.Int64ObservableGauge
+	queryHook     QueryHook
+	logger        *slog.Logger
+	slowQuery     time.Duration
+	compactEdges  bool
+	ready         readyCache
+	// searchAnalyzer and searchMinScore tune SearchActors; see the config
+	// fields of the same names.
+	searchAnalyzer string
+	searchMinScore float64
 }
 
 type PathStep struct {
@@ -36,11 +58,52 @@ type PathStep struct {
 	MovieYear  int
 }
 
+// ActorProfile is an actor with the connections and movies recorded for them
+// in the graph.
+type ActorProfile struct {
+	Actor       models.Actor
+	Connections int // distinct co-stars
+	// ProfilePath is the photo stored on the actor when TMDb enrichment ran;
+	// empty otherwise.
+	ProfilePath string
+	Movies      []models.Movie
+}
+
+// Costar is a co-star of some actor and the number of movies they share.
+type Costar struct {
+	Actor        models.Actor
+	SharedMovies int
+	// Movie is the most recent movie they share, to label the connection.
+	Movie models.Movie
+}
+
+// SearchResult is an actor matching a search, with what the dropdown shows to
+// tell namesakes apart. ProfilePath and Popularity are empty for actors that
+// haven't been enriched with TMDb person details. KnownFor is the shared movie
+// with the actor's best-connected co-star, or empty for an actor with none.
+type SearchResult struct {
+	Actor       models.Actor
+	ProfilePath string
+	Popularity  float64
+	KnownFor    string
+	Score       float64 // the fulltext match score, comparable within one search
+}
+
+// NeighborEdge is one co-star of a queried actor, with a movie they shared.
+type NeighborEdge struct {
+	FromID     int
+	Actor      models.Actor
+	MovieTitle string
+	MovieYear  int
+}
+
 type Stats struct {
 	ActorCount         int
 	EdgeCount          int
 	MostConnectedActor string
 	MostConnectedCount int
+	// LastIngestAt is when an ingest run last completed; zero if none has.
+	LastIngestAt time.Time
 }
 
 func NewDriver(ctx context.Context, cfg config.Config) (*Driver, error) {
@@ -53,10 +116,17 @@ func NewDriver(ctx context.Context, cfg config.Config) (*Driver, error) {
 	}
 
 	if err = driver.VerifyAuthentication(ctx, nil); err != nil {
+		driver.Close(ctx)
 		return nil, fmt.Errorf("error authenticating into neo4j: %w", err)
 	}
 
-	d := &Driver{driver: driver}
+	d := &Driver{
+		driver:         driver,
+		slowQuery:      cfg.DB.SlowQuery,
+		compactEdges:   cfg.DB.CompactEdges,
+		searchAnalyzer: cmp.Or(cfg.DB.SearchAnalyzer, DefaultSearchAnalyzer),
+		searchMinScore: cfg.DB.SearchMinScore,
+	}
 
 	// Instruments are resolved against the global providers set by internal/telemetry.
 	meter := otel.Meter("degrees-of-separation/graph")
@@ -104,22 +174,89 @@ func NewDriver(ctx context.Context, cfg config.Config) (*Driver, error) {
 	return d, nil
 }
 
-func (d *Driver) SetupSchema(ctx context.Context) error {
-	queries := []string{
-		"CREATE CONSTRAINT actor_tmdb_id IF NOT EXISTS FOR (a:Actor) REQUIRE a.tmdb_id IS UNIQUE",
-		"CREATE FULLTEXT INDEX actor_name IF NOT EXISTS FOR (a:Actor) ON EACH [a.name]",
+// SetQueryHook installs a callback invoked after each instrumented query.
+// It must be called before the driver is shared between goroutines.
+func (d *Driver) SetQueryHook(hook QueryHook) {
+	d.queryHook = hook
+}
+
+// SetLogger installs the logger failed and slow queries are reported to.
+// Without one, they are only visible in traces and metrics. Like
+// SetQueryHook, it must be called before the driver is shared between
+// goroutines.
+func (d *Driver) SetLogger(logger *slog.Logger) {
+	d.logger = logger
+}
+
+// txTimeout turns ctx's deadline into a transaction timeout, so Neo4j
+// abandons a query the caller has stopped waiting for rather than running it
+// to completion. Without a deadline the server's default applies.
+func txTimeout(ctx context.Context) []func(*neo4j.TransactionConfig) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
 	}
+	// Zero would mean "no timeout"; an expired deadline fails on ctx anyway.
+	remaining := max(time.Until(deadline), time.Millisecond)
+	return []func(*neo4j.TransactionConfig){neo4j.WithTxTimeout(remaining)}
+}
 
-	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
-	defer session.Close(ctx)
+// startSpan starts the client span for the query name, as a child of
+// whatever span ctx carries (the HTTP request's, when there is one). It tags
+// the span with the Cypher text, attrs and the request_id, if any. With
+// telemetry disabled the global tracer is a no-op and so is this.
+func (d *Driver) startSpan(ctx context.Context, name, cypher string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, semconv.DBSystemNeo4j, semconv.DBQueryText(cypher))
+	if id := requestid.FromContext(ctx); id != "" {
+		attrs = append(attrs, attribute.String("request_id", id))
+	}
+	return d.tracer.Start(ctx, "neo4j."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
 
-	for _, query := range queries {
-		if _, err := session.Run(ctx, query, nil); err != nil {
-			return fmt.Errorf("error running schema query: %w", err)
-		}
+// observe records a finished query against the OTel histogram and the query
+// hook, and logs it if it failed or ran for at least the slow-query
+// threshold. params are slog key-value pairs identifying what the query was
+// asked, so a pathological input can be reproduced from the log line. Queries
+// run for an HTTP request carry its request_id; ingest queries have none.
+func (d *Driver) observe(ctx context.Context, name string, start time.Time, err error, params ...any) {
+	elapsed := time.Since(start)
+	d.queryDuration.Record(ctx, elapsed.Seconds(),
+		metric.WithAttributes(attribute.String("query_name", name)))
+	if d.queryHook != nil {
+		d.queryHook(name, elapsed, err)
+	}
+	if d.logger == nil {
+		return
 	}
 
-	return nil
+	failed := err != nil && !errors.Is(err, context.Canceled) // a cancelled query is the client leaving
+	slow := d.slowQuery > 0 && elapsed >= d.slowQuery
+	if !failed && !slow {
+		return
+	}
+	args := append([]any{"query", name, "duration_ms", elapsed.Milliseconds()}, params...)
+	if id := requestid.FromContext(ctx); id != "" {
+		args = append(args, "request_id", id)
+	}
+	if failed {
+		d.logger.ErrorContext(ctx, "query failed", append(args, "error", err)...)
+		return
+	}
+	d.logger.WarnContext(ctx, "slow query", args...)
+}
+
+// SetupSchema brings the schema up to date by running any pending
+// migrations, then rebuilds the actor name index if it was built with an
+// analyzer other than the configured one. It is safe to call on every
+// startup.
+func (d *Driver) SetupSchema(ctx context.Context) error {
+	if _, err := d.RunMigrations(ctx); err != nil {
+		return err
+	}
+	return d.ensureSearchAnalyzer(ctx)
 }
 
 func (d *Driver) Close(ctx context.Context) error {
@@ -131,8 +268,8 @@ func (d *Driver) VerifyConnectivity(ctx context.Context) error {
 }
 
 func (d *Driver) UpsertActor(ctx context.Context, actor models.Actor) error {
-	cypher := "MERGE (a:Actor {tmdb_id: $id}) SET a.name = $name"
-	params := map[string]any{"id": actor.TmdbID, "name": actor.Name}
+	cypher := "MERGE (a:Actor {tmdb_id: $id}) SET a.name = $name, a.normalized_name = $normalized"
+	params := map[string]any{"id": actor.TmdbID, "name": actor.Name, "normalized": normalizeName(actor.Name)}
 
 	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
@@ -145,23 +282,31 @@ func (d *Driver) UpsertActor(ctx context.Context, actor models.Actor) error {
 	return nil
 }
 
+// canonicalPair orders two actor ids so a collaboration is always stored as
+// one edge from the lower id to the higher, whichever order it is seen in.
+func canonicalPair(a, b int) (int, int) {
+	return min(a, b), max(a, b)
+}
+
+// CreateCostarEdge links two actors for a movie. The edge direction is
+// canonical, so calling it with the ids swapped is a no-op.
 func (d *Driver) CreateCostarEdge(ctx context.Context, actorA, actorB int, movie models.Movie) error {
-	cypher := `
-		MATCH (a:Actor {tmdb_id: $idA}), (b:Actor {tmdb_id: $idB})
-		MERGE (a)-[r:COSTARRED {tmdb_movie_id: $movieID}]->(b)
-		SET r.movie_title = $title, r.year = $year`
+	actorA, actorB = canonicalPair(actorA, actorB)
 	params := map[string]any{
-		"idA":     actorA,
-		"idB":     actorB,
-		"movieID": movie.TmdbID,
-		"title":   movie.Title,
-		"year":    movie.Year,
+		"pairs": []map[string]any{{
+			"idA":     actorA,
+			"idB":     actorB,
+			"movieID": movie.TmdbID,
+			"title":   movie.Title,
+			"year":    movie.Year,
+			"decade":  decadeParam(movie),
+		}},
 	}
 
 	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
 
-	_, err := session.Run(ctx, cypher, params)
+	_, err := session.Run(ctx, d.edgeCypher(), params)
 	if err != nil {
 		return fmt.Errorf("error creating costar edge: %w", err)
 	}
@@ -169,39 +314,43 @@ func (d *Driver) CreateCostarEdge(ctx context.Context, actorA, actorB int, movie
 	return nil
 }
 
+// decadeParam is movie's decade as an edge property value, nil when the year
+// is unknown so the edge carries no decade rather than a bogus 0.
+func decadeParam(movie models.Movie) any {
+	if d := movie.Decade(); d != 0 {
+		return d
+	}
+	return nil
+}
+
 // IngestMovieCast upserts actors and their co-star edges in a single write transaction.
-func (d *Driver) IngestMovieCast(ctx context.Context, movie models.Movie, cast []models.Actor) error {
-	cypher := `UNWIND $actors AS a MERGE (act:Actor {tmdb_id: a.id}) SET act.name = a.name`
+func (d *Driver) IngestMovieCast(ctx context.Context, movie models.Movie, cast []models.Actor) (err error) {
+	cypher := `UNWIND $actors AS a MERGE (act:Actor {tmdb_id: a.id}) SET act.name = a.name, act.normalized_name = a.normalized`
 	start := time.Now()
-	ctx, span := d.tracer.Start(ctx, "neo4j.IngestMovieCast",
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithAttributes(
-			semconv.DBSystemNeo4j,
-			semconv.DBQueryText(cypher),
-			attribute.Int("cast.size", len(cast)),
-		),
-	)
+	ctx, span := d.startSpan(ctx, "IngestMovieCast", cypher, attribute.Int("cast.size", len(cast)))
 	defer func() {
-		d.queryDuration.Record(ctx, time.Since(start).Seconds(),
-			metric.WithAttributes(attribute.String("query_name", "IngestMovieCast")))
+		d.observe(ctx, "IngestMovieCast", start, err)
 		span.End()
 	}()
 
 	actors := make([]map[string]any, len(cast))
 	for i, a := range cast {
-		actors[i] = map[string]any{"id": a.TmdbID, "name": a.Name}
+		actors[i] = map[string]any{"id": a.TmdbID, "name": a.Name, "normalized": normalizeName(a.Name)}
 	}
 
 	n := len(cast)
+	decade := decadeParam(movie)
 	pairs := make([]map[string]any, 0, n*(n-1)/2)
 	for i := 0; i < n-1; i++ {
 		for j := i + 1; j < n; j++ {
+			idA, idB := canonicalPair(cast[i].TmdbID, cast[j].TmdbID)
 			pairs = append(pairs, map[string]any{
-				"idA":     cast[i].TmdbID,
-				"idB":     cast[j].TmdbID,
+				"idA":     idA,
+				"idB":     idB,
 				"movieID": movie.TmdbID,
 				"title":   movie.Title,
 				"year":    movie.Year,
+				"decade":  decade,
 			})
 		}
 	}
@@ -209,23 +358,14 @@ func (d *Driver) IngestMovieCast(ctx context.Context, movie models.Movie, cast [
 	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
 
-	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-		_, err := tx.Run(ctx,
-			"UNWIND $actors AS a MERGE (act:Actor {tmdb_id: a.id}) SET act.name = a.name",
-			map[string]any{"actors": actors},
-		)
+	_, err = session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		_, err := tx.Run(ctx, cypher, map[string]any{"actors": actors})
 		if err != nil {
 			return nil, fmt.Errorf("error batch upserting actors: %w", err)
 		}
 
 		if len(pairs) > 0 {
-			_, err = tx.Run(ctx,
-				`UNWIND $pairs AS p
-				 MATCH (a:Actor {tmdb_id: p.idA}), (b:Actor {tmdb_id: p.idB})
-				 MERGE (a)-[r:COSTARRED {tmdb_movie_id: p.movieID}]->(b)
-				 SET r.movie_title = p.title, r.year = p.year`,
-				map[string]any{"pairs": pairs},
-			)
+			_, err = tx.Run(ctx, d.edgeCypher(), map[string]any{"pairs": pairs})
 			if err != nil {
 				return nil, fmt.Errorf("error batch creating costar edges: %w", err)
 			}
@@ -243,8 +383,94 @@ func (d *Driver) IngestMovieCast(ctx context.Context, movie models.Movie, cast [
 	return nil
 }
 
+// MergeActors folds a duplicate actor node into the one being kept. Every
+// COSTARRED edge on mergeID is re-pointed at keepID unless keepID already has
+// an edge to the same co-star for the same movie (in either direction); edges
+// between the two nodes are dropped rather than becoming self-loops.
+// Re-pointed edges keep the canonical low-to-high id direction. With compact
+// edges, the duplicate's movies are appended to keepID's edge with each
+// co-star instead. The merged node is deleted in the same transaction.
+func (d *Driver) MergeActors(ctx context.Context, keepID, mergeID int) (err error) {
+	if keepID == mergeID {
+		return fmt.Errorf("cannot merge actor %d into itself", keepID)
+	}
+
+	cypher := `
+		MATCH (keep:Actor {tmdb_id: $keepID}), (dup:Actor {tmdb_id: $mergeID})
+		MATCH (dup)-[r:COSTARRED]-(other:Actor)
+		WHERE other <> keep
+		  AND NOT EXISTS {
+		    MATCH (keep)-[e:COSTARRED]-(other)
+		    WHERE e.tmdb_movie_id = r.tmdb_movie_id
+		  }
+		FOREACH (_ IN CASE WHEN keep.tmdb_id < other.tmdb_id THEN [1] ELSE [] END |
+		  MERGE (keep)-[n:COSTARRED {tmdb_movie_id: r.tmdb_movie_id}]->(other)
+		  SET n.movie_title = r.movie_title, n.year = r.year, n.decade = r.decade)
+		FOREACH (_ IN CASE WHEN keep.tmdb_id > other.tmdb_id THEN [1] ELSE [] END |
+		  MERGE (other)-[n:COSTARRED {tmdb_movie_id: r.tmdb_movie_id}]->(keep)
+		  SET n.movie_title = r.movie_title, n.year = r.year, n.decade = r.decade)`
+	if d.compactEdges {
+		cypher = compactMergeCypher
+	}
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "MergeActors", cypher,
+		attribute.Int("actor.keep", keepID),
+		attribute.Int("actor.merge", mergeID),
+	)
+	defer func() {
+		d.observe(ctx, "MergeActors", start, err)
+		span.End()
+	}()
+
+	params := map[string]any{"keepID": keepID, "mergeID": mergeID}
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+
+	_, err = session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		result, err := tx.Run(ctx,
+			`OPTIONAL MATCH (keep:Actor {tmdb_id: $keepID})
+			 OPTIONAL MATCH (dup:Actor {tmdb_id: $mergeID})
+			 RETURN keep IS NOT NULL AS hasKeep, dup IS NOT NULL AS hasDup`,
+			params,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up actors to merge: %w", err)
+		}
+		record, err := result.Single(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up actors to merge: %w", err)
+		}
+		hasKeep, _ := record.Get("hasKeep")
+		hasDup, _ := record.Get("hasDup")
+		if !hasKeep.(bool) {
+			return nil, fmt.Errorf("actor %d not found", keepID)
+		}
+		if !hasDup.(bool) {
+			return nil, fmt.Errorf("actor %d not found", mergeID)
+		}
+
+		if _, err = tx.Run(ctx, cypher, params); err != nil {
+			return nil, fmt.Errorf("error re-pointing costar edges: %w", err)
+		}
+
+		if _, err = tx.Run(ctx, "MATCH (dup:Actor {tmdb_id: $mergeID}) DETACH DELETE dup", params); err != nil {
+			return nil, fmt.Errorf("error deleting merged actor: %w", err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("error merging actors: %w", err)
+	}
+
+	return nil
+}
+
 // ShortestPath finds the shortest co-star chain between two actors.
-func (d *Driver) ShortestPath(ctx context.Context, actorA, actorB int) ([]PathStep, error) {
+func (d *Driver) ShortestPath(ctx context.Context, actorA, actorB int) (_ []PathStep, err error) {
 	cypher := `
 		MATCH (a:Actor {tmdb_id: $idA}), (b:Actor {tmdb_id: $idB}),
 		      p = shortestPath((a)-[:COSTARRED*]-(b))
@@ -252,27 +478,133 @@ func (d *Driver) ShortestPath(ctx context.Context, actorA, actorB int) ([]PathSt
 		       [r IN relationships(p) | {title: r.movie_title, year: r.year}] AS movies`
 
 	start := time.Now()
-	ctx, span := d.tracer.Start(ctx, "neo4j.ShortestPath",
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithAttributes(
-			semconv.DBSystemNeo4j,
-			semconv.DBQueryText(cypher),
-			attribute.Int("actor_a", actorA),
-			attribute.Int("actor_b", actorB),
-		),
+	ctx, span := d.startSpan(ctx, "ShortestPath", cypher,
+		attribute.Int("actor_a", actorA),
+		attribute.Int("actor_b", actorB),
 	)
 	defer func() {
-		d.queryDuration.Record(ctx, time.Since(start).Seconds(),
-			metric.WithAttributes(attribute.String("query_name", "ShortestPath")))
+		d.observe(ctx, "ShortestPath", start, err, "actor_a", actorA, "actor_b", actorB)
 		span.End()
 	}()
 
-	params := map[string]any{"idA": actorA, "idB": actorB}
+	return d.runPathQuery(ctx, span, cypher, map[string]any{"idA": actorA, "idB": actorB})
+}
+
+// ErrBrokenChain is returned by Chain when a step joins actors who never
+// co-starred, or names an actor not in the graph.
+var ErrBrokenChain = errors.New("chain has a step between actors who never co-starred")
+
+// ErrYearWindowUnsupported is returned by ShortestPathWithYearWindow and
+// ChronologicalPath on a compact-edge graph, where an edge stands for several
+// movies and so has no single year to compare.
+var ErrYearWindowUnsupported = errors.New("year window paths need per-movie edges")
+
+// MaxYearWindowDegrees caps the paths ShortestPathWithYearWindow considers.
+// Neo4j can't apply the window during its breadth-first search, since it
+// compares neighbouring edges, so without a cap a pair with no such path
+// would have it try every chain there is.
+const MaxYearWindowDegrees = 6
+
+// ShortestPathWithYearWindow finds the shortest co-star chain between two
+// actors, within MaxYearWindowDegrees, in which each movie was released
+// within windowYears of the one before it. Edges without a year can't be
+// checked and are never used. It returns nil, nil when no such chain exists.
+// Checking the candidate chains can still take a while on a dense graph, so
+// callers should bound the query with a context deadline.
+func (d *Driver) ShortestPathWithYearWindow(ctx context.Context, actorA, actorB, windowYears int) (_ []PathStep, err error) {
+	if windowYears < 0 {
+		return nil, fmt.Errorf("error finding shortest path: year window must not be negative, got %d", windowYears)
+	}
+	if d.compactEdges {
+		return nil, ErrYearWindowUnsupported
+	}
+
+	cypher := fmt.Sprintf(`
+		MATCH (a:Actor {tmdb_id: $idA}), (b:Actor {tmdb_id: $idB}),
+		      p = shortestPath((a)-[:COSTARRED*..%d]-(b))
+		WHERE all(r IN relationships(p) WHERE r.year IS NOT NULL)
+		  AND all(i IN range(0, length(p) - 2)
+		          WHERE abs(relationships(p)[i].year - relationships(p)[i + 1].year) <= $window)
+		RETURN [n IN nodes(p) | {id: n.tmdb_id, name: n.name}] AS actors,
+		       [r IN relationships(p) | {title: r.movie_title, year: r.year}] AS movies`, MaxYearWindowDegrees)
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "ShortestPathWithYearWindow", cypher,
+		attribute.Int("actor_a", actorA),
+		attribute.Int("actor_b", actorB),
+		attribute.Int("window_years", windowYears),
+	)
+	defer func() {
+		d.observe(ctx, "ShortestPathWithYearWindow", start, err,
+			"actor_a", actorA, "actor_b", actorB, "window_years", windowYears)
+		span.End()
+	}()
 
+	params := map[string]any{"idA": actorA, "idB": actorB, "window": windowYears}
+	return d.runPathQuery(ctx, span, cypher, params)
+}
+
+// MaxChronologicalDegrees caps the paths ChronologicalPath considers. Its
+// year check rules out the usual breadth-first search, so without a cap a
+// pair with no chronological path would have it try every chain there is.
+const MaxChronologicalDegrees = 6
+
+// IsChronological reports whether a path's movies are in release order, each
+// released no earlier than the one before. A movie without a year can't be
+// placed, so a path with one isn't.
+func IsChronological(steps []PathStep) bool {
+	prev := 0
+	for _, step := range steps {
+		if step.Actor != nil {
+			continue
+		}
+		if step.MovieYear <= 0 || step.MovieYear < prev {
+			return false
+		}
+		prev = step.MovieYear
+	}
+	return true
+}
+
+// ChronologicalPath finds the shortest co-star chain from actorA to actorB
+// whose movies are in release order, as IsChronological checks, within
+// MaxChronologicalDegrees. Like ShortestPathWithYearWindow it never uses an
+// edge without a year, needs per-movie edges, and returns nil, nil when no
+// such chain exists; callers should bound it with a context deadline too.
+func (d *Driver) ChronologicalPath(ctx context.Context, actorA, actorB int) (_ []PathStep, err error) {
+	if d.compactEdges {
+		return nil, ErrYearWindowUnsupported
+	}
+
+	cypher := fmt.Sprintf(`
+		MATCH (a:Actor {tmdb_id: $idA}), (b:Actor {tmdb_id: $idB}),
+		      p = shortestPath((a)-[:COSTARRED*..%d]-(b))
+		WHERE all(r IN relationships(p) WHERE r.year IS NOT NULL)
+		  AND all(i IN range(0, length(p) - 2)
+		          WHERE relationships(p)[i].year <= relationships(p)[i + 1].year)
+		RETURN [n IN nodes(p) | {id: n.tmdb_id, name: n.name}] AS actors,
+		       [r IN relationships(p) | {title: r.movie_title, year: r.year}] AS movies`, MaxChronologicalDegrees)
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "ChronologicalPath", cypher,
+		attribute.Int("actor_a", actorA),
+		attribute.Int("actor_b", actorB),
+	)
+	defer func() {
+		d.observe(ctx, "ChronologicalPath", start, err, "actor_a", actorA, "actor_b", actorB)
+		span.End()
+	}()
+
+	return d.runPathQuery(ctx, span, cypher, map[string]any{"idA": actorA, "idB": actorB})
+}
+
+// runPathQuery runs a query returning a single path's actors and movies lists
+// and decodes it into steps. It returns nil, nil when there is no path.
+func (d *Driver) runPathQuery(ctx context.Context, span trace.Span, cypher string, params map[string]any) ([]PathStep, error) {
 	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	defer session.Close(ctx)
 
-	result, err := session.Run(ctx, cypher, params)
+	result, err := session.Run(ctx, cypher, params, txTimeout(ctx)...)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
@@ -280,125 +612,846 @@ func (d *Driver) ShortestPath(ctx context.Context, actorA, actorB int) ([]PathSt
 	}
 
 	record, err := result.Single(ctx)
-	if err != nil {
+	if neo4j.IsUsageError(err) {
 		return nil, nil // no path found
 	}
+	if err != nil {
+		// The query can also fail while streaming, e.g. on the timeout.
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error finding shortest path: %w", err)
+	}
 
 	actorList, _ := record.Get("actors")
 	movieList, _ := record.Get("movies")
-	actors := actorList.([]any)
-	movies := movieList.([]any)
+	actors, _ := actorList.([]any)
+	movies, _ := movieList.([]any)
+	steps := decodePathRecord(actors, movies)
+
+	span.SetAttributes(attribute.Int("result.steps", len(steps)))
+	return steps, nil
+}
 
+// decodePathRecord interleaves the actors and movies lists a path query
+// returns into actor, movie, actor, ... steps. A path of n actors carries
+// n-1 movies.
+func decodePathRecord(actors, movies []any) []PathStep {
 	steps := make([]PathStep, 0, len(actors)+len(movies))
 	for i, actor := range actors {
-		a := actor.(map[string]any)
-		id, _ := a["id"].(int64)
-		steps = append(steps, PathStep{Actor: &models.Actor{TmdbID: int(id), Name: a["name"].(string)}})
+		steps = append(steps, PathStep{Actor: decodePathActor(actor)})
 		if i < len(movies) {
-			m := movies[i].(map[string]any)
-			year, _ := m["year"].(int64)
-			steps = append(steps, PathStep{
-				MovieTitle: m["title"].(string),
-				MovieYear:  int(year),
-			})
+			title, year := decodePathMovie(movies[i])
+			steps = append(steps, PathStep{MovieTitle: title, MovieYear: year})
 		}
 	}
+	return steps
+}
 
-	span.SetAttributes(attribute.Int("result.steps", len(steps)))
-	return steps, nil
+// decodePathActor reads an {id, name} map from a path query. Missing or
+// mistyped fields decode as zero values: a partially ingested actor without a
+// name should show up blank, not panic the request.
+func decodePathActor(v any) *models.Actor {
+	m, _ := v.(map[string]any)
+	id, _ := m["id"].(int64)
+	name, _ := m["name"].(string)
+	return &models.Actor{TmdbID: int(id), Name: name}
+}
+
+// decodePathMovie reads a {title, year} map from a path query, with the same
+// zero-value fallback as decodePathActor.
+func decodePathMovie(v any) (title string, year int) {
+	m, _ := v.(map[string]any)
+	title, _ = m["title"].(string)
+	y, _ := m["year"].(int64)
+	return title, int(y)
+}
+
+// GetActorByID returns the actor with the given id, or nil, nil when there is
+// none. Unlike GetActor it reads only the node, so it is cheap enough to
+// check an id with.
+func (d *Driver) GetActorByID(ctx context.Context, id int) (_ *models.Actor, err error) {
+	cypher := `MATCH (a:Actor {tmdb_id: $id}) RETURN a.name AS name`
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "GetActorByID", cypher, attribute.Int("actor_id", id))
+	defer func() {
+		d.observe(ctx, "GetActorByID", start, err)
+		span.End()
+	}()
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, map[string]any{"id": id})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error getting actor: %w", err)
+	}
+	if !result.Next(ctx) {
+		if err = result.Err(); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("error getting actor: %w", err)
+		}
+		return nil, nil
+	}
+	name, _ := result.Record().Get("name")
+	actor := &models.Actor{TmdbID: id}
+	actor.Name, _ = name.(string)
+	return actor, nil
 }
 
-// SearchActors runs a fulltext index query against the actor_name index.
-func (d *Driver) SearchActors(ctx context.Context, prefix string, limit int) ([]models.Actor, error) {
+// GetActor loads an actor's profile. It returns nil, nil when no actor has
+// the given id. Movies are ordered newest first.
+func (d *Driver) GetActor(ctx context.Context, id int) (_ *ActorProfile, err error) {
 	cypher := `
-		CALL db.index.fulltext.queryNodes("actor_name", $query)
-		YIELD node, score
-		RETURN node.tmdb_id AS id, node.name AS name
-		ORDER BY score DESC
-		LIMIT $limit`
+		MATCH (a:Actor {tmdb_id: $id})
+		OPTIONAL MATCH (a)-[r:COSTARRED]-(c:Actor)
+		WITH a, c, ` + edgeMoviesCypher + ` AS ms
+		WITH a, count(DISTINCT c) AS connections, collect(ms) AS lists
+		WITH a, connections, reduce(acc = [], ms IN lists | acc + [m IN ms WHERE NOT m IN acc]) AS movies
+		RETURN a.name AS name, a.profile_path AS profile_path, connections,
+		       [m IN movies WHERE m.id IS NOT NULL] AS movies`
 
 	start := time.Now()
-	ctx, span := d.tracer.Start(ctx, "neo4j.SearchActors",
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithAttributes(
-			semconv.DBSystemNeo4j,
-			semconv.DBQueryText(cypher),
-			attribute.String("search.prefix", prefix),
-		),
-	)
+	ctx, span := d.startSpan(ctx, "GetActor", cypher, attribute.Int("actor_id", id))
 	defer func() {
-		d.queryDuration.Record(ctx, time.Since(start).Seconds(),
-			metric.WithAttributes(attribute.String("query_name", "SearchActors")))
+		d.observe(ctx, "GetActor", start, err)
 		span.End()
 	}()
 
-	params := map[string]any{"query": prefix + "*", "limit": limit}
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, map[string]any{"id": id})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error getting actor: %w", err)
+	}
+
+	record, err := result.Single(ctx)
+	if neo4j.IsUsageError(err) {
+		return nil, nil // no such actor
+	}
+	if err != nil {
+		// The query can also fail while streaming, e.g. on the timeout.
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error getting actor: %w", err)
+	}
+
+	name, _ := record.Get("name")
+	profilePath, _ := record.Get("profile_path")
+	connections, _ := record.Get("connections")
+	movieList, _ := record.Get("movies")
+
+	profile := &ActorProfile{Actor: models.Actor{TmdbID: id}}
+	profile.Actor.Name, _ = name.(string)
+	profile.ProfilePath, _ = profilePath.(string)
+	if n, ok := connections.(int64); ok {
+		profile.Connections = int(n)
+	}
+	movies, _ := movieList.([]any)
+	for _, m := range movies {
+		fields, _ := m.(map[string]any)
+		movieID, _ := fields["id"].(int64)
+		title, _ := fields["title"].(string)
+		year, _ := fields["year"].(int64)
+		profile.Movies = append(profile.Movies, models.Movie{TmdbID: int(movieID), Title: title, Year: int(year)})
+	}
+	slices.SortFunc(profile.Movies, func(a, b models.Movie) int {
+		return cmp.Or(cmp.Compare(b.Year, a.Year), cmp.Compare(a.Title, b.Title))
+	})
+
+	return profile, nil
+}
+
+// GetCostars returns an actor's most frequent co-stars, ordered by the number
+// of movies they share, each with the most recent of those movies.
+func (d *Driver) GetCostars(ctx context.Context, id, limit int) (_ []Costar, err error) {
+	// Per-movie edges carry one movie each; compact edges carry parallel
+	// lists, which the index unwinds together.
+	cypher := `
+		MATCH (a:Actor {tmdb_id: $id})-[r:COSTARRED]-(c:Actor)
+		UNWIND range(0, size(coalesce(r.movie_ids, [r.tmdb_movie_id])) - 1) AS i
+		WITH c,
+		     coalesce(r.movie_ids[i], r.tmdb_movie_id) AS movieID,
+		     coalesce(r.titles[i], r.movie_title) AS title,
+		     coalesce(r.years[i], r.year) AS year
+		ORDER BY coalesce(year, 0) DESC, title
+		WITH c, count(DISTINCT movieID) AS shared, collect({id: movieID, title: title, year: year})[0] AS movie
+		RETURN c.tmdb_id AS id, c.name AS name, shared, movie
+		ORDER BY shared DESC, name
+		LIMIT $limit`
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "GetCostars", cypher, attribute.Int("actor_id", id))
+	defer func() {
+		d.observe(ctx, "GetCostars", start, err)
+		span.End()
+	}()
 
 	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	defer session.Close(ctx)
 
-	result, err := session.Run(ctx, cypher, params)
+	result, err := session.Run(ctx, cypher, map[string]any{"id": id, "limit": limit})
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return nil, fmt.Errorf("error searching actors: %w", err)
+		return nil, fmt.Errorf("error getting costars: %w", err)
 	}
 
-	var actors []models.Actor
+	var costars []Costar
 	for result.Next(ctx) {
 		record := result.Record()
-		id, _ := record.Get("id")
+		costarID, _ := record.Get("id")
 		name, _ := record.Get("name")
-		actors = append(actors, models.Actor{
-			TmdbID: int(id.(int64)),
-			Name:   name.(string),
+		shared, _ := record.Get("shared")
+
+		c := Costar{}
+		if n, ok := costarID.(int64); ok {
+			c.Actor.TmdbID = int(n)
+		}
+		c.Actor.Name, _ = name.(string)
+		if n, ok := shared.(int64); ok {
+			c.SharedMovies = int(n)
+		}
+		movie, _ := record.Get("movie")
+		fields, _ := movie.(map[string]any)
+		movieID, _ := fields["id"].(int64)
+		c.Movie.TmdbID = int(movieID)
+		c.Movie.Title, c.Movie.Year = decodePathMovie(fields)
+		costars = append(costars, c)
+	}
+	if err = result.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error iterating costar results: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("result.count", len(costars)))
+	return costars, nil
+}
+
+// Chain checks that each consecutive pair of ids co-starred and returns the
+// chain in ShortestPath's actor, movie, actor, ... form, each link labelled
+// with the pair's most recent shared movie. It returns ErrBrokenChain if any
+// step isn't an edge in the graph.
+func (d *Driver) Chain(ctx context.Context, ids []int) (_ []PathStep, err error) {
+	cypher := `
+		UNWIND range(0, size($ids) - 1) AS i
+		OPTIONAL MATCH (a:Actor {tmdb_id: $ids[i]})
+		OPTIONAL MATCH (a)-[r:COSTARRED]-(:Actor {tmdb_id: $ids[i + 1]})
+		WITH i, a, r
+		ORDER BY i, coalesce(r.year, 0) DESC
+		WITH i, a, collect(r)[0] AS r
+		ORDER BY i
+		RETURN collect(CASE WHEN a IS NOT NULL THEN {id: a.tmdb_id, name: a.name} END) AS actors,
+		       collect(CASE WHEN r IS NOT NULL THEN {title: r.movie_title, year: r.year} END) AS movies`
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "Chain", cypher, attribute.Int("chain.length", len(ids)))
+	defer func() {
+		d.observe(ctx, "Chain", start, err)
+		span.End()
+	}()
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, map[string]any{"ids": ids})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error checking chain: %w", err)
+	}
+	record, err := result.Single(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error checking chain: %w", err)
+	}
+
+	// collect skips nulls, so a missing actor or edge shows up as a short
+	// list.
+	actorList, _ := record.Get("actors")
+	movieList, _ := record.Get("movies")
+	actors, _ := actorList.([]any)
+	movies, _ := movieList.([]any)
+	if len(ids) == 0 || len(actors) != len(ids) || len(movies) != len(ids)-1 {
+		return nil, ErrBrokenChain
+	}
+	return decodePathRecord(actors, movies), nil
+}
+
+// Distance returns the number of hops between two actors, or Unconnected when
+// no path of at most maxHops exists. Unlike ShortestPath it only returns the
+// length, and the bound keeps a miss from walking the whole graph. maxHops
+// must be positive. As for path queries, ctx's deadline is passed to Neo4j
+// as the transaction timeout.
+func (d *Driver) Distance(ctx context.Context, actorA, actorB, maxHops int) (_ int, err error) {
+	if maxHops < 1 {
+		return 0, fmt.Errorf("max hops must be positive, got %d", maxHops)
+	}
+
+	// Variable-length bounds can't be parameterised in Cypher; maxHops is an
+	// int so formatting it into the query is safe.
+	cypher := fmt.Sprintf(`
+		MATCH (a:Actor {tmdb_id: $idA}), (b:Actor {tmdb_id: $idB}),
+		      p = shortestPath((a)-[:COSTARRED*..%d]-(b))
+		RETURN length(p) AS hops`, maxHops)
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "Distance", cypher,
+		attribute.Int("actor_a", actorA),
+		attribute.Int("actor_b", actorB),
+		attribute.Int("max_hops", maxHops),
+	)
+	defer func() {
+		d.observe(ctx, "Distance", start, err)
+		span.End()
+	}()
+
+	params := map[string]any{"idA": actorA, "idB": actorB}
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, params, txTimeout(ctx)...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("error computing distance: %w", err)
+	}
+
+	record, err := result.Single(ctx)
+	if neo4j.IsUsageError(err) {
+		return Unconnected, nil // no path within maxHops
+	}
+	if err != nil {
+		// The query can also fail while streaming, e.g. on the timeout.
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("error computing distance: %w", err)
+	}
+
+	hops, _ := record.Get("hops")
+	return int(hops.(int64)), nil
+}
+
+// GetRandomConnectedPair picks two distinct actors uniformly at random from
+// those with at least one co-star. Both ends are in the co-star network, but
+// they may sit in different components, so callers measuring distance should
+// still expect Unconnected. Returns ErrNotEnoughActors if fewer than two
+// actors have any edges.
+func (d *Driver) GetRandomConnectedPair(ctx context.Context) (int, int, error) {
+	cypher := `
+		MATCH (a:Actor)
+		WHERE EXISTS { (a)-[:COSTARRED]-() }
+		WITH a.tmdb_id AS id
+		ORDER BY rand()
+		LIMIT 2
+		RETURN collect(id) AS ids`
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error picking random pair: %w", err)
+	}
+
+	record, err := result.Single(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error picking random pair: %w", err)
+	}
+	idList, _ := record.Get("ids")
+	ids, _ := idList.([]any)
+	if len(ids) < 2 {
+		return 0, 0, ErrNotEnoughActors
+	}
+
+	a, _ := ids[0].(int64)
+	b, _ := ids[1].(int64)
+	return int(a), int(b), nil
+}
+
+// RankedActor is an actor on the TopActors leaderboard.
+type RankedActor struct {
+	Actor       models.Actor
+	Connections int // COSTARRED edges, as on ActorProfile
+}
+
+// TopActors returns up to limit actors with the most COSTARRED edges, best
+// connected first, after skipping the first offset. Ties go to the lower TMDb
+// id, so pages never overlap or skip anyone while the graph is unchanged.
+// Actors without edges are left out, and an offset past the end gives none.
+func (d *Driver) TopActors(ctx context.Context, offset, limit int) (_ []RankedActor, err error) {
+	cypher := `
+		MATCH (a:Actor)
+		WITH a, COUNT { (a)-[:COSTARRED]-() } AS degree
+		WHERE degree > 0
+		ORDER BY degree DESC, a.tmdb_id
+		SKIP $offset
+		LIMIT $limit
+		RETURN a.tmdb_id AS id, a.name AS name, degree`
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "TopActors", cypher, attribute.Int("offset", offset), attribute.Int("limit", limit))
+	defer func() {
+		d.observe(ctx, "TopActors", start, err)
+		span.End()
+	}()
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, map[string]any{"offset": offset, "limit": limit})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error listing top actors: %w", err)
+	}
+
+	var actors []RankedActor
+	for result.Next(ctx) {
+		id, _ := result.Record().Get("id")
+		name, _ := result.Record().Get("name")
+		degree, _ := result.Record().Get("degree")
+		actorName, _ := name.(string)
+		actors = append(actors, RankedActor{
+			Actor:       models.Actor{TmdbID: int(id.(int64)), Name: actorName},
+			Connections: int(degree.(int64)),
 		})
 	}
 	if err = result.Err(); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return nil, fmt.Errorf("error iterating actor results: %w", err)
+		return nil, fmt.Errorf("error iterating top actors: %w", err)
 	}
 
 	span.SetAttributes(attribute.Int("result.count", len(actors)))
 	return actors, nil
 }
 
-func (d *Driver) GetLastIngestedPage(ctx context.Context) (int, error) {
-	cypher := "MATCH (s:IngestState) RETURN s.last_page AS page"
+// Unconnected is the distance reported for pairs with no path within the
+// search bound, and the SampleDegreeDistribution bucket that counts them.
+const Unconnected = -1
+
+// distributionMaxHops bounds each Distance call made while sampling. Pairs
+// further apart than this land in the Unconnected bucket.
+const distributionMaxHops = 10
+
+// SampleDegreeDistribution estimates how many hops apart actors are by
+// measuring sampleSize random pairs and tallying their distances, with
+// unreachable pairs counted under Unconnected. The exact distribution needs a
+// shortest path for every pair of actors — O(n²) searches — so this is only an
+// approximation whose accuracy depends on sampleSize. An empty or single-actor
+// graph yields an empty map, not an error.
+func (d *Driver) SampleDegreeDistribution(ctx context.Context, sampleSize int) (_ map[int]int, err error) {
+	if sampleSize <= 0 {
+		return nil, fmt.Errorf("sample size must be positive, got %d", sampleSize)
+	}
+
+	cypher := `
+		MATCH (a:Actor)
+		WITH a.tmdb_id AS id
+		ORDER BY rand()
+		LIMIT $limit
+		RETURN collect(id) AS ids`
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "SampleDegreeDistribution", cypher, attribute.Int("sample_size", sampleSize))
+	defer func() {
+		d.observe(ctx, "SampleDegreeDistribution", start, err, "sample_size", sampleSize)
+		span.End()
+	}()
 
 	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	defer session.Close(ctx)
 
-	result, err := session.Run(ctx, cypher, nil)
+	result, err := session.Run(ctx, cypher, map[string]any{"limit": sampleSize * 2}, txTimeout(ctx)...)
 	if err != nil {
-		return 0, fmt.Errorf("error reading ingest state: %w", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error sampling actors: %w", err)
 	}
 
 	record, err := result.Single(ctx)
 	if err != nil {
-		return 0, nil // no IngestState node yet (first run)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error sampling actors: %w", err)
+	}
+	idList, _ := record.Get("ids")
+	ids, _ := idList.([]any)
+
+	dist := make(map[int]int)
+	for i := 0; i+1 < len(ids); i += 2 {
+		a, _ := ids[i].(int64)
+		b, _ := ids[i+1].(int64)
+		hops, err := d.Distance(ctx, int(a), int(b), distributionMaxHops)
+		if err != nil {
+			return nil, err
+		}
+		dist[hops]++
 	}
 
-	page, _ := record.Get("page")
-	return int(page.(int64)), nil
+	return dist, nil
 }
 
-func (d *Driver) SetLastIngestedPage(ctx context.Context, page int) error {
-	cypher := "MERGE (s:IngestState) SET s.last_page = $page"
-	params := map[string]any{"page": page}
+// Neighbors returns the one-hop co-stars of every actor in ids in a single
+// round trip, excluding actors that are themselves in ids. Each (actor,
+// co-star) pair appears once with one of their shared movies, and at most
+// limit edges are returned.
+func (d *Driver) Neighbors(ctx context.Context, ids []int, limit int) (_ []NeighborEdge, err error) {
+	cypher := `
+		UNWIND $ids AS id
+		MATCH (a:Actor {tmdb_id: id})-[r:COSTARRED]-(n:Actor)
+		WHERE NOT n.tmdb_id IN $ids
+		WITH a, n, collect(r)[0] AS r
+		RETURN a.tmdb_id AS fromID, n.tmdb_id AS id, n.name AS name,
+		       r.movie_title AS title, r.year AS year
+		LIMIT $limit`
 
-	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "Neighbors", cypher, attribute.Int("ids.count", len(ids)))
+	defer func() {
+		d.observe(ctx, "Neighbors", start, err)
+		span.End()
+	}()
+
+	params := map[string]any{"ids": ids, "limit": limit}
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, params)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error fetching neighbors: %w", err)
+	}
+
+	var edges []NeighborEdge
+	for result.Next(ctx) {
+		record := result.Record()
+		fromID, _ := record.Get("fromID")
+		id, _ := record.Get("id")
+		name, _ := record.Get("name")
+		title, _ := record.Get("title")
+		year, _ := record.Get("year")
+
+		e := NeighborEdge{FromID: int(fromID.(int64))}
+		e.Actor.TmdbID = int(id.(int64))
+		e.Actor.Name, _ = name.(string)
+		e.MovieTitle, _ = title.(string)
+		if y, ok := year.(int64); ok {
+			e.MovieYear = int(y)
+		}
+		edges = append(edges, e)
+	}
+	if err = result.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error iterating neighbor results: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("result.count", len(edges)))
+	return edges, nil
+}
+
+// MaxReachDepth bounds ReachCounts. Each level multiplies the frontier by
+// the average co-star count, so a well-connected actor's third level can
+// already cover most of the graph; a fifth would read all of it on every call.
+const MaxReachDepth = 4
+
+// ReachCounts returns how many distinct actors sit at exactly 1, 2, ...
+// maxDepth degrees from the actor id, keyed by degree. Every degree up to
+// maxDepth has an entry, so an isolated or unknown actor gets all zeros.
+//
+// It is a breadth-first search with one query per level: each query expands
+// the previous level's actors, and actors already seen are dropped here. The
+// cost grows with the size of the last frontier, which for a prolific actor
+// at depth 3 or 4 is tens or hundreds of thousands of ids sent to and from
+// Neo4j, so callers should cache the result rather than compute it per view.
+func (d *Driver) ReachCounts(ctx context.Context, id, maxDepth int) (_ map[int]int, err error) {
+	if maxDepth < 1 || maxDepth > MaxReachDepth {
+		return nil, fmt.Errorf("reach depth must be between 1 and %d, got %d", MaxReachDepth, maxDepth)
+	}
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "ReachCounts", expandFrontierCypher,
+		attribute.Int("actor_id", id),
+		attribute.Int("max_depth", maxDepth),
+	)
+	defer func() {
+		d.observe(ctx, "ReachCounts", start, err)
+		span.End()
+	}()
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	defer session.Close(ctx)
 
-	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-		_, err := tx.Run(ctx, cypher, params)
+	counts := make(map[int]int, maxDepth)
+	seen := map[int64]bool{int64(id): true}
+	frontier := []int64{int64(id)}
+	for depth := 1; depth <= maxDepth; depth++ {
+		counts[depth] = 0
+		if len(frontier) == 0 {
+			continue
+		}
+
+		next, err := expandFrontier(ctx, session, frontier, seen)
 		if err != nil {
-			return nil, fmt.Errorf("error saving ingest state: %w", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("error expanding reach level %d: %w", depth, err)
+		}
+		counts[depth] = len(next)
+		frontier = next
+	}
+
+	span.SetAttributes(attribute.Int("result.reached", len(seen)-1))
+	return counts, nil
+}
+
+// expandFrontierCypher finds every co-star of the actors in $frontier.
+const expandFrontierCypher = `
+	UNWIND $frontier AS id
+	MATCH (:Actor {tmdb_id: id})-[:COSTARRED]-(n:Actor)
+	RETURN DISTINCT n.tmdb_id AS id`
+
+// expandFrontier takes one breadth-first step: it returns the co-stars of
+// the frontier's actors that aren't in seen, and adds them to it.
+func expandFrontier(ctx context.Context, session neo4j.SessionWithContext, frontier []int64, seen map[int64]bool) ([]int64, error) {
+	result, err := session.Run(ctx, expandFrontierCypher, map[string]any{"frontier": frontier})
+	if err != nil {
+		return nil, err
+	}
+	var next []int64
+	for result.Next(ctx) {
+		v, _ := result.Record().Get("id")
+		n, ok := v.(int64)
+		if !ok || seen[n] {
+			continue
+		}
+		seen[n] = true
+		next = append(next, n)
+	}
+	return next, result.Err()
+}
+
+// MaxNetworkDegrees bounds ActorsWithinDegrees. Two degrees already covers
+// tens of thousands of actors for a prolific one; a third is most of the graph.
+const MaxNetworkDegrees = 2
+
+// networkCountCap stops ActorsWithinDegrees counting a group past this many
+// actors, so a hub's second degree can't turn into a scan of the graph.
+const networkCountCap = 50000
+
+// NetworkGroup is the actors at one distance from some actor: how many there
+// are and a sample of them, best connected first.
+type NetworkGroup struct {
+	Degree int
+	Count  int
+	// Capped means counting stopped at the cap, so Count is a lower bound.
+	Capped bool
+	Actors []models.Actor
+}
+
+// ActorsWithinDegrees returns the actors 1 to maxDegrees co-star hops from the
+// actor id, one group per degree, each actor in the group for its shortest
+// distance only. Every group is counted in full up to a hard cap, but only
+// its first limit actors are returned, so a hub with thousands of co-stars
+// costs a count rather than a list. An unknown actor gets empty groups.
+func (d *Driver) ActorsWithinDegrees(ctx context.Context, id, maxDegrees, limit int) (_ []NetworkGroup, err error) {
+	if maxDegrees < 1 || maxDegrees > MaxNetworkDegrees {
+		return nil, fmt.Errorf("network degrees must be between 1 and %d, got %d", MaxNetworkDegrees, maxDegrees)
+	}
+	// Each level stops at the cap before sorting, and the second skips the
+	// source and its direct co-stars, leaving each actor at one degree.
+	cypher := `
+		MATCH (a:Actor {tmdb_id: $id})
+		CALL (a) {
+			MATCH (a)-[:COSTARRED]-(c:Actor)
+			WITH DISTINCT c LIMIT $cap
+			WITH c ORDER BY COUNT { (c)-[:COSTARRED]-() } DESC, c.name
+			RETURN count(c) AS directCount,
+			       collect({id: c.tmdb_id, name: c.name})[..$limit] AS direct
+		}
+		CALL (a) {
+			MATCH (a)-[:COSTARRED]-(:Actor)-[:COSTARRED]-(b:Actor)
+			WHERE $maxDegrees >= 2 AND b <> a AND NOT (a)-[:COSTARRED]-(b)
+			WITH DISTINCT b LIMIT $cap
+			WITH b ORDER BY COUNT { (b)-[:COSTARRED]-() } DESC, b.name
+			RETURN count(b) AS secondCount,
+			       collect({id: b.tmdb_id, name: b.name})[..$limit] AS second
+		}
+		RETURN directCount, direct, secondCount, second`
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "ActorsWithinDegrees", cypher,
+		attribute.Int("actor_id", id),
+		attribute.Int("max_degrees", maxDegrees),
+	)
+	defer func() {
+		d.observe(ctx, "ActorsWithinDegrees", start, err)
+		span.End()
+	}()
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, map[string]any{
+		"id":         id,
+		"maxDegrees": maxDegrees,
+		"limit":      limit,
+		"cap":        networkCountCap,
+	}, txTimeout(ctx)...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error getting actor network: %w", err)
+	}
+
+	groups := make([]NetworkGroup, maxDegrees)
+	for i := range groups {
+		groups[i].Degree = i + 1
+	}
+	if result.Next(ctx) {
+		record := result.Record()
+		for i, key := range []string{"direct", "second"}[:maxDegrees] {
+			count, _ := record.Get(key + "Count")
+			n, _ := count.(int64)
+			groups[i].Count = int(n)
+			groups[i].Capped = n >= networkCountCap
+			list, _ := record.Get(key)
+			rows, _ := list.([]any)
+			for _, row := range rows {
+				fields, _ := row.(map[string]any)
+				actorID, _ := fields["id"].(int64)
+				name, _ := fields["name"].(string)
+				groups[i].Actors = append(groups[i].Actors, models.Actor{TmdbID: int(actorID), Name: name})
+			}
+		}
+	}
+	if err = result.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error reading actor network: %w", err)
+	}
+
+	total := 0
+	for _, g := range groups {
+		total += g.Count
+	}
+	span.SetAttributes(attribute.Int("result.count", total))
+	return groups, nil
+}
+
+// SearchActors runs a fulltext index query against the actor_name index,
+// which covers normalized names, so matching ignores case, accents and
+// punctuation: "penelope" finds "Penélope Cruz" and "obrien" "Dylan O'Brien".
+// Matches scoring under SEARCH_MIN_SCORE, such as one sharing only a first
+// name with a two-word query, are dropped.
+func (d *Driver) SearchActors(ctx context.Context, prefix string, limit int) (_ []SearchResult, err error) {
+	// The known-for lookup runs once per result, after the limit, so its cost
+	// is bounded by the dropdown size rather than by the number of matches.
+	cypher := `
+		CALL db.index.fulltext.queryNodes("actor_name", $query)
+		YIELD node, score
+		WHERE score >= $minScore
+		WITH node, score
+		ORDER BY score DESC, coalesce(node.popularity, 0.0) DESC
+		LIMIT $limit
+		CALL (node) {
+			OPTIONAL MATCH (node)-[r:COSTARRED]-(c:Actor)
+			WITH r, c
+			ORDER BY COUNT { (c)-[:COSTARRED]-() } DESC
+			LIMIT 1
+			RETURN r.movie_title AS known_for
 		}
+		RETURN node.tmdb_id AS id, node.name AS name,
+		       node.profile_path AS profile_path, node.popularity AS popularity,
+		       known_for, score
+		ORDER BY score DESC, coalesce(node.popularity, 0.0) DESC`
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "SearchActors", cypher, attribute.String("search.prefix", prefix))
+	defer func() {
+		d.observe(ctx, "SearchActors", start, err, "prefix", prefix, "limit", limit)
+		span.End()
+	}()
+
+	// Normalizing the query the same way leaves nothing Lucene would read as
+	// syntax, just words.
+	query := normalizeName(prefix)
+	if query == "" {
 		return nil, nil
-	})
-	return err
+	}
+	params := map[string]any{"query": query + "*", "limit": limit, "minScore": d.searchMinScore}
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, params)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error searching actors: %w", err)
+	}
+
+	var results []SearchResult
+	for result.Next(ctx) {
+		record := result.Record()
+		id, _ := record.Get("id")
+		name, _ := record.Get("name")
+		profilePath, _ := record.Get("profile_path")
+		popularity, _ := record.Get("popularity")
+		knownFor, _ := record.Get("known_for")
+		score, _ := record.Get("score")
+		r := SearchResult{Actor: models.Actor{
+			TmdbID: int(id.(int64)),
+			Name:   name.(string),
+		}}
+		// Missing properties come back as nil.
+		r.ProfilePath, _ = profilePath.(string)
+		r.Popularity, _ = popularity.(float64)
+		r.KnownFor, _ = knownFor.(string)
+		r.Score, _ = score.(float64)
+		results = append(results, r)
+	}
+	if err = result.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error iterating actor results: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("result.count", len(results)))
+	return results, nil
+}
+
+// HasActors reports whether the graph holds at least one actor. It stops at
+// the first match, so it stays cheap enough for readiness probes.
+func (d *Driver) HasActors(ctx context.Context) (bool, error) {
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, "RETURN EXISTS { MATCH (:Actor) } AS nonEmpty", nil)
+	if err != nil {
+		return false, fmt.Errorf("error checking for actors: %w", err)
+	}
+
+	record, err := result.Single(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error checking for actors: %w", err)
+	}
+	nonEmpty, _ := record.Get("nonEmpty")
+	ok, _ := nonEmpty.(bool)
+	return ok, nil
 }
 
 // GetCounts returns actor and edge counts using two fast label/type scans.
@@ -431,29 +1484,24 @@ func (d *Driver) GetCounts(ctx context.Context) ([2]int, error) {
 
 // GetStats runs an aggregate Cypher query. Called from HTTP handlers and from
 // the async gauge callback on each Prometheus scrape.
-func (d *Driver) GetStats(ctx context.Context) (*Stats, error) {
+func (d *Driver) GetStats(ctx context.Context) (_ *Stats, err error) {
 	cypher := `
+		OPTIONAL MATCH (m:Meta {key: 'ingest'})
+		WITH m.last_ingest_completed AS lastIngest
 		OPTIONAL MATCH (a:Actor)
-		WITH count(a) AS actorCount
+		WITH lastIngest, count(a) AS actorCount
 		OPTIONAL MATCH ()-[r:COSTARRED]->()
-		WITH actorCount, count(r) AS edgeCount
+		WITH lastIngest, actorCount, count(r) AS edgeCount
 		OPTIONAL MATCH (a:Actor)-[r:COSTARRED]-()
-		WITH actorCount, edgeCount, a, count(r) AS rels
+		WITH lastIngest, actorCount, edgeCount, a, count(r) AS rels
 		ORDER BY rels DESC
 		LIMIT 1
-		RETURN actorCount, edgeCount, a.name AS topActor, rels AS topCount`
+		RETURN actorCount, edgeCount, a.name AS topActor, rels AS topCount, lastIngest`
 
 	start := time.Now()
-	ctx, span := d.tracer.Start(ctx, "neo4j.GetStats",
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithAttributes(
-			semconv.DBSystemNeo4j,
-			semconv.DBQueryText(cypher),
-		),
-	)
+	ctx, span := d.startSpan(ctx, "GetStats", cypher)
 	defer func() {
-		d.queryDuration.Record(ctx, time.Since(start).Seconds(),
-			metric.WithAttributes(attribute.String("query_name", "GetStats")))
+		d.observe(ctx, "GetStats", start, err)
 		span.End()
 	}()
 
@@ -476,11 +1524,15 @@ func (d *Driver) GetStats(ctx context.Context) (*Stats, error) {
 	edgeCount, _ := record.Get("edgeCount")
 	topActor, _ := record.Get("topActor")
 	topCount, _ := record.Get("topCount")
+	lastIngest, _ := record.Get("lastIngest")
 
 	stats := &Stats{
 		ActorCount: int(actorCount.(int64)),
 		EdgeCount:  int(edgeCount.(int64)),
 	}
+	if at, ok := lastIngest.(time.Time); ok {
+		stats.LastIngestAt = at
+	}
 	if topActor != nil {
 		stats.MostConnectedActor = topActor.(string)
 		stats.MostConnectedCount = int(topCount.(int64))
@@ -6,14 +6,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"maps"
 	"os"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
-	tcneo4j "github.com/testcontainers/testcontainers-go/modules/neo4j"
 
-	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph/graphtest"
 	"github.com/mark-c-hall/degrees-of-separation/internal/models"
 )
 
@@ -22,42 +23,11 @@ var testDriver *Driver
 func TestMain(m *testing.M) {
 	ctx := context.Background()
 
-	container, err := tcneo4j.Run(ctx, "neo4j:5", tcneo4j.WithoutAuthentication())
+	d, stop, err := graphtest.Start(ctx, NewDriver)
 	if err != nil {
-		log.Fatalf("failed to start neo4j container: %v", err)
-	}
-	defer container.Terminate(ctx)
-
-	boltURL, err := container.BoltUrl(ctx)
-	if err != nil {
-		log.Fatalf("failed to get bolt url: %v", err)
-	}
-
-	cfg := config.Config{
-		DB: config.DBConfig{
-			URI:  boltURL,
-			User: "neo4j",
-			Pass: "",
-		},
-	}
-
-	// Neo4j may need a moment to be ready for auth-free connections
-	var d *Driver
-	for range 10 {
-		d, err = NewDriver(ctx, cfg)
-		if err == nil {
-			break
-		}
-		time.Sleep(500 * time.Millisecond)
-	}
-	if err != nil {
-		log.Fatalf("failed to create driver: %v", err)
-	}
-	defer d.Close(ctx)
-
-	if err = d.SetupSchema(ctx); err != nil {
-		log.Fatalf("failed to setup schema: %v", err)
+		log.Fatal(err)
 	}
+	defer stop()
 
 	// Wait for the fulltext index to come online
 	time.Sleep(2 * time.Second)
@@ -166,6 +136,81 @@ func TestCreateCostarEdge(t *testing.T) {
 	}
 }
 
+func TestCreateCostarEdge_Decade(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	for id := 1; id <= 3; id++ {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: id, Name: "Actor"})
+	}
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 100, Title: "Nineties", Year: 1994})
+	testDriver.CreateCostarEdge(ctx, 2, 3, models.Movie{TmdbID: 200, Title: "Undated"})
+
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+	result, err := session.Run(ctx, "MATCH ()-[r:COSTARRED]->() RETURN r.movie_title AS title, r.decade AS decade", nil)
+	if err != nil {
+		t.Fatalf("verification query failed: %v", err)
+	}
+	records, err := result.Collect(ctx)
+	if err != nil {
+		t.Fatalf("verification query failed: %v", err)
+	}
+	decades := map[string]any{}
+	for _, record := range records {
+		title, _ := record.Get("title")
+		decade, _ := record.Get("decade")
+		decades[title.(string)] = decade
+	}
+	if decades["Nineties"] != int64(1990) {
+		t.Errorf("expected decade 1990 for a 1994 movie, got %v", decades["Nineties"])
+	}
+	if decades["Undated"] != nil {
+		t.Errorf("expected no decade for a movie without a year, got %v", decades["Undated"])
+	}
+}
+
+func TestCreateCostarEdge_CanonicalDirection(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	fightClub := models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999}
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Brad Pitt"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 2, Name: "Edward Norton"})
+
+	if err := testDriver.CreateCostarEdge(ctx, 2, 1, fightClub); err != nil {
+		t.Fatalf("CreateCostarEdge(2, 1) failed: %v", err)
+	}
+	if err := testDriver.CreateCostarEdge(ctx, 1, 2, fightClub); err != nil {
+		t.Fatalf("CreateCostarEdge(1, 2) failed: %v", err)
+	}
+	// The same collaboration seen through a cast list in the other order.
+	cast := []models.Actor{{TmdbID: 2, Name: "Edward Norton"}, {TmdbID: 1, Name: "Brad Pitt"}}
+	if err := testDriver.IngestMovieCast(ctx, fightClub, cast); err != nil {
+		t.Fatalf("IngestMovieCast failed: %v", err)
+	}
+
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, "MATCH (a:Actor)-[r:COSTARRED]->(b:Actor) RETURN count(r) AS c, collect(a.tmdb_id)[0] AS from", nil)
+	if err != nil {
+		t.Fatalf("verification query failed: %v", err)
+	}
+	record, err := result.Single(ctx)
+	if err != nil {
+		t.Fatalf("expected exactly one record: %v", err)
+	}
+	count, _ := record.Get("c")
+	from, _ := record.Get("from")
+	if count.(int64) != 1 {
+		t.Errorf("expected 1 edge, got %d", count)
+	}
+	if from.(int64) != 1 {
+		t.Errorf("expected edge to start at the lower id 1, got %d", from)
+	}
+}
+
 func TestShortestPath(t *testing.T) {
 	clearGraph(t)
 	ctx := context.Background()
@@ -221,6 +266,142 @@ func TestShortestPath_NoPath(t *testing.T) {
 	}
 }
 
+func TestShortestPathWithYearWindow(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// A --1950-- B --2010-- C is shortest but jumps 60 years; the detour
+	// A --1950-- D --1955-- E --1960-- C stays within a decade at each hop.
+	for id, name := range map[int]string{1: "Actor A", 2: "Actor B", 3: "Actor C", 4: "Actor D", 5: "Actor E"} {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: id, Name: name})
+	}
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 100, Title: "Old", Year: 1950})
+	testDriver.CreateCostarEdge(ctx, 2, 3, models.Movie{TmdbID: 200, Title: "New", Year: 2010})
+	testDriver.CreateCostarEdge(ctx, 1, 4, models.Movie{TmdbID: 300, Title: "Detour One", Year: 1950})
+	testDriver.CreateCostarEdge(ctx, 4, 5, models.Movie{TmdbID: 400, Title: "Detour Two", Year: 1955})
+	testDriver.CreateCostarEdge(ctx, 5, 3, models.Movie{TmdbID: 500, Title: "Detour Three", Year: 1960})
+
+	steps, err := testDriver.ShortestPathWithYearWindow(ctx, 1, 3, 10)
+	if err != nil {
+		t.Fatalf("ShortestPathWithYearWindow failed: %v", err)
+	}
+	var titles []string
+	for _, step := range steps {
+		if step.Actor == nil {
+			titles = append(titles, step.MovieTitle)
+		}
+	}
+	if want := []string{"Detour One", "Detour Two", "Detour Three"}; !slices.Equal(titles, want) {
+		t.Errorf("expected the path through %v, got %v", want, titles)
+	}
+
+	// No chain stays within a year of each movie.
+	steps, err = testDriver.ShortestPathWithYearWindow(ctx, 1, 3, 1)
+	if err != nil {
+		t.Fatalf("ShortestPathWithYearWindow failed: %v", err)
+	}
+	if steps != nil {
+		t.Errorf("expected nil steps when no path fits the window, got %+v", steps)
+	}
+
+	// A single edge has no neighbour to compare with, but without a year it
+	// still can't be checked.
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 6, Name: "Actor F"})
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+	if _, err := session.Run(ctx,
+		`MATCH (a:Actor {tmdb_id: 1}), (f:Actor {tmdb_id: 6})
+		 CREATE (a)-[:COSTARRED {tmdb_movie_id: 600, movie_title: "Undated"}]->(f)`, nil); err != nil {
+		t.Fatalf("creating an edge without a year failed: %v", err)
+	}
+	steps, err = testDriver.ShortestPathWithYearWindow(ctx, 1, 6, 10)
+	if err != nil {
+		t.Fatalf("ShortestPathWithYearWindow failed: %v", err)
+	}
+	if steps != nil {
+		t.Errorf("expected nil steps over an edge without a year, got %+v", steps)
+	}
+}
+
+func TestChronologicalPath(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// A --2010-- B --1995-- C is shortest but runs backwards in time; the
+	// detour A --1990-- D --2000-- C is in order.
+	for id, name := range map[int]string{1: "Actor A", 2: "Actor B", 3: "Actor C", 4: "Actor D"} {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: id, Name: name})
+	}
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 100, Title: "Later", Year: 2010})
+	testDriver.CreateCostarEdge(ctx, 2, 3, models.Movie{TmdbID: 200, Title: "Earlier", Year: 1995})
+
+	shortest, err := testDriver.ShortestPath(ctx, 1, 3)
+	if err != nil {
+		t.Fatalf("ShortestPath failed: %v", err)
+	}
+	if IsChronological(shortest) {
+		t.Errorf("expected the shortest path to be out of order, got %+v", shortest)
+	}
+
+	// With only the backwards chain there is no chronological path.
+	steps, err := testDriver.ChronologicalPath(ctx, 1, 3)
+	if err != nil {
+		t.Fatalf("ChronologicalPath failed: %v", err)
+	}
+	if steps != nil {
+		t.Errorf("expected nil steps when no path is in order, got %+v", steps)
+	}
+	// The reverse direction reads 1995 then 2010, which is.
+	if steps, err = testDriver.ChronologicalPath(ctx, 3, 1); err != nil || len(steps) != 5 {
+		t.Errorf("expected the two-movie path from C to A, got %+v, %v", steps, err)
+	}
+
+	testDriver.CreateCostarEdge(ctx, 1, 4, models.Movie{TmdbID: 300, Title: "Detour One", Year: 1990})
+	testDriver.CreateCostarEdge(ctx, 4, 3, models.Movie{TmdbID: 400, Title: "Detour Two", Year: 2000})
+
+	steps, err = testDriver.ChronologicalPath(ctx, 1, 3)
+	if err != nil {
+		t.Fatalf("ChronologicalPath failed: %v", err)
+	}
+	var titles []string
+	for _, step := range steps {
+		if step.Actor == nil {
+			titles = append(titles, step.MovieTitle)
+		}
+	}
+	if want := []string{"Detour One", "Detour Two"}; !slices.Equal(titles, want) || !IsChronological(steps) {
+		t.Errorf("expected the path through %v, got %v", want, titles)
+	}
+}
+
+func TestSearchActors_MinScore(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Tom Hanks"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 2, Name: "Tom Cruise"})
+	time.Sleep(2 * time.Second)
+
+	// "Tom Cruise" shares only the first word of the query.
+	actors, err := testDriver.SearchActors(ctx, "Tom Hanks", 10)
+	if err != nil {
+		t.Fatalf("SearchActors failed: %v", err)
+	}
+	if len(actors) != 2 || actors[0].Actor.Name != "Tom Hanks" || actors[0].Score <= actors[1].Score {
+		t.Fatalf("expected Tom Hanks ahead of a weaker Tom Cruise, got %+v", actors)
+	}
+
+	defer func(score float64) { testDriver.searchMinScore = score }(testDriver.searchMinScore)
+	testDriver.searchMinScore = (actors[0].Score + actors[1].Score) / 2
+	actors, err = testDriver.SearchActors(ctx, "Tom Hanks", 10)
+	if err != nil {
+		t.Fatalf("SearchActors failed: %v", err)
+	}
+	if len(actors) != 1 || actors[0].Actor.Name != "Tom Hanks" {
+		t.Errorf("expected the weak match filtered out, got %+v", actors)
+	}
+}
+
 func TestSearchActors(t *testing.T) {
 	clearGraph(t)
 	ctx := context.Background()
@@ -243,13 +424,123 @@ func TestSearchActors(t *testing.T) {
 
 	names := map[string]bool{}
 	for _, a := range actors {
-		names[a.Name] = true
+		names[a.Actor.Name] = true
 	}
 	if !names["Leonardo DiCaprio"] || !names["Leon Kennedy"] {
 		t.Errorf("expected Leonardo DiCaprio and Leon Kennedy, got %+v", actors)
 	}
 }
 
+func TestSearchActors_IgnoresAccents(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Penélope Cruz"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 2, Name: "Penny Marshall"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 3, Name: "Zoe Saldana"})
+
+	time.Sleep(2 * time.Second)
+
+	for query, want := range map[string]int{
+		"Penelope": 1, // unaccented query, accented name
+		"penélope": 1, // the exact spelling still matches
+		"PENÉ":     1,
+		"Zoë":      3, // accented query, unaccented name
+	} {
+		results, err := testDriver.SearchActors(ctx, query, 10)
+		if err != nil {
+			t.Fatalf("SearchActors(%q) failed: %v", query, err)
+		}
+		if len(results) != 1 || results[0].Actor.TmdbID != want {
+			t.Errorf("SearchActors(%q): expected only actor %d, got %+v", query, want, results)
+		}
+	}
+}
+
+func TestSearchActors_IgnoresPunctuation(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 100, Title: "Movie One", Year: 2000}, []models.Actor{
+		{TmdbID: 1, Name: "Dylan O'Brien"},
+		{TmdbID: 2, Name: "Robert De Niro"},
+		{TmdbID: 3, Name: "J.K. Simmons"},
+	})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 4, Name: "Jean-Claude Van Damme"})
+
+	time.Sleep(2 * time.Second)
+
+	for query, want := range map[string]int{
+		"obrien":   1,
+		"O'Bri":    1,
+		"o’brien":  1,
+		"de niro":  2,
+		"Niro":     2,
+		"jk simm":  3,
+		"J.K.":     3,
+		"claude":   4,
+		"Jean-Cla": 4,
+	} {
+		results, err := testDriver.SearchActors(ctx, query, 10)
+		if err != nil {
+			t.Fatalf("SearchActors(%q) failed: %v", query, err)
+		}
+		if len(results) == 0 || results[0].Actor.TmdbID != want {
+			t.Errorf("SearchActors(%q): expected actor %d first, got %+v", query, want, results)
+		}
+	}
+
+	if results, err := testDriver.SearchActors(ctx, "'.", 10); err != nil || len(results) != 0 {
+		t.Errorf("expected no results for punctuation alone, got %+v, %v", results, err)
+	}
+}
+
+func TestSearchActors_EnrichmentAndKnownFor(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	pitt := models.Actor{TmdbID: 287, Name: "Brad Pitt"}
+	norton := models.Actor{TmdbID: 819, Name: "Edward Norton"}
+	carter := models.Actor{TmdbID: 1283, Name: "Helena Bonham Carter"}
+	ramsey := models.Actor{TmdbID: 9, Name: "Bradley Ramsey"}
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999}, []models.Actor{pitt, norton, carter})
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 1, Title: "Small Film", Year: 2001}, []models.Actor{pitt, ramsey})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 10, Name: "Bradford Lone"})
+
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{})
+	_, err := session.Run(ctx, `MATCH (a:Actor {tmdb_id: 287}) SET a.profile_path = "/pitt.jpg", a.popularity = 42.5`, nil)
+	session.Close(ctx)
+	if err != nil {
+		t.Fatalf("enriching actor failed: %v", err)
+	}
+
+	time.Sleep(2 * time.Second)
+
+	results, err := testDriver.SearchActors(ctx, "Brad", 10)
+	if err != nil {
+		t.Fatalf("SearchActors failed: %v", err)
+	}
+	byID := map[int]SearchResult{}
+	for _, r := range results {
+		byID[r.Actor.TmdbID] = r
+	}
+
+	if got := byID[287]; got.ProfilePath != "/pitt.jpg" || got.Popularity != 42.5 || got.KnownFor != "Fight Club" {
+		t.Errorf("expected Pitt enriched and known for Fight Club (Norton has more co-stars than Ramsey), got %+v", got)
+	}
+	if got := byID[9]; got.ProfilePath != "" || got.Popularity != 0 || got.KnownFor != "Small Film" {
+		t.Errorf("expected Ramsey unenriched and known for Small Film, got %+v", got)
+	}
+	if got, ok := byID[10]; !ok || got.KnownFor != "" {
+		t.Errorf("expected Lone found with no known-for movie, got %+v (found %v)", got, ok)
+	}
+
+	profile, err := testDriver.GetActor(ctx, 287)
+	if err != nil || profile == nil || profile.ProfilePath != "/pitt.jpg" {
+		t.Errorf("expected Pitt's profile to carry the stored photo, got %+v, %v", profile, err)
+	}
+}
+
 func TestSearchActors_Limit(t *testing.T) {
 	clearGraph(t)
 	ctx := context.Background()
@@ -307,6 +598,37 @@ func TestGetStats(t *testing.T) {
 	}
 }
 
+func TestSetLastIngestedAt(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	stats, err := testDriver.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if !stats.LastIngestAt.IsZero() {
+		t.Errorf("expected a zero time before any ingest, got %v", stats.LastIngestAt)
+	}
+
+	at := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+	testDriver.SetLastIngestedPage(ctx, 7)
+	if err := testDriver.SetLastIngestedAt(ctx, at); err != nil {
+		t.Fatalf("SetLastIngestedAt failed: %v", err)
+	}
+
+	stats, err = testDriver.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if !stats.LastIngestAt.Equal(at) {
+		t.Errorf("expected last ingest at %v, got %v", at, stats.LastIngestAt)
+	}
+	// It shares the state node with resume progress without disturbing it.
+	if page, _ := testDriver.GetLastIngestedPage(ctx); page != 7 {
+		t.Errorf("expected last page 7 to survive, got %d", page)
+	}
+}
+
 func TestGetLastIngestedPage_EmptyGraph(t *testing.T) {
 	clearGraph(t)
 	ctx := context.Background()
@@ -438,3 +760,564 @@ func TestVerifyConnectivity(t *testing.T) {
 		t.Fatalf("VerifyConnectivity failed: %v", err)
 	}
 }
+
+func TestMergeActors(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// Actor 1 and 10 are the same person under two TMDB ids.
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Brad Pitt"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 10, Name: "Brad Pitt"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 2, Name: "Edward Norton"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 3, Name: "Angelina Jolie"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 4, Name: "Morgan Freeman"})
+
+	fightClub := models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999}
+	testDriver.CreateCostarEdge(ctx, 1, 2, fightClub)
+	// Duplicate collaboration recorded on both ids, in the opposite direction.
+	testDriver.CreateCostarEdge(ctx, 2, 10, fightClub)
+	testDriver.CreateCostarEdge(ctx, 10, 3, models.Movie{TmdbID: 1000, Title: "Mr. & Mrs. Smith", Year: 2005})
+	testDriver.CreateCostarEdge(ctx, 4, 10, models.Movie{TmdbID: 807, Title: "Se7en", Year: 1995})
+	// An edge between the two duplicates must not become a self-loop.
+	testDriver.CreateCostarEdge(ctx, 1, 10, models.Movie{TmdbID: 999, Title: "Self", Year: 2000})
+
+	if err := testDriver.MergeActors(ctx, 1, 10); err != nil {
+		t.Fatalf("MergeActors failed: %v", err)
+	}
+
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, "MATCH (a:Actor {tmdb_id: 10}) RETURN count(a) AS c", nil)
+	if err != nil {
+		t.Fatalf("merged node query failed: %v", err)
+	}
+	record, err := result.Single(ctx)
+	if err != nil {
+		t.Fatalf("expected one record: %v", err)
+	}
+	if c, _ := record.Get("c"); c.(int64) != 0 {
+		t.Errorf("expected merged node to be deleted, got %d", c)
+	}
+
+	result, err = session.Run(ctx,
+		`MATCH (:Actor {tmdb_id: 1})-[r:COSTARRED]-(o:Actor)
+		 RETURN o.tmdb_id AS other, r.tmdb_movie_id AS movie, r.decade AS decade
+		 ORDER BY other, movie`, nil)
+	if err != nil {
+		t.Fatalf("kept node edge query failed: %v", err)
+	}
+	records, err := result.Collect(ctx)
+	if err != nil {
+		t.Fatalf("collecting edges failed: %v", err)
+	}
+
+	// Re-pointed edges keep their decade, so decade filters still see them.
+	want := [][3]int64{{2, 550, 1990}, {3, 1000, 2000}, {4, 807, 1990}}
+	if len(records) != len(want) {
+		t.Fatalf("expected %d edges on kept node, got %d", len(want), len(records))
+	}
+	for i, rec := range records {
+		other, _ := rec.Get("other")
+		movie, _ := rec.Get("movie")
+		decade, _ := rec.Get("decade")
+		if other.(int64) != want[i][0] || movie.(int64) != want[i][1] || decade != want[i][2] {
+			t.Errorf("edge %d: expected %v, got [%v %v %v]", i, want[i], other, movie, decade)
+		}
+	}
+}
+
+func TestMergeActors_Missing(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Brad Pitt"})
+
+	if err := testDriver.MergeActors(ctx, 1, 10); err == nil {
+		t.Error("expected error merging a missing actor, got nil")
+	}
+	if err := testDriver.MergeActors(ctx, 1, 1); err == nil {
+		t.Error("expected error merging an actor into itself, got nil")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// Chain A - B - C, plus an isolated D
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Actor A"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 2, Name: "Actor B"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 3, Name: "Actor C"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 4, Name: "Actor D"})
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 100, Title: "Movie One", Year: 2000})
+	testDriver.CreateCostarEdge(ctx, 2, 3, models.Movie{TmdbID: 200, Title: "Movie Two", Year: 2010})
+
+	tests := []struct {
+		a, b, maxHops, want int
+	}{
+		{1, 2, 6, 1},
+		{1, 3, 6, 2},
+		{1, 3, 1, Unconnected}, // beyond the bound
+		{1, 4, 6, Unconnected},
+	}
+	for _, tt := range tests {
+		got, err := testDriver.Distance(ctx, tt.a, tt.b, tt.maxHops)
+		if err != nil {
+			t.Fatalf("Distance(%d, %d, %d) failed: %v", tt.a, tt.b, tt.maxHops, err)
+		}
+		if got != tt.want {
+			t.Errorf("Distance(%d, %d, %d) = %d, want %d", tt.a, tt.b, tt.maxHops, got, tt.want)
+		}
+	}
+
+	if _, err := testDriver.Distance(ctx, 1, 3, 0); err == nil {
+		t.Error("expected an error for a zero bound")
+	}
+	// A failed query is an error, not a missing path.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if got, err := testDriver.Distance(canceled, 1, 3, 6); err == nil {
+		t.Errorf("expected an error on a canceled context, got %d", got)
+	}
+}
+
+func TestSampleDegreeDistribution(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// Empty graph is not an error
+	dist, err := testDriver.SampleDegreeDistribution(ctx, 5)
+	if err != nil {
+		t.Fatalf("SampleDegreeDistribution on empty graph failed: %v", err)
+	}
+	if len(dist) != 0 {
+		t.Errorf("expected empty distribution, got %v", dist)
+	}
+
+	// Four actors, all pairwise connected: every sampled pair is 1 degree apart
+	cast := []models.Actor{
+		{TmdbID: 1, Name: "Actor A"},
+		{TmdbID: 2, Name: "Actor B"},
+		{TmdbID: 3, Name: "Actor C"},
+		{TmdbID: 4, Name: "Actor D"},
+	}
+	testDriver.IngestMovieCast(ctx, models.Movie{TmdbID: 100, Title: "Movie One", Year: 2000}, cast)
+
+	dist, err = testDriver.SampleDegreeDistribution(ctx, 2)
+	if err != nil {
+		t.Fatalf("SampleDegreeDistribution failed: %v", err)
+	}
+	if dist[1] != 2 || len(dist) != 1 {
+		t.Errorf("expected {1: 2}, got %v", dist)
+	}
+
+	if _, err := testDriver.SampleDegreeDistribution(ctx, 0); err == nil {
+		t.Error("expected an error for a sample size of 0")
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// A - B path, with C and D hanging off A and B respectively
+	for i, name := range []string{"Actor A", "Actor B", "Actor C", "Actor D"} {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: i + 1, Name: name})
+	}
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 100, Title: "Movie One", Year: 2000})
+	testDriver.CreateCostarEdge(ctx, 3, 1, models.Movie{TmdbID: 200, Title: "Movie Two", Year: 2005})
+	testDriver.CreateCostarEdge(ctx, 2, 4, models.Movie{TmdbID: 300, Title: "Movie Three", Year: 2010})
+	testDriver.CreateCostarEdge(ctx, 2, 4, models.Movie{TmdbID: 400, Title: "Movie Four", Year: 2015})
+
+	edges, err := testDriver.Neighbors(ctx, []int{1, 2}, 10)
+	if err != nil {
+		t.Fatalf("Neighbors failed: %v", err)
+	}
+
+	// The A-B edge is internal to the id set; B-D collapses to one edge.
+	got := map[[2]int]bool{}
+	for _, e := range edges {
+		got[[2]int{e.FromID, e.Actor.TmdbID}] = true
+	}
+	if len(edges) != 2 || !got[[2]int{1, 3}] || !got[[2]int{2, 4}] {
+		t.Errorf("expected edges 1->3 and 2->4, got %+v", edges)
+	}
+
+	edges, err = testDriver.Neighbors(ctx, []int{1, 2}, 1)
+	if err != nil {
+		t.Fatalf("Neighbors with limit failed: %v", err)
+	}
+	if len(edges) != 1 {
+		t.Errorf("expected limit to cap edges at 1, got %d", len(edges))
+	}
+}
+
+func TestGetActor(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Brad Pitt"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 2, Name: "Edward Norton"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 3, Name: "Morgan Freeman"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 4, Name: "Loner"})
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999})
+	testDriver.CreateCostarEdge(ctx, 3, 1, models.Movie{TmdbID: 807, Title: "Se7en", Year: 1995})
+
+	profile, err := testDriver.GetActor(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetActor failed: %v", err)
+	}
+	if profile == nil || profile.Actor.Name != "Brad Pitt" {
+		t.Fatalf("expected Brad Pitt, got %+v", profile)
+	}
+	if profile.Connections != 2 {
+		t.Errorf("expected 2 connections, got %d", profile.Connections)
+	}
+	if len(profile.Movies) != 2 || profile.Movies[0].Title != "Fight Club" || profile.Movies[1].Title != "Se7en" {
+		t.Errorf("expected movies newest first, got %+v", profile.Movies)
+	}
+
+	loner, err := testDriver.GetActor(ctx, 4)
+	if err != nil {
+		t.Fatalf("GetActor for unconnected actor failed: %v", err)
+	}
+	if loner == nil || loner.Connections != 0 || len(loner.Movies) != 0 {
+		t.Errorf("expected unconnected profile, got %+v", loner)
+	}
+
+	missing, err := testDriver.GetActor(ctx, 999)
+	if err != nil {
+		t.Fatalf("GetActor for missing actor failed: %v", err)
+	}
+	if missing != nil {
+		t.Errorf("expected nil for missing actor, got %+v", missing)
+	}
+}
+
+func TestGetActorByID(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 287, Name: "Brad Pitt"})
+
+	actor, err := testDriver.GetActorByID(ctx, 287)
+	if err != nil {
+		t.Fatalf("GetActorByID failed: %v", err)
+	}
+	if actor == nil || *actor != (models.Actor{TmdbID: 287, Name: "Brad Pitt"}) {
+		t.Errorf("expected Brad Pitt, got %+v", actor)
+	}
+
+	if actor, err := testDriver.GetActorByID(ctx, 99); err != nil || actor != nil {
+		t.Errorf("expected nil for an unknown id, got %+v, %v", actor, err)
+	}
+}
+
+func TestGetCostars(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Brad Pitt"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 2, Name: "Edward Norton"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 3, Name: "George Clooney"})
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999})
+	testDriver.CreateCostarEdge(ctx, 1, 3, models.Movie{TmdbID: 161, Title: "Ocean's Eleven", Year: 2001})
+	testDriver.CreateCostarEdge(ctx, 3, 1, models.Movie{TmdbID: 163, Title: "Ocean's Twelve", Year: 2004})
+
+	costars, err := testDriver.GetCostars(ctx, 1, 10)
+	if err != nil {
+		t.Fatalf("GetCostars failed: %v", err)
+	}
+	if len(costars) != 2 {
+		t.Fatalf("expected 2 costars, got %+v", costars)
+	}
+	if costars[0].Actor.Name != "George Clooney" || costars[0].SharedMovies != 2 {
+		t.Errorf("expected George Clooney with 2 shared movies first, got %+v", costars[0])
+	}
+	if costars[1].Actor.Name != "Edward Norton" || costars[1].SharedMovies != 1 {
+		t.Errorf("expected Edward Norton with 1 shared movie second, got %+v", costars[1])
+	}
+	if m := costars[0].Movie; m.TmdbID != 163 || m.Title != "Ocean's Twelve" || m.Year != 2004 {
+		t.Errorf("expected the most recent shared movie, Ocean's Twelve, got %+v", m)
+	}
+	if m := costars[1].Movie; m.TmdbID != 550 || m.Title != "Fight Club" || m.Year != 1999 {
+		t.Errorf("expected Fight Club for Edward Norton, got %+v", m)
+	}
+}
+
+func TestChain(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Brad Pitt"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 2, Name: "George Clooney"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 3, Name: "Julia Roberts"})
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 161, Title: "Ocean's Eleven", Year: 2001})
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 163, Title: "Ocean's Twelve", Year: 2004})
+	testDriver.CreateCostarEdge(ctx, 2, 3, models.Movie{TmdbID: 161, Title: "Ocean's Eleven", Year: 2001})
+
+	steps, err := testDriver.Chain(ctx, []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("Chain failed: %v", err)
+	}
+	if len(steps) != 5 {
+		t.Fatalf("expected 5 steps, got %+v", steps)
+	}
+	if steps[0].Actor.Name != "Brad Pitt" || steps[2].Actor.TmdbID != 2 || steps[4].Actor.Name != "Julia Roberts" {
+		t.Errorf("expected the actors in chain order, got %+v", steps)
+	}
+	if steps[1].MovieTitle != "Ocean's Twelve" || steps[3].MovieTitle != "Ocean's Eleven" {
+		t.Errorf("expected each link labelled with the latest shared movie, got %q and %q", steps[1].MovieTitle, steps[3].MovieTitle)
+	}
+
+	if steps, err := testDriver.Chain(ctx, []int{3}); err != nil || len(steps) != 1 {
+		t.Errorf("expected a lone actor to be a valid chain, got %+v, %v", steps, err)
+	}
+
+	for _, ids := range [][]int{{1, 3}, {1, 2, 1, 3}, {1, 99}, {99}, {}} {
+		if _, err := testDriver.Chain(ctx, ids); err != ErrBrokenChain {
+			t.Errorf("Chain(%v): expected ErrBrokenChain, got %v", ids, err)
+		}
+	}
+}
+
+func TestReachCounts(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// 1's co-stars are 2 and 3; 4 is reachable through both and 5 through 3,
+	// so two actors are at degree 2, and 7 beyond them at 3. 6 is isolated.
+	for id := 1; id <= 7; id++ {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)})
+	}
+	movie := models.Movie{TmdbID: 100, Title: "Movie", Year: 2000}
+	for _, e := range [][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 4}, {3, 5}, {4, 7}, {5, 7}} {
+		testDriver.CreateCostarEdge(ctx, e[0], e[1], movie)
+	}
+
+	got, err := testDriver.ReachCounts(ctx, 1, 4)
+	if err != nil {
+		t.Fatalf("ReachCounts failed: %v", err)
+	}
+	want := map[int]int{1: 2, 2: 2, 3: 1, 4: 0}
+	if !maps.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	got, err = testDriver.ReachCounts(ctx, 6, 3)
+	if err != nil {
+		t.Fatalf("ReachCounts failed: %v", err)
+	}
+	if want := map[int]int{1: 0, 2: 0, 3: 0}; !maps.Equal(got, want) {
+		t.Errorf("expected zeros for an isolated actor, got %v", got)
+	}
+
+	for _, depth := range []int{0, MaxReachDepth + 1} {
+		if _, err := testDriver.ReachCounts(ctx, 1, depth); err == nil {
+			t.Errorf("expected an error for depth %d", depth)
+		}
+	}
+}
+
+func TestActorsWithinDegrees(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// The same graph as TestReachCounts: 2 and 3 are 1's co-stars, 4 and 5 are
+	// two degrees away, and 7 is further. 4 shares movies with three actors,
+	// so it leads its group.
+	for id := 1; id <= 7; id++ {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)})
+	}
+	movie := models.Movie{TmdbID: 100, Title: "Movie", Year: 2000}
+	for _, e := range [][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 4}, {3, 5}, {4, 7}, {5, 7}} {
+		testDriver.CreateCostarEdge(ctx, e[0], e[1], movie)
+	}
+
+	groups, err := testDriver.ActorsWithinDegrees(ctx, 1, 2, 10)
+	if err != nil {
+		t.Fatalf("ActorsWithinDegrees failed: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("expected two groups, got %+v", groups)
+	}
+	if g := groups[0]; g.Degree != 1 || g.Count != 2 || len(g.Actors) != 2 || g.Actors[0].TmdbID != 3 {
+		t.Errorf("expected 2 and 3 at one degree, best connected first, got %+v", g)
+	}
+	if g := groups[1]; g.Degree != 2 || g.Count != 2 || len(g.Actors) != 2 || g.Actors[0].TmdbID != 4 || g.Actors[1].TmdbID != 5 {
+		t.Errorf("expected 4 and 5 at two degrees, each once, got %+v", g)
+	}
+
+	groups, err = testDriver.ActorsWithinDegrees(ctx, 1, 2, 1)
+	if err != nil {
+		t.Fatalf("ActorsWithinDegrees failed: %v", err)
+	}
+	for _, g := range groups {
+		if g.Count != 2 || len(g.Actors) != 1 || g.Capped {
+			t.Errorf("expected the full count but one actor with limit 1, got %+v", g)
+		}
+	}
+
+	groups, err = testDriver.ActorsWithinDegrees(ctx, 1, 1, 10)
+	if err != nil || len(groups) != 1 || groups[0].Count != 2 {
+		t.Errorf("expected only the direct co-stars for one degree, got %+v, %v", groups, err)
+	}
+
+	groups, err = testDriver.ActorsWithinDegrees(ctx, 6, 2, 10)
+	if err != nil || len(groups) != 2 || groups[0].Count != 0 || groups[1].Count != 0 {
+		t.Errorf("expected empty groups for an isolated actor, got %+v, %v", groups, err)
+	}
+
+	for _, degrees := range []int{0, MaxNetworkDegrees + 1} {
+		if _, err := testDriver.ActorsWithinDegrees(ctx, 1, degrees, 10); err == nil {
+			t.Errorf("expected an error for %d degrees", degrees)
+		}
+	}
+}
+
+func TestGetRandomConnectedPair(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Actor A"})
+	if _, _, err := testDriver.GetRandomConnectedPair(ctx); err != ErrNotEnoughActors {
+		t.Fatalf("expected ErrNotEnoughActors, got %v", err)
+	}
+
+	// Actor 3 has no edges and must never be picked.
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 2, Name: "Actor B"})
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 3, Name: "Actor C"})
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 100, Title: "Movie One", Year: 2000})
+
+	for range 5 {
+		a, b, err := testDriver.GetRandomConnectedPair(ctx)
+		if err != nil {
+			t.Fatalf("GetRandomConnectedPair failed: %v", err)
+		}
+		if a == b || a == 3 || b == 3 {
+			t.Errorf("expected the pair {1, 2}, got (%d, %d)", a, b)
+		}
+	}
+}
+
+func TestTopActors(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// A star around actor 2, plus 1-3; actor 5 has no edges.
+	for id := 1; id <= 5; id++ {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)})
+	}
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 100, Title: "Movie One", Year: 2000})
+	testDriver.CreateCostarEdge(ctx, 2, 3, models.Movie{TmdbID: 101, Title: "Movie Two", Year: 2001})
+	testDriver.CreateCostarEdge(ctx, 2, 4, models.Movie{TmdbID: 102, Title: "Movie Three", Year: 2002})
+	testDriver.CreateCostarEdge(ctx, 1, 3, models.Movie{TmdbID: 103, Title: "Movie Four", Year: 2003})
+
+	actors, err := testDriver.TopActors(ctx, 0, 3)
+	if err != nil {
+		t.Fatalf("TopActors failed: %v", err)
+	}
+	var ids []int
+	for _, a := range actors {
+		ids = append(ids, a.Actor.TmdbID)
+	}
+	if !slices.Equal(ids, []int{2, 1, 3}) {
+		t.Errorf("expected actors 2, 1, 3 by edge count, got %v", ids)
+	}
+	if actors[0].Connections != 3 || actors[0].Actor.Name != "Actor 2" || actors[1].Connections != 2 {
+		t.Errorf("expected edge counts 3 and 2 for the top two, got %+v", actors)
+	}
+
+	actors, err = testDriver.TopActors(ctx, 0, 10)
+	if err != nil || len(actors) != 4 {
+		t.Errorf("expected only the four connected actors, got %+v, %v", actors, err)
+	}
+}
+
+func TestTopActors_Paging(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// Actor 1 plays opposite 2-6, 2 also opposite 3 and 4, and 3-6 otherwise
+	// tie, so pages have to break ties by id to line up.
+	for id := 1; id <= 6; id++ {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)})
+	}
+	movie := 100
+	for _, pair := range [][2]int{{1, 2}, {1, 3}, {1, 4}, {1, 5}, {1, 6}, {2, 3}, {2, 4}, {5, 6}} {
+		testDriver.CreateCostarEdge(ctx, pair[0], pair[1], models.Movie{TmdbID: movie, Title: fmt.Sprintf("Movie %d", movie), Year: 2000})
+		movie++
+	}
+
+	var ids []int
+	for offset := 0; offset < 6; offset += 2 {
+		page, err := testDriver.TopActors(ctx, offset, 2)
+		if err != nil {
+			t.Fatalf("TopActors(%d, 2) failed: %v", offset, err)
+		}
+		if len(page) != 2 {
+			t.Fatalf("expected a full page at offset %d, got %+v", offset, page)
+		}
+		for _, a := range page {
+			ids = append(ids, a.Actor.TmdbID)
+		}
+	}
+	if want := []int{1, 2, 3, 4, 5, 6}; !slices.Equal(ids, want) {
+		t.Errorf("expected pages to run %v, got %v", want, ids)
+	}
+
+	if page, err := testDriver.TopActors(ctx, 6, 2); err != nil || len(page) != 0 {
+		t.Errorf("expected an empty page past the end, got %+v, %v", page, err)
+	}
+}
+
+func TestSetAndGetLastIngestedMovie(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	if _, _, ok, err := testDriver.GetLastIngestedMovie(ctx); err != nil || ok {
+		t.Fatalf("expected no movie state on empty graph, got ok=%v err=%v", ok, err)
+	}
+
+	testDriver.SetLastIngestedPage(ctx, 4)
+	if err := testDriver.SetLastIngestedMovie(ctx, 5, 7); err != nil {
+		t.Fatalf("SetLastIngestedMovie failed: %v", err)
+	}
+
+	page, index, ok, err := testDriver.GetLastIngestedMovie(ctx)
+	if err != nil {
+		t.Fatalf("GetLastIngestedMovie failed: %v", err)
+	}
+	if !ok || page != 5 || index != 7 {
+		t.Errorf("expected page 5 index 7, got page %d index %d ok=%v", page, index, ok)
+	}
+
+	// Completing the page clears the partial position but keeps the page.
+	testDriver.SetLastIngestedPage(ctx, 5)
+	if _, _, ok, _ := testDriver.GetLastIngestedMovie(ctx); ok {
+		t.Error("expected movie state to be cleared after SetLastIngestedPage")
+	}
+	if last, _ := testDriver.GetLastIngestedPage(ctx); last != 5 {
+		t.Errorf("expected last page 5, got %d", last)
+	}
+}
+
+func TestHasActors(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	if ok, err := testDriver.HasActors(ctx); err != nil || ok {
+		t.Fatalf("expected an empty graph to have no actors, got %v, %v", ok, err)
+	}
+
+	// Ingest state alone doesn't make the graph useful.
+	testDriver.SetLastIngestedPage(ctx, 1)
+	if ok, _ := testDriver.HasActors(ctx); ok {
+		t.Error("expected only ingest state to count as empty")
+	}
+
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1, Name: "Kevin Bacon"})
+	if ok, err := testDriver.HasActors(ctx); err != nil || !ok {
+		t.Errorf("expected actors after an upsert, got %v, %v", ok, err)
+	}
+}
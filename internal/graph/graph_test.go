@@ -0,0 +1,280 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/internal/requestid"
+)
+
+func TestDecodePathActor(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       any
+		wantID   int
+		wantName string
+	}{
+		{"complete", map[string]any{"id": int64(287), "name": "Brad Pitt"}, 287, "Brad Pitt"},
+		{"missing name", map[string]any{"id": int64(287)}, 287, ""},
+		{"null name", map[string]any{"id": int64(287), "name": nil}, 287, ""},
+		{"wrong types", map[string]any{"id": "287", "name": 42}, 0, ""},
+		{"not a map", nil, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := decodePathActor(tt.in)
+			if a == nil {
+				t.Fatal("expected an actor, got nil")
+			}
+			if a.TmdbID != tt.wantID || a.Name != tt.wantName {
+				t.Errorf("expected {%d %q}, got {%d %q}", tt.wantID, tt.wantName, a.TmdbID, a.Name)
+			}
+		})
+	}
+}
+
+func TestDecodePathMovie(t *testing.T) {
+	title, year := decodePathMovie(map[string]any{"title": "Fight Club", "year": int64(1999)})
+	if title != "Fight Club" || year != 1999 {
+		t.Errorf("expected Fight Club (1999), got %q (%d)", title, year)
+	}
+
+	title, year = decodePathMovie(map[string]any{"year": nil})
+	if title != "" || year != 0 {
+		t.Errorf("expected zero values for a record missing its fields, got %q (%d)", title, year)
+	}
+}
+
+func TestDecodePathRecord(t *testing.T) {
+	actor := func(id int64, name string) any { return map[string]any{"id": id, "name": name} }
+	movie := func(title string, year int64) any { return map[string]any{"title": title, "year": year} }
+
+	t.Run("alternating", func(t *testing.T) {
+		steps := decodePathRecord(
+			[]any{actor(1, "Kevin Bacon"), actor(2, "Tom Hanks"), actor(3, "Meg Ryan")},
+			[]any{movie("Apollo 13", 1995), movie("Sleepless in Seattle", 1993)},
+		)
+		if len(steps) != 5 {
+			t.Fatalf("expected 5 steps, got %d: %+v", len(steps), steps)
+		}
+		for i, want := range []string{"Kevin Bacon", "Tom Hanks", "Meg Ryan"} {
+			a := steps[i*2].Actor
+			if a == nil || a.Name != want || a.TmdbID != i+1 {
+				t.Errorf("step %d: expected actor %d %q, got %+v", i*2, i+1, want, a)
+			}
+		}
+		if steps[1].Actor != nil || steps[1].MovieTitle != "Apollo 13" || steps[1].MovieYear != 1995 {
+			t.Errorf("step 1: expected Apollo 13 (1995), got %+v", steps[1])
+		}
+		if steps[3].MovieTitle != "Sleepless in Seattle" || steps[3].MovieYear != 1993 {
+			t.Errorf("step 3: expected Sleepless in Seattle (1993), got %+v", steps[3])
+		}
+	})
+
+	t.Run("single actor", func(t *testing.T) {
+		steps := decodePathRecord([]any{actor(1, "Kevin Bacon")}, []any{})
+		if len(steps) != 1 || steps[0].Actor == nil || steps[0].Actor.Name != "Kevin Bacon" {
+			t.Errorf("expected a single Kevin Bacon step, got %+v", steps)
+		}
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		steps := decodePathRecord(
+			[]any{map[string]any{"id": int64(1)}, actor(2, "Tom Hanks")},
+			[]any{map[string]any{"year": int64(1995)}},
+		)
+		if len(steps) != 3 {
+			t.Fatalf("expected 3 steps, got %d: %+v", len(steps), steps)
+		}
+		if steps[0].Actor.TmdbID != 1 || steps[0].Actor.Name != "" {
+			t.Errorf("expected nameless actor 1, got %+v", steps[0].Actor)
+		}
+		if steps[1].MovieTitle != "" || steps[1].MovieYear != 1995 {
+			t.Errorf("expected untitled 1995 movie, got %+v", steps[1])
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if steps := decodePathRecord(nil, nil); len(steps) != 0 {
+			t.Errorf("expected no steps, got %+v", steps)
+		}
+	})
+}
+
+func TestIsChronological(t *testing.T) {
+	path := func(years ...int) []PathStep {
+		steps := []PathStep{{Actor: &models.Actor{TmdbID: 1}}}
+		for i, year := range years {
+			steps = append(steps, PathStep{MovieTitle: "Movie", MovieYear: year}, PathStep{Actor: &models.Actor{TmdbID: i + 2}})
+		}
+		return steps
+	}
+	tests := []struct {
+		name  string
+		steps []PathStep
+		want  bool
+	}{
+		{"rising", path(1995, 2001, 2010), true},
+		{"same year twice", path(1995, 1995), true},
+		{"one movie", path(2010), true},
+		{"no movies", path(), true},
+		{"jumps back", path(2010, 1995, 2020), false},
+		{"unknown year", path(1995, 0, 2010), false},
+	}
+	for _, tt := range tests {
+		if got := IsChronological(tt.steps); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// newObservingDriver returns a Driver with just enough set up for observe.
+func newObservingDriver(t *testing.T, logger *slog.Logger, slowQuery time.Duration) *Driver {
+	t.Helper()
+	hist, err := noop.NewMeterProvider().Meter("test").Float64Histogram("test")
+	if err != nil {
+		t.Fatalf("creating histogram: %v", err)
+	}
+	return &Driver{queryDuration: hist, logger: logger, slowQuery: slowQuery}
+}
+
+func TestObserve_LogsSlowQueries(t *testing.T) {
+	var buf bytes.Buffer
+	d := newObservingDriver(t, slog.New(slog.NewJSONHandler(&buf, nil)), 100*time.Millisecond)
+	ctx := context.Background()
+
+	// A query that started 250ms ago is past the threshold; one that just
+	// started isn't.
+	d.observe(ctx, "ShortestPath", time.Now().Add(-250*time.Millisecond), nil, "actor_a", 4724, "actor_b", 31)
+	d.observe(ctx, "SearchActors", time.Now(), nil, "prefix", "kev", "limit", 15)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected exactly the slow query to be logged, got %d lines:\n%s", len(lines), buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("bad log line: %v", err)
+	}
+	if entry["msg"] != "slow query" || entry["level"] != "WARN" || entry["query"] != "ShortestPath" {
+		t.Errorf("unexpected log line %v", entry)
+	}
+	if entry["actor_a"] != float64(4724) || entry["actor_b"] != float64(31) {
+		t.Errorf("expected the query parameters in the log line, got %v", entry)
+	}
+	if _, ok := entry["request_id"]; ok {
+		t.Errorf("expected no request_id outside a request, got %v", entry["request_id"])
+	}
+	if ms, _ := entry["duration_ms"].(float64); ms < 250 {
+		t.Errorf("expected duration_ms of at least 250, got %v", entry["duration_ms"])
+	}
+}
+
+func TestObserve_NoLoggerOrThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	ctx := context.Background()
+	long := time.Now().Add(-time.Hour)
+
+	newObservingDriver(t, nil, time.Millisecond).observe(ctx, "GetStats", long, nil)
+	newObservingDriver(t, logger, 0).observe(ctx, "GetStats", long, nil)
+
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing logged without a logger or threshold, got %s", buf.String())
+	}
+}
+
+func TestObserve_LogsFailedQueriesWithRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	d := newObservingDriver(t, slog.New(slog.NewJSONHandler(&buf, nil)), 0)
+	ctx := requestid.NewContext(context.Background(), "support-ticket-42")
+
+	d.observe(ctx, "GetActor", time.Now(), errors.New("connection reset"))
+	// A client hanging up isn't a database problem.
+	d.observe(ctx, "SearchActors", time.Now(), fmt.Errorf("error searching actors: %w", context.Canceled))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected exactly the failed query to be logged, got %d lines:\n%s", len(lines), buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("bad log line: %v", err)
+	}
+	if entry["msg"] != "query failed" || entry["level"] != "ERROR" || entry["query"] != "GetActor" {
+		t.Errorf("unexpected log line %v", entry)
+	}
+	if entry["request_id"] != "support-ticket-42" {
+		t.Errorf("expected the request id from the context, got %v", entry["request_id"])
+	}
+	if entry["error"] != "connection reset" {
+		t.Errorf("expected the query error, got %v", entry["error"])
+	}
+}
+
+func TestTxTimeout(t *testing.T) {
+	if got := txTimeout(context.Background()); got != nil {
+		t.Errorf("expected no transaction config without a deadline, got %d", len(got))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var cfg neo4j.TransactionConfig
+	for _, apply := range txTimeout(ctx) {
+		apply(&cfg)
+	}
+	if cfg.Timeout <= 4*time.Second || cfg.Timeout > 5*time.Second {
+		t.Errorf("expected a timeout just under 5s, got %s", cfg.Timeout)
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	cfg = neo4j.TransactionConfig{}
+	for _, apply := range txTimeout(expired) {
+		apply(&cfg)
+	}
+	if cfg.Timeout != time.Millisecond {
+		t.Errorf("expected an expired deadline to clamp to 1ms rather than 0 (no timeout), got %s", cfg.Timeout)
+	}
+}
+
+func TestConnect_GivesUpAfterRetries(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	cfg := config.Config{DB: config.DBConfig{
+		// Nothing listens on port 1, so every attempt fails at once.
+		URI:            "bolt://127.0.0.1:1",
+		StartupRetries: 2,
+		StartupBackoff: time.Millisecond,
+	}}
+
+	d, err := Connect(context.Background(), cfg, logger)
+	if d != nil || err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("expected failure after three attempts, got %v, %v", d, err)
+	}
+	if n := strings.Count(logs.String(), "neo4j not ready, retrying"); n != 2 {
+		t.Errorf("expected each of the two retries logged, got %d\n%s", n, logs.String())
+	}
+}
+
+func TestConnect_StopsWithContext(t *testing.T) {
+	cfg := config.Config{DB: config.DBConfig{URI: "bolt://127.0.0.1:1", StartupRetries: 5, StartupBackoff: time.Hour}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := Connect(ctx, cfg, slog.New(slog.DiscardHandler)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context's error while waiting to retry, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+//go:build integration
+
+// Package graphtest starts the throwaway Neo4j that integration tests run
+// against.
+package graphtest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tcneo4j "github.com/testcontainers/testcontainers-go/modules/neo4j"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+)
+
+// Driver is what Start needs of a graph.Driver. It's an interface so graph's
+// own tests can use Start without an import cycle.
+type Driver interface {
+	SetupSchema(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+// Start runs a Neo4j container without authentication, connects to it with
+// connect and sets up the schema. stop closes the driver and removes the
+// container.
+func Start[D Driver](ctx context.Context, connect func(context.Context, config.Config) (D, error)) (d D, stop func(), err error) {
+	container, err := tcneo4j.Run(ctx, "neo4j:5", tcneo4j.WithoutAuthentication())
+	if err != nil {
+		return d, nil, fmt.Errorf("failed to start neo4j container: %w", err)
+	}
+	terminate := func() { container.Terminate(ctx) }
+
+	boltURL, err := container.BoltUrl(ctx)
+	if err != nil {
+		terminate()
+		return d, nil, fmt.Errorf("failed to get bolt url: %w", err)
+	}
+	cfg := config.Config{DB: config.DBConfig{URI: boltURL, User: "neo4j"}}
+
+	// Neo4j may need a moment to be ready for auth-free connections
+	for range 10 {
+		d, err = connect(ctx, cfg)
+		if err == nil {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	if err != nil {
+		terminate()
+		return d, nil, fmt.Errorf("failed to create driver: %w", err)
+	}
+	stop = func() {
+		d.Close(ctx)
+		terminate()
+	}
+
+	if err := d.SetupSchema(ctx); err != nil {
+		stop()
+		return d, nil, fmt.Errorf("failed to setup schema: %w", err)
+	}
+	return d, stop, nil
+}
@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+)
+
+// Operational state lives on (:Meta {key}) nodes, one per concern, with each
+// value a typed property on the node. The ingest methods below read and write
+// the "ingest" node:
+//
+//   - last_page: the last fully ingested page.
+//   - movie_page, movie_index: the last movie written on a partial page,
+//     absent when the last run stopped on a page boundary.
+//   - last_ingest_completed: when an ingest run last finished.
+
+// ingestMetaKey is the Meta node holding ingest progress.
+const ingestMetaKey = "ingest"
+
+// GetMeta returns the properties of the Meta node key, without key itself.
+// The map is empty if the node doesn't exist. Integers come back as int64 and
+// times as time.Time, as the driver decodes them.
+func (d *Driver) GetMeta(ctx context.Context, key string) (map[string]any, error) {
+	cypher := "MATCH (m:Meta {key: $key}) RETURN properties(m) AS props"
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, map[string]any{"key": key})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s metadata: %w", key, err)
+	}
+
+	var props map[string]any
+	if result.Next(ctx) {
+		p, _ := result.Record().Get("props")
+		props, _ = p.(map[string]any)
+	}
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s metadata: %w", key, err)
+	}
+	if props == nil {
+		props = map[string]any{}
+	}
+	delete(props, "key")
+	return props, nil
+}
+
+// SetMeta merges values into the Meta node key, creating it if needed. A nil
+// value removes that property; properties not named are left alone.
+func (d *Driver) SetMeta(ctx context.Context, key string, values map[string]any) error {
+	cypher := "MERGE (m:Meta {key: $key}) SET m += $values"
+	params := map[string]any{"key": key, "values": values}
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+
+	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		if _, err := tx.Run(ctx, cypher, params); err != nil {
+			return nil, fmt.Errorf("error saving %s metadata: %w", key, err)
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// GetLastIngestedPage returns the last fully ingested page, or 0 before the
+// first run.
+func (d *Driver) GetLastIngestedPage(ctx context.Context) (int, error) {
+	meta, err := d.GetMeta(ctx, ingestMetaKey)
+	if err != nil {
+		return 0, err
+	}
+	page, _ := meta["last_page"].(int64)
+	return int(page), nil
+}
+
+// SetLastIngestedPage records a fully ingested page and clears any
+// movie-level position, which only ever describes the page after it.
+func (d *Driver) SetLastIngestedPage(ctx context.Context, page int) error {
+	return d.SetMeta(ctx, ingestMetaKey, map[string]any{
+		"last_page":   page,
+		"movie_page":  nil,
+		"movie_index": nil,
+	})
+}
+
+// GetLastIngestedMovie returns the page and zero-based index of the last
+// movie ingested on a partially completed page. ok is false when the last run
+// stopped on a page boundary, in which case GetLastIngestedPage is authoritative.
+func (d *Driver) GetLastIngestedMovie(ctx context.Context) (page, index int, ok bool, err error) {
+	meta, err := d.GetMeta(ctx, ingestMetaKey)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	p, ok := meta["movie_page"].(int64)
+	if !ok {
+		return 0, 0, false, nil // no partial page recorded
+	}
+	i, _ := meta["movie_index"].(int64)
+	return int(p), int(i), true, nil
+}
+
+// SetLastIngestedMovie records progress within a page so a resumed ingest can
+// skip movies that were already written.
+func (d *Driver) SetLastIngestedMovie(ctx context.Context, page, index int) error {
+	return d.SetMeta(ctx, ingestMetaKey, map[string]any{
+		"movie_page":  page,
+		"movie_index": index,
+	})
+}
+
+// SetLastIngestedAt records when an ingest run completed, for the stats
+// page's freshness line.
+func (d *Driver) SetLastIngestedAt(ctx context.Context, at time.Time) error {
+	return d.SetMeta(ctx, ingestMetaKey, map[string]any{"last_ingest_completed": at})
+}
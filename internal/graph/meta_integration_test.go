@@ -0,0 +1,92 @@
+//go:build integration
+
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+)
+
+func TestMeta_GetMissing(t *testing.T) {
+	clearGraph(t)
+
+	meta, err := testDriver.GetMeta(context.Background(), "nothing-here")
+	if err != nil {
+		t.Fatalf("GetMeta failed: %v", err)
+	}
+	if meta == nil || len(meta) != 0 {
+		t.Errorf("expected an empty map for a missing key, got %#v", meta)
+	}
+}
+
+func TestMeta_SetMergesAndRemoves(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	at := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+	if err := testDriver.SetMeta(ctx, "test", map[string]any{"count": 3, "name": "first", "at": at}); err != nil {
+		t.Fatalf("SetMeta failed: %v", err)
+	}
+	if err := testDriver.SetMeta(ctx, "test", map[string]any{"count": 4, "name": nil}); err != nil {
+		t.Fatalf("second SetMeta failed: %v", err)
+	}
+	if err := testDriver.SetMeta(ctx, "other", map[string]any{"count": 99}); err != nil {
+		t.Fatalf("SetMeta on another key failed: %v", err)
+	}
+
+	meta, err := testDriver.GetMeta(ctx, "test")
+	if err != nil {
+		t.Fatalf("GetMeta failed: %v", err)
+	}
+	if len(meta) != 2 {
+		t.Errorf("expected count and at only, got %#v", meta)
+	}
+	if meta["count"] != int64(4) {
+		t.Errorf("expected count 4 as int64, got %#v", meta["count"])
+	}
+	if got, ok := meta["at"].(time.Time); !ok || !got.Equal(at) {
+		t.Errorf("expected at %v as time.Time, got %#v", at, meta["at"])
+	}
+	if _, ok := meta["name"]; ok {
+		t.Errorf("expected a nil value to remove name, got %#v", meta["name"])
+	}
+}
+
+func TestSetupSchema_MigratesIngestState(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+	if _, err := session.Run(ctx, "CREATE (:IngestState {last_page: 12, movie_page: 13, movie_index: 4})", nil); err != nil {
+		t.Fatalf("failed to create legacy state: %v", err)
+	}
+
+	if err := testDriver.SetupSchema(ctx); err != nil {
+		t.Fatalf("SetupSchema failed: %v", err)
+	}
+
+	page, err := testDriver.GetLastIngestedPage(ctx)
+	if err != nil || page != 12 {
+		t.Errorf("expected last page 12 after migration, got %d, %v", page, err)
+	}
+	moviePage, index, ok, err := testDriver.GetLastIngestedMovie(ctx)
+	if err != nil || !ok || moviePage != 13 || index != 4 {
+		t.Errorf("expected movie 13/4 after migration, got %d/%d (ok %v), %v", moviePage, index, ok, err)
+	}
+
+	result, err := session.Run(ctx, "MATCH (s:IngestState) RETURN count(s) AS c", nil)
+	if err != nil {
+		t.Fatalf("count query failed: %v", err)
+	}
+	record, err := result.Single(ctx)
+	if err != nil {
+		t.Fatalf("expected one record: %v", err)
+	}
+	if c, _ := record.Get("c"); c != int64(0) {
+		t.Errorf("expected the legacy node to be removed, %v remain", c)
+	}
+}
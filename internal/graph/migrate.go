@@ -0,0 +1,244 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+)
+
+// DefaultSearchAnalyzer is the analyzer the migrations build the actor name
+// index with. normalized_name is already lowercase words, so splitting on
+// whitespace is all it has to do.
+const DefaultSearchAnalyzer = "whitespace"
+
+// schemaMetaKey is the Meta node whose version property counts the migrations
+// applied so far.
+const schemaMetaKey = "schema"
+
+// migration is one step in the schema's history. Migrations are applied in
+// order and never edited once released; a change to the schema is a new
+// migration appended to the list. Each must be safe to re-run, because a
+// crash between applying one and recording it replays it on the next start,
+// and graphs that predate versioning replay them all.
+type migration struct {
+	name  string
+	apply func(ctx context.Context, d *Driver) error
+}
+
+var migrations = []migration{
+	{"actor id constraint and name index", func(ctx context.Context, d *Driver) error {
+		return d.runSchema(ctx,
+			"CREATE CONSTRAINT actor_tmdb_id IF NOT EXISTS FOR (a:Actor) REQUIRE a.tmdb_id IS UNIQUE",
+			"CREATE FULLTEXT INDEX actor_name IF NOT EXISTS FOR (a:Actor) ON EACH [a.name]",
+		)
+	}},
+	{"meta nodes replace IngestState", func(ctx context.Context, d *Driver) error {
+		return d.runSchema(ctx,
+			"CREATE CONSTRAINT meta_key IF NOT EXISTS FOR (m:Meta) REQUIRE m.key IS UNIQUE",
+			"MATCH (s:IngestState) MERGE (m:Meta {key: 'ingest'}) SET m += properties(s) DELETE s",
+		)
+	}},
+	{"costar edge decade index", func(ctx context.Context, d *Driver) error {
+		return d.runSchema(ctx,
+			"CREATE INDEX costarred_decade IF NOT EXISTS FOR ()-[r:COSTARRED]-() ON (r.decade)",
+		)
+	}},
+	{"accent-folding actor name index", func(ctx context.Context, d *Driver) error {
+		// A fulltext index's analyzer is fixed at creation, so the index is
+		// rebuilt under the same name.
+		return d.runSchema(ctx,
+			"DROP INDEX actor_name IF EXISTS",
+			"CREATE FULLTEXT INDEX actor_name IF NOT EXISTS FOR (a:Actor) ON EACH [a.name] OPTIONS {indexConfig: {`fulltext.analyzer`: 'standard-folding'}}",
+		)
+	}},
+	{"normalized actor names", func(ctx context.Context, d *Driver) error {
+		if err := d.backfillNormalizedNames(ctx); err != nil {
+			return err
+		}
+		// See DefaultSearchAnalyzer; ensureSearchAnalyzer swaps it for
+		// another when configured.
+		return d.runSchema(ctx,
+			"DROP INDEX actor_name IF EXISTS",
+			"CREATE FULLTEXT INDEX actor_name IF NOT EXISTS FOR (a:Actor) ON EACH [a.normalized_name] OPTIONS {indexConfig: {`fulltext.analyzer`: 'whitespace'}}",
+		)
+	}},
+	{"movie lookup indexes", func(ctx context.Context, d *Driver) error {
+		// Movies live on the edges, so finding one's cast or matching its
+		// title means indexing relationships.
+		return d.runSchema(ctx,
+			"CREATE INDEX costarred_movie_id IF NOT EXISTS FOR ()-[r:COSTARRED]-() ON (r.tmdb_movie_id)",
+			"CREATE FULLTEXT INDEX movie_title IF NOT EXISTS FOR ()-[r:COSTARRED]-() ON EACH [r.movie_title] OPTIONS {indexConfig: {`fulltext.analyzer`: 'standard-folding'}}",
+		)
+	}},
+	{"bacon number index", func(ctx context.Context, d *Driver) error {
+		return d.runSchema(ctx,
+			"CREATE INDEX actor_bacon_number IF NOT EXISTS FOR (a:Actor) ON (a.bacon_number)",
+		)
+	}},
+}
+
+// SchemaVersion returns the number of migrations applied to the graph.
+func (d *Driver) SchemaVersion(ctx context.Context) (int, error) {
+	meta, err := d.GetMeta(ctx, schemaMetaKey)
+	if err != nil {
+		return 0, err
+	}
+	version, _ := meta["version"].(int64)
+	return int(version), nil
+}
+
+// RunMigrations applies the migrations the graph hasn't had yet, recording
+// the version after each, and returns how many it applied. Servers starting
+// together may both apply a migration; that is harmless since each is
+// idempotent.
+func (d *Driver) RunMigrations(ctx context.Context) (int, error) {
+	version, err := d.SchemaVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for i := version; i < len(migrations); i++ {
+		m := migrations[i]
+		if err := m.apply(ctx, d); err != nil {
+			return applied, fmt.Errorf("error applying migration %d (%s): %w", i, m.name, err)
+		}
+		if err := d.SetMeta(ctx, schemaMetaKey, map[string]any{"version": i + 1}); err != nil {
+			return applied, fmt.Errorf("error recording migration %d: %w", i, err)
+		}
+		applied++
+		if d.logger != nil {
+			d.logger.InfoContext(ctx, "applied schema migration", "version", i+1, "name", m.name)
+		}
+	}
+
+	if applied > 0 {
+		d.ready.reset()
+	}
+	return applied, nil
+}
+
+// ensureSearchAnalyzer rebuilds the actor_name index with d.searchAnalyzer
+// when it was built with another. The analyzer is outside the migrations
+// since it is a setting, not part of the schema's history: changing it back
+// rebuilds the index again. Searches find nothing until the new index is
+// populated.
+func (d *Driver) ensureSearchAnalyzer(ctx context.Context) error {
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, `
+		SHOW FULLTEXT INDEXES YIELD name, options
+		WHERE name = 'actor_name'
+		RETURN options.indexConfig['fulltext.analyzer'] AS analyzer`, nil)
+	if err != nil {
+		return fmt.Errorf("error reading the search analyzer: %w", err)
+	}
+	var current string
+	if result.Next(ctx) {
+		analyzer, _ := result.Record().Get("analyzer")
+		current, _ = analyzer.(string)
+	}
+	if err = result.Err(); err != nil {
+		return fmt.Errorf("error reading the search analyzer: %w", err)
+	}
+	if current == d.searchAnalyzer {
+		return nil
+	}
+
+	// Checking the name against Neo4j's list gives a clearer error than the
+	// index creation failing, and makes it safe to put in the query.
+	result, err = session.Run(ctx, `
+		CALL db.index.fulltext.listAvailableAnalyzers() YIELD analyzer
+		WHERE analyzer = $analyzer
+		RETURN count(*) > 0 AS known`, map[string]any{"analyzer": d.searchAnalyzer})
+	if err != nil {
+		return fmt.Errorf("error listing fulltext analyzers: %w", err)
+	}
+	record, err := result.Single(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing fulltext analyzers: %w", err)
+	}
+	if known, _ := record.Get("known"); known != true {
+		return fmt.Errorf("unknown fulltext analyzer %q; CALL db.index.fulltext.listAvailableAnalyzers() lists them", d.searchAnalyzer)
+	}
+
+	err = d.runSchema(ctx,
+		"DROP INDEX actor_name IF EXISTS",
+		fmt.Sprintf("CREATE FULLTEXT INDEX actor_name IF NOT EXISTS FOR (a:Actor) ON EACH [a.normalized_name] OPTIONS {indexConfig: {`fulltext.analyzer`: '%s'}}", d.searchAnalyzer),
+	)
+	if err != nil {
+		return err
+	}
+	d.ready.reset()
+	if d.logger != nil {
+		d.logger.InfoContext(ctx, "rebuilt the actor name index", "analyzer", d.searchAnalyzer, "was", current)
+	}
+	return nil
+}
+
+// backfillBatchSize is how many actors backfillNormalizedNames updates per
+// transaction.
+const backfillBatchSize = 1000
+
+// backfillNormalizedNames sets normalized_name on every actor written before
+// the property existed. The normalization lives in Go, so names are read and
+// written back a batch at a time.
+func (d *Driver) backfillNormalizedNames(ctx context.Context) error {
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+
+	for {
+		n, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+			result, err := tx.Run(ctx, `
+				MATCH (a:Actor) WHERE a.normalized_name IS NULL
+				RETURN a.tmdb_id AS id, a.name AS name LIMIT $batch`,
+				map[string]any{"batch": backfillBatchSize})
+			if err != nil {
+				return 0, err
+			}
+			records, err := result.Collect(ctx)
+			if err != nil {
+				return 0, err
+			}
+			actors := make([]map[string]any, len(records))
+			for i, record := range records {
+				id, _ := record.Get("id")
+				name, _ := record.Get("name")
+				s, _ := name.(string) // a missing name normalizes to ""
+				actors[i] = map[string]any{"id": id, "normalized": normalizeName(s)}
+			}
+			_, err = tx.Run(ctx, `
+				UNWIND $actors AS a
+				MATCH (act:Actor {tmdb_id: a.id})
+				SET act.normalized_name = a.normalized`,
+				map[string]any{"actors": actors})
+			return len(actors), err
+		})
+		if err != nil {
+			return fmt.Errorf("error backfilling normalized names: %w", err)
+		}
+		if n.(int) < backfillBatchSize {
+			return nil
+		}
+	}
+}
+
+// runSchema runs each query in its own auto-commit transaction, as schema
+// changes can't share a transaction with each other or with data writes.
+func (d *Driver) runSchema(ctx context.Context, queries ...string) error {
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+
+	for _, query := range queries {
+		result, err := session.Run(ctx, query, nil)
+		if err == nil {
+			_, err = result.Consume(ctx)
+		}
+		if err != nil {
+			return fmt.Errorf("error running schema query: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+//go:build integration
+
+package graph
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+)
+
+func TestRunMigrations_AppliesOnlyPending(t *testing.T) {
+	clearGraph(t) // drops the recorded version, as on a graph that predates it
+	ctx := context.Background()
+
+	applied, err := testDriver.RunMigrations(ctx)
+	if err != nil {
+		t.Fatalf("RunMigrations failed: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("expected all %d migrations applied, got %d", len(migrations), applied)
+	}
+	version, err := testDriver.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("SchemaVersion failed: %v", err)
+	}
+	if version != len(migrations) {
+		t.Errorf("expected schema version %d, got %d", len(migrations), version)
+	}
+
+	applied, err = testDriver.RunMigrations(ctx)
+	if err != nil {
+		t.Fatalf("second RunMigrations failed: %v", err)
+	}
+	if applied != 0 {
+		t.Errorf("expected nothing applied the second time, got %d", applied)
+	}
+}
+
+func TestRunMigrations_ResumesFromRecordedVersion(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	if err := testDriver.SetMeta(ctx, schemaMetaKey, map[string]any{"version": len(migrations) - 1}); err != nil {
+		t.Fatalf("SetMeta failed: %v", err)
+	}
+	applied, err := testDriver.RunMigrations(ctx)
+	if err != nil {
+		t.Fatalf("RunMigrations failed: %v", err)
+	}
+	if applied != 1 {
+		t.Errorf("expected only the last migration applied, got %d", applied)
+	}
+}
+
+func TestRunMigrations_BackfillsNormalizedNames(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// Actors written before normalized_name existed.
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+	if _, err := session.Run(ctx, `UNWIND range(1, $n) AS id CREATE (:Actor {tmdb_id: id, name: "Dylan O'Brien " + id})`,
+		map[string]any{"n": backfillBatchSize + 1}); err != nil {
+		t.Fatalf("creating actors failed: %v", err)
+	}
+
+	i := slices.IndexFunc(migrations, func(m migration) bool { return m.name == "normalized actor names" })
+	if err := testDriver.SetMeta(ctx, schemaMetaKey, map[string]any{"version": i}); err != nil {
+		t.Fatalf("SetMeta failed: %v", err)
+	}
+	if _, err := testDriver.RunMigrations(ctx); err != nil {
+		t.Fatalf("RunMigrations failed: %v", err)
+	}
+
+	result, err := session.Run(ctx, `
+		MATCH (a:Actor)
+		RETURN count(a.normalized_name) AS filled, collect(a.normalized_name)[0] AS sample`, nil)
+	if err != nil {
+		t.Fatalf("reading actors failed: %v", err)
+	}
+	record, err := result.Single(ctx)
+	if err != nil {
+		t.Fatalf("reading actors failed: %v", err)
+	}
+	filled, _ := record.Get("filled")
+	sample, _ := record.Get("sample")
+	if filled != int64(backfillBatchSize+1) {
+		t.Errorf("expected every actor backfilled, got %v", filled)
+	}
+	if s, _ := sample.(string); !strings.HasPrefix(s, "dylan obrien ") {
+		t.Errorf("expected a normalized name, got %q", s)
+	}
+}
+
+// actorNameAnalyzer reads the analyzer the actor_name index was built with.
+func actorNameAnalyzer(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, "SHOW FULLTEXT INDEXES YIELD name, options WHERE name = 'actor_name' RETURN options.indexConfig['fulltext.analyzer'] AS analyzer", nil)
+	if err != nil {
+		t.Fatalf("failed to read the index: %v", err)
+	}
+	record, err := result.Single(ctx)
+	if err != nil {
+		t.Fatalf("failed to read the index: %v", err)
+	}
+	analyzer, _ := record.Get("analyzer")
+	return analyzer.(string)
+}
+
+func TestSetupSchema_SearchAnalyzer(t *testing.T) {
+	ctx := context.Background()
+	defer func() {
+		testDriver.searchAnalyzer = DefaultSearchAnalyzer
+		if err := testDriver.SetupSchema(ctx); err != nil {
+			t.Errorf("failed to restore the default analyzer: %v", err)
+		}
+		time.Sleep(2 * time.Second)
+	}()
+
+	if got := actorNameAnalyzer(t); got != DefaultSearchAnalyzer {
+		t.Fatalf("expected the index built with %s, got %s", DefaultSearchAnalyzer, got)
+	}
+
+	testDriver.searchAnalyzer = "standard-folding"
+	if err := testDriver.SetupSchema(ctx); err != nil {
+		t.Fatalf("SetupSchema failed: %v", err)
+	}
+	if got := actorNameAnalyzer(t); got != "standard-folding" {
+		t.Errorf("expected the index rebuilt with standard-folding, got %s", got)
+	}
+
+	testDriver.searchAnalyzer = "no-such-analyzer"
+	if err := testDriver.SetupSchema(ctx); err == nil || !strings.Contains(err.Error(), "unknown fulltext analyzer") {
+		t.Errorf("expected an unknown analyzer refused, got %v", err)
+	}
+	if got := actorNameAnalyzer(t); got != "standard-folding" {
+		t.Errorf("expected the index left alone after a bad analyzer, got %s", got)
+	}
+}
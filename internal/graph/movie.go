@@ -0,0 +1,226 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// The graph has no movie nodes: a movie is the set of actors on the
+// COSTARRED edges that record it, which is its cast less anyone who never
+// shared it with another ingested actor.
+
+// ErrUnknownMovie is returned by ShortestPathActorToMovie when no edge
+// records the movie.
+var ErrUnknownMovie = errors.New("movie not in graph")
+
+// movieSearchHits bounds how many edges a movie search reads. Every pair in a
+// cast has an edge carrying the title, so one popular match can fill
+// hundreds of hits on its own.
+const movieSearchHits = 1000
+
+// castCypher collects the actors on the movie $movie's edges as cast, and
+// the movie as an {id, title, year} map, null when no edge records it. A
+// per-movie edge is found through the costarred_movie_id index; the compact
+// model has no index on list members, so it scans every edge.
+func (d *Driver) castCypher() string {
+	if d.compactEdges {
+		return `
+			MATCH (x:Actor)-[r:COSTARRED]-()
+			WHERE $movie IN r.movie_ids
+			WITH x, r, [i IN range(0, size(r.movie_ids) - 1) WHERE r.movie_ids[i] = $movie][0] AS i
+			WITH collect(DISTINCT x) AS cast,
+			     head(collect({id: $movie, title: r.titles[i], year: r.years[i]})) AS movie`
+	}
+	return `
+		MATCH (x:Actor)-[r:COSTARRED {tmdb_movie_id: $movie}]-()
+		WITH collect(DISTINCT x) AS cast,
+		     head(collect({id: r.tmdb_movie_id, title: r.movie_title, year: r.year})) AS movie`
+}
+
+// DeleteMovieEdges removes movieTmdbID from the graph, so the movie can be
+// re-ingested from a fresh cast. Per-movie edges are deleted; in the compact
+// model the movie is taken off each edge that lists it, and edges it was the
+// only movie on are deleted. Actors are left in place even if they lose
+// their last edge. It returns how many edges it changed, 0 when no edge
+// records the movie.
+func (d *Driver) DeleteMovieEdges(ctx context.Context, movieTmdbID int) (_ int, err error) {
+	cypher := `
+		MATCH ()-[r:COSTARRED {tmdb_movie_id: $movie}]->()
+		DELETE r
+		RETURN count(*) AS c`
+	if d.compactEdges {
+		cypher = compactDeleteMovieCypher
+	}
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "DeleteMovieEdges", cypher, attribute.Int("movie_id", movieTmdbID))
+	defer func() {
+		d.observe(ctx, "DeleteMovieEdges", start, err, "movie_id", movieTmdbID)
+		span.End()
+	}()
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+
+	deleted, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		result, err := tx.Run(ctx, cypher, map[string]any{"movie": movieTmdbID})
+		if err != nil {
+			return 0, err
+		}
+		record, err := result.Single(ctx)
+		if err != nil {
+			return 0, err
+		}
+		count, _ := record.Get("c")
+		n, _ := count.(int64)
+		return int(n), nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("error deleting movie edges: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("edges.deleted", deleted.(int)))
+	return deleted.(int), nil
+}
+
+// ShortestPathActorToMovie finds the shortest chain from the actor to anyone
+// in the movie, and returns it like ShortestPath with the movie appended as a
+// final movie step. An actor in the movie gets just themselves and the movie.
+// It returns nil, nil when the actor is unknown or can't reach the cast, and
+// ErrUnknownMovie when no edge records the movie.
+func (d *Driver) ShortestPathActorToMovie(ctx context.Context, actorID, movieTmdbID int) (_ []PathStep, err error) {
+	// shortestPath() needs both ends bound, so reaching any of the cast takes
+	// one search per cast member; SHORTEST runs one search that stops at
+	// whichever it meets first.
+	cypher := `
+		CALL () {` + d.castCypher() + `
+			RETURN cast, movie
+		}
+		CALL (cast) {
+			MATCH p = SHORTEST 1 (a:Actor {tmdb_id: $id})-[:COSTARRED]-*(t:Actor WHERE t IN cast)
+			RETURN collect(p)[0] AS p
+		}
+		RETURN movie,
+		       [n IN nodes(p) | {id: n.tmdb_id, name: n.name}] AS actors,
+		       [r IN relationships(p) | {title: r.movie_title, year: r.year}] AS movies`
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "ShortestPathActorToMovie", cypher,
+		attribute.Int("actor_id", actorID),
+		attribute.Int("movie_id", movieTmdbID),
+	)
+	defer func() {
+		d.observe(ctx, "ShortestPathActorToMovie", start, err, "actor_id", actorID, "movie_id", movieTmdbID)
+		span.End()
+	}()
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, map[string]any{"id": actorID, "movie": movieTmdbID}, txTimeout(ctx)...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error finding path to movie: %w", err)
+	}
+	record, err := result.Single(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error finding path to movie: %w", err)
+	}
+
+	movie, _ := record.Get("movie")
+	if movie == nil {
+		return nil, ErrUnknownMovie
+	}
+	actorList, _ := record.Get("actors")
+	actors, _ := actorList.([]any)
+	if len(actors) == 0 {
+		return nil, nil // no path found
+	}
+	movieList, _ := record.Get("movies")
+	movies, _ := movieList.([]any)
+	steps := decodePathRecord(actors, movies)
+	title, year := decodePathMovie(movie)
+	steps = append(steps, PathStep{MovieTitle: title, MovieYear: year})
+
+	span.SetAttributes(attribute.Int("result.steps", len(steps)))
+	return steps, nil
+}
+
+// SearchMovies finds movies whose title matches query, best match first and
+// newer first among equals. The last word matches as a prefix, as in
+// SearchActors.
+func (d *Driver) SearchMovies(ctx context.Context, query string, limit int) (_ []models.Movie, err error) {
+	// A compact edge indexes only its latest title, so the match is read back
+	// from the edge's movies by that title.
+	cypher := `
+		CALL db.index.fulltext.queryRelationships("movie_title", $query, {limit: $hits})
+		YIELD relationship AS r, score
+		UNWIND ` + edgeMoviesCypher + ` AS m
+		WITH m, score
+		WHERE m.title = r.movie_title
+		RETURN m.id AS id, m.title AS title, m.year AS year, max(score) AS score
+		ORDER BY score DESC, coalesce(year, 0) DESC
+		LIMIT $limit`
+
+	start := time.Now()
+	ctx, span := d.startSpan(ctx, "SearchMovies", cypher, attribute.String("search.query", query))
+	defer func() {
+		d.observe(ctx, "SearchMovies", start, err, "query", query, "limit", limit)
+		span.End()
+	}()
+
+	// Titles are indexed as they are, apostrophes and all, so the query is
+	// escaped rather than normalized like SearchActors'.
+	terms := escapeLucene(query)
+	if terms == "" {
+		return nil, nil
+	}
+	params := map[string]any{"query": terms + "*", "hits": movieSearchHits, "limit": limit}
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, cypher, params)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error searching movies: %w", err)
+	}
+
+	var movies []models.Movie
+	for result.Next(ctx) {
+		record := result.Record()
+		id, _ := record.Get("id")
+		title, _ := record.Get("title")
+		year, _ := record.Get("year")
+		m := models.Movie{}
+		if n, ok := id.(int64); ok {
+			m.TmdbID = int(n)
+		}
+		m.Title, _ = title.(string)
+		if n, ok := year.(int64); ok {
+			m.Year = int(n)
+		}
+		movies = append(movies, m)
+	}
+	if err = result.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("error iterating movie results: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("result.count", len(movies)))
+	return movies, nil
+}
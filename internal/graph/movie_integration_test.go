@@ -0,0 +1,160 @@
+//go:build integration
+
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+// ingestMovieGraph builds Fight Club (Pitt, Norton) and Keeping the Faith
+// (Norton, Stiller), with Hanks and Ryan off in You've Got Mail on their own.
+func ingestMovieGraph(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	pitt := models.Actor{TmdbID: 1, Name: "Brad Pitt"}
+	norton := models.Actor{TmdbID: 2, Name: "Edward Norton"}
+	stiller := models.Actor{TmdbID: 3, Name: "Ben Stiller"}
+	hanks := models.Actor{TmdbID: 4, Name: "Tom Hanks"}
+	ryan := models.Actor{TmdbID: 5, Name: "Meg Ryan"}
+	for _, m := range []struct {
+		movie models.Movie
+		cast  []models.Actor
+	}{
+		{models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999}, []models.Actor{pitt, norton}},
+		{models.Movie{TmdbID: 2103, Title: "Keeping the Faith", Year: 2000}, []models.Actor{norton, stiller}},
+		{models.Movie{TmdbID: 9489, Title: "You've Got Mail", Year: 1998}, []models.Actor{hanks, ryan}},
+	} {
+		if err := testDriver.IngestMovieCast(ctx, m.movie, m.cast); err != nil {
+			t.Fatalf("IngestMovieCast failed: %v", err)
+		}
+	}
+}
+
+func testShortestPathActorToMovie(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+
+	steps, err := testDriver.ShortestPathActorToMovie(ctx, 1, 2103)
+	if err != nil {
+		t.Fatalf("ShortestPathActorToMovie failed: %v", err)
+	}
+	if len(steps) != 4 || steps[0].Actor.TmdbID != 1 || steps[1].MovieTitle != "Fight Club" || steps[2].Actor.TmdbID != 2 {
+		t.Fatalf("expected Pitt to Norton through Fight Club, got %+v", steps)
+	}
+	if last := steps[3]; last.Actor != nil || last.MovieTitle != "Keeping the Faith" || last.MovieYear != 2000 {
+		t.Errorf("expected the movie as the final step, got %+v", last)
+	}
+
+	// Someone in the movie is already there.
+	steps, err = testDriver.ShortestPathActorToMovie(ctx, 3, 2103)
+	if err != nil {
+		t.Fatalf("ShortestPathActorToMovie failed: %v", err)
+	}
+	if len(steps) != 2 || steps[0].Actor.TmdbID != 3 || steps[1].MovieTitle != "Keeping the Faith" {
+		t.Errorf("expected just Stiller and the movie, got %+v", steps)
+	}
+
+	steps, err = testDriver.ShortestPathActorToMovie(ctx, 1, 9489)
+	if err != nil || steps != nil {
+		t.Errorf("expected no path to a movie in another component, got %+v, %v", steps, err)
+	}
+	steps, err = testDriver.ShortestPathActorToMovie(ctx, 99, 550)
+	if err != nil || steps != nil {
+		t.Errorf("expected no path from an unknown actor, got %+v, %v", steps, err)
+	}
+	if _, err := testDriver.ShortestPathActorToMovie(ctx, 1, 424242); !errors.Is(err, ErrUnknownMovie) {
+		t.Errorf("expected ErrUnknownMovie, got %v", err)
+	}
+}
+
+func TestShortestPathActorToMovie(t *testing.T) {
+	clearGraph(t)
+	ingestMovieGraph(t)
+	testShortestPathActorToMovie(t)
+}
+
+func TestShortestPathActorToMovie_CompactEdges(t *testing.T) {
+	clearGraph(t)
+	useCompactEdges(t)
+	ingestMovieGraph(t)
+	testShortestPathActorToMovie(t)
+}
+
+func testDeleteMovieEdges(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+
+	// Pitt and Norton also share a later movie, so in the compact model
+	// their edge survives with Fight Club taken off it.
+	later := models.Movie{TmdbID: 1000, Title: "Later Movie", Year: 2005}
+	if err := testDriver.IngestMovieCast(ctx, later, []models.Actor{{TmdbID: 1, Name: "Brad Pitt"}, {TmdbID: 2, Name: "Edward Norton"}}); err != nil {
+		t.Fatalf("IngestMovieCast failed: %v", err)
+	}
+
+	deleted, err := testDriver.DeleteMovieEdges(ctx, 2103)
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected one edge deleted, got %d, %v", deleted, err)
+	}
+	if _, err := testDriver.ShortestPathActorToMovie(ctx, 1, 2103); !errors.Is(err, ErrUnknownMovie) {
+		t.Errorf("expected Keeping the Faith gone, got %v", err)
+	}
+	if steps, err := testDriver.ShortestPath(ctx, 1, 3); err != nil || steps != nil {
+		t.Errorf("expected Stiller cut off, got %+v, %v", steps, err)
+	}
+
+	if _, err := testDriver.DeleteMovieEdges(ctx, 550); err != nil {
+		t.Fatalf("DeleteMovieEdges failed: %v", err)
+	}
+	steps, err := testDriver.ShortestPath(ctx, 1, 2)
+	if err != nil || len(steps) != 3 || steps[1].MovieTitle != "Later Movie" {
+		t.Errorf("expected Pitt and Norton joined by Later Movie only, got %+v, %v", steps, err)
+	}
+
+	if deleted, err := testDriver.DeleteMovieEdges(ctx, 424242); err != nil || deleted != 0 {
+		t.Errorf("expected nothing deleted for an unknown movie, got %d, %v", deleted, err)
+	}
+}
+
+func TestDeleteMovieEdges(t *testing.T) {
+	clearGraph(t)
+	ingestMovieGraph(t)
+	testDeleteMovieEdges(t)
+}
+
+func TestDeleteMovieEdges_CompactEdges(t *testing.T) {
+	clearGraph(t)
+	useCompactEdges(t)
+	ingestMovieGraph(t)
+	testDeleteMovieEdges(t)
+}
+
+func TestSearchMovies(t *testing.T) {
+	clearGraph(t)
+	ingestMovieGraph(t)
+	ctx := context.Background()
+
+	movies, err := testDriver.SearchMovies(ctx, "keeping the fa", 10)
+	if err != nil {
+		t.Fatalf("SearchMovies failed: %v", err)
+	}
+	if len(movies) != 1 || movies[0] != (models.Movie{TmdbID: 2103, Title: "Keeping the Faith", Year: 2000}) {
+		t.Errorf("expected Keeping the Faith once, got %+v", movies)
+	}
+
+	if movies, err := testDriver.SearchMovies(ctx, "FIGHT", 10); err != nil || len(movies) != 1 || movies[0].TmdbID != 550 {
+		t.Errorf("expected a case-insensitive match on Fight Club, got %+v, %v", movies, err)
+	}
+	if movies, err := testDriver.SearchMovies(ctx, "you've got", 10); err != nil || len(movies) != 1 || movies[0].TmdbID != 9489 {
+		t.Errorf("expected the apostrophe matched as typed in You've Got Mail, got %+v, %v", movies, err)
+	}
+	if movies, err := testDriver.SearchMovies(ctx, "fight (club", 10); err != nil || len(movies) != 1 || movies[0].TmdbID != 550 {
+		t.Errorf("expected Lucene syntax in the query searched as text, got %+v, %v", movies, err)
+	}
+	if movies, err := testDriver.SearchMovies(ctx, "?!", 10); err != nil || movies != nil {
+		t.Errorf("expected nothing for a query with no words, got %+v, %v", movies, err)
+	}
+}
@@ -0,0 +1,114 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+)
+
+// ErrSchemaNotReady is returned while an index SetupSchema creates is missing
+// or still populating. Until both are online, lookups by id scan every actor
+// and search returns nothing.
+var ErrSchemaNotReady = errors.New("schema not ready")
+
+// schemaIndexes are the indexes SetupSchema creates. The uniqueness
+// constraints' backing indexes share their names.
+var schemaIndexes = []string{"actor_tmdb_id", "actor_name", "meta_key", "costarred_decade", "costarred_movie_id", "movie_title", "actor_bacon_number"}
+
+// readyCacheTTL is how long Ready reuses its last answer, so frequent probes
+// don't each run SHOW INDEXES.
+const readyCacheTTL = 5 * time.Second
+
+// schemaPollInterval spaces out index checks while AwaitSchema waits.
+const schemaPollInterval = 500 * time.Millisecond
+
+// readyCache holds Ready's last answer.
+type readyCache struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// reset drops the cached answer, e.g. after the schema changes.
+func (c *readyCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checked, c.err = time.Time{}, nil
+}
+
+// Ready reports whether the driver can serve requests: Neo4j is reachable and
+// every schema index is online. Results are cached for readyCacheTTL.
+func (d *Driver) Ready(ctx context.Context) error {
+	d.ready.mu.Lock()
+	defer d.ready.mu.Unlock()
+	if !d.ready.checked.IsZero() && time.Since(d.ready.checked) < readyCacheTTL {
+		return d.ready.err
+	}
+
+	err := d.VerifyConnectivity(ctx)
+	if err != nil {
+		err = fmt.Errorf("neo4j unreachable: %w", err)
+	} else {
+		err = d.checkSchema(ctx)
+	}
+	d.ready.checked, d.ready.err = time.Now(), err
+	return err
+}
+
+// AwaitSchema blocks until every schema index is online, returning an error
+// wrapping ErrSchemaNotReady if ctx ends first.
+func (d *Driver) AwaitSchema(ctx context.Context) error {
+	ticker := time.NewTicker(schemaPollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := d.checkSchema(ctx)
+		if err == nil || !errors.Is(err, ErrSchemaNotReady) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for indexes: %w", err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkSchema looks up the state of each schema index.
+func (d *Driver) checkSchema(ctx context.Context) error {
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx,
+		"SHOW INDEXES YIELD name, state WHERE name IN $names RETURN name, state",
+		map[string]any{"names": schemaIndexes},
+	)
+	if err != nil {
+		return fmt.Errorf("error listing indexes: %w", err)
+	}
+
+	states := make(map[string]string, len(schemaIndexes))
+	for result.Next(ctx) {
+		name, _ := result.Record().Get("name")
+		state, _ := result.Record().Get("state")
+		n, _ := name.(string)
+		states[n], _ = state.(string)
+	}
+	if err = result.Err(); err != nil {
+		return fmt.Errorf("error listing indexes: %w", err)
+	}
+
+	for _, name := range schemaIndexes {
+		switch state, ok := states[name]; {
+		case !ok:
+			return fmt.Errorf("%w: index %s is missing", ErrSchemaNotReady, name)
+		case state != "ONLINE":
+			return fmt.Errorf("%w: index %s is %s", ErrSchemaNotReady, name, state)
+		}
+	}
+	return nil
+}
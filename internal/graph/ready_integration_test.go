@@ -0,0 +1,80 @@
+//go:build integration
+
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+)
+
+// dropNameIndex drops the name index and the schema version with it, so the
+// graph looks like one that predates the index and SetupSchema recreates it.
+func dropNameIndex(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+	if _, err := session.Run(ctx, "DROP INDEX actor_name IF EXISTS", nil); err != nil {
+		t.Fatalf("failed to drop index: %v", err)
+	}
+	if _, err := session.Run(ctx, "MATCH (m:Meta {key: $key}) DELETE m", map[string]any{"key": schemaMetaKey}); err != nil {
+		t.Fatalf("failed to reset schema version: %v", err)
+	}
+	testDriver.ready.reset()
+}
+
+func TestReady_SchemaWindow(t *testing.T) {
+	ctx := context.Background()
+	t.Cleanup(func() {
+		testDriver.SetupSchema(ctx)
+		testDriver.AwaitSchema(ctx)
+	})
+
+	if err := testDriver.SetupSchema(ctx); err != nil {
+		t.Fatalf("SetupSchema failed: %v", err)
+	}
+	if err := testDriver.AwaitSchema(ctx); err != nil {
+		t.Fatalf("AwaitSchema failed: %v", err)
+	}
+	if err := testDriver.Ready(ctx); err != nil {
+		t.Fatalf("expected ready once indexes are online, got %v", err)
+	}
+
+	// Before SetupSchema has (re)created an index, the server isn't ready.
+	dropNameIndex(t)
+	err := testDriver.Ready(ctx)
+	if !errors.Is(err, ErrSchemaNotReady) {
+		t.Fatalf("expected ErrSchemaNotReady with the name index missing, got %v", err)
+	}
+
+	// SetupSchema drops the cached answer, so the restored index is seen
+	// without waiting out the cache.
+	if err := testDriver.SetupSchema(ctx); err != nil {
+		t.Fatalf("SetupSchema failed: %v", err)
+	}
+	if err := testDriver.AwaitSchema(ctx); err != nil {
+		t.Fatalf("AwaitSchema failed: %v", err)
+	}
+	if err := testDriver.Ready(ctx); err != nil {
+		t.Errorf("expected ready after the index came back, got %v", err)
+	}
+}
+
+func TestAwaitSchema_Deadline(t *testing.T) {
+	ctx := context.Background()
+	t.Cleanup(func() {
+		testDriver.SetupSchema(ctx)
+		testDriver.AwaitSchema(ctx)
+	})
+	dropNameIndex(t)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := testDriver.AwaitSchema(ctx); !errors.Is(err, ErrSchemaNotReady) {
+		t.Errorf("expected AwaitSchema to give up with ErrSchemaNotReady, got %v", err)
+	}
+}
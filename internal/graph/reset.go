@@ -0,0 +1,76 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+)
+
+// deleteRoundBatches is how many batches a deletion commits between progress
+// reports.
+const deleteRoundBatches = 10
+
+// deleteBatchesCypher follows a match clause binding n, deleting up to $limit
+// of the matches and their relationships and committing every $batch of them
+// so no single transaction has to hold the whole set. CALL ... IN
+// TRANSACTIONS only runs in an auto-commit transaction.
+const deleteBatchesCypher = `
+	WITH n LIMIT $limit
+	CALL (n) { DETACH DELETE n } IN TRANSACTIONS OF $batch ROWS
+	RETURN count(*) AS deleted`
+
+// DeleteInBatches detach-deletes every node bound to n by matchClause, e.g.
+// "MATCH (n:Actor) WHERE NOT (n)--()", committing batchSize nodes per
+// transaction. matchClause is spliced into the query, so it must be a
+// constant, never user input. It returns how many nodes were deleted, even on
+// error; batches committed before a failure stay deleted.
+func (d *Driver) DeleteInBatches(ctx context.Context, matchClause string, batchSize int) (int64, error) {
+	return d.deleteInBatches(ctx, matchClause, batchSize, nil)
+}
+
+// deleteInBatches is DeleteInBatches with progress, if non-nil, called with
+// the running total after each round of deleteRoundBatches batches.
+func (d *Driver) deleteInBatches(ctx context.Context, matchClause string, batchSize int, progress func(deleted int64)) (int64, error) {
+	if batchSize < 1 {
+		return 0, fmt.Errorf("error deleting in batches: batch size must be at least 1, got %d", batchSize)
+	}
+	cypher := matchClause + deleteBatchesCypher
+	params := map[string]any{"limit": batchSize * deleteRoundBatches, "batch": batchSize}
+
+	session := d.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+
+	var total int64
+	for {
+		result, err := session.Run(ctx, cypher, params)
+		if err != nil {
+			return total, fmt.Errorf("error deleting in batches: %w", err)
+		}
+		record, err := result.Single(ctx)
+		if err != nil {
+			return total, fmt.Errorf("error deleting in batches: %w", err)
+		}
+		deleted, _ := record.Get("deleted")
+		n, _ := deleted.(int64)
+		if n == 0 {
+			return total, nil
+		}
+		total += n
+		if progress != nil {
+			progress(total)
+		}
+	}
+}
+
+// ClearGraph deletes every node and relationship, including Meta state, in
+// transactions of batchSize nodes. Indexes and constraints are left in place.
+// progress, if non-nil, is called with the running total as deletion goes.
+func (d *Driver) ClearGraph(ctx context.Context, batchSize int, progress func(deleted int64)) (int64, error) {
+	defer d.ready.reset()
+	deleted, err := d.deleteInBatches(ctx, "MATCH (n)", batchSize, progress)
+	if err != nil {
+		return deleted, fmt.Errorf("error clearing graph: %w", err)
+	}
+	return deleted, nil
+}
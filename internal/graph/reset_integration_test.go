@@ -0,0 +1,83 @@
+//go:build integration
+
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+func TestClearGraph(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	for id := 1; id <= 5; id++ {
+		testDriver.UpsertActor(ctx, models.Actor{TmdbID: id, Name: "Actor"})
+	}
+	testDriver.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 100, Title: "Movie One", Year: 2000})
+	testDriver.SetLastIngestedPage(ctx, 3)
+
+	var reports []int64
+	deleted, err := testDriver.ClearGraph(ctx, 2, func(n int64) { reports = append(reports, n) })
+	if err != nil {
+		t.Fatalf("ClearGraph failed: %v", err)
+	}
+	// Five actors plus the ingest Meta node.
+	if deleted != 6 {
+		t.Errorf("expected 6 nodes deleted, got %d", deleted)
+	}
+	if len(reports) == 0 || reports[len(reports)-1] != deleted {
+		t.Errorf("expected progress to end at %d, got %v", deleted, reports)
+	}
+
+	nonEmpty, err := testDriver.HasActors(ctx)
+	if err != nil {
+		t.Fatalf("HasActors failed: %v", err)
+	}
+	if nonEmpty {
+		t.Error("expected no actors left")
+	}
+	page, err := testDriver.GetLastIngestedPage(ctx)
+	if err != nil {
+		t.Fatalf("GetLastIngestedPage failed: %v", err)
+	}
+	if page != 0 {
+		t.Errorf("expected ingest progress to be cleared, got page %d", page)
+	}
+}
+
+func TestClearGraph_RejectsBadBatchSize(t *testing.T) {
+	if _, err := testDriver.ClearGraph(context.Background(), 0, nil); err == nil {
+		t.Error("expected an error for a zero batch size")
+	}
+}
+
+func TestDeleteInBatches(t *testing.T) {
+	clearGraph(t)
+	ctx := context.Background()
+
+	// 300 actors across many small batches, with a keeper the match skips.
+	session := testDriver.driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+	if _, err := session.Run(ctx, "UNWIND range(1, 300) AS id CREATE (:Actor {tmdb_id: id, name: 'Extra'})", nil); err != nil {
+		t.Fatalf("failed to create actors: %v", err)
+	}
+	testDriver.UpsertActor(ctx, models.Actor{TmdbID: 1000, Name: "Keeper"})
+
+	deleted, err := testDriver.DeleteInBatches(ctx, "MATCH (n:Actor {name: 'Extra'})", 7)
+	if err != nil {
+		t.Fatalf("DeleteInBatches failed: %v", err)
+	}
+	if deleted != 300 {
+		t.Errorf("expected 300 nodes deleted, got %d", deleted)
+	}
+
+	actor, err := testDriver.GetActor(ctx, 1000)
+	if err != nil || actor == nil {
+		t.Errorf("expected the unmatched actor to survive, got %v, %v", actor, err)
+	}
+}
@@ -0,0 +1,393 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+// maxExpandedNodes caps how many neighbor nodes ?expand=1 adds to a path graph
+// so a path through a prolific actor doesn't return thousands of nodes.
+const maxExpandedNodes = 50
+
+// maxNeighbors caps the co-stars one /api/v1/neighbors call returns, keeping
+// each expansion step small however prolific the actor.
+const maxNeighbors = 50
+
+// maxBatchTargets caps the targets in one /api/v1/paths request. Each target
+// is its own path query, so this bounds what a single request can cost.
+const maxBatchTargets = 20
+
+// batchWorkers is how many of a batch's path queries run against Neo4j at
+// once.
+const batchWorkers = 4
+
+// batchMaxHops bounds each batch path query; targets further away than this
+// are reported as unreachable.
+const batchMaxHops = 10
+
+// connectedMaxHops bounds /api/v1/connected. Actors further apart than this
+// are reported as not connected, which keeps a miss from walking the graph.
+const connectedMaxHops = 10
+
+const (
+	nodeEndpoint = "endpoint"
+	nodePath     = "path"
+	nodeNeighbor = "neighbor"
+)
+
+type graphNode struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type graphLink struct {
+	Source int    `json:"source"`
+	Target int    `json:"target"`
+	Movie  string `json:"movie"`
+	Year   int    `json:"year"`
+}
+
+// pathGraph is the node-link shape D3 and cytoscape consume directly.
+type pathGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Links []graphLink `json:"links"`
+}
+
+// neighbor is one co-star in a /api/v1/neighbors response, with the most
+// recent movie they share as the connection's label.
+type neighbor struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	SharedMovies int    `json:"shared_movies"`
+	Movie        string `json:"movie"`
+	Year         int    `json:"year,omitempty"`
+}
+
+type neighborsResponse struct {
+	ID        int        `json:"id"`
+	Neighbors []neighbor `json:"neighbors"`
+}
+
+// searchResult is one actor in a /api/v1/search response.
+type searchResult struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	ProfilePath string  `json:"profile_path,omitempty"`
+	Popularity  float64 `json:"popularity,omitempty"`
+	KnownFor    string  `json:"known_for,omitempty"`
+}
+
+type searchResponse struct {
+	Query   string         `json:"query"`
+	Results []searchResult `json:"results"`
+}
+
+type batchPathsRequest struct {
+	From int   `json:"from"`
+	To   []int `json:"to"`
+}
+
+// batchPathResult is one target's distance from the batch's source actor.
+// Degrees is null when no path was found within batchMaxHops, or when the
+// query failed, in which case Error says why.
+type batchPathResult struct {
+	To      int    `json:"to"`
+	Degrees *int   `json:"degrees"`
+	Error   string `json:"error,omitempty"`
+}
+
+type batchPathsResponse struct {
+	Results []batchPathResult `json:"results"`
+}
+
+// connectedResponse answers /api/v1/connected. Degrees is omitted when the
+// actors aren't connected.
+type connectedResponse struct {
+	Connected bool `json:"connected"`
+	Degrees   *int `json:"degrees,omitempty"`
+}
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// buildPathGraph converts an alternating actor/movie path into nodes and links.
+// The first and last actors are typed as endpoints.
+func buildPathGraph(steps []graph.PathStep) *pathGraph {
+	g := &pathGraph{Nodes: []graphNode{}, Links: []graphLink{}}
+
+	prev := -1
+	var title string
+	var year int
+	for i, step := range steps {
+		if step.Actor == nil {
+			title, year = step.MovieTitle, step.MovieYear
+			continue
+		}
+
+		nodeType := nodePath
+		if i == 0 || i == len(steps)-1 {
+			nodeType = nodeEndpoint
+		}
+		g.Nodes = append(g.Nodes, graphNode{ID: step.Actor.TmdbID, Name: step.Actor.Name, Type: nodeType})
+
+		if prev >= 0 {
+			g.Links = append(g.Links, graphLink{Source: prev, Target: step.Actor.TmdbID, Movie: title, Year: year})
+		}
+		prev = step.Actor.TmdbID
+	}
+	return g
+}
+
+// addNeighbors appends neighbor nodes and their links to the path graph,
+// adding each neighbor node once even when it touches several path actors.
+func (g *pathGraph) addNeighbors(edges []graph.NeighborEdge) {
+	seen := make(map[int]bool, len(g.Nodes))
+	for _, n := range g.Nodes {
+		seen[n.ID] = true
+	}
+	for _, e := range edges {
+		if !seen[e.Actor.TmdbID] {
+			seen[e.Actor.TmdbID] = true
+			g.Nodes = append(g.Nodes, graphNode{ID: e.Actor.TmdbID, Name: e.Actor.Name, Type: nodeNeighbor})
+		}
+		g.Links = append(g.Links, graphLink{Source: e.FromID, Target: e.Actor.TmdbID, Movie: e.MovieTitle, Year: e.MovieYear})
+	}
+}
+
+func (h *Handler) pathGraphHandler(w http.ResponseWriter, r *http.Request) {
+	idA, errA := parseActorID(r.URL.Query().Get("a"))
+	idB, errB := parseActorID(r.URL.Query().Get("b"))
+	if errA != nil || errB != nil {
+		h.renderError(w, r, badRequest("a and b must be positive actor ids"))
+		return
+	}
+	if idA == idB {
+		h.renderError(w, r, badRequest("a and b must be different actors"))
+		return
+	}
+	expand, err := parseFlag("expand", r.URL.Query().Get("expand"))
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+
+	steps, err := h.shortestPath(r.Context(), idA, idB)
+	if isTimeout(err) {
+		h.log(r.Context()).Warn("shortest path timed out", "a", idA, "b", idB, "timeout", h.pathTimeout)
+		h.renderPathTimeout(w, r)
+		return
+	}
+	if err != nil {
+		h.log(r.Context()).Error("failed to get shortest path", "a", idA, "b", idB, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	g := buildPathGraph(steps)
+
+	if expand && len(g.Nodes) > 0 {
+		ids := make([]int, len(g.Nodes))
+		for i, n := range g.Nodes {
+			ids[i] = n.ID
+		}
+		edges, err := h.db.Neighbors(r.Context(), ids, maxExpandedNodes)
+		if err != nil {
+			h.log(r.Context()).Error("failed to get path neighbors", "a", idA, "b", idB, "err", err)
+			h.renderError(w, r, err)
+			return
+		}
+		g.addNeighbors(edges)
+	}
+
+	writeJSON(w, http.StatusOK, g)
+}
+
+// apiSearchHandler is /search as JSON. limit defaults to the configured search
+// limit and may go up to searchMaxLimit.
+func (h *Handler) apiSearchHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := cleanQuery(r.URL.Query().Get("q"), h.maxQueryLen)
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+	limit := h.searchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > h.searchMaxLimit {
+			h.renderError(w, r, badRequest(fmt.Sprintf("limit must be between 1 and %d", h.searchMaxLimit)))
+			return
+		}
+	}
+
+	resp := searchResponse{Query: query, Results: []searchResult{}}
+	if query == "" {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	results, err := h.db.SearchActors(r.Context(), query, limit)
+	if err != nil {
+		h.log(r.Context()).Error("failed to search actors", "query", query, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+	for _, res := range results {
+		resp.Results = append(resp.Results, searchResult{
+			ID:          res.Actor.TmdbID,
+			Name:        res.Actor.Name,
+			ProfilePath: res.ProfilePath,
+			Popularity:  res.Popularity,
+			KnownFor:    res.KnownFor,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// neighborsHandler returns an actor's immediate co-stars, those sharing the
+// most movies first, so a client can grow a graph one click at a time. An
+// unknown actor has no neighbors rather than being a 404.
+func (h *Handler) neighborsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseActorID(r.URL.Query().Get("id"))
+	if err != nil {
+		h.renderError(w, r, badRequest("id must be a positive actor id"))
+		return
+	}
+
+	costars, err := h.db.GetCostars(r.Context(), id, maxNeighbors)
+	if err != nil {
+		h.log(r.Context()).Error("failed to get neighbors", "id", id, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	resp := neighborsResponse{ID: id, Neighbors: make([]neighbor, len(costars))}
+	for i, c := range costars {
+		resp.Neighbors[i] = neighbor{
+			ID:           c.Actor.TmdbID,
+			Name:         c.Actor.Name,
+			SharedMovies: c.SharedMovies,
+			Movie:        c.Movie.Title,
+			Year:         c.Movie.Year,
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// connectedHandler reports whether two actors are connected, and by how many
+// degrees, without reconstructing the path: a cheap check before asking for
+// one. An actor is connected to themselves at 0 degrees.
+func (h *Handler) connectedHandler(w http.ResponseWriter, r *http.Request) {
+	idA, errA := parseActorID(r.URL.Query().Get("a"))
+	idB, errB := parseActorID(r.URL.Query().Get("b"))
+	if errA != nil || errB != nil {
+		h.renderError(w, r, badRequest("a and b must be positive actor ids"))
+		return
+	}
+	if idA == idB {
+		zero := 0
+		writeJSON(w, http.StatusOK, connectedResponse{Connected: true, Degrees: &zero})
+		return
+	}
+
+	ctx, cancel := h.pathContext(r.Context())
+	defer cancel()
+	hops, err := h.db.Distance(ctx, idA, idB, connectedMaxHops)
+	if err != nil {
+		if isTimeout(err) {
+			h.log(r.Context()).Warn("distance timed out", "a", idA, "b", idB, "timeout", h.pathTimeout)
+			h.renderPathTimeout(w, r)
+			return
+		}
+		h.log(r.Context()).Error("failed to get distance", "a", idA, "b", idB, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	resp := connectedResponse{}
+	if hops != graph.Unconnected {
+		resp.Connected, resp.Degrees = true, &hops
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// batchPathsHandler measures the degrees between one actor and up to
+// maxBatchTargets others, in the order the targets were given. Only the hop
+// count is computed, not the path itself.
+func (h *Handler) batchPathsHandler(w http.ResponseWriter, r *http.Request) {
+	var req batchPathsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.renderError(w, r, badRequest(`body must be JSON like {"from": 1, "to": [2, 3]}`))
+		return
+	}
+	if req.From <= 0 {
+		h.renderError(w, r, badRequest("from must be a positive actor id"))
+		return
+	}
+	if len(req.To) == 0 || len(req.To) > maxBatchTargets {
+		h.renderError(w, r, badRequest(fmt.Sprintf("to must list between 1 and %d actor ids", maxBatchTargets)))
+		return
+	}
+	for _, id := range req.To {
+		if id <= 0 {
+			h.renderError(w, r, badRequest("to must only contain positive actor ids"))
+			return
+		}
+	}
+
+	ctx, cancel := h.pathContext(r.Context())
+	defer cancel()
+
+	writeJSON(w, http.StatusOK, batchPathsResponse{Results: h.batchDistances(ctx, req.From, req.To)})
+}
+
+// batchDistances runs a Distance query per target, at most batchWorkers at a
+// time. A failed query, or one still queued when ctx runs out, is reported on
+// its own result and doesn't stop the others.
+func (h *Handler) batchDistances(ctx context.Context, from int, targets []int) []batchPathResult {
+	results := make([]batchPathResult, len(targets))
+	sem := make(chan struct{}, batchWorkers)
+	var wg sync.WaitGroup
+	for i, to := range targets {
+		results[i].To = to
+		if to == from {
+			zero := 0
+			results[i].Degrees = &zero
+			continue
+		}
+		wg.Go(func() {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				_, results[i].Error = errorStatus(ctx.Err())
+				return
+			}
+			hops, err := h.db.Distance(ctx, from, to, batchMaxHops)
+			if err != nil {
+				h.log(ctx).Error("failed to get batch distance", "from", from, "to", to, "err", err)
+				h.availability.observe(err)
+				_, results[i].Error = errorStatus(err)
+				return
+			}
+			if hops != graph.Unconnected {
+				results[i].Degrees = &hops
+			}
+		})
+	}
+	wg.Wait()
+	return results
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,409 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/web"
+)
+
+func testPath() []graph.PathStep {
+	return []graph.PathStep{
+		{Actor: &models.Actor{TmdbID: 1, Name: "Actor A"}},
+		{MovieTitle: "Movie One", MovieYear: 2000},
+		{Actor: &models.Actor{TmdbID: 2, Name: "Actor B"}},
+		{MovieTitle: "Movie Two", MovieYear: 2010},
+		{Actor: &models.Actor{TmdbID: 3, Name: "Actor C"}},
+	}
+}
+
+func TestBuildPathGraph(t *testing.T) {
+	g := buildPathGraph(testPath())
+
+	wantNodes := []graphNode{
+		{ID: 1, Name: "Actor A", Type: nodeEndpoint},
+		{ID: 2, Name: "Actor B", Type: nodePath},
+		{ID: 3, Name: "Actor C", Type: nodeEndpoint},
+	}
+	if len(g.Nodes) != len(wantNodes) {
+		t.Fatalf("expected %d nodes, got %+v", len(wantNodes), g.Nodes)
+	}
+	for i, n := range wantNodes {
+		if g.Nodes[i] != n {
+			t.Errorf("node %d: expected %+v, got %+v", i, n, g.Nodes[i])
+		}
+	}
+
+	wantLinks := []graphLink{
+		{Source: 1, Target: 2, Movie: "Movie One", Year: 2000},
+		{Source: 2, Target: 3, Movie: "Movie Two", Year: 2010},
+	}
+	if len(g.Links) != len(wantLinks) {
+		t.Fatalf("expected %d links, got %+v", len(wantLinks), g.Links)
+	}
+	for i, l := range wantLinks {
+		if g.Links[i] != l {
+			t.Errorf("link %d: expected %+v, got %+v", i, l, g.Links[i])
+		}
+	}
+}
+
+func TestBuildPathGraph_Empty(t *testing.T) {
+	b, err := json.Marshal(buildPathGraph(nil))
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if string(b) != `{"nodes":[],"links":[]}` {
+		t.Errorf("expected empty arrays, got %s", b)
+	}
+}
+
+func TestPathGraph_AddNeighbors(t *testing.T) {
+	g := buildPathGraph(testPath())
+	g.addNeighbors([]graph.NeighborEdge{
+		{FromID: 1, Actor: models.Actor{TmdbID: 9, Name: "Actor Z"}, MovieTitle: "Movie Nine", MovieYear: 1990},
+		{FromID: 3, Actor: models.Actor{TmdbID: 9, Name: "Actor Z"}, MovieTitle: "Movie Ten", MovieYear: 1995},
+	})
+
+	if len(g.Nodes) != 4 {
+		t.Fatalf("expected shared neighbor to be added once, got %+v", g.Nodes)
+	}
+	if g.Nodes[3].Type != nodeNeighbor {
+		t.Errorf("expected neighbor node type, got %q", g.Nodes[3].Type)
+	}
+	if len(g.Links) != 4 {
+		t.Errorf("expected a link per neighbor edge, got %+v", g.Links)
+	}
+}
+
+func TestPathGraph_PathTimeout(t *testing.T) {
+	cfg := testServerConfig()
+	cfg.PathQueryTimeout = 10 * time.Millisecond
+	h := newConfiguredHandler(t, cfg)
+	h.db = &fakeStore{shortestPath: func(ctx context.Context, _, _ int) ([]graph.PathStep, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	rec := serve(h, "/api/v1/path/graph?a=1&b=3", false)
+	if rec.Code != http.StatusGatewayTimeout || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected a JSON 504 once the path timeout passed, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestDegreesFragment_EmbedsGraphJSON(t *testing.T) {
+	tmpl := template.Must(parseTemplates(web.FS, tmdbImages{}, ""))
+
+	steps := testPath()
+	var buf strings.Builder
+	err := tmpl.ExecuteTemplate(&buf, "degrees.html", pathResult{Steps: steps, Degrees: 2, Graph: buildPathGraph(steps)})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	const open = `<script type="application/json" id="path-graph-data">`
+	_, rest, ok := strings.Cut(buf.String(), open)
+	if !ok {
+		t.Fatalf("expected embedded graph script, got:\n%s", buf.String())
+	}
+	raw, _, _ := strings.Cut(rest, "</script>")
+
+	var g pathGraph
+	if err := json.Unmarshal([]byte(raw), &g); err != nil {
+		t.Fatalf("embedded graph is not valid JSON: %v\n%s", err, raw)
+	}
+	if len(g.Nodes) != 3 || len(g.Links) != 2 {
+		t.Errorf("unexpected embedded graph: %+v", g)
+	}
+}
+
+// servePaths posts body to /api/v1/paths through the full handler stack
+// backed by db.
+func servePaths(t *testing.T, db graphStore, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := newTestHandler(t)
+	h.db = db
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/paths", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBatchPaths(t *testing.T) {
+	hops := map[int]int{4: 2, 5: graph.Unconnected, 6: 1, 8: 3}
+	var inFlight, peak atomic.Int32
+	db := &fakeStore{distance: func(ctx context.Context, a, b, maxHops int) (int, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		if a != 123 || maxHops != batchMaxHops {
+			t.Errorf("unexpected Distance(%d, %d, %d)", a, b, maxHops)
+		}
+		return hops[b], nil
+	}}
+
+	rec := servePaths(t, db, `{"from": 123, "to": [4, 5, 6, 123, 8, 4, 5, 6, 8]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := `{"results":[{"to":4,"degrees":2},{"to":5,"degrees":null},{"to":6,"degrees":1},{"to":123,"degrees":0},` +
+		`{"to":8,"degrees":3},{"to":4,"degrees":2},{"to":5,"degrees":null},{"to":6,"degrees":1},{"to":8,"degrees":3}]}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+	if p := peak.Load(); p > batchWorkers {
+		t.Errorf("expected at most %d concurrent queries, saw %d", batchWorkers, p)
+	}
+}
+
+func TestBatchPaths_Rejections(t *testing.T) {
+	db := &fakeStore{} // any query panics
+	tooMany := make([]string, maxBatchTargets+1)
+	for i := range tooMany {
+		tooMany[i] = strconv.Itoa(i + 1)
+	}
+
+	for _, body := range []string{
+		`not json`,
+		`{"from": 0, "to": [1]}`,
+		`{"from": 1, "to": []}`,
+		`{"from": 1, "to": [2, -3]}`,
+		`{"from": 1, "to": [` + strings.Join(tooMany, ",") + `]}`,
+	} {
+		rec := servePaths(t, db, body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rec.Code)
+		}
+		var resp errorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+			t.Errorf("%s: expected a JSON error, got %q", body, rec.Body.String())
+		}
+	}
+}
+
+func TestBatchPaths_QueryFailure(t *testing.T) {
+	db := &fakeStore{distance: func(ctx context.Context, a, b, maxHops int) (int, error) {
+		switch b {
+		case 5:
+			return 0, errors.New("boom")
+		case 6:
+			return 0, context.DeadlineExceeded
+		}
+		return 2, nil
+	}}
+
+	rec := servePaths(t, db, `{"from": 1, "to": [2, 5, 6]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// A failure is reported on its pair, never as an unconnected null.
+	want := `{"results":[{"to":2,"degrees":2},{"to":5,"degrees":null,"error":"something went wrong"},` +
+		`{"to":6,"degrees":null,"error":"the path search took too long"}]}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestConnected(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{distance: func(_ context.Context, a, b, maxHops int) (int, error) {
+		if maxHops != connectedMaxHops {
+			t.Errorf("unexpected Distance(%d, %d, %d)", a, b, maxHops)
+		}
+		if b == 4724 {
+			return 2, nil
+		}
+		return graph.Unconnected, nil
+	}}
+
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{"/api/v1/connected?a=287&b=4724", `{"connected":true,"degrees":2}`},
+		{"/api/v1/connected?a=287&b=9", `{"connected":false}`},
+		{"/api/v1/connected?a=287&b=287", `{"connected":true,"degrees":0}`},
+	} {
+		rec := serve(h, tt.path, false)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", tt.path, rec.Code)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestConnected_QueryErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"timeout", context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{"failure", errors.New("connection reset"), http.StatusInternalServerError},
+	} {
+		h := newTestHandler(t)
+		h.db = &fakeStore{distance: func(context.Context, int, int, int) (int, error) { return 0, tt.err }}
+
+		rec := serve(h, "/api/v1/connected?a=287&b=4724", false)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.status, rec.Code)
+		}
+		var resp errorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+			t.Errorf("%s: expected a JSON error, got %q", tt.name, rec.Body.String())
+		}
+	}
+}
+
+func TestConnected_BadIDs(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{} // any query panics
+
+	for _, path := range []string{"/api/v1/connected?a=287", "/api/v1/connected?a=x&b=2", "/api/v1/connected?a=1&b=-2"} {
+		rec := serve(h, path, false)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rec.Code)
+		}
+		var resp errorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+			t.Errorf("%s: expected a JSON error, got %q", path, rec.Body.String())
+		}
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{costars: func(_ context.Context, id, limit int) ([]graph.Costar, error) {
+		if id != 287 || limit != maxNeighbors {
+			t.Errorf("unexpected GetCostars(%d, %d)", id, limit)
+		}
+		return []graph.Costar{
+			{Actor: models.Actor{TmdbID: 1461, Name: "George Clooney"}, SharedMovies: 3, Movie: models.Movie{TmdbID: 163, Title: "Ocean's Thirteen", Year: 2007}},
+			{Actor: models.Actor{TmdbID: 819, Name: "Edward Norton"}, SharedMovies: 1, Movie: models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999}},
+		}, nil
+	}}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/neighbors?id=287", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := `{"id":287,"neighbors":[{"id":1461,"name":"George Clooney","shared_movies":3,"movie":"Ocean's Thirteen","year":2007},` +
+		`{"id":819,"name":"Edward Norton","shared_movies":1,"movie":"Fight Club","year":1999}]}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestNeighbors_Empty(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{costars: func(context.Context, int, int) ([]graph.Costar, error) {
+		return nil, nil
+	}}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/neighbors?id=5", nil))
+	if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || got != `{"id":5,"neighbors":[]}` {
+		t.Errorf("expected an empty neighbor list, got %d %s", rec.Code, got)
+	}
+
+	for _, query := range []string{"", "?id=", "?id=abc", "?id=-1"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/neighbors"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestAPISearch(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{search: func(_ context.Context, prefix string, limit int) ([]graph.SearchResult, error) {
+		if prefix != "brad" || limit != 15 {
+			t.Errorf("expected the default limit, got SearchActors(%q, %d)", prefix, limit)
+		}
+		return []graph.SearchResult{
+			{Actor: models.Actor{TmdbID: 287, Name: "Brad Pitt"}, ProfilePath: "/pitt.jpg", Popularity: 42.5, KnownFor: "Fight Club"},
+			{Actor: models.Actor{TmdbID: 9, Name: "Bradley Ramsey"}},
+		}, nil
+	}}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/search?q=brad", nil))
+	want := `{"query":"brad","results":[{"id":287,"name":"Brad Pitt","profile_path":"/pitt.jpg","popularity":42.5,"known_for":"Fight Club"},` +
+		`{"id":9,"name":"Bradley Ramsey"}]}`
+	if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || got != want {
+		t.Errorf("expected 200\n%s\ngot %d\n%s", want, rec.Code, got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/search?q=", nil))
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"query":"","results":[]}` {
+		t.Errorf("expected no results for an empty query, got %s", got)
+	}
+}
+
+func TestAPISearch_LimitCap(t *testing.T) {
+	h := newTestHandler(t)
+	var got []int
+	h.db = &fakeStore{search: func(_ context.Context, _ string, limit int) ([]graph.SearchResult, error) {
+		got = append(got, limit)
+		return nil, nil
+	}}
+
+	for _, limit := range []string{"1", "50"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/search?q=a&limit="+limit, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("limit=%s: expected 200, got %d", limit, rec.Code)
+		}
+	}
+	if !slices.Equal(got, []int{1, 50}) {
+		t.Errorf("expected the requested limits passed through, got %v", got)
+	}
+
+	for _, limit := range []string{"51", "0", "-3", "ten"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/search?q=a&limit="+limit, nil))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "limit must be between 1 and 50") {
+			t.Errorf("limit=%s: expected 400, got %d %s", limit, rec.Code, rec.Body.String())
+		}
+	}
+	if len(got) != 2 {
+		t.Errorf("expected rejected limits never to reach the database, got %v", got)
+	}
+}
+
+func TestSearch_ConfiguredLimit(t *testing.T) {
+	cfg := testServerConfig()
+	cfg.SearchLimit = 5
+	h := newConfiguredHandler(t, cfg)
+	h.db = &fakeStore{search: func(_ context.Context, _ string, limit int) ([]graph.SearchResult, error) {
+		if limit != 5 {
+			t.Errorf("expected the configured limit 5, got %d", limit)
+		}
+		return nil, nil
+	}}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=a", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+}
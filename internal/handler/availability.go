@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+)
+
+// errUnavailable is served instead of running a query while the database is
+// known to be down.
+var errUnavailable = &requestError{
+	status: http.StatusServiceUnavailable,
+	msg:    "the graph database is temporarily unavailable",
+}
+
+// availability tracks whether Neo4j is reachable. Failed queries mark it
+// down straight away; a background probe marks it up again once the
+// database answers, so requests in between fail fast instead of each waiting
+// out its timeout against a dead connection.
+type availability struct {
+	down     atomic.Bool
+	probe    func(context.Context) error
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func newAvailability(probe func(context.Context) error, interval time.Duration, logger *slog.Logger) *availability {
+	return &availability{probe: probe, interval: interval, logger: logger}
+}
+
+// Available reports whether queries should be attempted.
+func (a *availability) Available() bool {
+	return !a.down.Load()
+}
+
+// observe marks the database down if err says it couldn't be reached. Query
+// errors such as bad input or timeouts leave the state alone.
+func (a *availability) observe(err error) {
+	if isUnavailable(err) {
+		a.set(false, err)
+	}
+}
+
+// set records the new state and logs the transition, if any.
+func (a *availability) set(up bool, err error) {
+	if wasDown := a.down.Swap(!up); wasDown == !up {
+		return
+	}
+	if up {
+		a.logger.Info("graph database available again")
+	} else {
+		a.logger.Error("graph database unavailable", "err", err)
+	}
+}
+
+// Run probes the database every interval until ctx is done. Each probe gets
+// at most one interval to answer.
+func (a *availability) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.check(ctx)
+		}
+	}
+}
+
+func (a *availability) check(ctx context.Context) {
+	probeCtx, cancel := context.WithTimeout(ctx, a.interval)
+	defer cancel()
+	err := a.probe(probeCtx)
+	if ctx.Err() != nil {
+		return // shutting down, not an outage
+	}
+	a.set(err == nil, err)
+}
+
+// retryAfter is the Retry-After value for a 503: the next probe is at most
+// one interval away.
+func (a *availability) retryAfter() string {
+	return strconv.Itoa(max(1, int((a.interval+time.Second-1)/time.Second)))
+}
+
+// isUnavailable reports whether err means Neo4j couldn't be reached, either
+// directly or after the driver exhausted its retries.
+func isUnavailable(err error) bool {
+	var connErr *neo4j.ConnectivityError
+	var retryErr *neo4j.TransactionExecutionLimit
+	return errors.As(err, &connErr) || errors.As(err, &retryErr)
+}
+
+// requireDB wraps a handler that queries the database so it answers with a
+// 503 while the database is down.
+func (h *Handler) requireDB(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.availability.Available() {
+			w.Header().Set("Retry-After", h.availability.retryAfter())
+			h.renderError(w, r, errUnavailable)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// MonitorDB probes the database in the background until ctx is done, so the
+// handler notices when an outage ends. Without it, routes stay short-circuited
+// after the first connectivity failure.
+func (h *Handler) MonitorDB(ctx context.Context) {
+	h.availability.Run(ctx)
+}
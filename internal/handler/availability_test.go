@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+)
+
+// fakeProbe stands in for VerifyConnectivity, failing while down is set.
+type fakeProbe struct {
+	down atomic.Bool
+}
+
+func (p *fakeProbe) probe(context.Context) error {
+	if p.down.Load() {
+		return &neo4j.ConnectivityError{Inner: errors.New("connection refused")}
+	}
+	return nil
+}
+
+func TestAvailability_Transitions(t *testing.T) {
+	p := &fakeProbe{}
+	a := newAvailability(p.probe, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx := context.Background()
+
+	if !a.Available() {
+		t.Fatal("expected available before any failure")
+	}
+
+	a.observe(badRequest("invalid actor id"))
+	a.observe(fmt.Errorf("error getting shortest path: %w", context.DeadlineExceeded))
+	if !a.Available() {
+		t.Fatal("expected query errors to leave the state alone")
+	}
+
+	a.observe(fmt.Errorf("error searching actors: %w", &neo4j.ConnectivityError{Inner: errors.New("EOF")}))
+	if a.Available() {
+		t.Fatal("expected a connectivity error to mark the database down")
+	}
+
+	p.down.Store(true)
+	a.check(ctx)
+	if a.Available() {
+		t.Fatal("expected a failed probe to keep the database down")
+	}
+
+	p.down.Store(false)
+	a.check(ctx)
+	if !a.Available() {
+		t.Fatal("expected a successful probe to mark the database up")
+	}
+
+	p.down.Store(true)
+	a.check(ctx)
+	if a.Available() {
+		t.Fatal("expected a failed probe to mark a healthy database down")
+	}
+}
+
+func TestAvailability_RunRecovers(t *testing.T) {
+	p := &fakeProbe{}
+	a := newAvailability(p.probe, 10*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a.observe(&neo4j.ConnectivityError{Inner: errors.New("EOF")})
+	go a.Run(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for !a.Available() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the poll to mark the database up again")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRequireDB_ShortCircuitsWhileDown(t *testing.T) {
+	h := newTestHandler(t)
+	p := &fakeProbe{}
+	h.availability = newAvailability(p.probe, 5*time.Second, h.logger)
+	h.availability.observe(&neo4j.ConnectivityError{Inner: errors.New("EOF")})
+
+	// With a nil database these would panic if the short circuit didn't
+	// stop them first.
+	for _, path := range []string{"/search?q=bacon", "/degrees?a=1&b=2", "/stats", "/actor/1", "/api/v1/path/graph?a=1&b=2"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected 503, got %d", path, rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "5" {
+			t.Errorf("%s: expected Retry-After 5, got %q", path, got)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, "the graph database is temporarily unavailable") {
+			t.Errorf("%s: expected the unavailable fragment, got %s", path, body)
+		}
+	}
+
+	h.availability.check(context.Background())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 after recovery, got %d", rec.Code)
+	}
+}
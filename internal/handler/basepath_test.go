@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+func newPrefixedHandler(t *testing.T) *Handler {
+	t.Helper()
+	cfg := testServerConfig()
+	cfg.BasePath = "/degrees"
+	h := newConfiguredHandler(t, cfg)
+	h.db = &fakeStore{shortestPath: func(context.Context, int, int) ([]graph.PathStep, error) {
+		return testPath(), nil
+	}}
+	return h
+}
+
+func serve(h http.Handler, path string, htmx bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if htmx {
+		req.Header.Set("HX-Request", "true")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBasePath_RedirectsBarePrefix(t *testing.T) {
+	h := newPrefixedHandler(t)
+
+	rec := serve(h, "/degrees?a=1", false)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/degrees/?a=1" {
+		t.Errorf("expected a redirect to /degrees/?a=1, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestBasePath_Navigation(t *testing.T) {
+	h := newPrefixedHandler(t)
+
+	rec := serve(h, "/degrees/", false)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the home page, got %d", rec.Code)
+	}
+	page := rec.Body.String()
+	for _, want := range []string{`href="/degrees/"`, `hx-get="/degrees/search"`, `hx-get="/degrees/degrees"`, `href="/degrees/daily"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("home page missing %q", want)
+		}
+	}
+	if regexp.MustCompile(`(href|hx-get)="/(search|daily|stats)`).MatchString(page) {
+		t.Error("home page still links outside the prefix")
+	}
+
+	css := regexp.MustCompile(`href="(/degrees/static/style\.css\?v=[0-9a-f]+)"`).FindStringSubmatch(page)
+	if css == nil {
+		t.Fatal("expected a versioned stylesheet URL under the prefix")
+	}
+	if rec := serve(h, css[1], false); rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("expected the stylesheet to resolve, got %d %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestBasePath_Fragments(t *testing.T) {
+	h := newPrefixedHandler(t)
+
+	if rec := serve(h, "/degrees/search", true); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for the search fragment, got %d", rec.Code)
+	}
+
+	rec := serve(h, "/degrees/degrees?a=1&b=3", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the degrees fragment, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{`href="/degrees/actor/1"`, `href="/degrees/degrees/export?a=1&b=3&format=csv"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("degrees fragment missing %q\n%s", want, body)
+		}
+	}
+}
+
+func TestBasePath_OutsidePrefix(t *testing.T) {
+	h := newPrefixedHandler(t)
+
+	if rec := serve(h, "/search", true); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 outside the prefix, got %d", rec.Code)
+	}
+
+	rec := serve(h, "/degrees/nope", false)
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), `href="/degrees/"`) {
+		t.Errorf("expected a 404 page linking back under the prefix, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,310 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"golang.org/x/sync/singleflight"
+)
+
+// staticMaxAge is how long browsers may reuse a static file fetched by its
+// plain path. Templates link assets through the asset func, so a plain path
+// is an old page or a hand-typed URL; it's kept short and the ETag makes
+// revalidation a cheap 304.
+const staticMaxAge = 10 * time.Minute
+
+// assetMaxAge is the lifetime of a versioned asset URL. The ?v= hash changes
+// with the contents, so the response never needs revalidating.
+const assetMaxAge = 365 * 24 * time.Hour
+
+// staticServer serves the embedded static files with strong ETags hashed from
+// their contents at startup. Embedded files carry no modification time, so
+// http.FileServer would send no validator at all.
+type staticServer struct {
+	fs       iofs.FS
+	etags    map[string]string
+	versions map[string]string
+}
+
+func newStaticServer(fsys iofs.FS) (*staticServer, error) {
+	s := &staticServer{fs: fsys, etags: make(map[string]string), versions: make(map[string]string)}
+	err := walkFiles(fsys, func(name string, b []byte) {
+		s.etags[name] = contentETag(b)
+		s.versions[name] = assetVersion(b)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash static files: %w", err)
+	}
+	return s, nil
+}
+
+// ServeHTTP expects the /static/ prefix to have been stripped. Only files
+// hashed at startup are served, so there are no directory listings. A ?v=
+// naming the current contents makes the response immutable; a stale or
+// missing one still gets the file, with the short lifetime.
+func (s *staticServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	etag, ok := s.etags[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// embed.FS files implement io.ReadSeeker.
+	f, err := s.fs.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(staticMaxAge.Seconds()))
+	if v := r.URL.Query().Get("v"); v != "" && v == s.versions[name] {
+		cacheControl = fmt.Sprintf("public, max-age=%d, immutable", int(assetMaxAge.Seconds()))
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+
+	// ServeContent handles If-None-Match, Range and HEAD from here.
+	http.ServeContent(w, r, name, time.Time{}, f.(io.ReadSeeker))
+}
+
+// assetURLs returns the template func behind {{asset "style.css"}}: the
+// file's /static/ URL with a ?v= hash of the contents of fsys's static
+// directory. Names with no such file get the plain URL. fsys without a static
+// directory is fine; every URL is plain then.
+func assetURLs(fsys iofs.FS) (func(name string) string, error) {
+	versions := make(map[string]string)
+	if _, err := iofs.Stat(fsys, "static"); err == nil {
+		static, err := iofs.Sub(fsys, "static")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create static sub-filesystem: %w", err)
+		}
+		err = walkFiles(static, func(name string, b []byte) {
+			versions[name] = assetVersion(b)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash static files: %w", err)
+		}
+	}
+	return func(name string) string {
+		if v, ok := versions[name]; ok {
+			return "/static/" + name + "?v=" + v
+		}
+		return "/static/" + name
+	}, nil
+}
+
+// walkFiles calls visit with the name and contents of every file in fsys.
+func walkFiles(fsys iofs.FS, visit func(name string, b []byte)) error {
+	return iofs.WalkDir(fsys, ".", func(name string, d iofs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := iofs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		visit(name, b)
+		return nil
+	})
+}
+
+// assetVersion is the short content hash carried in a versioned asset URL.
+func assetVersion(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:6])
+}
+
+func contentETag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// statsETag identifies the graph state a stats fragment was rendered from.
+// The degree histogram is a random sample and isn't part of it: a client
+// holding a fragment for the same counts has nothing new to fetch. The
+// freshness line is, as rendered, so "5 minutes ago" doesn't stick around in a
+// cached copy once it has become "1 hour ago". Two fragments with the same
+// tag can still differ in their sample, so the tag is weak.
+func statsETag(s *graph.Stats) string {
+	var ago string
+	if !s.LastIngestAt.IsZero() {
+		ago = timeAgo(s.LastIngestAt, time.Now())
+	}
+	return "W/" + contentETag(fmt.Appendf(nil, "%d|%d|%d|%s|%s", s.ActorCount, s.EdgeCount, s.MostConnectedCount, s.MostConnectedActor, ago))
+}
+
+// ttlCache reuses a fetched value for ttl: the graph's stats, so a busy home
+// page doesn't run the stats aggregate on every load, and the pool of popular
+// actors /suggest picks from. A zero ttl bypasses the cache and fetches every
+// time. The lock isn't held across a fetch; requests arriving while one runs
+// share it instead.
+type ttlCache[V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	fetched time.Time
+	val     V
+	fetches singleflight.Group
+}
+
+// setTTL changes how long a fetched value is reused, from the next request
+// on.
+func (c *ttlCache[V]) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
+// get returns the value fetched within the TTL before now, or fetches it
+// with fetch. A failed fetch isn't cached.
+func (c *ttlCache[V]) get(ctx context.Context, now time.Time, fetch func(context.Context) (V, error)) (V, error) {
+	c.mu.Lock()
+	ttl := c.ttl
+	if ttl > 0 && !c.fetched.IsZero() && now.Sub(c.fetched) < ttl {
+		val := c.val
+		c.mu.Unlock()
+		return val, nil
+	}
+	c.mu.Unlock()
+	if ttl <= 0 {
+		return fetch(ctx)
+	}
+
+	return sharedCall(ctx, &c.fetches, "", func(ctx context.Context) (V, error) {
+		val, err := fetch(ctx)
+		if err != nil {
+			return val, err
+		}
+		c.mu.Lock()
+		if now.After(c.fetched) {
+			c.fetched, c.val = now, val
+		}
+		c.mu.Unlock()
+		return val, nil
+	})
+}
+
+// dayCache holds a value fetched once per UTC day: the actors the sitemap
+// lists, or the leaderboard's ranking. Requests arriving while the day's
+// fetch runs share it rather than wait on the lock. A failed fetch isn't
+// cached.
+type dayCache[V any] struct {
+	mu      sync.Mutex
+	day     time.Time
+	val     V
+	fetches singleflight.Group
+}
+
+// get returns the value for now's UTC day, fetching it with fetch on the
+// first call of the day.
+func (c *dayCache[V]) get(ctx context.Context, now time.Time, fetch func(context.Context) (V, error)) (V, error) {
+	y, m, d := now.UTC().Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	c.mu.Lock()
+	if c.day.Equal(day) {
+		val := c.val
+		c.mu.Unlock()
+		return val, nil
+	}
+	c.mu.Unlock()
+
+	return sharedCall(ctx, &c.fetches, day.Format(time.DateOnly), func(ctx context.Context) (V, error) {
+		val, err := fetch(ctx)
+		if err != nil {
+			return val, err
+		}
+		c.mu.Lock()
+		// A fetch for yesterday finishing late mustn't replace today's.
+		if !c.day.After(day) {
+			c.day, c.val = day, val
+		}
+		c.mu.Unlock()
+		return val, nil
+	})
+}
+
+// extrasCache keeps what TMDb said about an actor or movie for
+// tmdbExtrasTTL, so a profile or result viewed again, or a crawler walking
+// the sitemap, doesn't wait on TMDb and its rate limiter for every page. A
+// miss is cached like anything else; a failed fetch isn't put, so the next
+// view asks again. Past tmdbExtrasCacheSize entries the expired ones are
+// dropped and, if that isn't enough, an arbitrary one. The zero value is
+// ready to use.
+type extrasCache[V any] struct {
+	mu      sync.Mutex
+	entries map[int]extrasEntry[V]
+}
+
+type extrasEntry[V any] struct {
+	val     V
+	fetched time.Time
+}
+
+// get returns the value put for id within the TTL before now.
+func (c *extrasCache[V]) get(id int, now time.Time) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok || now.Sub(e.fetched) >= tmdbExtrasTTL {
+		var zero V
+		return zero, false
+	}
+	return e.val, true
+}
+
+func (c *extrasCache[V]) put(id int, val V, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[int]extrasEntry[V])
+	}
+	if _, ok := c.entries[id]; !ok && len(c.entries) >= tmdbExtrasCacheSize {
+		for key, e := range c.entries {
+			if now.Sub(e.fetched) >= tmdbExtrasTTL {
+				delete(c.entries, key)
+			}
+		}
+		for key := range c.entries {
+			if len(c.entries) < tmdbExtrasCacheSize {
+				break
+			}
+			delete(c.entries, key)
+		}
+	}
+	c.entries[id] = extrasEntry[V]{val: val, fetched: now}
+}
+
+// notModified sets etag on the response and, when the request's If-None-Match
+// already names it, writes a 304 and reports true.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches applies the weak comparison If-None-Match calls for, so a W/
+// tag from a compressed response still matches its strong original.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
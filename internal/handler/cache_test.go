@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/web"
+)
+
+func TestStatic_ConditionalGet(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/style.css", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("expected a strong ETag, got %q", etag)
+	}
+	if cc := rec.Header().Get("Cache-Control"); !strings.Contains(cc, "max-age=") {
+		t.Errorf("expected a max-age Cache-Control, got %q", cc)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("expected text/css, got %q", rec.Header().Get("Content-Type"))
+	}
+
+	for _, inm := range []string{etag, "W/" + etag, `"stale", ` + etag} {
+		req := httptest.NewRequest(http.MethodGet, "/static/style.css", nil)
+		req.Header.Set("If-None-Match", inm)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: expected 304, got %d", inm, rec.Code)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: expected empty body, got %d bytes", inm, rec.Body.Len())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/static/style.css", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestStatic_NoDirectoryListing(t *testing.T) {
+	h := newTestHandler(t)
+
+	for _, path := range []string{"/static/", "/static/missing.js"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestAssetURLs_ChangeWithContents(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "static/style.css", "body { color: red; }")
+
+	urlFor := func() string {
+		t.Helper()
+		asset, err := assetURLs(os.DirFS(dir))
+		if err != nil {
+			t.Fatalf("assetURLs failed: %v", err)
+		}
+		return asset("style.css")
+	}
+
+	first := urlFor()
+	if !strings.HasPrefix(first, "/static/style.css?v=") {
+		t.Fatalf("expected a versioned URL, got %q", first)
+	}
+	if again := urlFor(); again != first {
+		t.Errorf("expected the same contents to keep the URL %q, got %q", first, again)
+	}
+	writeTemplate(t, dir, "static/style.css", "body { color: blue; }")
+	if second := urlFor(); second == first {
+		t.Errorf("expected a new URL after the file changed, still %q", second)
+	}
+
+	asset, err := assetURLs(os.DirFS(t.TempDir()))
+	if err != nil {
+		t.Fatalf("assetURLs without a static directory failed: %v", err)
+	}
+	if got := asset("style.css"); got != "/static/style.css" {
+		t.Errorf("expected the plain URL without a static directory, got %q", got)
+	}
+}
+
+func TestStatic_VersionedAndPlainURLs(t *testing.T) {
+	h := newTestHandler(t)
+	asset, err := assetURLs(web.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versioned := asset("style.css")
+
+	var buf strings.Builder
+	if err := h.execute(&buf, "base.html", nil); err != nil {
+		t.Fatalf("render index failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `href="`+versioned+`"`) {
+		t.Errorf("expected the page to link %s", versioned)
+	}
+
+	for _, tt := range []struct {
+		path      string
+		immutable bool
+	}{
+		{versioned, true},
+		{"/static/style.css", false},
+		{"/static/style.css?v=stale", false},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", tt.path, rec.Code)
+			continue
+		}
+		cc := rec.Header().Get("Cache-Control")
+		if got := strings.Contains(cc, "immutable"); got != tt.immutable {
+			t.Errorf("%s: immutable = %v, want %v (Cache-Control %q)", tt.path, got, tt.immutable, cc)
+		}
+	}
+}
+
+func TestNotModified_StatsRepeatRequest(t *testing.T) {
+	stats := &graph.Stats{ActorCount: 10, EdgeCount: 40, MostConnectedActor: "Kevin Bacon", MostConnectedCount: 9}
+
+	first := httptest.NewRecorder()
+	if notModified(first, httptest.NewRequest(http.MethodGet, "/stats", nil), statsETag(stats)) {
+		t.Fatal("first request without If-None-Match should not be a 304")
+	}
+	etag := first.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected a weak ETag on first response, got %q", etag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	req.Header.Set("If-None-Match", etag)
+	repeat := httptest.NewRecorder()
+	if !notModified(repeat, req, statsETag(stats)) {
+		t.Fatal("repeat request with matching If-None-Match should be a 304")
+	}
+	if repeat.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", repeat.Code)
+	}
+
+	// New data in the graph must invalidate the client's copy.
+	changed := *stats
+	changed.EdgeCount++
+	after := httptest.NewRecorder()
+	if notModified(after, req, statsETag(&changed)) {
+		t.Error("expected a changed graph to produce a fresh response")
+	}
+}
+
+func TestTTLCache_TTL(t *testing.T) {
+	var c ttlCache[*graph.Stats]
+	calls := 0
+	fetch := func(context.Context) (*graph.Stats, error) {
+		calls++
+		return &graph.Stats{ActorCount: calls}, nil
+	}
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	c.get(context.Background(), now, fetch)
+	c.get(context.Background(), now, fetch)
+	if calls != 2 {
+		t.Fatalf("expected no caching without a ttl, got %d fetches", calls)
+	}
+
+	c.setTTL(time.Minute)
+	c.get(context.Background(), now.Add(59*time.Second), fetch)
+	got, _ := c.get(context.Background(), now.Add(time.Minute), fetch)
+	if calls != 3 || got.ActorCount != 3 {
+		t.Errorf("expected the stats refetched once the ttl passed, got %d fetches and %+v", calls, got)
+	}
+}
+
+func TestTTLCache_FetchDoesNotBlock(t *testing.T) {
+	for _, ttl := range []time.Duration{0, time.Minute} {
+		var c ttlCache[*graph.Stats]
+		c.setTTL(ttl)
+		started, release := make(chan struct{}), make(chan struct{})
+		slow := func(context.Context) (*graph.Stats, error) {
+			close(started)
+			<-release
+			return &graph.Stats{ActorCount: 1}, nil
+		}
+		go c.get(context.Background(), time.Now(), slow)
+		<-started
+
+		// A request whose context ends while the slow fetch runs isn't held up
+		// by it, cached or not.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		done := make(chan struct{})
+		go func() {
+			c.get(ctx, time.Now(), func(ctx context.Context) (*graph.Stats, error) { return nil, ctx.Err() })
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Errorf("ttl %v: expected a get not to wait on another request's fetch", ttl)
+		}
+		close(release)
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+// crawlMaxAge is how long crawlers and proxies may cache robots.txt and the
+// sitemap.
+const crawlMaxAge = time.Hour
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+// siteOrigin is the scheme and host that absolute links are built on: the
+// configured site URL, or else the request's own.
+func (h *Handler) siteOrigin(r *http.Request) string {
+	if h.siteURL != "" {
+		return h.siteURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// robotsHandler keeps crawlers to the home page and actor profiles: every
+// actor pair is a distinct /degrees URL, and crawling them all would mean a
+// path query for each.
+func (h *Handler) robotsHandler(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	buf.WriteString("User-agent: *\n")
+	fmt.Fprintf(&buf, "Allow: %s/\n", h.basePath)
+	fmt.Fprintf(&buf, "Allow: %s/actor/\n", h.basePath)
+	for _, path := range h.robotsDisallow {
+		fmt.Fprintf(&buf, "Disallow: %s%s\n", h.basePath, path)
+	}
+	if h.sitemapSize > 0 {
+		fmt.Fprintf(&buf, "\nSitemap: %s%s/sitemap.xml\n", h.siteOrigin(r), h.basePath)
+	}
+	writeCrawlFile(w, "text/plain; charset=utf-8", buf.Bytes())
+}
+
+// sitemapHandler lists the home page and the profiles of the sitemapSize
+// best-connected actors.
+func (h *Handler) sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	if h.sitemapSize == 0 {
+		h.renderError(w, r, notFound("page not found"))
+		return
+	}
+	actors, err := h.sitemap.get(r.Context(), time.Now(), func(ctx context.Context) ([]models.Actor, error) {
+		ranked, err := h.db.TopActors(ctx, 0, h.sitemapSize)
+		if err != nil {
+			return nil, err
+		}
+		actors := make([]models.Actor, len(ranked))
+		for i, r := range ranked {
+			actors[i] = r.Actor
+		}
+		return actors, nil
+	})
+	if err != nil {
+		h.log(r.Context()).Error("failed to list sitemap actors", "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	base := h.siteOrigin(r) + h.basePath
+	set := sitemapURLSet{URLs: make([]sitemapURL, 0, len(actors)+1)}
+	set.URLs = append(set.URLs, sitemapURL{Loc: base + "/"})
+	for _, a := range actors {
+		set.URLs = append(set.URLs, sitemapURL{Loc: base + "/actor/" + strconv.Itoa(a.TmdbID)})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		h.log(r.Context()).Error("failed to encode sitemap", "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+	buf.WriteByte('\n')
+	writeCrawlFile(w, "application/xml; charset=utf-8", buf.Bytes())
+}
+
+func writeCrawlFile(w http.ResponseWriter, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(crawlMaxAge.Seconds())))
+	w.Write(body)
+}
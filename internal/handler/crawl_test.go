@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+func crawlConfig() config.ServerConfig {
+	cfg := testServerConfig()
+	cfg.SiteURL = "https://degrees.example.com"
+	cfg.RobotsDisallow = []string{"/degrees", "/search", "/api/"}
+	cfg.SitemapSize = 3
+	return cfg
+}
+
+func TestRobots(t *testing.T) {
+	h := newConfiguredHandler(t, crawlConfig())
+
+	rec := serve(h, "/robots.txt", false)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("expected a plain-text 200, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	want := "User-agent: *\n" +
+		"Allow: /\n" +
+		"Allow: /actor/\n" +
+		"Disallow: /degrees\n" +
+		"Disallow: /search\n" +
+		"Disallow: /api/\n" +
+		"\nSitemap: https://degrees.example.com/sitemap.xml\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("robots.txt =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRobots_UnderBasePath(t *testing.T) {
+	cfg := crawlConfig()
+	cfg.BasePath = "/degrees"
+	cfg.SiteURL = ""
+	cfg.RobotsDisallow = []string{"/search"}
+	h := newConfiguredHandler(t, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/degrees/robots.txt", nil)
+	req.Host = "films.example.org"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{"Allow: /degrees/actor/\n", "Disallow: /degrees/search\n", "Sitemap: http://films.example.org/degrees/sitemap.xml\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("robots.txt missing %q\n%s", want, body)
+		}
+	}
+}
+
+func TestSitemap(t *testing.T) {
+	h := newConfiguredHandler(t, crawlConfig())
+	calls := 0
+	h.db = &fakeStore{topActors: func(_ context.Context, offset, limit int) ([]graph.RankedActor, error) {
+		calls++
+		if offset != 0 || limit != 3 {
+			t.Errorf("expected the first page of the configured sitemap size, got %d, %d", offset, limit)
+		}
+		return []graph.RankedActor{
+			{Actor: models.Actor{TmdbID: 287, Name: "Brad Pitt"}, Connections: 112},
+			{Actor: models.Actor{TmdbID: 819, Name: "Edward Norton"}, Connections: 80},
+		}, nil
+	}}
+
+	rec := serve(h, "/sitemap.xml", false)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/xml") {
+		t.Fatalf("expected an XML 200, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, xml.Header) {
+		t.Errorf("expected an XML declaration\n%s", body)
+	}
+
+	var set struct {
+		XMLName xml.Name
+		URLs    []struct {
+			Loc string `xml:"loc"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &set); err != nil {
+		t.Fatalf("sitemap is not valid XML: %v\n%s", err, body)
+	}
+	if set.XMLName.Space != "http://www.sitemaps.org/schemas/sitemap/0.9" || set.XMLName.Local != "urlset" {
+		t.Errorf("expected a sitemaps.org urlset, got %+v", set.XMLName)
+	}
+	var locs []string
+	for _, u := range set.URLs {
+		locs = append(locs, u.Loc)
+	}
+	want := []string{
+		"https://degrees.example.com/",
+		"https://degrees.example.com/actor/287",
+		"https://degrees.example.com/actor/819",
+	}
+	if strings.Join(locs, " ") != strings.Join(want, " ") {
+		t.Errorf("sitemap locs = %v, want %v", locs, want)
+	}
+
+	serve(h, "/sitemap.xml", false)
+	if calls != 1 {
+		t.Errorf("expected the actor list cached for the day, got %d queries", calls)
+	}
+}
+
+func TestDayCache_RefreshesDaily(t *testing.T) {
+	var c dayCache[[]models.Actor]
+	calls := 0
+	fetch := func(context.Context) ([]models.Actor, error) {
+		calls++
+		return []models.Actor{{TmdbID: calls}}, nil
+	}
+
+	day := time.Date(2026, 3, 14, 1, 0, 0, 0, time.UTC)
+	c.get(context.Background(), day, fetch)
+	c.get(context.Background(), day.Add(22*time.Hour), fetch)
+	got, _ := c.get(context.Background(), day.Add(23*time.Hour), fetch)
+	if calls != 2 || got[0].TmdbID != 2 {
+		t.Errorf("expected one fetch per UTC day, got %d calls and %+v", calls, got)
+	}
+}
+
+func TestSitemap_Disabled(t *testing.T) {
+	cfg := crawlConfig()
+	cfg.SitemapSize = 0
+	h := newConfiguredHandler(t, cfg)
+	h.db = &fakeStore{}
+
+	if rec := serve(h, "/sitemap.xml", false); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 with the sitemap off, got %d", rec.Code)
+	}
+	if rec := serve(h, "/robots.txt", false); strings.Contains(rec.Body.String(), "Sitemap:") {
+		t.Errorf("expected robots.txt without a sitemap\n%s", rec.Body.String())
+	}
+}
+
+func TestCrawlFiles_BypassRateLimit(t *testing.T) {
+	cfg := crawlConfig()
+	cfg.RateLimitPerSec = 0.001
+	cfg.RateBurst = 1
+	h := newConfiguredHandler(t, cfg)
+	h.db = &fakeStore{topActors: func(context.Context, int, int) ([]graph.RankedActor, error) { return nil, nil }}
+
+	for _, path := range []string{"/favicon.ico", "/robots.txt", "/sitemap.xml"} {
+		for range 5 {
+			if rec := serve(h, path, false); rec.Code != http.StatusOK {
+				t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+			}
+		}
+	}
+	serve(h, "/search", true)
+	if rec := serve(h, "/search", true); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected other routes still limited, got %d", rec.Code)
+	}
+}
+
+func TestFavicon(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := serve(h, "/favicon.ico", false)
+	if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Fatalf("expected the favicon, got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+	if !strings.Contains(serve(h, "/", false).Body.String(), `rel="icon" href="/static/favicon.ico?v=`) {
+		t.Error("expected pages to link the versioned favicon")
+	}
+}
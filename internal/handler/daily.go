@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+// dailyCache holds the day's challenge so DailyPair, which measures up to
+// dailyAttempts paths, runs once per UTC day rather than once per visit.
+// Requests arriving while it runs wait for that result instead of starting
+// their own, and the pick runs outside the lock, so one that is slow doesn't
+// hold up anything else.
+type dailyCache struct {
+	mu        sync.Mutex
+	day       time.Time
+	challenge *graph.DailyChallenge
+	err       error // ErrNoDailyPair; other errors aren't kept
+	picks     singleflight.Group
+}
+
+// get returns the challenge for now's UTC day, picking it with pick on the
+// first call of the day. Only a challenge or ErrNoDailyPair is kept; any
+// other failure is left for the next request to retry.
+func (c *dailyCache) get(ctx context.Context, now time.Time, pick func(context.Context, time.Time) (*graph.DailyChallenge, error)) (*graph.DailyChallenge, error) {
+	y, m, d := now.UTC().Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	c.mu.Lock()
+	if c.day.Equal(day) {
+		challenge, err := c.challenge, c.err
+		c.mu.Unlock()
+		return challenge, err
+	}
+	c.mu.Unlock()
+
+	return sharedCall(ctx, &c.picks, day.Format(time.DateOnly), func(ctx context.Context) (*graph.DailyChallenge, error) {
+		challenge, err := pick(ctx, day)
+		if err != nil && !errors.Is(err, graph.ErrNoDailyPair) {
+			return nil, err
+		}
+		c.mu.Lock()
+		// A pick for yesterday finishing late mustn't replace today's.
+		if !c.day.After(day) {
+			c.day, c.challenge, c.err = day, challenge, err
+		}
+		c.mu.Unlock()
+		return challenge, err
+	})
+}
+
+// dailyView is the challenge page. A nil Challenge means the graph couldn't
+// supply one today.
+type dailyView struct {
+	Challenge *graph.DailyChallenge
+}
+
+// dailyHandler shows today's two actors without the path between them: the
+// bare fragment for HTMX requests, otherwise the full page.
+func (h *Handler) dailyHandler(w http.ResponseWriter, r *http.Request) {
+	challenge, err := h.daily.get(r.Context(), time.Now(), h.db.DailyPair)
+	if err != nil && !errors.Is(err, graph.ErrNoDailyPair) {
+		h.log(r.Context()).Error("failed to pick daily pair", "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	view := dailyView{Challenge: challenge}
+	if r.Header.Get("HX-Request") == "true" {
+		h.renderFragment(w, r, "daily.html", view)
+		return
+	}
+	h.renderFragment(w, r, "daily_page.html", view)
+}
+
+// dailyRevealHandler renders the shortest path for today's challenge.
+func (h *Handler) dailyRevealHandler(w http.ResponseWriter, r *http.Request) {
+	challenge, err := h.daily.get(r.Context(), time.Now(), h.db.DailyPair)
+	if errors.Is(err, graph.ErrNoDailyPair) {
+		h.renderError(w, r, notFound("there is no challenge today"))
+		return
+	}
+	if err != nil {
+		h.log(r.Context()).Error("failed to pick daily pair", "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	a, b := challenge.A.TmdbID, challenge.B.TmdbID
+	steps, err := h.shortestPath(r.Context(), a, b)
+	if err != nil {
+		if isTimeout(err) {
+			h.log(r.Context()).Warn("shortest path timed out", "a", a, "b", b, "timeout", h.pathTimeout)
+			h.renderPathTimeout(w, r)
+			return
+		}
+		h.log(r.Context()).Error("failed to get shortest path", "a", a, "b", b, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+	h.renderFragment(w, r, "degrees.html", newPathResult(a, b, steps))
+}
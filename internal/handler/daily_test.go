@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+func testChallenge(day time.Time) *graph.DailyChallenge {
+	return &graph.DailyChallenge{
+		Date:    day,
+		A:       models.Actor{TmdbID: 1, Name: "Actor A"},
+		B:       models.Actor{TmdbID: 3, Name: "Actor C"},
+		Degrees: 2,
+	}
+}
+
+func TestDailyCache_OncePerUTCDay(t *testing.T) {
+	var c dailyCache
+	var picked []time.Time
+	pick := func(_ context.Context, day time.Time) (*graph.DailyChallenge, error) {
+		picked = append(picked, day)
+		return testChallenge(day), nil
+	}
+
+	morning := time.Date(2026, 3, 14, 0, 30, 0, 0, time.UTC)
+	for _, now := range []time.Time{
+		morning,
+		morning.Add(23 * time.Hour),
+		time.Date(2026, 3, 14, 16, 0, 0, 0, time.FixedZone("PDT", -7*60*60)), // 23:00 UTC
+	} {
+		got, err := c.get(context.Background(), now, pick)
+		if err != nil || !got.Date.Equal(time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("get(%s) = %+v, %v", now, got, err)
+		}
+	}
+	if len(picked) != 1 {
+		t.Errorf("expected one pick for the day, got %v", picked)
+	}
+
+	c.get(context.Background(), morning.Add(24*time.Hour), pick)
+	if len(picked) != 2 {
+		t.Errorf("expected a new pick the next day, got %v", picked)
+	}
+}
+
+func TestDailyCache_Errors(t *testing.T) {
+	var c dailyCache
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	calls := 0
+
+	// A query failure is retried by the next request...
+	failing := func(context.Context, time.Time) (*graph.DailyChallenge, error) {
+		calls++
+		return nil, errors.New("boom")
+	}
+	c.get(context.Background(), now, failing)
+	c.get(context.Background(), now, failing)
+	if calls != 2 {
+		t.Errorf("expected a failed pick to be retried, got %d calls", calls)
+	}
+
+	// ...but a graph with no suitable pair stays that way for the day.
+	calls = 0
+	sparse := func(context.Context, time.Time) (*graph.DailyChallenge, error) {
+		calls++
+		return nil, graph.ErrNoDailyPair
+	}
+	for range 2 {
+		if _, err := c.get(context.Background(), now, sparse); !errors.Is(err, graph.ErrNoDailyPair) {
+			t.Errorf("expected ErrNoDailyPair, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected ErrNoDailyPair to be cached, got %d calls", calls)
+	}
+}
+
+func TestDailyCache_PickOutsideLock(t *testing.T) {
+	var c dailyCache
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	started, release := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	pick := func(ctx context.Context, day time.Time) (*graph.DailyChallenge, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return testChallenge(day), ctx.Err()
+	}
+
+	// The first caller leaving doesn't cancel the pick it started.
+	first, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.get(first, now, pick)
+		done <- err
+	}()
+	<-started
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the departed caller to get context.Canceled, got %v", err)
+	}
+
+	// A caller that gives up stops waiting rather than queueing on a lock.
+	impatient, cancelImpatient := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelImpatient()
+	if _, err := c.get(impatient, now, pick); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the waiter to give up on its own deadline, got %v", err)
+	}
+
+	close(release)
+	got, err := c.get(context.Background(), now, pick)
+	if err != nil || got == nil {
+		t.Fatalf("expected the shared pick's challenge, got %+v, %v", got, err)
+	}
+	if _, err := c.get(context.Background(), now, pick); err != nil || calls.Load() != 1 {
+		t.Errorf("expected every caller to share one pick, got %v after %d picks", err, calls.Load())
+	}
+}
+
+func TestDaily_HidesPathUntilReveal(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{
+		dailyPair: func(_ context.Context, day time.Time) (*graph.DailyChallenge, error) {
+			return testChallenge(day), nil
+		},
+		shortestPath: func(_ context.Context, a, b int) ([]graph.PathStep, error) {
+			if a != 1 || b != 3 {
+				t.Errorf("expected the path for the daily pair, got (%d, %d)", a, b)
+			}
+			return testPath(), nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/daily", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	page := rec.Body.String()
+	for _, want := range []string{"<!DOCTYPE html>", `href="/actor/1">Actor A</a>`, `href="/actor/3">Actor C</a>`, `hx-get="/daily/reveal"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("daily page missing %q", want)
+		}
+	}
+	if strings.Contains(page, "Movie One") {
+		t.Error("daily page should not give away the path")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/daily/reveal", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Movie One") {
+		t.Errorf("expected the reveal to show the path, got %d\n%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDaily_NoChallenge(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{dailyPair: func(context.Context, time.Time) (*graph.DailyChallenge, error) {
+		return nil, graph.ErrNoDailyPair
+	}}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/daily", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "no challenge today") {
+		t.Errorf("expected the no-challenge message, got %d\n%s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/daily/reveal", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 revealing a missing challenge, got %d", rec.Code)
+	}
+}
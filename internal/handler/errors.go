@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+
+	mw "github.com/mark-c-hall/degrees-of-separation/internal/middleware"
+)
+
+// requestError is an error whose message is safe to show the user, together
+// with the status it should be served with.
+type requestError struct {
+	status int
+	msg    string
+}
+
+func (e *requestError) Error() string { return e.msg }
+
+func badRequest(msg string) error {
+	return &requestError{status: http.StatusBadRequest, msg: msg}
+}
+
+func notFound(msg string) error {
+	return &requestError{status: http.StatusNotFound, msg: msg}
+}
+
+type errorView struct {
+	Status    int
+	Message   string
+	RequestID string
+}
+
+// errorStatus maps err to a status and user-facing message. Anything not
+// recognised is a 500 whose details stay in the logs.
+func errorStatus(err error) (int, string) {
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &reqErr):
+		return reqErr.status, reqErr.msg
+	case isUnavailable(err):
+		return errUnavailable.status, errUnavailable.msg
+	case isTimeout(err):
+		return http.StatusGatewayTimeout, "the path search took too long"
+	default:
+		return http.StatusInternalServerError, "something went wrong"
+	}
+}
+
+// isTimeout reports whether err means a query ran out of time, either on the
+// request's deadline or on the transaction timeout Neo4j enforces.
+func isTimeout(err error) bool {
+	var neoErr *neo4j.Neo4jError
+	return errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &neoErr) && strings.Contains(neoErr.Code, "TransactionTimedOut"))
+}
+
+// jsonRoute reports whether r is for an /api/ or /admin/ route, which answer
+// in JSON rather than HTML.
+func jsonRoute(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/admin/")
+}
+
+// renderError writes err as the JSON error envelope for jsonRoute requests.
+// HTMX requests get the error.html fragment so the swap shows a readable
+// message in place; a full navigation gets a styled page instead,
+// not_found.html for a 404 and error_page.html for anything else. Callers log err first. A
+// connectivity error also marks the database unavailable.
+func (h *Handler) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	h.availability.observe(err)
+	status, msg := errorStatus(err)
+	requestID := mw.RequestIDFrom(r.Context())
+
+	if jsonRoute(r) {
+		writeJSON(w, status, errorResponse{Error: msg, RequestID: requestID})
+		return
+	}
+
+	name := "error.html"
+	switch {
+	case r.Header.Get("HX-Request") == "true":
+	case status == http.StatusNotFound:
+		name = "not_found.html"
+	default:
+		name = "error_page.html"
+	}
+
+	var buf bytes.Buffer
+	view := errorView{Status: status, Message: msg, RequestID: requestID}
+	if err := h.execute(&buf, name, view); err != nil {
+		h.log(r.Context()).Error("failed to render fragment", "template", name, "err", err)
+		buf.Reset()
+		buf.WriteString(msg)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// renderPanic is the Recovery middleware's renderer for non-API requests.
+func (h *Handler) renderPanic(w http.ResponseWriter, r *http.Request) {
+	h.renderError(w, r, errors.New("panic recovered"))
+}
+
+// unmatched wraps mux so the 404 and 405 it writes for requests that match
+// no route are rendered like any other error, keeping the Allow header the
+// mux sets on a 405. Routed requests pass straight through.
+func (h *Handler) unmatched(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallback, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		c := &muxErrorCapture{header: http.Header{}}
+		fallback.ServeHTTP(c, r)
+		switch c.status {
+		case http.StatusNotFound:
+			h.pageNotFound(w, r)
+		case http.StatusMethodNotAllowed:
+			w.Header().Set("Allow", c.header.Get("Allow"))
+			h.renderError(w, r, &requestError{status: http.StatusMethodNotAllowed, msg: "method not allowed"})
+		default:
+			mux.ServeHTTP(w, r)
+		}
+	})
+}
+
+// muxErrorCapture records the status and headers of the mux's built-in error
+// handlers and drops their plain-text bodies.
+type muxErrorCapture struct {
+	header http.Header
+	status int
+}
+
+func (c *muxErrorCapture) Header() http.Header { return c.header }
+
+func (c *muxErrorCapture) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return len(p), nil
+}
+
+func (c *muxErrorCapture) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+// renderPathTimeout replaces the generic timeout message on /degrees with one
+// that says why a path search can be slow and what to try instead. JSON
+// routes get the usual 504 envelope.
+func (h *Handler) renderPathTimeout(w http.ResponseWriter, r *http.Request) {
+	if jsonRoute(r) {
+		h.renderError(w, r, context.DeadlineExceeded)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.execute(&buf, "path_timeout.html", nil); err != nil {
+		h.log(r.Context()).Error("failed to render fragment", "template", "path_timeout.html", "err", err)
+		h.renderError(w, r, context.DeadlineExceeded)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusGatewayTimeout)
+	buf.WriteTo(w)
+}
+
+type slowDownView struct {
+	RetryAfter int // seconds
+	RequestID  string
+}
+
+// renderRateLimited is the rate limiter's OnLimit hook. Retry-After is
+// already set; this fills in a body the client can act on.
+func (h *Handler) renderRateLimited(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	seconds := int(retryAfter / time.Second)
+	requestID := mw.RequestIDFrom(r.Context())
+
+	if jsonRoute(r) {
+		msg := fmt.Sprintf("rate limit exceeded, retry in %d seconds", seconds)
+		writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: msg, RequestID: requestID})
+		return
+	}
+
+	var buf bytes.Buffer
+	view := slowDownView{RetryAfter: seconds, RequestID: requestID}
+	if err := h.execute(&buf, "slowdown.html", view); err != nil {
+		h.log(r.Context()).Error("failed to render fragment", "template", "slowdown.html", "err", err)
+		buf.Reset()
+		buf.WriteString("rate limit exceeded")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusTooManyRequests)
+	buf.WriteTo(w)
+}
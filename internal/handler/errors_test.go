@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	mw "github.com/mark-c-hall/degrees-of-separation/internal/middleware"
+	"github.com/mark-c-hall/degrees-of-separation/web"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantMsg    string
+	}{
+		{badRequest("invalid actor id"), http.StatusBadRequest, "invalid actor id"},
+		{notFound("actor not found"), http.StatusNotFound, "actor not found"},
+		{fmt.Errorf("error getting shortest path: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "the path search took too long"},
+		{fmt.Errorf("error searching actors: %w", &neo4j.ConnectivityError{Inner: io.EOF}), http.StatusServiceUnavailable, "the graph database is temporarily unavailable"},
+		{errors.New("neo4j: connection reset"), http.StatusInternalServerError, "something went wrong"},
+	}
+	for _, tt := range tests {
+		status, msg := errorStatus(tt.err)
+		if status != tt.wantStatus || msg != tt.wantMsg {
+			t.Errorf("%v: expected %d %q, got %d %q", tt.err, tt.wantStatus, tt.wantMsg, status, msg)
+		}
+	}
+}
+
+func TestRenderError_Fragment(t *testing.T) {
+	h := newTestHandler(t)
+
+	for _, tt := range []struct {
+		path       string
+		wantStatus int
+		wantMsg    string
+	}{
+		{"/degrees?a=x&b=1", http.StatusBadRequest, "actor ids must be positive whole numbers"},
+		{"/actor/abc", http.StatusNotFound, "actor not found"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("HX-Request", "true")
+		req.Header.Set("X-Request-ID", "support-ticket-42")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.wantStatus, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("%s: expected html content type, got %q", tt.path, ct)
+		}
+		body := rec.Body.String()
+		for _, want := range []string{`class="error-message"`, tt.wantMsg, "Request ID <code>support-ticket-42</code>"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: body missing %q\n%s", tt.path, want, body)
+			}
+		}
+	}
+}
+
+func TestRenderError_FullPage(t *testing.T) {
+	h := newTestHandler(t)
+
+	for _, tt := range []struct {
+		method     string
+		path       string
+		htmx       bool
+		wantStatus int
+		want       []string
+		dontWant   string
+	}{
+		{http.MethodGet, "/nope", false, http.StatusNotFound, []string{"<!DOCTYPE html>", "Page not found", "page not found"}, ""},
+		{http.MethodGet, "/actor/abc", false, http.StatusNotFound, []string{"<!DOCTYPE html>", "Page not found", "actor not found"}, ""},
+		{http.MethodGet, "/degrees?a=x&b=1", false, http.StatusBadRequest, []string{"<!DOCTYPE html>", "Something went wrong", "actor ids must be positive whole numbers"}, ""},
+		{http.MethodPost, "/stats", false, http.StatusMethodNotAllowed, []string{"<!DOCTYPE html>", "method not allowed"}, ""},
+		{http.MethodGet, "/nope", true, http.StatusNotFound, []string{`class="error-message"`, "page not found"}, "<!DOCTYPE html>"},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		req.Header.Set("X-Request-ID", "support-ticket-42")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.wantStatus, rec.Code)
+		}
+		body := rec.Body.String()
+		for _, want := range append(tt.want, "Request ID <code>support-ticket-42</code>") {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s %s: body missing %q\n%s", tt.method, tt.path, want, body)
+			}
+		}
+		if tt.dontWant != "" && strings.Contains(body, tt.dontWant) {
+			t.Errorf("%s %s: expected a fragment, got a full page\n%s", tt.method, tt.path, body)
+		}
+	}
+}
+
+func TestRenderPanic(t *testing.T) {
+	h := newTestHandler(t)
+	panics := mw.Recovery(slog.New(slog.NewTextHandler(io.Discard, nil)), h.renderPanic)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") }))
+
+	for _, tt := range []struct {
+		htmx bool
+		want string
+	}{
+		{false, "<!DOCTYPE html>"},
+		{true, `class="error-message"`},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/degrees?a=1&b=2", nil)
+		if tt.htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		rec := httptest.NewRecorder()
+		panics.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("htmx=%v: expected 500, got %d", tt.htmx, rec.Code)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, tt.want) || !strings.Contains(body, "something went wrong") {
+			t.Errorf("htmx=%v: expected %q and the generic message, got\n%s", tt.htmx, tt.want, body)
+		}
+		if tt.htmx && strings.Contains(body, "<!DOCTYPE html>") {
+			t.Errorf("htmx=true: expected a fragment, got a full page")
+		}
+	}
+}
+
+func TestRenderError_API(t *testing.T) {
+	h := newTestHandler(t)
+
+	for _, path := range []string{"/api/v1/path/graph?a=x&b=1", "/api/v1/path/graph?a=1&b=1"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: expected application/json, got %q", path, ct)
+		}
+
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: body is not JSON: %v\n%s", path, err, rec.Body.String())
+		}
+		if body["error"] == "" {
+			t.Errorf("%s: expected an error message, got %v", path, body)
+		}
+		if body["request_id"] == "" || body["request_id"] != rec.Header().Get("X-Request-ID") {
+			t.Errorf("%s: expected request_id matching the X-Request-ID header, got %v", path, body)
+		}
+	}
+}
+
+func TestRenderRateLimited(t *testing.T) {
+	slow := config.RoutePolicy{PerSec: 0.5, Burst: 1, Cost: 1}
+	cfg := testServerConfig()
+	cfg.RateRoutes = map[string]config.RoutePolicy{"/search": slow, "/api/v1/path/graph": slow}
+	h, err := NewHandler(nil, nil, web.FS, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	t.Cleanup(h.Close)
+
+	// The first request to each route spends its only token without
+	// reaching the (nil) database; the second is rejected.
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Request-ID", "support-ticket-42")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	serve("/api/v1/path/graph?a=-1&b=1")
+	rec := serve("/api/v1/path/graph?a=-1&b=1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("api: expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("api: expected Retry-After 2 at 0.5 tokens/s, got %q", got)
+	}
+	var resp errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("api: decoding body failed: %v", err)
+	}
+	if resp.Error != "rate limit exceeded, retry in 2 seconds" || resp.RequestID != "support-ticket-42" {
+		t.Errorf("api: unexpected body %+v", resp)
+	}
+
+	serve("/search?q=")
+	rec = serve("/search?q=")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("search: expected 429, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("search: expected html content type, got %q", ct)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "Slow down! Try again in 2 seconds.") {
+		t.Errorf("search: expected the slow down fragment, got %s", body)
+	}
+	if got, want := rec.Header().Get("Content-Length"), strconv.Itoa(rec.Body.Len()); got != want {
+		t.Errorf("search: expected Content-Length %s, got %q", want, got)
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+// exportRow is one actor in an exported path, with the movie that links them
+// to the previous actor. The first actor has no movie.
+type exportRow struct {
+	Step       int    `json:"step"`
+	ActorName  string `json:"actor_name"`
+	ActorID    int    `json:"actor_tmdb_id"`
+	MovieTitle string `json:"movie_title,omitempty"`
+	MovieYear  int    `json:"movie_year,omitempty"`
+}
+
+var exportHeader = []string{"step", "actor_name", "actor_tmdb_id", "movie_title", "movie_year"}
+
+// exportRows flattens an alternating actor/movie path into one row per actor.
+func exportRows(steps []graph.PathStep) []exportRow {
+	var rows []exportRow
+	var title string
+	var year int
+	for _, step := range steps {
+		if step.Actor == nil {
+			title, year = step.MovieTitle, step.MovieYear
+			continue
+		}
+		rows = append(rows, exportRow{
+			Step:       len(rows),
+			ActorName:  step.Actor.Name,
+			ActorID:    step.Actor.TmdbID,
+			MovieTitle: title,
+			MovieYear:  year,
+		})
+	}
+	return rows
+}
+
+// exportHandler serves the shortest path between a and b as a CSV or JSON
+// download, picked by format. A pair with no path is a 404 rather than an
+// empty file.
+func (h *Handler) exportHandler(w http.ResponseWriter, r *http.Request) {
+	idA, errA := parseActorID(r.URL.Query().Get("a"))
+	idB, errB := parseActorID(r.URL.Query().Get("b"))
+	if errA != nil || errB != nil {
+		h.renderError(w, r, badRequest("a and b must be positive actor ids"))
+		return
+	}
+	if idA == idB {
+		h.renderError(w, r, badRequest("a and b must be different actors"))
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "csv" && format != "json" {
+		h.renderError(w, r, badRequest("format must be csv or json"))
+		return
+	}
+
+	steps, err := h.shortestPath(r.Context(), idA, idB)
+	if err != nil {
+		h.log(r.Context()).Error("failed to get shortest path for export", "a", idA, "b", idB, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+	if len(steps) == 0 {
+		h.renderError(w, r, notFound("no connection found between these actors"))
+		return
+	}
+	rows := exportRows(steps)
+
+	filename := fmt.Sprintf("degrees-%d-%d.%s", idA, idB, format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "json" {
+		writeJSON(w, http.StatusOK, rows)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write(exportHeader)
+	for _, row := range rows {
+		year := ""
+		if row.MovieYear != 0 {
+			year = strconv.Itoa(row.MovieYear)
+		}
+		cw.Write([]string{strconv.Itoa(row.Step), row.ActorName, strconv.Itoa(row.ActorID), row.MovieTitle, year})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		h.log(r.Context()).Error("failed to write path export", "a", idA, "b", idB, "err", err)
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+func serveExport(t *testing.T, steps []graph.PathStep, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := newTestHandler(t)
+	h.db = &fakeStore{shortestPath: func(context.Context, int, int) ([]graph.PathStep, error) {
+		return steps, nil
+	}}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/degrees/export?"+query, nil))
+	return rec
+}
+
+func TestExport_CSV(t *testing.T) {
+	steps := testPath()
+	steps[1].MovieTitle = `Crazy, Stupid, "Love"`
+
+	rec := serveExport(t, steps, "a=1&b=3&format=csv")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="degrees-1-3.csv"` {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), `"Crazy, Stupid, ""Love"""`) {
+		t.Errorf("expected the title quoted and escaped, got\n%s", rec.Body.String())
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	want := [][]string{
+		{"step", "actor_name", "actor_tmdb_id", "movie_title", "movie_year"},
+		{"0", "Actor A", "1", "", ""},
+		{"1", "Actor B", "2", `Crazy, Stupid, "Love"`, "2000"},
+		{"2", "Actor C", "3", "Movie Two", "2010"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("expected %d records, got %v", len(want), records)
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("record %d: expected %q, got %q", i, want[i], records[i])
+		}
+	}
+}
+
+func TestExport_JSON(t *testing.T) {
+	rec := serveExport(t, testPath(), "a=1&b=3&format=json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="degrees-1-3.json"` {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+	var rows []exportRow
+	if err := json.Unmarshal(rec.Body.Bytes(), &rows); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	if len(rows) != 3 || rows[2].ActorName != "Actor C" || rows[2].MovieTitle != "Movie Two" || rows[0].MovieTitle != "" {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+}
+
+func TestExport_Rejections(t *testing.T) {
+	tests := []struct {
+		name       string
+		steps      []graph.PathStep
+		query      string
+		wantStatus int
+	}{
+		{"no path", nil, "a=1&b=3&format=csv", http.StatusNotFound},
+		{"same actor", testPath(), "a=1&b=1&format=csv", http.StatusBadRequest},
+		{"bad id", testPath(), "a=x&b=3&format=csv", http.StatusBadRequest},
+		{"unknown format", testPath(), "a=1&b=3&format=xml", http.StatusBadRequest},
+		{"missing format", testPath(), "a=1&b=3", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveExport(t, tt.steps, tt.query)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if rec.Header().Get("Content-Disposition") != "" {
+				t.Error("expected no download for a failed export")
+			}
+		})
+	}
+}
+
+func TestDegreesFragment_ExportLinks(t *testing.T) {
+	h := newTestHandler(t)
+	var buf strings.Builder
+	result := pathResult{A: 1, B: 3, Steps: testPath(), Degrees: 2}
+	if err := h.execute(&buf, "degrees.html", result); err != nil {
+		t.Fatalf("render degrees failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `href="/degrees/export?a=1&b=3&format=csv"`) {
+		t.Errorf("expected a CSV download link\n%s", buf.String())
+	}
+}
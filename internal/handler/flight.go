@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+// sharedCall runs fn through g for key, so concurrent callers with the same
+// key share one call; results aren't kept once it returns. fn runs detached
+// from ctx, keeping its values and deadline but not its cancellation, so the
+// caller that started it going away doesn't fail the rest; any caller whose
+// ctx ends stops waiting and gets ctx.Err(). A panic in fn becomes every
+// waiter's error. The result is shared, so callers must not modify it.
+func sharedCall[V any](ctx context.Context, g *singleflight.Group, key string, fn func(context.Context) (V, error)) (V, error) {
+	ch := g.DoChan(key, func() (_ any, err error) {
+		ctx, cancel := detach(ctx)
+		defer cancel()
+		defer func() {
+			// DoChan would re-panic on a goroutine nothing recovers.
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic in shared call: %v", p)
+			}
+		}()
+		return fn(ctx)
+	})
+
+	select {
+	case res := <-ch:
+		v, _ := res.Val.(V)
+		return v, res.Err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// detach returns a context with ctx's values and deadline that isn't
+// cancelled along with it.
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached, deadline)
+	}
+	return context.WithCancel(detached)
+}
+
+// shortestPath is ShortestPath bounded by the path timeout, with concurrent
+// requests for the same pair, either way round, sharing one query. A popular
+// pair, say from a shared link, then costs one search however many visitors
+// open it at once. The query always runs from the lower id; the steps are
+// reversed for a caller who asked from the higher.
+func (h *Handler) shortestPath(ctx context.Context, a, b int) ([]graph.PathStep, error) {
+	lo, hi := min(a, b), max(a, b)
+	steps, err := sharedCall(ctx, &h.paths, fmt.Sprintf("%d-%d", lo, hi), func(ctx context.Context) ([]graph.PathStep, error) {
+		ctx, cancel := h.pathContext(ctx)
+		defer cancel()
+		return h.db.ShortestPath(ctx, lo, hi)
+	})
+	if a > b {
+		steps = slices.Clone(steps)
+		slices.Reverse(steps)
+	}
+	return steps, err
+}
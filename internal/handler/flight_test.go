@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+func TestDegrees_ConcurrentIdenticalRequestsShareQuery(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		const n = 10
+		h := newTestHandler(t)
+		var queries atomic.Int32
+		release := make(chan struct{})
+		h.db = &fakeStore{shortestPath: func(ctx context.Context, a, b int) ([]graph.PathStep, error) {
+			queries.Add(1)
+			if a != 1 || b != 3 {
+				t.Errorf("expected the query run from the lower id, got %d to %d", a, b)
+			}
+			<-release
+			return testPath(), nil
+		}}
+
+		// Half the requests ask the other way round and share the query too.
+		var wg sync.WaitGroup
+		bodies := make([]string, n)
+		codes := make([]int, n)
+		for i := range n {
+			path := "/degrees?a=1&b=3"
+			if i%2 == 1 {
+				path = "/degrees?a=3&b=1"
+			}
+			wg.Go(func() {
+				rec := serve(h, path, true)
+				codes[i], bodies[i] = rec.Code, rec.Body.String()
+			})
+		}
+		synctest.Wait()
+		close(release)
+		wg.Wait()
+
+		if got := queries.Load(); got != 1 {
+			t.Errorf("expected one query for %d requests for the pair, got %d", n, got)
+		}
+		for i, code := range codes {
+			if code != http.StatusOK {
+				t.Errorf("request %d: expected 200, got %d", i, code)
+				continue
+			}
+			first, last := strings.Index(bodies[i], "Actor A"), strings.Index(bodies[i], "Actor C")
+			if reversed := i%2 == 1; first < 0 || last < 0 || (first > last) != reversed {
+				t.Errorf("request %d: expected the path in the order asked for\n%s", i, bodies[i])
+			}
+		}
+
+		// Once the shared call is done, the next request queries afresh.
+		serve(h, "/degrees?a=1&b=3", true)
+		if got := queries.Load(); got != 2 {
+			t.Errorf("expected a new query after the first finished, got %d", got)
+		}
+	})
+}
+
+func TestSharedCall_StarterCancelling(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var g singleflight.Group
+		release := make(chan struct{})
+		fn := func(ctx context.Context) (string, error) {
+			<-release
+			return "done", ctx.Err()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		starter := make(chan error, 1)
+		go func() {
+			_, err := sharedCall(ctx, &g, "k", fn)
+			starter <- err
+		}()
+		synctest.Wait()
+
+		joined := make(chan string, 1)
+		go func() {
+			v, err := sharedCall(context.Background(), &g, "k", fn)
+			if err != nil {
+				v = err.Error()
+			}
+			joined <- v
+		}()
+		synctest.Wait()
+
+		cancel()
+		if err := <-starter; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the cancelled starter to stop waiting, got %v", err)
+		}
+		close(release)
+		if v := <-joined; v != "done" {
+			t.Errorf("expected the call to finish for the caller still waiting, got %q", v)
+		}
+	})
+}
+
+func TestSharedCall_Panic(t *testing.T) {
+	var g singleflight.Group
+	_, err := sharedCall(context.Background(), &g, "k", func(context.Context) (int, error) { panic("boom") })
+	if err == nil {
+		t.Fatal("expected a panicking call to return an error")
+	}
+	if v, err := sharedCall(context.Background(), &g, "k", func(context.Context) (int, error) { return 7, nil }); v != 7 || err != nil {
+		t.Errorf("expected the key usable again after a panic, got %d, %v", v, err)
+	}
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+// gameMaxSteps is how long a player's chain may grow before the game stops
+// offering co-stars. It also bounds the chain a URL can ask us to check.
+const gameMaxSteps = 10
+
+// gameCostarLimit caps the co-stars offered at each step; the picker's
+// filter works within these.
+const gameCostarLimit = 200
+
+// gameView is one state of the path-guessing game. The whole state is the
+// chain of ids in the URL, re-checked against the graph on every step, so
+// there is nothing to store between requests and nothing a player can forge.
+type gameView struct {
+	Target  models.Actor
+	Current models.Actor
+	Chain   []graph.PathStep
+	Steps   int // links in the chain so far
+	// ChainParam is the chain as the URL carries it; UndoParam is the same
+	// without its last actor, empty at the start.
+	ChainParam string
+	UndoParam  string
+	Costars    []graph.Costar
+	Won        bool
+	Shortest   int // the optimal number of steps, 0 if it couldn't be found
+	OutOfSteps bool
+}
+
+// gameStartHandler begins a game from a to b.
+func (h *Handler) gameStartHandler(w http.ResponseWriter, r *http.Request) {
+	a, errA := parseActorID(r.URL.Query().Get("a"))
+	b, errB := parseActorID(r.URL.Query().Get("b"))
+	if errA != nil || errB != nil {
+		h.renderError(w, r, badRequest("a and b must be positive actor ids"))
+		return
+	}
+	if a == b {
+		h.renderError(w, r, badRequest("pick two different actors"))
+		return
+	}
+	h.playGame(w, r, []int{a}, b)
+}
+
+// gameStepHandler renders the game after the player's latest pick, the last
+// id in chain.
+func (h *Handler) gameStepHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := parseActorID(r.URL.Query().Get("b"))
+	if err != nil {
+		h.renderError(w, r, badRequest("b must be a positive actor id"))
+		return
+	}
+	chain, err := parseChain(r.URL.Query().Get("chain"))
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+	if chain[0] == b {
+		h.renderError(w, r, badRequest("pick two different actors"))
+		return
+	}
+	if i := slices.Index(chain, b); i >= 0 && i < len(chain)-1 {
+		h.renderError(w, r, badRequest("the chain already reached b"))
+		return
+	}
+	h.playGame(w, r, chain, b)
+}
+
+// parseChain reads a comma-separated list of actor ids, at most
+// gameMaxSteps links long.
+func parseChain(s string) ([]int, error) {
+	if s == "" {
+		return nil, badRequest("chain must list actor ids")
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) > gameMaxSteps+1 {
+		return nil, badRequest("chain is too long")
+	}
+	chain := make([]int, len(parts))
+	for i, p := range parts {
+		id, err := parseActorID(p)
+		if err != nil {
+			return nil, badRequest("chain must list positive actor ids")
+		}
+		chain[i] = id
+	}
+	return chain, nil
+}
+
+func formatChain(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+// playGame checks the chain, then renders it with either the win feedback or
+// the co-stars of its last actor to pick from.
+func (h *Handler) playGame(w http.ResponseWriter, r *http.Request, chain []int, target int) {
+	log := h.log(r.Context())
+
+	steps, err := h.db.Chain(r.Context(), chain)
+	if errors.Is(err, graph.ErrBrokenChain) {
+		h.renderError(w, r, badRequest("those actors never appeared in a movie together"))
+		return
+	}
+	if err != nil {
+		log.Error("failed to check game chain", "chain", chain, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+	profile, err := h.db.GetActor(r.Context(), target)
+	if err != nil {
+		log.Error("failed to get actor", "id", target, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+	if profile == nil {
+		h.renderError(w, r, notFound("actor not found"))
+		return
+	}
+
+	last := chain[len(chain)-1]
+	view := gameView{
+		Target:     profile.Actor,
+		Current:    *steps[len(steps)-1].Actor,
+		Chain:      steps,
+		Steps:      len(chain) - 1,
+		ChainParam: formatChain(chain),
+		UndoParam:  formatChain(chain[:len(chain)-1]),
+	}
+
+	switch {
+	case last == target:
+		view.Won = true
+		best, err := h.shortestPath(r.Context(), chain[0], target)
+		if err != nil {
+			// The player's chain stands on its own; only the comparison is lost.
+			log.Warn("failed to get shortest path for game", "a", chain[0], "b", target, "err", err)
+		} else if len(best) > 0 {
+			view.Shortest = (len(best) - 1) / 2
+		}
+	case view.Steps >= gameMaxSteps:
+		view.OutOfSteps = true
+	default:
+		costars, err := h.db.GetCostars(r.Context(), last, gameCostarLimit)
+		if err != nil {
+			log.Error("failed to get costars", "id", last, "err", err)
+			h.renderError(w, r, err)
+			return
+		}
+		view.Costars = slices.DeleteFunc(costars, func(c graph.Costar) bool {
+			return slices.Contains(chain, c.Actor.TmdbID)
+		})
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		h.renderFragment(w, r, "game.html", view)
+		return
+	}
+	h.renderFragment(w, r, "game_page.html", view)
+}
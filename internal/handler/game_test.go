@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+// gameStore fakes a small graph for the game: 1-2-3 is the shortest way from
+// 1 to 3, and 1-4-5-3 a longer one.
+func gameStore(t *testing.T) *fakeStore {
+	edges := map[[2]int]bool{{1, 2}: true, {2, 3}: true, {1, 4}: true, {4, 5}: true, {5, 3}: true}
+	linked := func(a, b int) bool { return edges[[2]int{a, b}] || edges[[2]int{b, a}] }
+	actor := func(id int) *models.Actor { return &models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)} }
+
+	return &fakeStore{
+		chain: func(_ context.Context, ids []int) ([]graph.PathStep, error) {
+			steps := []graph.PathStep{{Actor: actor(ids[0])}}
+			for i := 1; i < len(ids); i++ {
+				if !linked(ids[i-1], ids[i]) {
+					return nil, graph.ErrBrokenChain
+				}
+				steps = append(steps, graph.PathStep{MovieTitle: fmt.Sprintf("Movie %d-%d", ids[i-1], ids[i])}, graph.PathStep{Actor: actor(ids[i])})
+			}
+			return steps, nil
+		},
+		getActor: func(_ context.Context, id int) (*graph.ActorProfile, error) {
+			return &graph.ActorProfile{Actor: *actor(id)}, nil
+		},
+		costars: func(_ context.Context, id, limit int) ([]graph.Costar, error) {
+			var costars []graph.Costar
+			for other := 1; other <= 5; other++ {
+				if linked(id, other) {
+					costars = append(costars, graph.Costar{Actor: *actor(other), SharedMovies: 1})
+				}
+			}
+			return costars, nil
+		},
+		shortestPath: func(_ context.Context, a, b int) ([]graph.PathStep, error) {
+			if a != 1 || b != 3 {
+				t.Errorf("unexpected ShortestPath(%d, %d)", a, b)
+			}
+			return testPath(), nil // two steps
+		},
+	}
+}
+
+func serveGame(t *testing.T, path string, htmx bool) *httptest.ResponseRecorder {
+	t.Helper()
+	h := newTestHandler(t)
+	h.db = gameStore(t)
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if htmx {
+		req.Header.Set("HX-Request", "true")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGame_Start(t *testing.T) {
+	rec := serveGame(t, "/game/start?a=1&b=3", false)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<title>Actor 1 to Actor 3 · Degrees of Separation</title>",
+		"Who has Actor 1 worked with?",
+		`href="/game/step?b=3&amp;chain=1,2"`,
+		`href="/game/step?b=3&amp;chain=1,4"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("start page missing %q", want)
+		}
+	}
+	if strings.Contains(body, "Undo") {
+		t.Error("expected nothing to undo at the start")
+	}
+
+	for _, path := range []string{"/game/start?a=1&b=1", "/game/start?a=1", "/game/start?a=x&b=3"} {
+		if rec := serveGame(t, path, true); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestGame_Step(t *testing.T) {
+	rec := serveGame(t, "/game/step?b=3&chain=1,4", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "<html") {
+		t.Error("expected a fragment for an HTMX step")
+	}
+	if !strings.Contains(body, "Movie 1-4") || !strings.Contains(body, "chain=1,4,5") {
+		t.Errorf("expected the chain so far and Actor 5 to pick\n%s", body)
+	}
+	if strings.Contains(body, "chain=1,4,1") {
+		t.Error("actors already in the chain should not be offered again")
+	}
+	if !strings.Contains(body, `hx-get="/game/step?b=3&amp;chain=1"`) {
+		t.Errorf("expected an undo link back to the start\n%s", body)
+	}
+}
+
+func TestGame_RejectsForgedSteps(t *testing.T) {
+	for _, chain := range []string{"1,3", "1,2,4", "1,5,3"} {
+		rec := serveGame(t, "/game/step?b=3&chain="+chain, true)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "never appeared in a movie together") {
+			t.Errorf("chain %s: expected 400 for a step without a shared movie, got %d", chain, rec.Code)
+		}
+	}
+
+	tooLong := make([]string, gameMaxSteps+2)
+	for i := range tooLong {
+		tooLong[i] = "2"
+	}
+	for _, query := range []string{
+		"b=3",
+		"b=3&chain=1,,2",
+		"b=3&chain=1,-2",
+		"b=3&chain=" + strings.Join(tooLong, ","),
+		"b=1&chain=1,2",
+		"b=2&chain=1,2,3",
+	} {
+		if rec := serveGame(t, "/game/step?"+query, true); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestGame_Win(t *testing.T) {
+	rec := serveGame(t, "/game/step?b=3&chain=1,2,3", true)
+	body := rec.Body.String()
+	if !strings.Contains(body, "<strong>2</strong> steps") || !strings.Contains(body, "shortest chain there is") {
+		t.Errorf("expected an optimal win in 2\n%s", body)
+	}
+	if strings.Contains(body, "costar-picker") {
+		t.Error("expected no picker once the target is reached")
+	}
+
+	rec = serveGame(t, "/game/step?b=3&chain=1,4,5,3", true)
+	body = rec.Body.String()
+	if !strings.Contains(body, "<strong>3</strong> steps") || !strings.Contains(body, "The shortest chain takes 2.") {
+		t.Errorf("expected a win in 3 against a best of 2\n%s", body)
+	}
+}
+
+func TestParseChain(t *testing.T) {
+	got, err := parseChain("1,22,333")
+	if err != nil || !slices.Equal(got, []int{1, 22, 333}) {
+		t.Errorf("parseChain = %v, %v", got, err)
+	}
+	if s := formatChain(got); s != "1,22,333" {
+		t.Errorf("formatChain = %q", s)
+	}
+}
@@ -2,35 +2,173 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"html/template"
 	iofs "io/fs"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"os"
+	"slices"
 	"strconv"
+	"time"
+	"unicode"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/sync/singleflight"
 	"golang.org/x/time/rate"
 
 	"github.com/mark-c-hall/degrees-of-separation/internal/config"
 	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/metrics"
 	mw "github.com/mark-c-hall/degrees-of-separation/internal/middleware"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/internal/tmdb"
+	"github.com/mark-c-hall/degrees-of-separation/internal/version"
 )
 
-const searchLimit = 15
+// costarLimit is the number of top co-stars shown on an actor profile.
+const costarLimit = 10
+
+// tmdbExtrasTimeout bounds the TMDb calls for an actor profile's photo and
+// biography and a movie's poster, which wait on the client's rate limiter too.
+const tmdbExtrasTimeout = 2 * time.Second
+
+// tmdbExtrasTTL is how long TMDb's extras are reused before being asked for
+// again, and tmdbExtrasCacheSize how many actors or movies are kept.
+const (
+	tmdbExtrasTTL       = 24 * time.Hour
+	tmdbExtrasCacheSize = 10000
+)
+
+// popularActorPool is how many of the best-connected actors /suggest picks
+// from: wide enough to vary between visits, narrow enough that every pick is
+// a recognisable name. popularActorsTTL is how long the pool is reused.
+const (
+	popularActorPool = 100
+	popularActorsTTL = 5 * time.Minute
+)
+
+// networkSampleSize is how many actors an actor's network lists per degree.
+// The rest are only counted.
+const networkSampleSize = 12
+
+// degreeSampleSize is the number of random actor pairs measured for the
+// degrees histogram on /stats. Each pair is a bounded path query, so keep it
+// small. degreeSampleTTL is how long a sample is shown before another is
+// taken; it's an estimate either way, and taking one per load would let
+// /stats run a scan and a score of path queries on every request.
+const (
+	degreeSampleSize = 20
+	degreeSampleTTL  = 10 * time.Minute
+)
+
+// compressMinSize is the smallest response body worth gzipping; below this the
+// gzip header and framing eat most of the saving.
+const compressMinSize = 1024
 
 type pathResult struct {
+	A, B      int // the queried actors, for the export links
 	Steps     []graph.PathStep
 	Degrees   int
 	SameActor bool
+	Graph     *pathGraph // embedded as JSON for client-side diagrams
+	// Movie is the target when the path runs from A to a movie; B is unset.
+	Movie *models.Movie
+	// TooSparse is set when /surprise found no connected pair to show.
+	TooSparse bool
+	// Chronological is set when the path's movies are in release order, and
+	// AskedChronological when only such a path would do (order=chronological).
+	Chronological      bool
+	AskedChronological bool
+}
+
+// newPathResult wraps the shortest path between a and b for degrees.html.
+func newPathResult(a, b int, steps []graph.PathStep) pathResult {
+	result := pathResult{A: a, B: b, Steps: steps, Chronological: graph.IsChronological(steps)}
+	if len(steps) > 1 {
+		result.Degrees = (len(steps) - 1) / 2
+	}
+	if len(steps) > 0 {
+		result.Graph = buildPathGraph(steps)
+	}
+	return result
+}
+
+type degreeBar struct {
+	Label   string
+	Count   int
+	Percent int // bar height relative to the tallest bucket
+}
+
+type statsView struct {
+	*graph.Stats
+	Distribution []degreeBar
+	SampleSize   int
+}
+
+type actorPage struct {
+	Profile *graph.ActorProfile
+	Costars []graph.Costar
+	// Details is nil when TMDb couldn't be asked or didn't answer in time.
+	Details *models.ActorDetails
+}
+
+type networkPage struct {
+	Actor  models.Actor
+	Groups []graph.NetworkGroup
 }
 
 type Handler struct {
-	db      *graph.Driver
-	tmpl    *template.Template
-	logger  *slog.Logger
-	handler http.Handler
+	db graphStore
+	// tmdb fetches movies for /admin/reingest and the extra details on actor
+	// profiles; nil when the server has no TMDb token.
+	tmdb        tmdbSource
+	templates   templateProvider
+	logger      *slog.Logger
+	handler     http.Handler
+	maxQueryLen int
+	// searchLimit is how many actors a search returns; searchMaxLimit caps
+	// what /api/v1/search?limit= may ask for.
+	searchLimit    int
+	searchMaxLimit int
+	// pathTimeout bounds each shortest-path query, in the handler and in
+	// Neo4j.
+	pathTimeout time.Duration
+	// requireNonEmptyGraph makes /readyz fail until the graph has an actor.
+	requireNonEmptyGraph bool
+	availability         *availability
+	// rateLimiter and corsOrigins are kept so Reload can change their
+	// settings in place.
+	rateLimiter *mw.RateLimiter
+	corsOrigins *mw.CORSOrigins
+	daily       dailyCache
+	// stats holds the graph's counts for STATS_CACHE_TTL. The ETag is taken
+	// from them, so clients revalidating within the TTL get a 304 without a
+	// query.
+	stats ttlCache[*graph.Stats]
+	// popular is the pool /suggest picks from, for popularActorsTTL.
+	popular ttlCache[[]graph.RankedActor]
+	// degrees is the sampled degree histogram on /stats, for degreeSampleTTL.
+	degrees ttlCache[map[int]int]
+	// actorExtras holds TMDb's details per actor; nil for an actor TMDb
+	// doesn't know.
+	actorExtras extrasCache[*models.ActorDetails]
+	// moviePosters holds TMDb's poster path per movie; "" for none.
+	moviePosters extrasCache[string]
+	// ranking is the leaderboard, every page of it, refreshed daily.
+	ranking dayCache[[]graph.RankedActor]
+	paths   singleflight.Group
+	// basePath, siteURL, robotsDisallow and sitemapSize shape robots.txt and
+	// the sitemap; see config.ServerConfig.
+	basePath       string
+	siteURL        string
+	robotsDisallow []string
+	sitemapSize    int
+	sitemap        dayCache[[]models.Actor]
 }
 
 func commify(n int) string {
@@ -41,10 +179,74 @@ func commify(n int) string {
 	return s
 }
 
-// NewHandler constructs the HTTP handler stack.
-func NewHandler(db *graph.Driver, fs embed.FS, cfg config.ServerConfig, logger *slog.Logger) (*Handler, error) {
-	funcs := template.FuncMap{"commify": commify}
-	tmpl, err := template.New("").Funcs(funcs).ParseFS(fs, "templates/base.html", "templates/fragments/*.html")
+// timeAgo describes how long before now t was, at the coarsest unit that
+// fits: "just now", "5 minutes ago", "3 hours ago", "12 days ago".
+func timeAgo(t, now time.Time) string {
+	d := now.Sub(t)
+	var n int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < 48*time.Hour:
+		n, unit = int(d/time.Hour), "hour"
+	default:
+		n, unit = int(d/(24*time.Hour)), "day"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s ago", n, unit)
+}
+
+// initial is the avatar letter for an actor without a photo.
+func initial(name string) string {
+	for _, r := range name {
+		return string(unicode.ToUpper(r))
+	}
+	return "?"
+}
+
+// tmdbImages is where templates point TMDb image paths: the CDN base, the
+// size for search thumbnails, and the size for actor photos and posters.
+type tmdbImages struct {
+	base, thumbnail, large string
+}
+
+// parseTemplates loads the page templates and HTMX fragments into one set.
+// images sizes the URLs the tmdbImage (thumbnail) and tmdbPhoto funcs build
+// from TMDb paths. Asset URLs are versioned from fs's own static directory.
+// basePath is the prefix the site is mounted under, which the url and asset
+// funcs put in front of every link.
+func parseTemplates(fs iofs.FS, images tmdbImages, basePath string) (*template.Template, error) {
+	asset, err := assetURLs(fs)
+	if err != nil {
+		return nil, err
+	}
+	build := version.Get().String()
+	funcs := template.FuncMap{
+		"commify":   commify,
+		"asset":     func(name string) string { return basePath + asset(name) },
+		"url":       func(path string) string { return basePath + path },
+		"version":   func() string { return build },
+		"initial":   initial,
+		"ago":       func(t time.Time) string { return timeAgo(t, time.Now()) },
+		"tmdbImage": func(path string) string { return tmdb.ImageURL(images.base, images.thumbnail, path) },
+		"tmdbPhoto": func(path string) string { return tmdb.ImageURL(images.base, images.large, path) },
+	}
+	return template.New("").Funcs(funcs).ParseFS(fs, "templates/*.html", "templates/fragments/*.html")
+}
+
+// NewHandler constructs the HTTP handler stack. /metrics is mounted here only
+// when cfg.MetricsAddr is empty; otherwise the caller serves m.Handler() on its
+// own listener. /debug/ is never mounted here: the caller serves it on
+// cfg.DebugAddr, off the public listener. tm may be nil: /admin/reingest is then
+// refused and actor profiles go without TMDb's photo and biography.
+func NewHandler(db *graph.Driver, tm *tmdb.Client, fs embed.FS, cfg config.ServerConfig, logger *slog.Logger, m *metrics.Metrics) (*Handler, error) {
+	images := tmdbImages{base: cfg.ImageBaseURL, thumbnail: cfg.ThumbnailSize, large: cfg.ImageSize}
+	tmpl, err := parseTemplates(fs, images, cfg.BasePath)
 	if err != nil {
 		return nil, err
 	}
@@ -54,139 +256,694 @@ func NewHandler(db *graph.Driver, fs embed.FS, cfg config.ServerConfig, logger *
 		return nil, fmt.Errorf("failed to create static sub-filesystem: %w", err)
 	}
 
-	h := &Handler{db: db, tmpl: tmpl, logger: logger}
+	static, err := newStaticServer(staticFS)
+	if err != nil {
+		return nil, err
+	}
+
+	var templates templateProvider = embeddedTemplates{tmpl: tmpl}
+	if cfg.DevMode {
+		dev := diskTemplates{fsys: os.DirFS(devWebDir), images: images, basePath: cfg.BasePath}
+		if _, err := dev.Templates(); err != nil {
+			return nil, fmt.Errorf("dev mode: failed to load templates from %s: %w", devWebDir, err)
+		}
+		templates = dev
+		logger.Info("dev mode: templates are re-read from disk on every render", "dir", devWebDir)
+	}
+
+	h := &Handler{
+		db:                   db,
+		templates:            templates,
+		logger:               logger,
+		maxQueryLen:          cfg.MaxQueryLen,
+		searchLimit:          cfg.SearchLimit,
+		searchMaxLimit:       cfg.SearchMaxLimit,
+		pathTimeout:          cfg.PathQueryTimeout,
+		requireNonEmptyGraph: cfg.RequireNonEmptyGraph,
+		availability:         newAvailability(db.VerifyConnectivity, cfg.AvailabilityPoll, logger),
+		basePath:             cfg.BasePath,
+		siteURL:              cfg.SiteURL,
+		robotsDisallow:       cfg.RobotsDisallow,
+		sitemapSize:          cfg.SitemapSize,
+	}
+	if tm != nil {
+		h.tmdb = tm
+	}
+	h.stats.setTTL(cfg.StatsCacheTTL)
+	h.popular.setTTL(popularActorsTTL)
+	h.degrees.setTTL(degreeSampleTTL)
 
 	mux := http.NewServeMux()
-	addRoutes(mux, h, staticFS)
+	addRoutes(mux, h, static, routeAuth(cfg))
 
 	// Build the inner middleware stack around the mux.
-	var inner http.Handler = mux
+	var inner http.Handler = h.unmatched(mux)
+	inner = mw.DiscardHeadBody(inner)
 	inner = mw.Timeout(cfg.RequestTimeout)(inner)
-	inner = mw.RateLimit(rate.Limit(cfg.RateLimitPerSec), cfg.RateBurst, logger)(inner)
-	inner = mw.Recovery(logger)(inner)
-	inner = mw.Logging(logger)(inner)
-	inner = mw.CORS(cfg.CORSOrigin)(inner)
+	ips := mw.NewIPResolver(cfg.TrustedProxies, cfg.TrustUnixPeers)
+	limits := rateLimitConfig(cfg)
+	limits.OnLimit = h.renderRateLimited
+	var rateLimit func(http.Handler) http.Handler
+	rateLimit, h.rateLimiter = mw.RateLimit(limits, mux, ips, logger, m)
+	inner = rateLimit(inner)
+	inner = mw.RequestLimits(cfg.MaxURLLen, cfg.MaxBodyBytes)(inner)
+	inner = mw.Recovery(logger, h.renderPanic)(inner)
+	if cfg.Compress {
+		inner = mw.Compress(compressMinSize)(inner)
+	}
+	inner = mw.Logging(logger, ips, mw.LogSampling{
+		Rate:       cfg.LogSampleRate,
+		Slow:       cfg.SlowRequest,
+		SlowParams: map[string][]string{"/degrees": {"a", "b"}, "/api/v1/path/graph": {"a", "b", "expand"}},
+	})(inner)
+	inner = mw.Metrics(m, mux)(inner)
+	if cfg.TLSEnabled() {
+		inner = mw.HSTS(cfg.HSTSMaxAge)(inner)
+	}
+	var cors func(http.Handler) http.Handler
+	cors, h.corsOrigins = mw.CORS(mw.CORSConfig{
+		Origins:          cfg.CORSOrigins,
+		Methods:          cfg.CORSMethods,
+		Headers:          cfg.CORSHeaders,
+		AllowCredentials: cfg.CORSCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	})
+	inner = cors(inner)
 
 	// otelhttp wraps the entire middleware stack so its span is already in the
 	// request context when Logging runs. This is what makes trace_id available
 	// in log lines — Logging reads the span from r.Context() after next returns.
-	// r.Pattern is not set here (mux hasn't matched yet), but all our routes are
-	// static paths with no variables so URL.Path is equivalent.
-	h.handler = otelhttp.NewHandler(inner, "degrees-of-separation",
+	// r.Pattern is not set here (mux hasn't matched yet), so the span is named
+	// after the pattern the mux would pick, keeping /actor/{id} to one name.
+	traced := otelhttp.NewHandler(inner, "degrees-of-separation",
 		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if route := mw.Route(mux, r); route != "" {
+				return r.Method + " " + route
+			}
 			return r.Method + " " + r.URL.Path
 		}),
 	)
+
+	// /metrics sits outside the stack so scrapes are neither rate limited nor
+	// logged and traced on every request.
+	root := http.NewServeMux()
+	if cfg.MetricsAddr == "" {
+		root.Handle("GET /metrics", m.Handler())
+	}
+	if cfg.BasePath == "" {
+		root.Handle("/", traced)
+	} else {
+		// Routes are registered at the root and the prefix is stripped on the
+		// way in, so everything inside, logs included, sees unprefixed paths.
+		root.Handle(cfg.BasePath+"/", http.StripPrefix(cfg.BasePath, traced))
+		root.Handle(cfg.BasePath, redirectToSlash(cfg.BasePath))
+	}
+	h.handler = root
 	return h, nil
 }
 
+// redirectToSlash sends the bare base path to the site's root page, keeping
+// any query string.
+func redirectToSlash(basePath string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := basePath + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
+
+// rateLimitConfig turns the configured route policies into the middleware's
+// form. Health checks, metrics, static assets and the files crawlers fetch
+// are never limited: probes and page loads shouldn't spend a visitor's budget.
+func rateLimitConfig(cfg config.ServerConfig) mw.RateLimitConfig {
+	routes := make(map[string]mw.RatePolicy, len(cfg.RateRoutes))
+	for route, p := range cfg.RateRoutes {
+		routes[route] = mw.RatePolicy{Limit: rate.Limit(p.PerSec), Burst: p.Burst, Cost: p.Cost}
+	}
+	return mw.RateLimitConfig{
+		Default:    mw.RatePolicy{Limit: rate.Limit(cfg.RateLimitPerSec), Burst: cfg.RateBurst, Cost: 1},
+		Routes:     routes,
+		Exempt:     []string{"/healthz", "/readyz", "/metrics", "/static/", "/favicon.ico", "/robots.txt", "/sitemap.xml"},
+		MaxClients: cfg.RateMaxClients,
+	}
+}
+
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.handler.ServeHTTP(w, r)
 }
 
-func addRoutes(mux *http.ServeMux, h *Handler, staticFS iofs.FS) {
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
-	mux.HandleFunc("/", h.indexHandler)
-	mux.HandleFunc("/search", h.searchHandler)
-	mux.HandleFunc("/degrees", h.degreesHandler)
-	mux.HandleFunc("/stats", h.statsHandler)
-	mux.HandleFunc("/healthz", h.healthHandler)
-	mux.HandleFunc("/readyz", h.readyHandler)
+// Close stops the handler's background work. Call it after the server has
+// shut down; the handler must not serve requests afterwards.
+func (h *Handler) Close() {
+	h.rateLimiter.Stop()
 }
 
-func (h *Handler) indexHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
+// Reload applies the settings that can change while the handler serves:
+// the rate limits, the stats cache TTL and the CORS origins. The rest of cfg
+// is ignored; config.Watcher decides what reaches here.
+func (h *Handler) Reload(cfg config.ServerConfig) {
+	h.rateLimiter.SetLimits(rateLimitConfig(cfg))
+	h.stats.setTTL(cfg.StatsCacheTTL)
+	h.corsOrigins.Set(cfg.CORSOrigins)
+}
+
+// authGroups holds the auth middleware for each protected route group.
+type authGroups struct {
+	api   func(http.Handler) http.Handler
+	admin func(http.Handler) http.Handler
+}
+
+// routeAuth builds the route groups' auth from cfg. The API stays open unless
+// API tokens are configured; admin routes always need a token.
+func routeAuth(cfg config.ServerConfig) authGroups {
+	groups := authGroups{
+		api:   func(next http.Handler) http.Handler { return next },
+		admin: mw.Auth(authTokens(cfg.AdminTokens)),
 	}
-	h.renderFragment(w, "base.html", nil)
+	if len(cfg.APITokens) > 0 {
+		groups.api = mw.Auth(authTokens(cfg.APITokens))
+	}
+	return groups
+}
+
+// authTokens converts configured tokens to the middleware's form, naming
+// unnamed ones by fingerprint.
+func authTokens(tokens []config.Token) []mw.Token {
+	out := make([]mw.Token, len(tokens))
+	for i, t := range tokens {
+		name := t.Name
+		if name == "" {
+			name = mw.TokenName(t.Secret)
+		}
+		out[i] = mw.Token{Name: name, Secret: t.Secret}
+	}
+	return out
+}
+
+func addRoutes(mux *http.ServeMux, h *Handler, static http.Handler, auth authGroups) {
+	// GET patterns also match HEAD. Any other method on a known path gets a
+	// 405 with an Allow header; unknown paths get a 404. Handler.unmatched
+	// renders both as styled error pages.
+	//
+	// Public HTML routes are open; /api/v1 and /admin routes are wrapped in
+	// their group's auth. The /admin/ catch-all keeps unknown admin paths
+	// behind auth too, so without a token nothing there is even a 404.
+	mux.Handle("GET /static/", http.StripPrefix("/static/", static))
+	mux.Handle("GET /favicon.ico", static)
+	mux.HandleFunc("GET /robots.txt", h.robotsHandler)
+	mux.HandleFunc("GET /sitemap.xml", h.requireDB(h.sitemapHandler))
+	mux.HandleFunc("GET /{$}", h.indexHandler)
+	mux.HandleFunc("GET /search", h.requireDB(h.searchHandler))
+	mux.HandleFunc("GET /search/movies", h.requireDB(h.searchMoviesHandler))
+	mux.HandleFunc("GET /degrees", h.requireDB(h.degreesHandler))
+	mux.HandleFunc("GET /degrees/export", h.requireDB(h.exportHandler))
+	mux.HandleFunc("GET /stats", h.requireDB(h.statsHandler))
+	mux.HandleFunc("GET /stats/leaderboard", h.requireDB(h.leaderboardHandler))
+	mux.HandleFunc("GET /suggest", h.requireDB(h.suggestHandler))
+	mux.HandleFunc("GET /surprise", h.requireDB(h.surpriseHandler))
+	mux.HandleFunc("GET /daily", h.requireDB(h.dailyHandler))
+	mux.HandleFunc("GET /game/start", h.requireDB(h.gameStartHandler))
+	mux.HandleFunc("GET /game/step", h.requireDB(h.gameStepHandler))
+	mux.HandleFunc("GET /daily/reveal", h.requireDB(h.dailyRevealHandler))
+	mux.HandleFunc("GET /actor/{id}", h.requireDB(h.actorHandler))
+	mux.HandleFunc("GET /actor/{id}/network", h.requireDB(h.networkHandler))
+	mux.HandleFunc("GET /healthz", h.healthHandler)
+	mux.HandleFunc("GET /readyz", h.readyHandler)
+	mux.Handle("GET /api/v1/search", auth.api(h.requireDB(h.apiSearchHandler)))
+	mux.Handle("GET /api/v1/path/graph", auth.api(h.requireDB(h.pathGraphHandler)))
+	mux.Handle("GET /api/v1/connected", auth.api(h.requireDB(h.connectedHandler)))
+	mux.Handle("POST /api/v1/paths", auth.api(h.requireDB(h.batchPathsHandler)))
+	mux.Handle("GET /api/v1/neighbors", auth.api(h.requireDB(h.neighborsHandler)))
+	mux.Handle("POST /admin/reingest", auth.admin(h.requireDB(h.reingestHandler)))
+	mux.Handle("/admin/", auth.admin(http.HandlerFunc(h.pageNotFound)))
+}
+
+func (h *Handler) pageNotFound(w http.ResponseWriter, r *http.Request) {
+	h.renderError(w, r, notFound("page not found"))
+}
+
+func (h *Handler) indexHandler(w http.ResponseWriter, r *http.Request) {
+	h.renderFragment(w, r, "base.html", nil)
 }
 
 func (h *Handler) searchHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
+	query, err := cleanQuery(r.URL.Query().Get("q"), h.maxQueryLen)
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
 	if query == "" {
-		h.renderFragment(w, "search.html", nil)
+		h.renderFragment(w, r, "search.html", nil)
 		return
 	}
 
-	actors, err := h.db.SearchActors(r.Context(), query, searchLimit)
+	results, err := h.db.SearchActors(r.Context(), query, h.searchLimit)
 	if err != nil {
-		h.logger.Error("failed to search actors", "query", query, "err", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.log(r.Context()).Error("failed to search actors", "query", query, "err", err)
+		h.renderError(w, r, err)
 		return
 	}
 
-	h.renderFragment(w, "search.html", actors)
+	h.renderFragment(w, r, "search.html", results)
 }
 
 func (h *Handler) degreesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("a") != "" && r.URL.Query().Get("movie") != "" {
+		h.degreesToMovieHandler(w, r)
+		return
+	}
 	if r.URL.Query().Get("a") == "" || r.URL.Query().Get("b") == "" {
-		h.renderFragment(w, "degrees.html", nil)
+		h.renderFragment(w, r, "degrees.html", nil)
 		return
 	}
 
-	idA, err := strconv.Atoi(r.URL.Query().Get("a"))
+	idA, err := parseActorID(r.URL.Query().Get("a"))
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+	idB, err := parseActorID(r.URL.Query().Get("b"))
 	if err != nil {
-		h.logger.Error("invalid actor id", "a", r.URL.Query().Get("a"), "err", err)
-		http.Error(w, "invalid actor id", http.StatusBadRequest)
+		h.renderError(w, r, err)
 		return
 	}
-	idB, err := strconv.Atoi(r.URL.Query().Get("b"))
+
+	chronological, err := parsePathOrder(r.URL.Query().Get("order"))
 	if err != nil {
-		h.logger.Error("invalid actor id", "b", r.URL.Query().Get("b"), "err", err)
-		http.Error(w, "invalid actor id", http.StatusBadRequest)
+		h.renderError(w, r, err)
 		return
 	}
 
 	if idA == idB {
-		h.renderFragment(w, "degrees.html", pathResult{Degrees: 0, SameActor: true})
+		if err := h.checkActors(r.Context(), idA); err != nil {
+			h.renderError(w, r, err)
+			return
+		}
+		h.renderFragment(w, r, "degrees.html", pathResult{Degrees: 0, SameActor: true})
+		return
+	}
+
+	var pathStep []graph.PathStep
+	if chronological {
+		pathStep, err = h.chronologicalPath(r.Context(), idA, idB)
+	} else {
+		pathStep, err = h.shortestPath(r.Context(), idA, idB)
+	}
+	if err != nil {
+		if errors.Is(err, graph.ErrYearWindowUnsupported) {
+			h.renderError(w, r, &requestError{status: http.StatusNotImplemented, msg: "chronological paths aren't available on this graph"})
+			return
+		}
+		if isTimeout(err) {
+			h.log(r.Context()).Warn("shortest path timed out", "a", idA, "b", idB, "timeout", h.pathTimeout)
+			h.renderPathTimeout(w, r)
+			return
+		}
+		h.log(r.Context()).Error("failed to get shortest path", "a", idA, "b", idB, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	// No path also comes back for an id that isn't in the graph, which
+	// shouldn't read as the actors being disconnected. Paths are found far
+	// more often than not, so the ids are only checked after a miss.
+	if len(pathStep) == 0 {
+		if err := h.checkActors(r.Context(), idA, idB); err != nil {
+			h.renderError(w, r, err)
+			return
+		}
+	}
+
+	result := newPathResult(idA, idB, pathStep)
+	result.AskedChronological = chronological
+	h.renderFragment(w, r, "degrees.html", result)
+}
+
+// parsePathOrder reads /degrees?order=, which is empty for the shortest path
+// or "chronological" for the shortest one whose movies are in release order.
+func parsePathOrder(s string) (chronological bool, err error) {
+	switch s {
+	case "":
+		return false, nil
+	case "chronological":
+		return true, nil
+	default:
+		return false, badRequest(fmt.Sprintf("unknown order %q; the only order is chronological", s))
+	}
+}
+
+// chronologicalPath is ChronologicalPath bounded by the path timeout. It is
+// asked for far less often than the shortest path, so unlike shortestPath
+// concurrent requests don't share a query.
+func (h *Handler) chronologicalPath(ctx context.Context, a, b int) ([]graph.PathStep, error) {
+	ctx, cancel := h.pathContext(ctx)
+	defer cancel()
+	return h.db.ChronologicalPath(ctx, a, b)
+}
+
+// checkActors returns a not-found error for the first of ids, given as actor
+// A then B, that isn't in the graph, or nil when they all are.
+func (h *Handler) checkActors(ctx context.Context, ids ...int) error {
+	for i, id := range ids {
+		actor, err := h.db.GetActorByID(ctx, id)
+		if err != nil {
+			h.log(ctx).Error("failed to get actor", "id", id, "err", err)
+			return err
+		}
+		if actor == nil {
+			return notFound(fmt.Sprintf("Actor %c not found: no actor has id %d", 'A'+i, id))
+		}
+	}
+	return nil
+}
+
+// pathContext bounds a single path query by PATH_QUERY_TIMEOUT, when set.
+func (h *Handler) pathContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if h.pathTimeout > 0 {
+		return context.WithTimeout(ctx, h.pathTimeout)
+	}
+	return ctx, func() {}
+}
+
+// surpriseAttempts is how many random pairs /surprise tries before giving up.
+// Both actors of a pair have co-stars but may sit in different components,
+// and a pair too far apart to finish within the path timeout is skipped too.
+const surpriseAttempts = 5
+
+// surpriseHandler picks two random connected actors and renders the path
+// between them, as a one-click demo. A graph where no attempt finds a path
+// gets a friendly message rather than "no connection".
+func (h *Handler) surpriseHandler(w http.ResponseWriter, r *http.Request) {
+	for range surpriseAttempts {
+		a, b, err := h.db.GetRandomConnectedPair(r.Context())
+		if errors.Is(err, graph.ErrNotEnoughActors) {
+			break
+		}
+		if err != nil {
+			h.log(r.Context()).Error("failed to pick random pair", "err", err)
+			h.renderError(w, r, err)
+			return
+		}
+
+		ctx, cancel := h.pathContext(r.Context())
+		steps, err := h.db.ShortestPath(ctx, a, b)
+		cancel()
+		if isTimeout(err) && r.Context().Err() == nil {
+			h.log(r.Context()).Debug("surprise pair timed out, trying another", "a", a, "b", b)
+			continue
+		}
+		if err != nil {
+			h.log(r.Context()).Error("failed to get shortest path", "a", a, "b", b, "err", err)
+			h.renderError(w, r, err)
+			return
+		}
+		if len(steps) > 0 {
+			h.renderFragment(w, r, "degrees.html", newPathResult(a, b, steps))
+			return
+		}
+	}
+	h.renderFragment(w, r, "degrees.html", pathResult{TooSparse: true})
+}
+
+// actorHandler renders an actor profile: the bare fragment for HTMX requests,
+// otherwise the full page.
+func (h *Handler) actorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseActorID(r.PathValue("id"))
+	if err != nil {
+		h.renderError(w, r, notFound("actor not found"))
+		return
+	}
+
+	profile, err := h.db.GetActor(r.Context(), id)
+	if err != nil {
+		h.log(r.Context()).Error("failed to get actor", "id", id, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+	if profile == nil {
+		h.renderError(w, r, notFound("actor not found"))
 		return
 	}
 
-	pathStep, err := h.db.ShortestPath(r.Context(), idA, idB)
+	costars, err := h.db.GetCostars(r.Context(), id, costarLimit)
 	if err != nil {
-		h.logger.Error("failed to get shortest path", "a", idA, "b", idB, "err", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.log(r.Context()).Error("failed to get costars", "id", id, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	page := actorPage{Profile: profile, Costars: costars, Details: h.actorDetails(r.Context(), profile)}
+	if r.Header.Get("HX-Request") == "true" {
+		h.renderFragment(w, r, "actor.html", page)
 		return
 	}
+	h.renderFragment(w, r, "actor_page.html", page)
+}
 
-	deg := 0
-	if len(pathStep) > 1 {
-		deg = (len(pathStep) - 1) / 2
+// actorDetails returns the actor's photo and biography from TMDb for their
+// profile, cached per actor for tmdbExtrasTTL. They are extras, so without a
+// TMDb client, on an error, or past tmdbExtrasTimeout the profile is shown
+// without them, save for the photo stored on the actor at enrichment.
+func (h *Handler) actorDetails(ctx context.Context, profile *graph.ActorProfile) *models.ActorDetails {
+	id := profile.Actor.TmdbID
+	details, ok := h.actorExtras.get(id, time.Now())
+	if !ok && h.tmdb != nil {
+		var err error
+		details, err = h.fetchActorDetails(ctx, id)
+		if err == nil {
+			h.actorExtras.put(id, details, time.Now())
+		}
 	}
-	h.renderFragment(w, "degrees.html", pathResult{Steps: pathStep, Degrees: deg})
+	if profile.ProfilePath == "" || details != nil && details.ProfilePath != "" {
+		return details
+	}
+	withPhoto := models.ActorDetails{ProfilePath: profile.ProfilePath}
+	if details != nil {
+		withPhoto.Biography = details.Biography
+	}
+	return &withPhoto
+}
+
+// fetchActorDetails asks TMDb for the actor's details, nil when TMDb doesn't
+// know them. An error is logged and returned so it isn't cached.
+func (h *Handler) fetchActorDetails(ctx context.Context, id int) (*models.ActorDetails, error) {
+	ctx, cancel := context.WithTimeout(ctx, tmdbExtrasTimeout)
+	defer cancel()
+	details, err := h.tmdb.GetActorDetails(ctx, id)
+	if errors.Is(err, tmdb.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		h.log(ctx).Warn("failed to get actor details, showing the profile without them", "id", id, "err", err)
+		return nil, err
+	}
+	return &details, nil
+}
+
+var errNetworkTimeout = &requestError{status: http.StatusGatewayTimeout, msg: "counting this actor's network took too long"}
+
+// networkHandler shows who an actor is within two degrees of: how many
+// actors are at each distance, with the best connected of them by name. A hub
+// walks a lot of the graph, so the query gets the path timeout.
+func (h *Handler) networkHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseActorID(r.PathValue("id"))
+	if err != nil {
+		h.renderError(w, r, notFound("actor not found"))
+		return
+	}
+
+	profile, err := h.db.GetActor(r.Context(), id)
+	if err != nil {
+		h.log(r.Context()).Error("failed to get actor", "id", id, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+	if profile == nil {
+		h.renderError(w, r, notFound("actor not found"))
+		return
+	}
+
+	ctx, cancel := h.pathContext(r.Context())
+	defer cancel()
+	groups, err := h.db.ActorsWithinDegrees(ctx, id, graph.MaxNetworkDegrees, networkSampleSize)
+	if isTimeout(err) {
+		h.log(r.Context()).Warn("actor network timed out", "id", id, "timeout", h.pathTimeout)
+		h.renderError(w, r, errNetworkTimeout)
+		return
+	}
+	if err != nil {
+		h.log(r.Context()).Error("failed to get actor network", "id", id, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	page := networkPage{Actor: profile.Actor, Groups: groups}
+	if r.Header.Get("HX-Request") == "true" {
+		h.renderFragment(w, r, "network.html", page)
+		return
+	}
+	h.renderFragment(w, r, "network_page.html", page)
+}
+
+// suggestHandler offers a random well-connected actor for Actor A, so a
+// first visit has somewhere to start. Ranking the actors scans the graph, so
+// the pool is cached and each load picks from it in memory. An empty graph
+// gets an empty fragment.
+func (h *Handler) suggestHandler(w http.ResponseWriter, r *http.Request) {
+	pool, err := h.popular.get(r.Context(), time.Now(), func(ctx context.Context) ([]graph.RankedActor, error) {
+		return h.db.TopActors(ctx, 0, popularActorPool)
+	})
+	if err != nil {
+		h.log(r.Context()).Error("failed to get popular actors", "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	var view *models.Actor
+	if len(pool) > 0 {
+		view = &pool[rand.IntN(len(pool))].Actor
+	}
+	// Every load should get a fresh pick.
+	w.Header().Set("Cache-Control", "no-store")
+	h.renderFragment(w, r, "suggest.html", view)
 }
 
 func (h *Handler) statsHandler(w http.ResponseWriter, r *http.Request) {
-	stats, err := h.db.GetStats(r.Context())
+	stats, err := h.stats.get(r.Context(), time.Now(), h.db.GetStats)
 	if err != nil {
-		h.logger.Error("failed to get stats", "err", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.log(r.Context()).Error("failed to get stats", "err", err)
+		h.renderError(w, r, err)
 		return
 	}
 
-	h.renderFragment(w, "stats.html", stats)
+	// The counts are cheap to read; when they match what the client already
+	// has, skip the sampling queries and the render entirely.
+	w.Header().Set("Cache-Control", "no-cache")
+	if notModified(w, r, statsETag(stats)) {
+		return
+	}
+
+	dist, err := h.degrees.get(r.Context(), time.Now(), h.sampleDegrees)
+	if err != nil {
+		h.log(r.Context()).Error("failed to sample degree distribution", "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	h.renderFragment(w, r, "stats.html", statsView{
+		Stats:        stats,
+		Distribution: degreeBars(dist),
+		SampleSize:   degreeSampleSize,
+	})
+}
+
+// sampleDegrees measures the degree histogram's random pairs within the path
+// timeout, so a slow graph costs /stats no more than one path query would.
+func (h *Handler) sampleDegrees(ctx context.Context) (map[int]int, error) {
+	ctx, cancel := h.pathContext(ctx)
+	defer cancel()
+	return h.db.SampleDegreeDistribution(ctx, degreeSampleSize)
+}
+
+// degreeBars orders the sampled distribution by hop count, with unconnected
+// pairs last, and scales each bucket against the largest one.
+func degreeBars(dist map[int]int) []degreeBar {
+	degrees := make([]int, 0, len(dist))
+	maxCount := 0
+	for deg, count := range dist {
+		if deg != graph.Unconnected {
+			degrees = append(degrees, deg)
+		}
+		maxCount = max(maxCount, count)
+	}
+	slices.Sort(degrees)
+	if _, ok := dist[graph.Unconnected]; ok {
+		degrees = append(degrees, graph.Unconnected)
+	}
+
+	bars := make([]degreeBar, 0, len(degrees))
+	for _, deg := range degrees {
+		label := strconv.Itoa(deg)
+		if deg == graph.Unconnected {
+			label = "∞"
+		}
+		bars = append(bars, degreeBar{
+			Label:   label,
+			Count:   dist[deg],
+			Percent: dist[deg] * 100 / maxCount,
+		})
+	}
+	return bars
 }
 
-func (h *Handler) renderFragment(w http.ResponseWriter, name string, data any) {
+// log returns the request-scoped logger for ctx, or the handler's own logger
+// for a context that didn't come through the logging middleware.
+func (h *Handler) log(ctx context.Context) *slog.Logger {
+	return mw.LoggerFrom(ctx, h.logger)
+}
+
+func (h *Handler) renderFragment(w http.ResponseWriter, r *http.Request, name string, data any) {
 	var buf bytes.Buffer
-	if err := h.tmpl.ExecuteTemplate(&buf, name, data); err != nil {
-		h.logger.Error("failed to render fragment", "template", name, "err", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+	if err := h.execute(&buf, name, data); err != nil {
+		h.log(r.Context()).Error("failed to render fragment", "template", name, "err", err)
+		h.renderError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
 	buf.WriteTo(w)
 }
 
+type healthResponse struct {
+	Status string `json:"status"`
+	version.Info
+}
+
+// healthHandler reports liveness along with the running build, so it's easy
+// to tell what an environment is serving. /readyz stays a bare status for
+// load balancers.
 func (h *Handler) healthHandler(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok", Info: version.Get()})
 }
 
+type notReadyResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// readyHandler fails while Neo4j is unreachable or its indexes aren't online
+// and, when the non-empty gate is on, until ingest has populated the graph: a
+// server with no actors would answer every search with nothing. A 503 carries
+// the reason as JSON; success stays a bare 200 for load balancers.
 func (h *Handler) readyHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.db.VerifyConnectivity(r.Context()); err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
+	if reason := h.notReadyReason(r.Context()); reason != "" {
+		writeJSON(w, http.StatusServiceUnavailable, notReadyResponse{Status: "unavailable", Reason: reason})
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
+
+// notReadyReason returns why the server can't take traffic, or "" if it can.
+// Connection errors are summarised rather than echoed to unauthenticated
+// probes.
+func (h *Handler) notReadyReason(ctx context.Context) string {
+	if err := h.db.Ready(ctx); err != nil {
+		if errors.Is(err, graph.ErrSchemaNotReady) {
+			return err.Error()
+		}
+		h.log(ctx).Warn("readiness check failed", "err", err)
+		return "neo4j unreachable"
+	}
+	if h.requireNonEmptyGraph {
+		hasActors, err := h.db.HasActors(ctx)
+		if err != nil {
+			return "could not check graph contents"
+		}
+		if !hasActors {
+			return "graph is empty"
+		}
+	}
+	return ""
+}
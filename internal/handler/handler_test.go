@@ -0,0 +1,916 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v6/neo4j"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/internal/version"
+	"github.com/mark-c-hall/degrees-of-separation/web"
+)
+
+// testServerConfig is the configuration newTestHandler uses: limits loose
+// enough that tests never trip them by accident.
+func testServerConfig() config.ServerConfig {
+	return config.ServerConfig{
+		RequestTimeout:  5 * time.Second,
+		CORSOrigins:     []string{"*"},
+		RateLimitPerSec: 1000,
+		RateBurst:       1000,
+		MaxQueryLen:     100,
+		SearchLimit:     15,
+		SearchMaxLimit:  50,
+		MaxURLLen:       2048,
+	}
+}
+
+// newTestHandler builds the full handler stack without a database. Only
+// routes that never reach the driver can be exercised through it.
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	return newConfiguredHandler(t, testServerConfig())
+}
+
+// newConfiguredHandler is newTestHandler with cfg in place of the defaults.
+func newConfiguredHandler(t *testing.T, cfg config.ServerConfig) *Handler {
+	t.Helper()
+	h, err := NewHandler(nil, nil, web.FS, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+func TestRoutes_MethodAndPath(t *testing.T) {
+	h := newTestHandler(t)
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{http.MethodGet, "/", http.StatusOK},
+		{http.MethodHead, "/", http.StatusOK},
+		{http.MethodGet, "/healthz", http.StatusOK},
+		{http.MethodGet, "/static/style.css", http.StatusOK},
+		{http.MethodPost, "/stats", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/search", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/degrees", http.StatusMethodNotAllowed},
+		{http.MethodTrace, "/", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/healthz", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/nope", http.StatusNotFound},
+		{http.MethodGet, "/search/extra", http.StatusNotFound},
+		{http.MethodPost, "/nope", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.wantStatus, rec.Code)
+		}
+		if tt.wantStatus == http.StatusMethodNotAllowed {
+			if allow := rec.Header().Get("Allow"); !strings.Contains(allow, http.MethodGet) {
+				t.Errorf("%s %s: expected Allow header listing GET, got %q", tt.method, tt.path, allow)
+			}
+		}
+	}
+}
+
+func TestActorPage_Render(t *testing.T) {
+	tmpl := template.Must(parseTemplates(web.FS, tmdbImages{}, ""))
+
+	page := actorPage{
+		Profile: &graph.ActorProfile{
+			Actor:       models.Actor{TmdbID: 287, Name: "Brad Pitt"},
+			Connections: 2,
+			Movies:      []models.Movie{{TmdbID: 550, Title: "Fight Club", Year: 1999}},
+		},
+		Costars: []graph.Costar{{Actor: models.Actor{TmdbID: 819, Name: "Edward Norton"}, SharedMovies: 1}},
+	}
+
+	var full strings.Builder
+	if err := tmpl.ExecuteTemplate(&full, "actor_page.html", page); err != nil {
+		t.Fatalf("render full page failed: %v", err)
+	}
+	for _, want := range []string{
+		"<title>Brad Pitt · Degrees of Separation</title>",
+		`<a href="/actor/819">Edward Norton</a>`,
+		`id="profile-a-id" name="a" value="287"`,
+		"Fight Club",
+		"function selectActor",
+	} {
+		if !strings.Contains(full.String(), want) {
+			t.Errorf("full page missing %q", want)
+		}
+	}
+
+	var frag strings.Builder
+	if err := tmpl.ExecuteTemplate(&frag, "actor.html", page); err != nil {
+		t.Fatalf("render fragment failed: %v", err)
+	}
+	if strings.Contains(frag.String(), "<html") {
+		t.Error("fragment should not include the page layout")
+	}
+}
+
+func TestActorPage_TMDbDetails(t *testing.T) {
+	store := &fakeStore{
+		getActor: func(_ context.Context, id int) (*graph.ActorProfile, error) {
+			return &graph.ActorProfile{Actor: models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)}}, nil
+		},
+		costars: func(context.Context, int, int) ([]graph.Costar, error) { return nil, nil },
+	}
+	cfg := testServerConfig()
+	cfg.ImageBaseURL, cfg.ThumbnailSize, cfg.ImageSize = "https://image.tmdb.org/t/p", "w92", "w185"
+
+	tests := []struct {
+		name     string
+		tmdb     tmdbSource
+		id       int
+		wantBio  bool
+		wantCode int
+	}{
+		{"with details", &fakeTMDb{}, 287, true, http.StatusOK},
+		{"no client", nil, 287, false, http.StatusOK},
+		{"unknown to tmdb", &fakeTMDb{}, 819, false, http.StatusOK},
+		{"tmdb down", &fakeTMDb{detailsErr: errors.New("connection refused")}, 287, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newConfiguredHandler(t, cfg)
+			h.db = store
+			h.tmdb = tt.tmdb
+
+			rec := serve(h, fmt.Sprintf("/actor/%d", tt.id), true)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d", tt.wantCode, rec.Code)
+			}
+			body := rec.Body.String()
+			hasBio := strings.Contains(body, "William Bradley Pitt is an American actor.") &&
+				strings.Contains(body, `src="https://image.tmdb.org/t/p/w185/pitt.jpg"`)
+			if hasBio != tt.wantBio {
+				t.Errorf("expected details shown %v, got body:\n%s", tt.wantBio, body)
+			}
+			if hasPlaceholder := strings.Contains(body, "profile-photo-initial"); hasPlaceholder == tt.wantBio {
+				t.Errorf("expected a placeholder photo %v, got %v", !tt.wantBio, hasPlaceholder)
+			}
+			if !strings.Contains(body, fmt.Sprintf("Actor %d", tt.id)) {
+				t.Error("expected the profile rendered either way")
+			}
+		})
+	}
+}
+
+func TestActorPage_TMDbDetailsCached(t *testing.T) {
+	profiles := map[int]string{287: "", 819: "", 1283: "/bonham.jpg"}
+	store := &fakeStore{
+		getActor: func(_ context.Context, id int) (*graph.ActorProfile, error) {
+			return &graph.ActorProfile{Actor: models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)}, ProfilePath: profiles[id]}, nil
+		},
+		costars: func(context.Context, int, int) ([]graph.Costar, error) { return nil, nil },
+	}
+	cfg := testServerConfig()
+	cfg.ImageBaseURL, cfg.ThumbnailSize, cfg.ImageSize = "https://image.tmdb.org/t/p", "w92", "w185"
+	h := newConfiguredHandler(t, cfg)
+	h.db = store
+	tm := &fakeTMDb{}
+	h.tmdb = tm
+
+	// A known actor and one TMDb doesn't know are each asked about once.
+	for range 3 {
+		serve(h, "/actor/287", true)
+		serve(h, "/actor/819", true)
+	}
+	if tm.detailsCalls != 2 {
+		t.Errorf("expected one TMDb call per actor, got %d", tm.detailsCalls)
+	}
+
+	// A failure isn't cached, and an enriched actor keeps the stored photo.
+	tm.detailsErr = errors.New("connection refused")
+	body := serve(h, "/actor/1283", true).Body.String()
+	if !strings.Contains(body, `src="https://image.tmdb.org/t/p/w185/bonham.jpg"`) {
+		t.Errorf("expected the stored photo when TMDb is down, got body:\n%s", body)
+	}
+	serve(h, "/actor/1283", true)
+	if tm.detailsCalls != 4 {
+		t.Errorf("expected a failed fetch asked again, got %d calls", tm.detailsCalls)
+	}
+}
+
+func TestActorPage_LookupFailed(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{getActor: func(context.Context, int) (*graph.ActorProfile, error) {
+		return nil, errors.New("connection reset")
+	}}
+
+	// A failed lookup is the server's fault, not a missing actor.
+	for _, path := range []string{"/actor/287", "/actor/287/network"} {
+		if rec := serve(h, path, true); rec.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected 500, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestActorNetwork(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{
+		getActor: func(_ context.Context, id int) (*graph.ActorProfile, error) {
+			if id != 287 {
+				return nil, nil
+			}
+			return &graph.ActorProfile{Actor: models.Actor{TmdbID: 287, Name: "Brad Pitt"}}, nil
+		},
+		network: func(_ context.Context, id, maxDegrees, limit int) ([]graph.NetworkGroup, error) {
+			if maxDegrees != graph.MaxNetworkDegrees || limit != networkSampleSize {
+				t.Errorf("unexpected ActorsWithinDegrees(%d, %d, %d)", id, maxDegrees, limit)
+			}
+			return []graph.NetworkGroup{
+				{Degree: 1, Count: 112, Actors: []models.Actor{{TmdbID: 819, Name: "Edward Norton"}}},
+				{Degree: 2, Count: 50000, Capped: true, Actors: []models.Actor{{TmdbID: 4724, Name: "Kevin Bacon"}}},
+			}, nil
+		},
+	}
+
+	rec := serve(h, "/actor/287/network", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"112 direct costars,",
+		"50,000+ at two degrees.",
+		`<a href="/actor/819">Edward Norton</a>`,
+		`<a href="/actor/4724">Kevin Bacon</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("network missing %q\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "<html") {
+		t.Error("an HTMX request should get the fragment")
+	}
+
+	if rec := serve(h, "/actor/287/network", false); !strings.Contains(rec.Body.String(), "<html") {
+		t.Error("a direct visit should get the full page")
+	}
+	if rec := serve(h, "/actor/1/network", true); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown actor, got %d", rec.Code)
+	}
+}
+
+func TestActorNetwork_Bounded(t *testing.T) {
+	cfg := testServerConfig()
+	cfg.PathQueryTimeout = 10 * time.Millisecond
+	cfg.RateRoutes = map[string]config.RoutePolicy{"/actor/{id}/network": {PerSec: 0.001, Burst: 6, Cost: 3}}
+	h := newConfiguredHandler(t, cfg)
+	h.db = &fakeStore{
+		getActor: func(_ context.Context, id int) (*graph.ActorProfile, error) {
+			return &graph.ActorProfile{Actor: models.Actor{TmdbID: id, Name: "Brad Pitt"}}, nil
+		},
+		network: func(ctx context.Context, _, _, _ int) ([]graph.NetworkGroup, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	rec := serve(h, "/actor/287/network", true)
+	if rec.Code != http.StatusGatewayTimeout || !strings.Contains(rec.Body.String(), "took too long") {
+		t.Errorf("expected a 504 once the path timeout passed, got %d\n%s", rec.Code, rec.Body.String())
+	}
+	serve(h, "/actor/287/network", true)
+	if rec := serve(h, "/actor/287/network", true); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the route's burst spent after two requests, got %d", rec.Code)
+	}
+}
+
+func TestSearchResults_Render(t *testing.T) {
+	tmpl := template.Must(parseTemplates(web.FS, tmdbImages{base: "https://image.tmdb.org/t/p/", thumbnail: "w92"}, ""))
+
+	var buf strings.Builder
+	results := []graph.SearchResult{
+		{Actor: models.Actor{TmdbID: 287, Name: "Brad Pitt"}, ProfilePath: "/pitt.jpg", Popularity: 42.5, KnownFor: "Fight Club"},
+		{Actor: models.Actor{TmdbID: 9, Name: "bradley Ramsey"}},
+	}
+	if err := tmpl.ExecuteTemplate(&buf, "search.html", results); err != nil {
+		t.Fatalf("render search results failed: %v", err)
+	}
+	items := strings.Split(buf.String(), "<li")[1:]
+	if len(items) != 2 {
+		t.Fatalf("expected 2 results, got %d\n%s", len(items), buf.String())
+	}
+
+	for _, want := range []string{
+		`data-tmdb-id="287"`,
+		`<img class="search-avatar" src="https://image.tmdb.org/t/p/w92/pitt.jpg"`,
+		"known for Fight Club",
+	} {
+		if !strings.Contains(items[0], want) {
+			t.Errorf("enriched result missing %q\n%s", want, items[0])
+		}
+	}
+
+	for _, want := range []string{
+		`data-tmdb-id="9"`,
+		`<span class="search-avatar search-avatar-initial" aria-hidden="true">B</span>`,
+	} {
+		if !strings.Contains(items[1], want) {
+			t.Errorf("bare result missing %q\n%s", want, items[1])
+		}
+	}
+	if strings.Contains(items[1], "<img") || strings.Contains(items[1], "known for") {
+		t.Errorf("bare result should have no photo or known-for hint\n%s", items[1])
+	}
+}
+
+func TestStats_RenderFreshness(t *testing.T) {
+	tmpl := template.Must(parseTemplates(web.FS, tmdbImages{}, ""))
+
+	render := func(stats *graph.Stats) string {
+		t.Helper()
+		var buf strings.Builder
+		if err := tmpl.ExecuteTemplate(&buf, "stats.html", statsView{Stats: stats}); err != nil {
+			t.Fatalf("render stats failed: %v", err)
+		}
+		return buf.String()
+	}
+
+	if got := render(&graph.Stats{ActorCount: 3}); strings.Contains(got, "Last updated") {
+		t.Errorf("expected no freshness line before any ingest\n%s", got)
+	}
+	got := render(&graph.Stats{ActorCount: 3, LastIngestAt: time.Now().Add(-3 * time.Hour)})
+	if !strings.Contains(got, "Last updated <time") || !strings.Contains(got, ">3 hours ago</time>") {
+		t.Errorf("expected a freshness line reading 3 hours ago\n%s", got)
+	}
+}
+
+func TestTimeAgo(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{10 * time.Second, "just now"},
+		{time.Minute, "1 minute ago"},
+		{59 * time.Minute, "59 minutes ago"},
+		{time.Hour, "1 hour ago"},
+		{47 * time.Hour, "47 hours ago"},
+		{48 * time.Hour, "2 days ago"},
+		{400 * 24 * time.Hour, "400 days ago"},
+	}
+	for _, tt := range tests {
+		if got := timeAgo(now.Add(-tt.ago), now); got != tt.want {
+			t.Errorf("timeAgo(%s) = %q, want %q", tt.ago, got, tt.want)
+		}
+	}
+}
+
+func TestIndexPage_Render(t *testing.T) {
+	tmpl := template.Must(parseTemplates(web.FS, tmdbImages{}, ""))
+
+	var buf strings.Builder
+	if err := tmpl.ExecuteTemplate(&buf, "base.html", nil); err != nil {
+		t.Fatalf("render index failed: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "<!DOCTYPE html>") {
+		t.Errorf("expected page to start with doctype, got %q", buf.String()[:min(40, buf.Len())])
+	}
+	if !strings.Contains(buf.String(), "<title>Degrees of Separation</title>") {
+		t.Error("index missing title")
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	serve := func(actors []graph.RankedActor, err error) *httptest.ResponseRecorder {
+		t.Helper()
+		h := newTestHandler(t)
+		h.db = &fakeStore{topActors: func(_ context.Context, offset, limit int) ([]graph.RankedActor, error) {
+			if offset != 0 || limit != popularActorPool {
+				t.Errorf("unexpected TopActors(%d, %d)", offset, limit)
+			}
+			return actors, err
+		}}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/suggest", nil))
+		return rec
+	}
+
+	rec := serve([]graph.RankedActor{{Actor: models.Actor{TmdbID: 31, Name: "Tom Hanks"}, Connections: 80}}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("expected Cache-Control no-store, got %q", got)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `data-tmdb-id="31"`) || !strings.Contains(body, ">Tom Hanks</button>") {
+		t.Errorf("expected a suggestion for Tom Hanks\n%s", body)
+	}
+
+	rec = serve(nil, nil)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "" {
+		t.Errorf("expected an empty 200 on an empty graph, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(nil, errors.New("boom"))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 on a query error, got %d", rec.Code)
+	}
+}
+
+func TestSuggest_PoolCached(t *testing.T) {
+	h := newTestHandler(t)
+	calls := 0
+	pool := rankedActors(popularActorPool)
+	h.db = &fakeStore{topActors: func(ctx context.Context, offset, limit int) ([]graph.RankedActor, error) {
+		calls++
+		return pool(ctx, offset, limit)
+	}}
+
+	picked := map[string]bool{}
+	for range 20 {
+		body := serve(h, "/suggest", true).Body.String()
+		_, rest, _ := strings.Cut(body, `data-tmdb-id="`)
+		id, _, _ := strings.Cut(rest, `"`)
+		picked[id] = true
+	}
+	if calls != 1 {
+		t.Errorf("expected the pool ranked once and reused, got %d queries", calls)
+	}
+	if len(picked) < 2 {
+		t.Errorf("expected picks to vary across loads, got %v", picked)
+	}
+}
+
+func TestSurprise_RetriesUnconnectedPairs(t *testing.T) {
+	h := newTestHandler(t)
+	var picks int
+	h.db = &fakeStore{
+		randomPair: func(context.Context) (int, int, error) {
+			picks++
+			return picks, 3, nil
+		},
+		shortestPath: func(_ context.Context, a, b int) ([]graph.PathStep, error) {
+			if a < 2 {
+				return nil, nil // different components
+			}
+			return testPath(), nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/surprise", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if picks != 2 {
+		t.Errorf("expected a second pick after the unconnected pair, got %d picks", picks)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "<strong>2</strong>") || !strings.Contains(body, "a=2&b=3") {
+		t.Errorf("expected the path between 2 and 3\n%s", body)
+	}
+}
+
+func TestSurprise_TooSparse(t *testing.T) {
+	for name, db := range map[string]*fakeStore{
+		"not enough actors": {randomPair: func(context.Context) (int, int, error) {
+			return 0, 0, graph.ErrNotEnoughActors
+		}},
+		"never connected": {
+			randomPair: func(context.Context) (int, int, error) { return 1, 2, nil },
+			shortestPath: func(context.Context, int, int) ([]graph.PathStep, error) {
+				return nil, nil
+			},
+		},
+	} {
+		h := newTestHandler(t)
+		h.db = db
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/surprise", nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "too sparse") {
+			t.Errorf("%s: expected the too-sparse message, got %d\n%s", name, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHead_MatchesGetWithoutBody(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{
+		stats: func(context.Context) (*graph.Stats, error) {
+			return &graph.Stats{ActorCount: 10, EdgeCount: 40, MostConnectedActor: "Kevin Bacon", MostConnectedCount: 9}, nil
+		},
+		distribution: func(context.Context, int) (map[int]int, error) {
+			return map[int]int{1: 3, 2: 5, graph.Unconnected: 1}, nil
+		},
+	}
+
+	for _, path := range []string{"/", "/stats", "/static/style.css", "/nope"} {
+		get := httptest.NewRecorder()
+		h.ServeHTTP(get, httptest.NewRequest(http.MethodGet, path, nil))
+		head := httptest.NewRecorder()
+		h.ServeHTTP(head, httptest.NewRequest(http.MethodHead, path, nil))
+
+		if head.Code != get.Code {
+			t.Errorf("HEAD %s: expected status %d like GET, got %d", path, get.Code, head.Code)
+		}
+		if head.Body.Len() != 0 {
+			t.Errorf("HEAD %s: expected no body, got %d bytes", path, head.Body.Len())
+		}
+		want := fmt.Sprint(get.Body.Len())
+		for name, rec := range map[string]*httptest.ResponseRecorder{"GET": get, "HEAD": head} {
+			if got := rec.Header().Get("Content-Length"); got != want {
+				t.Errorf("%s %s: expected Content-Length %s, got %q", name, path, want, got)
+			}
+		}
+		if ct := head.Header().Get("Content-Type"); ct != get.Header().Get("Content-Type") {
+			t.Errorf("HEAD %s: expected Content-Type %q like GET, got %q", path, get.Header().Get("Content-Type"), ct)
+		}
+	}
+}
+
+func TestHealthz_ReportsVersion(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if body["status"] != "ok" {
+		t.Errorf("expected status ok, got %v", body["status"])
+	}
+	if v, _ := body["version"].(string); v == "" {
+		t.Errorf("expected a version, got %v", body["version"])
+	}
+	for key := range body {
+		switch key {
+		case "status", "version", "commit", "build_date":
+		default:
+			t.Errorf("unexpected key %q in %v", key, body)
+		}
+	}
+}
+
+func TestPages_ShowVersionInFooter(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	want := `<div class="container">` + version.Get().String() + `</div>`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("expected the footer to show %q", want)
+	}
+}
+
+func TestRoutes_AuthGroups(t *testing.T) {
+	cfg := testServerConfig()
+	cfg.APITokens = []config.Token{{Name: "partner", Secret: "api-secret"}}
+	cfg.AdminTokens = []config.Token{{Secret: "admin-secret"}}
+	h, err := NewHandler(nil, nil, web.FS, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	t.Cleanup(h.Close)
+	open := newTestHandler(t)
+
+	tests := []struct {
+		name       string
+		h          *Handler
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"public page needs no token", h, "/", "", http.StatusOK},
+		{"api missing token", h, "/api/v1/path/graph?a=x&b=1", "", http.StatusUnauthorized},
+		{"api wrong token", h, "/api/v1/path/graph?a=x&b=1", "admin-secret", http.StatusForbidden},
+		{"api valid token", h, "/api/v1/path/graph?a=x&b=1", "api-secret", http.StatusBadRequest},
+		{"api open without api tokens", open, "/api/v1/path/graph?a=x&b=1", "", http.StatusBadRequest},
+		{"admin missing token", h, "/admin/reindex", "", http.StatusUnauthorized},
+		{"admin wrong token", h, "/admin/reindex", "api-secret", http.StatusForbidden},
+		{"admin valid token", h, "/admin/reindex", "admin-secret", http.StatusNotFound},
+		{"admin closed without admin tokens", open, "/admin/reindex", "admin-secret", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		tt.h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.wantStatus, rec.Code)
+		}
+	}
+}
+
+// knownActors stands in for GetActorByID with only ids 1, 2 and 3 in the
+// graph.
+func knownActors(_ context.Context, id int) (*models.Actor, error) {
+	if id < 1 || id > 3 {
+		return nil, nil
+	}
+	return &models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)}, nil
+}
+
+func TestDegrees_UnknownActor(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{
+		shortestPath: func(context.Context, int, int) ([]graph.PathStep, error) { return nil, nil },
+		actorByID:    knownActors,
+	}
+
+	for _, tt := range []struct {
+		name, path, want string
+	}{
+		{"missing a", "/degrees?a=99&b=2", "Actor A not found"},
+		{"missing b", "/degrees?a=1&b=99", "Actor B not found"},
+		{"missing both", "/degrees?a=98&b=99", "Actor A not found"},
+		{"same missing actor", "/degrees?a=99&b=99", "Actor A not found"},
+	} {
+		rec := serve(h, tt.path, true)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", tt.name, rec.Code)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, tt.want) || strings.Contains(body, "No connection found") {
+			t.Errorf("%s: expected %q rather than no connection\n%s", tt.name, tt.want, body)
+		}
+	}
+}
+
+func TestDegrees_Disconnected(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{
+		shortestPath: func(context.Context, int, int) ([]graph.PathStep, error) { return nil, nil },
+		actorByID:    knownActors,
+	}
+
+	rec := serve(h, "/degrees?a=1&b=3", true)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "No connection found between these actors.") {
+		t.Errorf("expected the no-connection message for two known actors, got %d\n%s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(h, "/degrees?a=2&b=2", true)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<strong>0</strong> degrees of separation") {
+		t.Errorf("expected 0 degrees for a known actor with themselves, got %d\n%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDegrees_Chronological(t *testing.T) {
+	path := func(years ...int) []graph.PathStep {
+		steps := []graph.PathStep{{Actor: &models.Actor{TmdbID: 1, Name: "Actor 1"}}}
+		for i, year := range years {
+			steps = append(steps, graph.PathStep{MovieTitle: fmt.Sprintf("Movie %d", i), MovieYear: year},
+				graph.PathStep{Actor: &models.Actor{TmdbID: i + 2, Name: fmt.Sprintf("Actor %d", i+2)}})
+		}
+		return steps
+	}
+	h := newTestHandler(t)
+	store := &fakeStore{
+		shortestPath: func(context.Context, int, int) ([]graph.PathStep, error) { return path(2010, 1995), nil },
+		chronoPath:   func(context.Context, int, int) ([]graph.PathStep, error) { return path(1990, 2000, 2005), nil },
+		actorByID:    knownActors,
+	}
+	h.db = store
+
+	tests := []struct {
+		name, path string
+		wantCode   int
+		want       string
+	}{
+		{"shortest out of order", "/degrees?a=1&b=3", http.StatusOK, "Its movies jump around in time."},
+		{"chronological", "/degrees?a=1&b=3&order=chronological", http.StatusOK, "<strong>3</strong>"},
+		{"unknown order", "/degrees?a=1&b=3&order=alphabetical", http.StatusBadRequest, "unknown order"},
+	}
+	for _, tt := range tests {
+		rec := serve(h, tt.path, true)
+		if rec.Code != tt.wantCode || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%s: expected %d with %q, got %d\n%s", tt.name, tt.wantCode, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+	if body := serve(h, "/degrees?a=1&b=3&order=chronological", true).Body.String(); !strings.Contains(body, "Its movies run in release order.") {
+		t.Errorf("expected the chronological path annotated\n%s", body)
+	}
+
+	store.chronoPath = func(context.Context, int, int) ([]graph.PathStep, error) { return nil, nil }
+	if body := serve(h, "/degrees?a=1&b=3&order=chronological", true).Body.String(); !strings.Contains(body, "No path between these actors runs in release order.") {
+		t.Errorf("expected the no-chronological-path message\n%s", body)
+	}
+
+	store.chronoPath = func(context.Context, int, int) ([]graph.PathStep, error) { return nil, graph.ErrYearWindowUnsupported }
+	if rec := serve(h, "/degrees?a=1&b=3&order=chronological", true); rec.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 on a compact-edge graph, got %d", rec.Code)
+	}
+}
+
+func TestDegrees_PathTimeout(t *testing.T) {
+	cfg := testServerConfig()
+	cfg.PathQueryTimeout = 20 * time.Millisecond
+	h, err := NewHandler(nil, nil, web.FS, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	t.Cleanup(h.Close)
+	h.db = &fakeStore{shortestPath: sleepUntilDone}
+
+	req := httptest.NewRequest(http.MethodGet, "/degrees?a=1&b=2", nil)
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(rec, req)
+
+	if elapsed := time.Since(start); elapsed >= cfg.RequestTimeout {
+		t.Errorf("expected the path timeout to fire well before the request timeout, took %s", elapsed)
+	}
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "these actors may be very far apart") {
+		t.Errorf("expected the path timeout fragment, got %s", body)
+	}
+}
+
+func TestDegrees_PathTimeoutFromNeo4j(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{shortestPath: func(context.Context, int, int) ([]graph.PathStep, error) {
+		return nil, fmt.Errorf("error finding shortest path: %w", &neo4j.Neo4jError{
+			Code: "Neo.ClientError.Transaction.TransactionTimedOutClientConfiguration",
+		})
+	}}
+
+	req := httptest.NewRequest(http.MethodGet, "/degrees?a=1&b=2", nil)
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout || !strings.Contains(rec.Body.String(), "these actors may be very far apart") {
+		t.Errorf("expected the path timeout fragment as a 504, got %d %s", rec.Code, rec.Body.String())
+	}
+	if got, want := rec.Header().Get("Content-Length"), strconv.Itoa(rec.Body.Len()); got != want {
+		t.Errorf("expected Content-Length %s, got %q", want, got)
+	}
+}
+
+func TestDegrees_ErrorLogCarriesRequestID(t *testing.T) {
+	var logs strings.Builder
+	h, err := NewHandler(nil, nil, web.FS, testServerConfig(), slog.New(slog.NewTextHandler(&logs, nil)), nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	t.Cleanup(h.Close)
+	h.db = &fakeStore{shortestPath: func(context.Context, int, int) ([]graph.PathStep, error) {
+		return nil, errors.New("connection reset")
+	}}
+
+	req := httptest.NewRequest(http.MethodGet, "/degrees?a=1&b=2", nil)
+	req.Header.Set("X-Request-ID", "support-ticket-7")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line string
+	for l := range strings.Lines(logs.String()) {
+		if strings.Contains(l, "failed to get shortest path") {
+			line = l
+		}
+	}
+	if line == "" {
+		t.Fatalf("expected a handler error log, got %q", logs.String())
+	}
+	for _, want := range []string{"request_id=support-ticket-7", "method=GET", "path=/degrees"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %s on the handler log line, got %q", want, line)
+		}
+	}
+}
+
+// TestDebugEndpoints_NeverOnMainListener checks that pprof and expvar stay
+// off the public handler whether or not they are turned on; cmd/server
+// serves them on DEBUG_ADDR.
+func TestDebugEndpoints_NeverOnMainListener(t *testing.T) {
+	for _, on := range []bool{false, true} {
+		cfg := testServerConfig()
+		cfg.DebugEndpoints = on
+		h := newConfiguredHandler(t, cfg)
+		for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/vars"} {
+			if rec := serve(h, path, false); rec.Code != http.StatusNotFound {
+				t.Errorf("debug endpoints %v: expected 404 for %s, got %d", on, path, rec.Code)
+			}
+		}
+	}
+}
+
+func TestHSTS_OnlyWithTLS(t *testing.T) {
+	plain := newTestHandler(t)
+	rec := httptest.NewRecorder()
+	plain.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("expected no HSTS without TLS, got %q", got)
+	}
+
+	cfg := testServerConfig()
+	cfg.TLSCertFile, cfg.TLSKeyFile = "cert.pem", "key.pem"
+	cfg.HSTSMaxAge = time.Hour
+	h, err := NewHandler(nil, nil, web.FS, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	t.Cleanup(h.Close)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=3600" {
+		t.Errorf("expected HSTS with TLS, got %q", got)
+	}
+}
+
+func TestStats_ReusesDegreeSample(t *testing.T) {
+	cfg := testServerConfig()
+	cfg.PathQueryTimeout = time.Second
+	h := newConfiguredHandler(t, cfg)
+	samples := 0
+	h.db = &fakeStore{
+		stats: func(context.Context) (*graph.Stats, error) { return &graph.Stats{ActorCount: 3}, nil },
+		distribution: func(ctx context.Context, _ int) (map[int]int, error) {
+			samples++
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("expected the sample bounded by the path timeout")
+			}
+			return map[int]int{1: 1}, nil
+		},
+	}
+
+	for i := range 3 {
+		if rec := serve(h, "/stats", true); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i+1, rec.Code)
+		}
+	}
+	if samples != 1 {
+		t.Errorf("expected one degree sample reused across loads, got %d", samples)
+	}
+}
+
+func TestReload(t *testing.T) {
+	cfg := testServerConfig()
+	cfg.RateLimitPerSec, cfg.RateBurst = 0.0001, 2
+	h := newConfiguredHandler(t, cfg)
+	calls := 0
+	h.db = &fakeStore{
+		stats: func(context.Context) (*graph.Stats, error) {
+			calls++
+			return &graph.Stats{ActorCount: 3}, nil
+		},
+		distribution: func(context.Context, int) (map[int]int, error) { return map[int]int{1: 1}, nil },
+	}
+
+	serve(h, "/stats", true)
+	if rec := serve(h, "/stats", true); rec.Code != http.StatusOK || calls != 2 {
+		t.Fatalf("expected two uncached stats loads, got %d after %d fetches", rec.Code, calls)
+	}
+	if rec := serve(h, "/stats", true); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the burst of two spent, got %d", rec.Code)
+	}
+
+	cfg.RateLimitPerSec, cfg.RateBurst = 1000, 1000
+	cfg.StatsCacheTTL = time.Hour
+	cfg.CORSOrigins = []string{"https://a.example"}
+	h.Reload(cfg)
+	time.Sleep(10 * time.Millisecond)
+
+	for i := range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+		req.Header.Set("Origin", "https://b.example")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d after raising the limit: expected 200, got %d", i+1, rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("expected the wildcard replaced by the new allowlist, got %q", got)
+		}
+	}
+	// Nothing was cached without a ttl, so the new one starts with a fetch.
+	if calls != 3 {
+		t.Errorf("expected one fetch reused under the new ttl, got %d fetches", calls)
+	}
+}
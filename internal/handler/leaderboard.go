@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+)
+
+// leaderboardPageSize is how many actors a page of /stats/leaderboard ranks.
+const leaderboardPageSize = 50
+
+// maxLeaderboardPage bounds ?page=, and with it how much of the ranking is
+// cached. Half a million actors deep is well past anyone worth ranking.
+const maxLeaderboardPage = 10000
+
+type leaderboardEntry struct {
+	Rank int
+	graph.RankedActor
+}
+
+type leaderboardPage struct {
+	Page    int
+	Entries []leaderboardEntry
+	// Prev and Next are the neighbouring page numbers, 0 when there is none.
+	Prev, Next int
+}
+
+// pageLink is a link to a neighbouring page, for the leaderboard-link
+// template.
+type pageLink struct {
+	Page  int
+	Label string
+}
+
+// PrevLink and NextLink link to the neighbouring pages; nil when there is
+// none.
+func (p leaderboardPage) PrevLink() *pageLink {
+	if p.Prev == 0 {
+		return nil
+	}
+	return &pageLink{Page: p.Prev, Label: "← Previous"}
+}
+
+func (p leaderboardPage) NextLink() *pageLink {
+	if p.Next == 0 {
+		return nil
+	}
+	return &pageLink{Page: p.Next, Label: "Next →"}
+}
+
+// parsePage reads ?page=, which defaults to the first.
+func parsePage(s string) (int, error) {
+	if s == "" {
+		return 1, nil
+	}
+	page, err := strconv.Atoi(s)
+	if err != nil || page < 1 || page > maxLeaderboardPage {
+		return 0, badRequest("page must be a whole number from 1 to " + strconv.Itoa(maxLeaderboardPage))
+	}
+	return page, nil
+}
+
+// leaderboardHandler ranks actors by connections, a page at a time. Ranking
+// them means counting every actor's edges, so the whole ranking is fetched
+// once per UTC day and paged in memory. A page past the end is empty rather
+// than a 404, so links to it never break.
+func (h *Handler) leaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePage(r.URL.Query().Get("page"))
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+
+	ranking, err := h.ranking.get(r.Context(), time.Now(), func(ctx context.Context) ([]graph.RankedActor, error) {
+		return h.db.TopActors(ctx, 0, maxLeaderboardPage*leaderboardPageSize)
+	})
+	if err != nil {
+		h.log(r.Context()).Error("failed to list top actors", "page", page, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	offset := (page - 1) * leaderboardPageSize
+	actors := ranking[min(offset, len(ranking)):min(offset+leaderboardPageSize, len(ranking))]
+
+	view := leaderboardPage{Page: page}
+	if page > 1 {
+		view.Prev = page - 1
+	}
+	if offset+leaderboardPageSize < len(ranking) {
+		view.Next = page + 1
+	}
+	for i, a := range actors {
+		view.Entries = append(view.Entries, leaderboardEntry{Rank: offset + i + 1, RankedActor: a})
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		h.renderFragment(w, r, "leaderboard.html", view)
+		return
+	}
+	h.renderFragment(w, r, "leaderboard_page.html", view)
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+// rankedActors stands in for TopActors over a graph of n connected actors,
+// ids 1 to n, each with one connection fewer than the last.
+func rankedActors(n int) func(context.Context, int, int) ([]graph.RankedActor, error) {
+	return func(_ context.Context, offset, limit int) ([]graph.RankedActor, error) {
+		var actors []graph.RankedActor
+		for id := offset + 1; id <= min(n, offset+limit); id++ {
+			actors = append(actors, graph.RankedActor{
+				Actor:       models.Actor{TmdbID: id, Name: fmt.Sprintf("Actor %d", id)},
+				Connections: 1000 - id,
+			})
+		}
+		return actors, nil
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{topActors: rankedActors(120)}
+
+	rec := serve(h, "/stats/leaderboard", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<a href="/actor/1">Actor 1</a>`,
+		`<a href="/actor/50">Actor 50</a>`,
+		"<td>999</td>",
+		`hx-get="/stats/leaderboard?page=2"`,
+		`hx-push-url="true">Next →</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("first page missing %q\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "Actor 51<") || strings.Contains(body, "Previous") {
+		t.Errorf("expected only the first page with no way back\n%s", body)
+	}
+
+	body = serve(h, "/stats/leaderboard?page=3", true).Body.String()
+	if !strings.Contains(body, "<td>101</td>") || !strings.Contains(body, "Actor 120<") || !strings.Contains(body, "?page=2") {
+		t.Errorf("expected ranks 101 to 120 linking back to page 2\n%s", body)
+	}
+	if strings.Contains(body, "Next") {
+		t.Errorf("expected no next link on the last page\n%s", body)
+	}
+
+	if rec := serve(h, "/stats/leaderboard?page=2", false); !strings.Contains(rec.Body.String(), "<html") {
+		t.Error("a direct visit should get the full page")
+	}
+}
+
+func TestLeaderboard_PastTheEnd(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{topActors: rankedActors(120)}
+
+	rec := serve(h, "/stats/leaderboard?page=9", true)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Nobody ranks this far down.") {
+		t.Errorf("expected an empty page, got %d\n%s", rec.Code, rec.Body.String())
+	}
+
+	for _, page := range []string{"0", "-1", "two", "10001"} {
+		if rec := serve(h, "/stats/leaderboard?page="+page, true); rec.Code != http.StatusBadRequest {
+			t.Errorf("page=%s: expected 400, got %d", page, rec.Code)
+		}
+	}
+}
+
+func TestLeaderboard_RankingCached(t *testing.T) {
+	h := newTestHandler(t)
+	calls := 0
+	ranked := rankedActors(120)
+	h.db = &fakeStore{topActors: func(ctx context.Context, offset, limit int) ([]graph.RankedActor, error) {
+		calls++
+		return ranked(ctx, offset, limit)
+	}}
+
+	for _, page := range []string{"1", "2", "3", "9"} {
+		serve(h, "/stats/leaderboard?page="+page, true)
+	}
+	if calls != 1 {
+		t.Errorf("expected the ranking fetched once and paged from memory, got %d queries", calls)
+	}
+}
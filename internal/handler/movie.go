@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/internal/tmdb"
+)
+
+// newMoviePathResult wraps a path from ShortestPathActorToMovie for
+// degrees.html. The final movie step becomes Movie, leaving Steps an actor
+// chain like any other path.
+func newMoviePathResult(a, movieID int, steps []graph.PathStep) pathResult {
+	if len(steps) == 0 {
+		return pathResult{A: a, Movie: &models.Movie{TmdbID: movieID}}
+	}
+	last := steps[len(steps)-1]
+	result := newPathResult(a, 0, steps[:len(steps)-1])
+	result.Movie = &models.Movie{TmdbID: movieID, Title: last.MovieTitle, Year: last.MovieYear}
+	return result
+}
+
+// resolveMovie reads /degrees?movie=, which is a TMDb movie id or else a
+// title, taken as the best match of a movie search.
+func (h *Handler) resolveMovie(ctx context.Context, s string) (int, error) {
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if id <= 0 || id > math.MaxInt32 {
+			return 0, badRequest("movie ids must be positive whole numbers")
+		}
+		return int(id), nil
+	}
+
+	title, err := cleanQuery(s, h.maxQueryLen)
+	if err != nil {
+		return 0, err
+	}
+	movies, err := h.db.SearchMovies(ctx, title, 1)
+	if err != nil {
+		h.log(ctx).Error("failed to search movies", "query", title, "err", err)
+		return 0, err
+	}
+	if len(movies) == 0 {
+		return 0, notFound(fmt.Sprintf("no movie in the graph matches %q", title))
+	}
+	return movies[0].TmdbID, nil
+}
+
+// degreesToMovieHandler is /degrees with a movie in place of actor b: the
+// shortest chain from a to anyone in the movie.
+func (h *Handler) degreesToMovieHandler(w http.ResponseWriter, r *http.Request) {
+	idA, err := parseActorID(r.URL.Query().Get("a"))
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+	movieID, err := h.resolveMovie(r.Context(), r.URL.Query().Get("movie"))
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+
+	ctx, cancel := h.pathContext(r.Context())
+	defer cancel()
+	steps, err := h.db.ShortestPathActorToMovie(ctx, idA, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, graph.ErrUnknownMovie):
+			h.renderError(w, r, notFound("movie not found"))
+		case isTimeout(err):
+			h.log(r.Context()).Warn("path to movie timed out", "a", idA, "movie", movieID, "timeout", h.pathTimeout)
+			h.renderPathTimeout(w, r)
+		default:
+			h.log(r.Context()).Error("failed to get path to movie", "a", idA, "movie", movieID, "err", err)
+			h.renderError(w, r, err)
+		}
+		return
+	}
+
+	result := newMoviePathResult(idA, movieID, steps)
+	result.Movie.PosterPath = h.moviePoster(r.Context(), movieID)
+	h.renderFragment(w, r, "degrees.html", result)
+}
+
+// moviePoster returns a movie's poster path from TMDb, which the graph doesn't
+// store, cached per movie like actorDetails. It gives up quietly the same
+// way, returning "" for the placeholder.
+func (h *Handler) moviePoster(ctx context.Context, id int) string {
+	if poster, ok := h.moviePosters.get(id, time.Now()); ok || h.tmdb == nil {
+		return poster
+	}
+	ctx, cancel := context.WithTimeout(ctx, tmdbExtrasTimeout)
+	defer cancel()
+	movie, err := h.tmdb.GetMovie(ctx, id)
+	if err != nil && !errors.Is(err, tmdb.ErrNotFound) {
+		h.log(ctx).Warn("failed to get movie poster, showing a placeholder", "movie", id, "err", err)
+		return ""
+	}
+	h.moviePosters.put(id, movie.PosterPath, time.Now())
+	return movie.PosterPath
+}
+
+// searchMoviesHandler is /search for the movie field of the degrees form.
+func (h *Handler) searchMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := cleanQuery(r.URL.Query().Get("q"), h.maxQueryLen)
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+	if query == "" {
+		h.renderFragment(w, r, "movie_search.html", nil)
+		return
+	}
+
+	movies, err := h.db.SearchMovies(r.Context(), query, h.searchLimit)
+	if err != nil {
+		h.log(r.Context()).Error("failed to search movies", "query", query, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	h.renderFragment(w, r, "movie_search.html", movies)
+}
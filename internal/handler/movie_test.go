@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+)
+
+// apollo13 stands in for ShortestPathActorToMovie with Apollo 13 (568) as the
+// only movie: Kevin Bacon is in it, Brad Pitt is one co-star away, and
+// anyone else is unreachable.
+func apollo13(_ context.Context, actorID, movieID int) ([]graph.PathStep, error) {
+	switch {
+	case movieID != 568:
+		return nil, graph.ErrUnknownMovie
+	case actorID == 4724:
+		// Kevin Bacon is in it.
+		return []graph.PathStep{
+			{Actor: &models.Actor{TmdbID: 4724, Name: "Kevin Bacon"}},
+			{MovieTitle: "Apollo 13", MovieYear: 1995},
+		}, nil
+	case actorID == 287:
+		return []graph.PathStep{
+			{Actor: &models.Actor{TmdbID: 287, Name: "Brad Pitt"}},
+			{MovieTitle: "Sleepers", MovieYear: 1996},
+			{Actor: &models.Actor{TmdbID: 4724, Name: "Kevin Bacon"}},
+			{MovieTitle: "Apollo 13", MovieYear: 1995},
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func TestDegreesToMovie(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{moviePath: apollo13}
+
+	body := serve(h, "/degrees?a=287&movie=568", true).Body.String()
+	for _, want := range []string{
+		"<strong>1</strong>",
+		"degree from <em>Apollo 13</em>",
+		`<a class="actor-node" href="/actor/287">Brad Pitt</a>`,
+		`<span class="movie-label">Sleepers (1996)</span>`,
+		`<a class="actor-node" href="/actor/4724">Kevin Bacon</a>`,
+		`<span class="movie-node">Apollo 13 (1995)</span>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("path to movie missing %q\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "/degrees/export") {
+		t.Error("expected no export links for a path to a movie")
+	}
+}
+
+func TestDegreesToMovie_Poster(t *testing.T) {
+	cfg := testServerConfig()
+	cfg.ImageBaseURL, cfg.ImageSize = "https://image.tmdb.org/t/p", "w185"
+	fightClub := func(context.Context, int, int) ([]graph.PathStep, error) {
+		return []graph.PathStep{
+			{Actor: &models.Actor{TmdbID: 287, Name: "Brad Pitt"}},
+			{MovieTitle: "Fight Club", MovieYear: 1999},
+		}, nil
+	}
+
+	tests := []struct {
+		name  string
+		tmdb  tmdbSource
+		movie string
+		want  string
+	}{
+		{"with poster", &fakeTMDb{}, "550", `<img class="movie-poster" src="https://image.tmdb.org/t/p/w185/fight.jpg"`},
+		{"no client", nil, "550", `<span class="movie-poster movie-poster-initial" aria-hidden="true">F</span>`},
+		{"unknown to tmdb", &fakeTMDb{}, "551", `movie-poster-initial`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newConfiguredHandler(t, cfg)
+			h.db = &fakeStore{moviePath: fightClub}
+			h.tmdb = tt.tmdb
+
+			body := serve(h, "/degrees?a=287&movie="+tt.movie, true).Body.String()
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("expected %q\n%s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestDegreesToMovie_PosterCached(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{moviePath: func(context.Context, int, int) ([]graph.PathStep, error) {
+		return []graph.PathStep{{Actor: &models.Actor{TmdbID: 287, Name: "Brad Pitt"}}, {MovieTitle: "Fight Club", MovieYear: 1999}}, nil
+	}}
+	tm := &fakeTMDb{}
+	h.tmdb = tm
+
+	// A movie with a poster and one TMDb doesn't know are each asked about
+	// once.
+	for range 3 {
+		serve(h, "/degrees?a=287&movie=550", true)
+		serve(h, "/degrees?a=287&movie=551", true)
+	}
+	if tm.movieCalls != 2 {
+		t.Errorf("expected one TMDb call per movie, got %d", tm.movieCalls)
+	}
+
+	// A failure isn't cached.
+	tm.movieErr = errors.New("connection refused")
+	serve(h, "/degrees?a=287&movie=552", true)
+	serve(h, "/degrees?a=287&movie=552", true)
+	if tm.movieCalls != 4 {
+		t.Errorf("expected a failed fetch asked again, got %d calls", tm.movieCalls)
+	}
+}
+
+func TestDegreesToMovie_ActorInMovie(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{moviePath: apollo13}
+
+	body := serve(h, "/degrees?a=4724&movie=568", true).Body.String()
+	if !strings.Contains(body, "Kevin Bacon is in <em>Apollo 13</em>") {
+		t.Errorf("expected the actor shown in the movie\n%s", body)
+	}
+	if strings.Contains(body, `class="movie-label"`) {
+		t.Errorf("expected no co-star hops\n%s", body)
+	}
+}
+
+func TestDegreesToMovie_Unreachable(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{moviePath: apollo13}
+
+	rec := serve(h, "/degrees?a=1&movie=568", true)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "No connection found between this actor and that movie.") {
+		t.Errorf("expected the no-path message, got %d\n%s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serve(h, "/degrees?a=287&movie=99", true); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a movie not in the graph, got %d", rec.Code)
+	}
+	if rec := serve(h, "/degrees?a=287&movie=-3", true); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a negative movie id, got %d", rec.Code)
+	}
+}
+
+func TestDegreesToMovie_ByTitle(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{
+		moviePath: apollo13,
+		searchMovies: func(_ context.Context, query string, limit int) ([]models.Movie, error) {
+			if query == "apollo 13" && limit == 1 {
+				return []models.Movie{{TmdbID: 568, Title: "Apollo 13", Year: 1995}}, nil
+			}
+			return nil, nil
+		},
+	}
+
+	if body := serve(h, "/degrees?a=287&movie=apollo+13", true).Body.String(); !strings.Contains(body, `<span class="movie-node">Apollo 13 (1995)</span>`) {
+		t.Errorf("expected the title resolved to the movie\n%s", body)
+	}
+	if rec := serve(h, "/degrees?a=287&movie=zardoz", true); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a title with no match, got %d", rec.Code)
+	}
+}
+
+func TestSearchMovies(t *testing.T) {
+	h := newTestHandler(t)
+	h.db = &fakeStore{searchMovies: func(_ context.Context, query string, limit int) ([]models.Movie, error) {
+		if limit != 15 {
+			t.Errorf("expected the configured search limit, got %d", limit)
+		}
+		return []models.Movie{{TmdbID: 568, Title: "Apollo 13", Year: 1995}, {TmdbID: 9, Title: "Apollo 18"}}, nil
+	}}
+
+	body := serve(h, "/search/movies?q=apollo", true).Body.String()
+	for _, want := range []string{`data-tmdb-id="568"`, `data-name="Apollo 13"`, "1995", `data-tmdb-id="9"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("movie search missing %q\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/tmdb"
+)
+
+// defaultReingestCast matches the ingest binary's default -max-cast, so a
+// refreshed movie keeps the shape the crawl gave it.
+const defaultReingestCast = 20
+
+// maxReingestCast bounds ?max_cast=. A cast of n makes n(n-1)/2 edges.
+const maxReingestCast = 100
+
+var errNoTMDb = &requestError{status: http.StatusServiceUnavailable, msg: "re-ingesting needs a TMDb token; set TMDB_API_TOKEN"}
+
+var errTMDbFailed = &requestError{status: http.StatusBadGateway, msg: "failed to fetch the movie from TMDb"}
+
+// errCastTooSmall refuses a cast that would make no edges: deleting the
+// movie's edges for it would only drop the movie from the graph.
+var errCastTooSmall = &requestError{status: http.StatusBadGateway, msg: "TMDb returned fewer than two cast members; the movie was left as it was"}
+
+type reingestResponse struct {
+	Movie int    `json:"movie"`
+	Title string `json:"title"`
+	Cast  int    `json:"cast"`
+	// EdgesDeleted counts the movie's edges removed before re-ingesting.
+	EdgesDeleted int `json:"edges_deleted"`
+}
+
+// reingestHandler serves POST /admin/reingest?movie=, refreshing one movie
+// whose cast was wrong or incomplete when it was crawled. It fetches the
+// movie and its cast from TMDb first, so a TMDb failure, or a cast too small
+// to make an edge, leaves the graph untouched, then deletes the movie's edges and ingests the new cast. The
+// delete and the ingest are separate transactions; if the ingest fails the
+// movie is left out of the graph until the request is retried.
+func (h *Handler) reingestHandler(w http.ResponseWriter, r *http.Request) {
+	if h.tmdb == nil {
+		h.renderError(w, r, errNoTMDb)
+		return
+	}
+	query := r.URL.Query()
+	id, err := strconv.Atoi(query.Get("movie"))
+	if err != nil || id <= 0 {
+		h.renderError(w, r, badRequest("movie must be a positive TMDb movie id"))
+		return
+	}
+	maxCast := defaultReingestCast
+	if s := query.Get("max_cast"); s != "" {
+		if maxCast, err = strconv.Atoi(s); err != nil || maxCast < 2 || maxCast > maxReingestCast {
+			h.renderError(w, r, badRequest("max_cast must be a whole number from 2 to "+strconv.Itoa(maxReingestCast)))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	logger := h.log(ctx)
+
+	movie, err := h.tmdb.GetMovie(ctx, id)
+	if errors.Is(err, tmdb.ErrNotFound) {
+		h.renderError(w, r, notFound("TMDb has no movie with that id"))
+		return
+	}
+	if err != nil {
+		logger.Error("failed to fetch movie", "movie_id", id, "err", err)
+		h.renderError(w, r, errTMDbFailed)
+		return
+	}
+	cast, err := h.tmdb.GetMovieCast(ctx, id, maxCast)
+	if err != nil {
+		logger.Error("failed to fetch cast", "movie_id", id, "err", err)
+		h.renderError(w, r, errTMDbFailed)
+		return
+	}
+	if len(cast) < 2 {
+		logger.Warn("refusing to re-ingest a cast of fewer than two", "movie_id", id, "cast", len(cast))
+		h.renderError(w, r, errCastTooSmall)
+		return
+	}
+
+	deleted, err := h.db.DeleteMovieEdges(ctx, id)
+	if err != nil {
+		logger.Error("failed to delete movie edges", "movie_id", id, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+	if err := h.db.IngestMovieCast(ctx, movie, cast); err != nil {
+		logger.Error("failed to re-ingest cast", "movie_id", id, "edges_deleted", deleted, "err", err)
+		h.renderError(w, r, err)
+		return
+	}
+
+	logger.Info("re-ingested movie", "movie_id", id, "title", movie.Title, "cast", len(cast), "edges_deleted", deleted)
+	writeJSON(w, http.StatusOK, reingestResponse{Movie: id, Title: movie.Title, Cast: len(cast), EdgesDeleted: deleted})
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/web"
+)
+
+// newReingestHandler is a handler with admin token "admin-secret", source as
+// its TMDb client and store as its graph.
+func newReingestHandler(t *testing.T, source tmdbSource, store *fakeStore) *Handler {
+	t.Helper()
+	cfg := testServerConfig()
+	cfg.AdminTokens = []config.Token{{Secret: "admin-secret"}}
+	h, err := NewHandler(nil, nil, web.FS, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	t.Cleanup(h.Close)
+	h.tmdb = source
+	h.db = store
+	return h
+}
+
+func postReingest(h *Handler, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/reingest"+query, nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReingest(t *testing.T) {
+	var calls []string
+	var ingested models.Movie
+	var cast []models.Actor
+	store := &fakeStore{
+		deleteMovie: func(_ context.Context, id int) (int, error) {
+			calls = append(calls, "delete")
+			return 6, nil
+		},
+		ingestCast: func(_ context.Context, movie models.Movie, c []models.Actor) error {
+			calls = append(calls, "ingest")
+			ingested, cast = movie, c
+			return nil
+		},
+	}
+	source := &fakeTMDb{}
+	h := newReingestHandler(t, source, store)
+
+	rec := postReingest(h, "?movie=550")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp reingestResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp != (reingestResponse{Movie: 550, Title: "Fight Club", Cast: 3, EdgesDeleted: 6}) {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if len(calls) != 2 || calls[0] != "delete" || calls[1] != "ingest" {
+		t.Errorf("expected the old edges deleted before ingesting, got %v", calls)
+	}
+	if ingested.Year != 1999 || len(cast) != 3 {
+		t.Errorf("expected Fight Club's fresh cast ingested, got %+v with %v", ingested, cast)
+	}
+	if source.maxCast != defaultReingestCast {
+		t.Errorf("expected the default cast size, got %d", source.maxCast)
+	}
+
+	postReingest(h, "?movie=550&max_cast=5")
+	if source.maxCast != 5 {
+		t.Errorf("expected max_cast=5 passed on, got %d", source.maxCast)
+	}
+}
+
+func TestReingest_Errors(t *testing.T) {
+	untouched := &fakeStore{
+		deleteMovie: func(context.Context, int) (int, error) { panic("graph changed") },
+	}
+	failingIngest := &fakeStore{
+		deleteMovie: func(context.Context, int) (int, error) { return 1, nil },
+		ingestCast:  func(context.Context, models.Movie, []models.Actor) error { return errors.New("write failed") },
+	}
+
+	tests := []struct {
+		name       string
+		source     tmdbSource
+		store      *fakeStore
+		query      string
+		wantStatus int
+	}{
+		{"missing movie", &fakeTMDb{}, untouched, "", http.StatusBadRequest},
+		{"bad movie", &fakeTMDb{}, untouched, "?movie=abc", http.StatusBadRequest},
+		{"bad max cast", &fakeTMDb{}, untouched, "?movie=550&max_cast=1000", http.StatusBadRequest},
+		{"unknown to tmdb", &fakeTMDb{}, untouched, "?movie=1", http.StatusNotFound},
+		{"tmdb down", &fakeTMDb{castErr: errors.New("connection refused")}, untouched, "?movie=550", http.StatusBadGateway},
+		{"empty cast", &fakeTMDb{cast: []models.Actor{}}, untouched, "?movie=550", http.StatusBadGateway},
+		{"cast of one", &fakeTMDb{cast: []models.Actor{{TmdbID: 287, Name: "Brad Pitt"}}}, untouched, "?movie=550", http.StatusBadGateway},
+		{"no tmdb client", nil, untouched, "?movie=550", http.StatusServiceUnavailable},
+		{"ingest fails", &fakeTMDb{}, failingIngest, "?movie=550", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		h := newReingestHandler(t, tt.source, tt.store)
+		rec := postReingest(h, tt.query)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.wantStatus, rec.Code, rec.Body)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: expected a JSON error, got %q", tt.name, ct)
+		}
+	}
+}
+
+func TestReingest_NeedsAdminToken(t *testing.T) {
+	h := newReingestHandler(t, &fakeTMDb{}, &fakeStore{})
+
+	for _, token := range []string{"", "wrong"} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/reingest?movie=550", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized && rec.Code != http.StatusForbidden {
+			t.Errorf("token %q: expected the request refused, got %d", token, rec.Code)
+		}
+	}
+}
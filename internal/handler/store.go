@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/internal/tmdb"
+)
+
+// graphStore is the part of graph.Driver the handlers use. Tests substitute
+// a fake to drive handlers through database behaviour, like slow queries,
+// that a real Neo4j can't easily be made to produce.
+type graphStore interface {
+	SearchActors(ctx context.Context, prefix string, limit int) ([]graph.SearchResult, error)
+	ShortestPath(ctx context.Context, actorA, actorB int) ([]graph.PathStep, error)
+	ChronologicalPath(ctx context.Context, actorA, actorB int) ([]graph.PathStep, error)
+	ShortestPathActorToMovie(ctx context.Context, actorID, movieTmdbID int) ([]graph.PathStep, error)
+	SearchMovies(ctx context.Context, query string, limit int) ([]models.Movie, error)
+	Distance(ctx context.Context, actorA, actorB, maxHops int) (int, error)
+	Neighbors(ctx context.Context, ids []int, limit int) ([]graph.NeighborEdge, error)
+	GetActor(ctx context.Context, id int) (*graph.ActorProfile, error)
+	GetActorByID(ctx context.Context, id int) (*models.Actor, error)
+	GetCostars(ctx context.Context, id, limit int) ([]graph.Costar, error)
+	ActorsWithinDegrees(ctx context.Context, id, maxDegrees, limit int) ([]graph.NetworkGroup, error)
+	Chain(ctx context.Context, ids []int) ([]graph.PathStep, error)
+	GetStats(ctx context.Context) (*graph.Stats, error)
+	SampleDegreeDistribution(ctx context.Context, sampleSize int) (map[int]int, error)
+	HasActors(ctx context.Context) (bool, error)
+	TopActors(ctx context.Context, offset, limit int) ([]graph.RankedActor, error)
+	GetRandomConnectedPair(ctx context.Context) (int, int, error)
+	DailyPair(ctx context.Context, date time.Time) (*graph.DailyChallenge, error)
+	Ready(ctx context.Context) error
+	DeleteMovieEdges(ctx context.Context, movieTmdbID int) (int, error)
+	IngestMovieCast(ctx context.Context, movie models.Movie, cast []models.Actor) error
+}
+
+var _ graphStore = (*graph.Driver)(nil)
+
+// tmdbSource is the part of tmdb.Client the handlers use, faked in tests the
+// same way.
+type tmdbSource interface {
+	GetMovie(ctx context.Context, movieID int) (models.Movie, error)
+	GetMovieCast(ctx context.Context, movieID, maxCast int) ([]models.Actor, error)
+	GetActorDetails(ctx context.Context, actorID int) (models.ActorDetails, error)
+}
+
+var _ tmdbSource = (*tmdb.Client)(nil)
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/internal/tmdb"
+)
+
+// fakeStore is a graphStore whose methods are set per test. Methods left
+// unset panic through the nil embedded interface, flagging an unexpected
+// database call.
+type fakeStore struct {
+	graphStore
+	shortestPath func(ctx context.Context, a, b int) ([]graph.PathStep, error)
+	chronoPath   func(ctx context.Context, a, b int) ([]graph.PathStep, error)
+	distance     func(ctx context.Context, a, b, maxHops int) (int, error)
+	randomPair   func(ctx context.Context) (int, int, error)
+	dailyPair    func(ctx context.Context, date time.Time) (*graph.DailyChallenge, error)
+	costars      func(ctx context.Context, id, limit int) ([]graph.Costar, error)
+	getActor     func(ctx context.Context, id int) (*graph.ActorProfile, error)
+	actorByID    func(ctx context.Context, id int) (*models.Actor, error)
+	chain        func(ctx context.Context, ids []int) ([]graph.PathStep, error)
+	stats        func(ctx context.Context) (*graph.Stats, error)
+	distribution func(ctx context.Context, sampleSize int) (map[int]int, error)
+	topActors    func(ctx context.Context, offset, limit int) ([]graph.RankedActor, error)
+	search       func(ctx context.Context, prefix string, limit int) ([]graph.SearchResult, error)
+	network      func(ctx context.Context, id, maxDegrees, limit int) ([]graph.NetworkGroup, error)
+	moviePath    func(ctx context.Context, actorID, movieID int) ([]graph.PathStep, error)
+	searchMovies func(ctx context.Context, query string, limit int) ([]models.Movie, error)
+	deleteMovie  func(ctx context.Context, movieID int) (int, error)
+	ingestCast   func(ctx context.Context, movie models.Movie, cast []models.Actor) error
+}
+
+func (f *fakeStore) SearchActors(ctx context.Context, prefix string, limit int) ([]graph.SearchResult, error) {
+	return f.search(ctx, prefix, limit)
+}
+
+func (f *fakeStore) ShortestPath(ctx context.Context, a, b int) ([]graph.PathStep, error) {
+	return f.shortestPath(ctx, a, b)
+}
+
+func (f *fakeStore) ChronologicalPath(ctx context.Context, a, b int) ([]graph.PathStep, error) {
+	return f.chronoPath(ctx, a, b)
+}
+
+func (f *fakeStore) ShortestPathActorToMovie(ctx context.Context, actorID, movieID int) ([]graph.PathStep, error) {
+	return f.moviePath(ctx, actorID, movieID)
+}
+
+func (f *fakeStore) SearchMovies(ctx context.Context, query string, limit int) ([]models.Movie, error) {
+	return f.searchMovies(ctx, query, limit)
+}
+
+func (f *fakeStore) Distance(ctx context.Context, a, b, maxHops int) (int, error) {
+	return f.distance(ctx, a, b, maxHops)
+}
+
+func (f *fakeStore) TopActors(ctx context.Context, offset, limit int) ([]graph.RankedActor, error) {
+	return f.topActors(ctx, offset, limit)
+}
+
+func (f *fakeStore) GetRandomConnectedPair(ctx context.Context) (int, int, error) {
+	return f.randomPair(ctx)
+}
+
+func (f *fakeStore) GetCostars(ctx context.Context, id, limit int) ([]graph.Costar, error) {
+	return f.costars(ctx, id, limit)
+}
+
+func (f *fakeStore) ActorsWithinDegrees(ctx context.Context, id, maxDegrees, limit int) ([]graph.NetworkGroup, error) {
+	return f.network(ctx, id, maxDegrees, limit)
+}
+
+func (f *fakeStore) GetActor(ctx context.Context, id int) (*graph.ActorProfile, error) {
+	return f.getActor(ctx, id)
+}
+
+func (f *fakeStore) GetActorByID(ctx context.Context, id int) (*models.Actor, error) {
+	return f.actorByID(ctx, id)
+}
+
+func (f *fakeStore) Chain(ctx context.Context, ids []int) ([]graph.PathStep, error) {
+	return f.chain(ctx, ids)
+}
+
+func (f *fakeStore) GetStats(ctx context.Context) (*graph.Stats, error) {
+	return f.stats(ctx)
+}
+
+func (f *fakeStore) SampleDegreeDistribution(ctx context.Context, sampleSize int) (map[int]int, error) {
+	return f.distribution(ctx, sampleSize)
+}
+
+func (f *fakeStore) DailyPair(ctx context.Context, date time.Time) (*graph.DailyChallenge, error) {
+	return f.dailyPair(ctx, date)
+}
+
+func (f *fakeStore) DeleteMovieEdges(ctx context.Context, movieID int) (int, error) {
+	return f.deleteMovie(ctx, movieID)
+}
+
+func (f *fakeStore) IngestMovieCast(ctx context.Context, movie models.Movie, cast []models.Actor) error {
+	return f.ingestCast(ctx, movie, cast)
+}
+
+// sleepUntilDone stands in for a query that outlives any deadline.
+func sleepUntilDone(ctx context.Context, _, _ int) ([]graph.PathStep, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// fakeTMDb stands in for the TMDb client with Fight Club and Brad Pitt as
+// the only movie and actor it knows.
+type fakeTMDb struct {
+	castErr    error
+	detailsErr error
+	// cast, when set, is the cast returned in place of Fight Club's.
+	cast    []models.Actor
+	maxCast int // the last maxCast asked for
+	// detailsCalls and movieCalls count GetActorDetails and GetMovie calls.
+	detailsCalls int
+	movieCalls   int
+	movieErr     error
+}
+
+func (f *fakeTMDb) GetMovie(_ context.Context, id int) (models.Movie, error) {
+	f.movieCalls++
+	if f.movieErr != nil {
+		return models.Movie{}, f.movieErr
+	}
+	if id != 550 {
+		return models.Movie{}, tmdb.ErrNotFound
+	}
+	return models.Movie{TmdbID: 550, Title: "Fight Club", Year: 1999, PosterPath: "/fight.jpg"}, nil
+}
+
+func (f *fakeTMDb) GetMovieCast(_ context.Context, _, maxCast int) ([]models.Actor, error) {
+	f.maxCast = maxCast
+	if f.castErr != nil {
+		return nil, f.castErr
+	}
+	if f.cast != nil {
+		return f.cast, nil
+	}
+	return []models.Actor{{TmdbID: 287, Name: "Brad Pitt"}, {TmdbID: 819, Name: "Edward Norton"}, {TmdbID: 1283, Name: "Helena Bonham Carter"}}, nil
+}
+
+func (f *fakeTMDb) GetActorDetails(_ context.Context, id int) (models.ActorDetails, error) {
+	f.detailsCalls++
+	if f.detailsErr != nil {
+		return models.ActorDetails{}, f.detailsErr
+	}
+	if id != 287 {
+		return models.ActorDetails{}, tmdb.ErrNotFound
+	}
+	return models.ActorDetails{Biography: "William Bradley Pitt is an American actor.", ProfilePath: "/pitt.jpg"}, nil
+}
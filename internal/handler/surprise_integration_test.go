@@ -0,0 +1,52 @@
+//go:build integration
+
+package handler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph"
+	"github.com/mark-c-hall/degrees-of-separation/internal/graph/graphtest"
+	"github.com/mark-c-hall/degrees-of-separation/internal/models"
+	"github.com/mark-c-hall/degrees-of-separation/web"
+)
+
+func TestSurprise_ConnectedGraph(t *testing.T) {
+	ctx := context.Background()
+	db, stop, err := graphtest.Start(ctx, graph.NewDriver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stop)
+
+	// A chain 1 - 2 - 3, so every random pair is connected.
+	for id := 1; id <= 3; id++ {
+		db.UpsertActor(ctx, models.Actor{TmdbID: id, Name: "Actor " + string(rune('A'+id-1))})
+	}
+	db.CreateCostarEdge(ctx, 1, 2, models.Movie{TmdbID: 100, Title: "Movie One", Year: 2000})
+	db.CreateCostarEdge(ctx, 2, 3, models.Movie{TmdbID: 101, Title: "Movie Two", Year: 2010})
+
+	h, err := NewHandler(db, nil, web.FS, testServerConfig(), slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	t.Cleanup(h.Close)
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/surprise", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, "of separation") || strings.Contains(body, "too sparse") {
+			t.Errorf("expected a path between two of the actors\n%s", body)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"html/template"
+	"io"
+	iofs "io/fs"
+)
+
+// devWebDir is where DEV_MODE reads templates from, relative to the working
+// directory the server is started in (the repository root).
+const devWebDir = "web"
+
+// templateProvider supplies the template set for each render, so handlers
+// don't care whether templates are baked into the binary or read from disk.
+type templateProvider interface {
+	Templates() (*template.Template, error)
+}
+
+// embeddedTemplates serves a set parsed once at startup.
+type embeddedTemplates struct {
+	tmpl *template.Template
+}
+
+func (p embeddedTemplates) Templates() (*template.Template, error) {
+	return p.tmpl, nil
+}
+
+// diskTemplates re-parses fsys on every render so template edits show up on
+// the next request without a rebuild. Parsing per request is far too slow
+// for production, which is why it's only used in dev mode.
+type diskTemplates struct {
+	fsys     iofs.FS
+	images   tmdbImages
+	basePath string
+}
+
+func (p diskTemplates) Templates() (*template.Template, error) {
+	return parseTemplates(p.fsys, p.images, p.basePath)
+}
+
+// execute renders the named template from the current set into w.
+func (h *Handler) execute(w io.Writer, name string, data any) error {
+	tmpl, err := h.templates.Templates()
+	if err != nil {
+		return err
+	}
+	return tmpl.ExecuteTemplate(w, name, data)
+}
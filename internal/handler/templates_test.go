@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark-c-hall/degrees-of-separation/web"
+)
+
+func writeTemplate(t *testing.T, dir, name, body string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmbeddedTemplates(t *testing.T) {
+	tmpl := template.Must(parseTemplates(web.FS, tmdbImages{}, ""))
+	h := &Handler{templates: embeddedTemplates{tmpl: tmpl}}
+
+	var buf bytes.Buffer
+	if err := h.execute(&buf, "search.html", nil); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	got, _ := h.templates.Templates()
+	if got != tmpl {
+		t.Error("expected the embedded set to be parsed once and reused")
+	}
+}
+
+func TestDiskTemplates_PicksUpChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "templates/base.html", `<h1>Degrees</h1>`)
+	writeTemplate(t, dir, "templates/fragments/search.html", `{{define "search.html"}}first draft{{end}}`)
+	h := &Handler{templates: diskTemplates{fsys: os.DirFS(dir)}}
+
+	render := func() string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := h.execute(&buf, "search.html", nil); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		return buf.String()
+	}
+
+	if got := render(); got != "first draft" {
+		t.Fatalf("expected the template on disk, got %q", got)
+	}
+	writeTemplate(t, dir, "templates/fragments/search.html", `{{define "search.html"}}second draft{{end}}`)
+	if got := render(); got != "second draft" {
+		t.Errorf("expected the edit to show up on the next render, got %q", got)
+	}
+
+	// A broken edit is reported rather than serving a stale copy.
+	writeTemplate(t, dir, "templates/fragments/search.html", `{{define "search.html"}}{{.Oops{{end}}`)
+	if err := h.execute(io.Discard, "search.html", nil); err == nil {
+		t.Error("expected a parse error from the broken template")
+	}
+}
+
+func TestNewHandler_DevModeNeedsTemplatesOnDisk(t *testing.T) {
+	// Tests run in internal/handler, which has no web/ directory.
+	cfg := testServerConfig()
+	cfg.DevMode = true
+	_, err := NewHandler(nil, nil, web.FS, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if err == nil || !strings.Contains(err.Error(), "dev mode") {
+		t.Errorf("expected dev mode to fail without web/ on disk, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errInvalidActorID is shared by every handler that takes an actor id so the
+// UI shows the same message wherever a bad id comes from.
+var errInvalidActorID = badRequest("actor ids must be positive whole numbers")
+
+// parseActorID accepts TMDb person ids: positive and within int32, which is
+// all TMDb issues and keeps huge values out of Cypher parameters.
+func parseActorID(s string) (int, error) {
+	id, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || id <= 0 || id > math.MaxInt32 {
+		return 0, errInvalidActorID
+	}
+	return int(id), nil
+}
+
+// cleanQuery strips control characters and surrounding space from a search
+// query, then rejects it if it is longer than maxLen characters.
+func cleanQuery(q string, maxLen int) (string, error) {
+	q = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, q))
+	if utf8.RuneCountInString(q) > maxLen {
+		return "", badRequest(fmt.Sprintf("search is limited to %d characters", maxLen))
+	}
+	return q, nil
+}
+
+// parseFlag accepts the values a boolean query parameter like expand may take.
+func parseFlag(name, v string) (bool, error) {
+	switch v {
+	case "", "0":
+		return false, nil
+	case "1":
+		return true, nil
+	default:
+		return false, badRequest(name + " must be 0 or 1")
+	}
+}
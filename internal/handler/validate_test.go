@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestValidation_Rejections(t *testing.T) {
+	h := newTestHandler(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantMsg    string
+	}{
+		{"query too long", "/search?q=" + strings.Repeat("a", 101), http.StatusBadRequest, "search is limited to 100 characters"},
+		{"url too long", "/search?q=" + strings.Repeat("a", 5000), http.StatusRequestURITooLong, "request URL too long"},
+		{"multibyte query too long", "/search?q=" + url.QueryEscape(strings.Repeat("é", 101)), http.StatusBadRequest, "search is limited to 100 characters"},
+		{"negative actor id", "/degrees?a=-1&b=2", http.StatusBadRequest, "actor ids must be positive whole numbers"},
+		{"zero actor id", "/degrees?a=1&b=0", http.StatusBadRequest, "actor ids must be positive whole numbers"},
+		{"non-numeric actor id", "/degrees?a=kevin&b=2", http.StatusBadRequest, "actor ids must be positive whole numbers"},
+		{"fractional actor id", "/degrees?a=1.5&b=2", http.StatusBadRequest, "actor ids must be positive whole numbers"},
+		{"actor id beyond int32", "/degrees?a=2147483648&b=2", http.StatusBadRequest, "actor ids must be positive whole numbers"},
+		{"api negative id", "/api/v1/path/graph?a=-5&b=1", http.StatusBadRequest, "a and b must be positive actor ids"},
+		{"api missing id", "/api/v1/path/graph?a=5", http.StatusBadRequest, "a and b must be positive actor ids"},
+		{"api bad expand", "/api/v1/path/graph?a=1&b=2&expand=yes", http.StatusBadRequest, "expand must be 0 or 1"},
+		{"profile negative id", "/actor/-1", http.StatusNotFound, "actor not found"},
+		{"profile id beyond int32", "/actor/99999999999", http.StatusNotFound, "actor not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantMsg) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantMsg, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCleanQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Kevin Bacon", "Kevin Bacon"},
+		{"  Kevin\x00 Bacon\n", "Kevin Bacon"},
+		{"\x01\x02\x7f", ""},
+		{"Penélope\u200b Cruz", "Penélope\u200b Cruz"}, // format characters aren't control characters
+	}
+	for _, tt := range tests {
+		got, err := cleanQuery(tt.in, 100)
+		if err != nil {
+			t.Errorf("cleanQuery(%q): unexpected error %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("cleanQuery(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearch_ControlCharsOnlyRendersEmptyForm(t *testing.T) {
+	h := newTestHandler(t)
+
+	// Nothing is left after stripping, so the handler must not reach the
+	// (nil) database.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=%01%02%1b", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+}
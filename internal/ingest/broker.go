@@ -0,0 +1,126 @@
+// Package ingest streams progress from a running ingest to anyone watching.
+// The ingest loop publishes events to a Broker, which fans them out to
+// server-sent event subscribers without ever making the loop wait on them.
+package ingest
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EventKind names an Event; it is also the SSE event name.
+type EventKind string
+
+const (
+	EventPage  EventKind = "page"  // a page of movies was fetched
+	EventMovie EventKind = "movie" // a movie's cast was ingested
+	EventError EventKind = "error" // a page or movie was skipped
+	EventDone  EventKind = "done"  // the run finished or was interrupted
+)
+
+// Event is one step of an ingest run. Which fields are set depends on Kind.
+type Event struct {
+	Kind      EventKind `json:"kind"`
+	Page      int       `json:"page,omitempty"`
+	FirstPage int       `json:"first_page,omitempty"` // page events: the run's page range
+	LastPage  int       `json:"last_page,omitempty"`
+	Position  int       `json:"position,omitempty"` // movie events: 1-based index within the page
+	PageSize  int       `json:"page_size,omitempty"`
+	MovieID   int       `json:"movie_id,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Movies    int       `json:"movies,omitempty"` // done events: movies ingested
+}
+
+// Broker fans published events out to subscribers. Publish never blocks: a
+// subscriber whose buffer is full misses the event. All methods are safe to
+// call on a nil *Broker, which publishes nothing.
+type Broker struct {
+	mu      sync.Mutex
+	subs    map[chan Event]struct{}
+	closed  bool
+	dropped atomic.Int64
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[chan Event]struct{})}
+}
+
+// Subscribe registers a subscriber with room for buffer pending events. The
+// returned cancel func unsubscribes; the channel is closed by cancel or by
+// Close, whichever comes first.
+func (b *Broker) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	if b == nil {
+		close(ch)
+		return ch, func() {}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+	return ch, func() { b.unsubscribe(ch) }
+}
+
+func (b *Broker) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Publish hands e to every subscriber with buffer space and drops it for the
+// rest.
+func (b *Broker) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns how many deliveries have been skipped because a subscriber
+// was too slow.
+func (b *Broker) Dropped() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.dropped.Load()
+}
+
+// Close ends every subscription and makes later ones end immediately, so
+// streaming handlers return on shutdown.
+func (b *Broker) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// subscribers returns the number of live subscriptions.
+func (b *Broker) subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
@@ -0,0 +1,104 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBroker_FanOut(t *testing.T) {
+	b := NewBroker()
+	a, cancelA := b.Subscribe(4)
+	defer cancelA()
+	c, cancelC := b.Subscribe(4)
+	defer cancelC()
+
+	b.Publish(Event{Kind: EventPage, Page: 1})
+	b.Publish(Event{Kind: EventMovie, Page: 1, Position: 1})
+
+	for name, ch := range map[string]<-chan Event{"a": a, "c": c} {
+		for _, want := range []EventKind{EventPage, EventMovie} {
+			select {
+			case e := <-ch:
+				if e.Kind != want {
+					t.Errorf("subscriber %s: expected %s, got %s", name, want, e.Kind)
+				}
+			default:
+				t.Fatalf("subscriber %s: expected a %s event to be waiting", name, want)
+			}
+		}
+	}
+}
+
+func TestBroker_SlowSubscriberDropsInsteadOfBlocking(t *testing.T) {
+	b := NewBroker()
+	slow, cancelSlow := b.Subscribe(1)
+	defer cancelSlow()
+	fast, cancelFast := b.Subscribe(10)
+	defer cancelFast()
+
+	published := make(chan struct{})
+	go func() {
+		for i := range 5 {
+			b.Publish(Event{Kind: EventMovie, Position: i + 1})
+		}
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a subscriber that isn't reading")
+	}
+
+	if e := <-slow; e.Position != 1 {
+		t.Errorf("slow subscriber: expected the first event to be kept, got position %d", e.Position)
+	}
+	select {
+	case e := <-slow:
+		t.Errorf("slow subscriber: expected later events to be dropped, got position %d", e.Position)
+	default:
+	}
+	if got := b.Dropped(); got != 4 {
+		t.Errorf("expected 4 dropped deliveries, got %d", got)
+	}
+
+	// A slow subscriber doesn't cost the others anything.
+	if len(fast) != 5 {
+		t.Errorf("fast subscriber: expected all 5 events, got %d", len(fast))
+	}
+}
+
+func TestBroker_NoSubscribers(t *testing.T) {
+	b := NewBroker()
+	b.Publish(Event{Kind: EventPage})
+	if got := b.Dropped(); got != 0 {
+		t.Errorf("expected nothing dropped with no subscribers, got %d", got)
+	}
+
+	var nilBroker *Broker
+	nilBroker.Publish(Event{Kind: EventPage})
+	nilBroker.Close()
+}
+
+func TestBroker_CancelAndClose(t *testing.T) {
+	b := NewBroker()
+	ch, cancel := b.Subscribe(1)
+	cancel()
+	cancel() // idempotent
+	if _, ok := <-ch; ok {
+		t.Error("expected a cancelled subscription to be closed")
+	}
+
+	ch, _ = b.Subscribe(1)
+	b.Close()
+	if _, ok := <-ch; ok {
+		t.Error("expected Close to end open subscriptions")
+	}
+	if n := b.subscribers(); n != 0 {
+		t.Errorf("expected no subscribers after Close, got %d", n)
+	}
+
+	ch, _ = b.Subscribe(1)
+	if _, ok := <-ch; ok {
+		t.Error("expected subscribing after Close to return a closed channel")
+	}
+}
@@ -0,0 +1,91 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	iofs "io/fs"
+	"net/http"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/version"
+)
+
+// subscriberBuffer is how many events a slow browser may fall behind by
+// before it starts missing them.
+const subscriberBuffer = 64
+
+// keepAliveInterval spaces out SSE comments sent while the ingest is quiet, so
+// proxies don't close an idle stream.
+const keepAliveInterval = 15 * time.Second
+
+// ServeHTTP streams events to the client as server-sent events until the
+// client disconnects or the broker is closed.
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	events, cancel := b.Subscribe(subscriberBuffer)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// NewAdminHandler serves the ingest progress page at /admin/ingest, its event
+// stream at /admin/ingest/events, and the site's static assets. fsys is the
+// web package's embedded filesystem.
+func NewAdminHandler(b *Broker, fsys iofs.FS) (http.Handler, error) {
+	build := version.Get().String()
+	tmpl, err := template.New("").Funcs(template.FuncMap{
+		"version": func() string { return build },
+		// The progress page is short-lived and its file server sets no cache
+		// headers, so plain asset URLs do.
+		"asset": func(name string) string { return "/static/" + name },
+		"url":   func(path string) string { return path },
+	}).ParseFS(fsys, "templates/layout.html", "templates/ingest_page.html", "templates/fragments/ingest.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ingest templates: %w", err)
+	}
+	staticFS, err := iofs.Sub(fsys, "static")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create static sub-filesystem: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(staticFS)))
+	mux.Handle("GET /admin/ingest/events", b)
+	mux.HandleFunc("GET /admin/ingest", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(w, "ingest_page.html", nil); err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	})
+	return mux, nil
+}
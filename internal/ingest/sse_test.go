@@ -0,0 +1,94 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/web"
+)
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBroker_ServeHTTP(t *testing.T) {
+	b := NewBroker()
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("connecting failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", ct)
+	}
+
+	waitFor(t, "the stream to subscribe", func() bool { return b.subscribers() == 1 })
+	b.Publish(Event{Kind: EventMovie, Page: 2, Position: 3, PageSize: 20, Title: "Tremors"})
+
+	lines := bufio.NewScanner(resp.Body)
+	var got []string
+	for len(got) < 2 && lines.Scan() {
+		got = append(got, lines.Text())
+	}
+	if len(got) != 2 || got[0] != "event: movie" || !strings.HasPrefix(got[1], "data: {") || !strings.Contains(got[1], `"title":"Tremors"`) {
+		t.Errorf("unexpected event framing: %q", got)
+	}
+
+	// A client that goes away is unsubscribed.
+	cancel()
+	waitFor(t, "the disconnected client to unsubscribe", func() bool { return b.subscribers() == 0 })
+}
+
+func TestBroker_ServeHTTPEndsOnClose(t *testing.T) {
+	b := NewBroker()
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		b.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/ingest/events", nil))
+		close(done)
+	}()
+
+	waitFor(t, "the stream to subscribe", func() bool { return b.subscribers() == 1 })
+	b.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream kept running after the broker closed")
+	}
+}
+
+func TestNewAdminHandler(t *testing.T) {
+	h, err := NewAdminHandler(NewBroker(), web.FS)
+	if err != nil {
+		t.Fatalf("NewAdminHandler failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/ingest", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	for _, want := range []string{`<progress id="ingest-bar"`, "new EventSource('/admin/ingest/events')"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("progress page missing %q", want)
+		}
+	}
+}
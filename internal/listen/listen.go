@@ -0,0 +1,114 @@
+// Package listen opens the server's listener: a TCP address, a unix socket
+// for a reverse proxy on the same host, or a socket handed over by systemd
+// socket activation.
+package listen
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// firstInheritedFD is where systemd places the first passed socket, after
+// stdin, stdout and stderr.
+const firstInheritedFD = 3
+
+// Config says where to listen when no socket is inherited.
+type Config struct {
+	Network string // "tcp" or "unix"
+	Addr    string
+	// SocketMode is applied to a unix socket once it is created.
+	SocketMode fs.FileMode
+}
+
+// Listen returns the socket systemd passed in, if the process was started
+// with one, and otherwise opens cfg's. Closing a unix socket listener it
+// opened removes the socket file, so the server's shutdown cleans up after
+// itself.
+func Listen(cfg Config) (net.Listener, error) {
+	l, err := inherited(os.Getenv, os.Getpid(), firstInheritedFD)
+	if err != nil || l != nil {
+		// The variables are meant for this process only.
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+		return l, err
+	}
+
+	if cfg.Network != "unix" {
+		l, err := net.Listen(cfg.Network, cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("error listening on %s: %w", cfg.Addr, err)
+		}
+		return l, nil
+	}
+	return listenUnix(cfg.Addr, cfg.SocketMode)
+}
+
+// inherited returns the first socket passed by systemd socket activation, or
+// nil when LISTEN_PID doesn't name this process. Only one socket is used.
+func inherited(getenv func(string) string, pid int, fd uintptr) (net.Listener, error) {
+	if getenv("LISTEN_PID") != strconv.Itoa(pid) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("error using inherited socket: LISTEN_FDS is %q", getenv("LISTEN_FDS"))
+	}
+
+	f := os.NewFile(fd, "inherited socket")
+	defer f.Close() // FileListener holds its own copy
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("error using inherited socket: %w", err)
+	}
+	return l, nil
+}
+
+// listenUnix creates a unix socket at path with the given mode. A socket file
+// left by a server that didn't shut down cleanly is removed first; one that
+// still accepts connections means another server is running.
+func listenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("error setting permissions on %s: %w", path, err)
+	}
+	return l, nil
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error checking %s: %w", path, err)
+	}
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("error listening on %s: the file exists and is not a socket", path)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("error listening on %s: another server is accepting connections on it", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("error checking %s: %w", path, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("error removing stale socket %s: %w", path, err)
+	}
+	return nil
+}
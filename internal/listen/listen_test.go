@@ -0,0 +1,155 @@
+package listen
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// unixClient makes every request over the socket at path, whatever the URL's
+// host.
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestListen_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "degrees.sock")
+	l, err := Listen(Config{Network: "unix", Addr: path, SocketMode: 0o600})
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected the socket file: %v", err)
+	}
+	if info.Mode().Type() != fs.ModeSocket || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected a socket with mode 0600, got %v", info.Mode())
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "over the socket")
+	})}
+	go srv.Serve(l)
+
+	resp, err := unixClient(path).Get("http://degrees/")
+	if err != nil {
+		t.Fatalf("request over the socket failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "over the socket" {
+		t.Errorf("expected the handler's response, got %q", body)
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected shutdown to remove the socket file, got %v", err)
+	}
+}
+
+func TestListen_RemovesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "degrees.sock")
+
+	// A server that died without cleaning up leaves its socket file behind.
+	old, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("net.Listen failed: %v", err)
+	}
+	old.(*net.UnixListener).SetUnlinkOnClose(false)
+	old.Close()
+
+	l, err := Listen(Config{Network: "unix", Addr: path, SocketMode: 0o660})
+	if err != nil {
+		t.Fatalf("expected the stale socket replaced, got %v", err)
+	}
+	l.Close()
+}
+
+func TestListen_RefusesLiveSocketOrOtherFile(t *testing.T) {
+	dir := t.TempDir()
+
+	live := filepath.Join(dir, "live.sock")
+	other, err := net.Listen("unix", live)
+	if err != nil {
+		t.Fatalf("net.Listen failed: %v", err)
+	}
+	defer other.Close()
+	if l, err := Listen(Config{Network: "unix", Addr: live}); err == nil {
+		l.Close()
+		t.Error("expected an error while another server holds the socket")
+	}
+
+	file := filepath.Join(dir, "notes.txt")
+	os.WriteFile(file, []byte("keep me"), 0o600)
+	if l, err := Listen(Config{Network: "unix", Addr: file}); err == nil {
+		l.Close()
+		t.Error("expected an error for a path that isn't a socket")
+	}
+	if b, _ := os.ReadFile(file); string(b) != "keep me" {
+		t.Error("a regular file must never be removed")
+	}
+}
+
+func TestListen_TCP(t *testing.T) {
+	l, err := Listen(Config{Network: "tcp", Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer l.Close()
+	if l.Addr().Network() != "tcp" {
+		t.Errorf("expected a tcp listener, got %s", l.Addr().Network())
+	}
+}
+
+func TestInherited(t *testing.T) {
+	// Stand in for a socket systemd opened by duplicating one of our own.
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen failed: %v", err)
+	}
+	defer tcp.Close()
+	f, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("File failed: %v", err)
+	}
+
+	pid := os.Getpid()
+	env := map[string]string{"LISTEN_PID": strconv.Itoa(pid), "LISTEN_FDS": "1"}
+	l, err := inherited(func(k string) string { return env[k] }, pid, f.Fd())
+	if err != nil {
+		t.Fatalf("inherited failed: %v", err)
+	}
+	defer l.Close()
+	if l.Addr().String() != tcp.Addr().String() {
+		t.Errorf("expected the passed socket at %s, got %s", tcp.Addr(), l.Addr())
+	}
+
+	// Variables meant for another process, or none at all, are ignored.
+	env["LISTEN_PID"] = strconv.Itoa(pid + 1)
+	if l, err := inherited(func(k string) string { return env[k] }, pid, 0); l != nil || err != nil {
+		t.Errorf("expected nothing inherited for another pid, got %v, %v", l, err)
+	}
+	if l, err := inherited(func(string) string { return "" }, pid, 0); l != nil || err != nil {
+		t.Errorf("expected nothing inherited without socket activation, got %v, %v", l, err)
+	}
+
+	env["LISTEN_PID"], env["LISTEN_FDS"] = strconv.Itoa(pid), "0"
+	if _, err := inherited(func(k string) string { return env[k] }, pid, 0); err == nil {
+		t.Error("expected an error when LISTEN_FDS passes no sockets")
+	}
+}
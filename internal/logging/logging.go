@@ -0,0 +1,23 @@
+// Package logging builds the slog loggers the server and ingest write with.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// New returns a logger writing records at or above level to w, as JSON or
+// logfmt-style text per format. The level is read on every record, so
+// setting it changes what the logger lets through without rebuilding it.
+func New(w io.Writer, format string, level *slog.LevelVar) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("log format must be json or text, got %q", format)
+	}
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew_LevelFilter(t *testing.T) {
+	var out bytes.Buffer
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+	logger, err := New(&out, "text", level)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+	if got := out.String(); strings.Contains(got, "dropped") || !strings.Contains(got, "kept") {
+		t.Errorf("expected only the warning at warn level, got %q", got)
+	}
+
+	out.Reset()
+	level.Set(slog.LevelDebug)
+	logger.Debug("now kept")
+	if !strings.Contains(out.String(), "now kept") {
+		t.Errorf("expected lowering the level to let debug through, got %q", out.String())
+	}
+}
+
+func TestNew_Formats(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "json", new(slog.LevelVar))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	logger.Info("ingested cast", "movie_id", 550)
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("expected a JSON record, got %q: %v", out.String(), err)
+	}
+	if record["msg"] != "ingested cast" || record["movie_id"] != float64(550) {
+		t.Errorf("unexpected record %v", record)
+	}
+
+	out.Reset()
+	logger, _ = New(&out, "text", new(slog.LevelVar))
+	logger.Info("ingested cast", "movie_id", 550)
+	if got := out.String(); !strings.Contains(got, `msg="ingested cast" movie_id=550`) {
+		t.Errorf("unexpected text record %q", got)
+	}
+
+	if _, err := New(&out, "xml", new(slog.LevelVar)); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,106 @@
+// Package metrics exposes request, query, and rate-limit series in the
+// Prometheus text format. It is hand-rolled to avoid pulling client_golang
+// in alongside the OTel SDK, which already pushes the same data over OTLP.
+package metrics
+
+import (
+	"bytes"
+	"expvar"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// expvar is process-global, so the query counters are too: every Metrics
+// adds to the same maps, shown at /debug/vars when debug endpoints are on.
+var (
+	queryCounts      = expvar.NewMap("neo4j_queries")
+	queryErrorCounts = expvar.NewMap("neo4j_query_errors")
+)
+
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// sizeBuckets run from an empty 304 up to a large stats or path page.
+var sizeBuckets = []float64{100, 1000, 10_000, 100_000, 1_000_000}
+
+// Metrics owns the registry and the series recorded by the server. All
+// methods are safe to call on a nil *Metrics, which records nothing.
+type Metrics struct {
+	registry        *Registry
+	requests        *CounterVec
+	requestDuration *HistogramVec
+	responseSize    *HistogramVec
+	queryDuration   *HistogramVec
+	queryErrors     *CounterVec
+	rateLimited     *CounterVec
+}
+
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		registry: r,
+		requests: r.NewCounterVec("http_requests_total",
+			"Total HTTP requests by method, route, and status.", "method", "route", "status"),
+		requestDuration: r.NewHistogramVec("http_request_duration_seconds",
+			"HTTP request latency by method and route.", latencyBuckets, "method", "route"),
+		responseSize: r.NewHistogramVec("http_response_size_bytes",
+			"HTTP response body size as sent, by method and route.", sizeBuckets, "method", "route"),
+		queryDuration: r.NewHistogramVec("neo4j_query_duration_seconds",
+			"Duration of Neo4j queries by query name.", latencyBuckets, "query"),
+		queryErrors: r.NewCounterVec("neo4j_query_errors_total",
+			"Neo4j queries that returned an error, by query name.", "query"),
+		rateLimited: r.NewCounterVec("http_rate_limited_total",
+			"Requests rejected by the per-IP rate limiter, by route.", "route"),
+	}
+}
+
+// ObserveRequest records one completed HTTP request and the number of body
+// bytes it wrote. route should be the mux pattern, not the raw path, to keep
+// label cardinality bounded.
+func (m *Metrics) ObserveRequest(method, route string, status, size int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.requests.Inc(method, route, strconv.Itoa(status))
+	m.requestDuration.Observe(elapsed.Seconds(), method, route)
+	m.responseSize.Observe(float64(size), method, route)
+}
+
+// ObserveQuery matches graph.QueryHook so it can be installed on the driver.
+// Counts by query name are also published through expvar.
+func (m *Metrics) ObserveQuery(name string, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.queryDuration.Observe(elapsed.Seconds(), name)
+	queryCounts.Add(name, 1)
+	if err != nil {
+		m.queryErrors.Inc(name)
+		queryErrorCounts.Add(name, 1)
+	}
+}
+
+// IncRateLimited counts a rejected request. The client isn't a label, since
+// series never expire and every address rejected would add one for good; the
+// rate limiter logs it instead.
+func (m *Metrics) IncRateLimited(route string) {
+	if m == nil {
+		return
+	}
+	m.rateLimited.Inc(route)
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var buf bytes.Buffer
+		if m != nil {
+			if err := m.registry.Write(&buf); err != nil {
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buf.WriteTo(w)
+	})
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuth(t *testing.T) {
+	tokens := []Token{{Name: "ci", Secret: "s3cret"}, {Name: "ops", Secret: "other"}}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		tokens        []Token
+		authorization string
+		wantStatus    int
+	}{
+		{"missing", tokens, "", http.StatusUnauthorized},
+		{"not bearer", tokens, "Basic czNjcmV0", http.StatusUnauthorized},
+		{"empty bearer", tokens, "Bearer ", http.StatusUnauthorized},
+		{"wrong", tokens, "Bearer s3cre", http.StatusForbidden},
+		{"valid", tokens, "Bearer s3cret", http.StatusOK},
+		{"valid lowercase scheme", tokens, "bearer other", http.StatusOK},
+		{"none configured", nil, "Bearer s3cret", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/reindex", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			Auth(tt.tokens)(ok).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			challenge := rec.Header().Get("WWW-Authenticate")
+			if (tt.wantStatus == http.StatusUnauthorized) != (challenge == "Bearer") {
+				t.Errorf("expected a Bearer challenge only on 401, got %q", challenge)
+			}
+		})
+	}
+}
+
+func TestAuth_JSONForAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Auth(nil)(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/path/graph", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected application/json, got %q", ct)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["error"] != "unauthorized" {
+		t.Errorf("expected {\"error\":\"unauthorized\"}, got %v (%v)", body, err)
+	}
+}
+
+func TestAuth_LogsTokenName(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	h := Logging(logger, nil, LogSampling{})(
+		Auth([]Token{{Name: "ci", Secret: "s3cret"}})(http.NotFoundHandler()))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/reindex", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not JSON: %v\n%s", err, buf.String())
+	}
+	if line["token"] != "ci" {
+		t.Errorf("expected token=ci in the log line, got %v", line["token"])
+	}
+	if strings.Contains(buf.String(), "s3cret") {
+		t.Error("expected the secret to stay out of the log")
+	}
+}
+
+func TestTokenName(t *testing.T) {
+	name := TokenName("s3cret")
+	if !strings.HasPrefix(name, "sha256:") || len(name) != len("sha256:")+8 {
+		t.Errorf("expected a short sha256 fingerprint, got %q", name)
+	}
+	if name != TokenName("s3cret") || name == TokenName("other") {
+		t.Error("expected fingerprints to be stable and distinct")
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// IPResolver finds the client address of a request that may have come
+// through reverse proxies. Forwarding headers are only believed when the
+// connection comes from a trusted proxy; anyone else could set them to dodge
+// rate limits or forge log entries. A nil *IPResolver trusts no proxies.
+type IPResolver struct {
+	trusted []netip.Prefix
+	// trustUnix trusts whatever connects over a unix socket, which has no
+	// address to match against trusted.
+	trustUnix bool
+}
+
+// NewIPResolver returns a resolver trusting proxies in the trusted ranges
+// and, with trustUnix, any peer on a unix socket, such as a reverse proxy on
+// the same host.
+func NewIPResolver(trusted []netip.Prefix, trustUnix bool) *IPResolver {
+	return &IPResolver{trusted: trusted, trustUnix: trustUnix}
+}
+
+// ClientIP returns the client address for r. When RemoteAddr is a trusted
+// proxy it walks X-Forwarded-For right to left, skipping trusted hops, and
+// returns the first untrusted one. X-Real-IP is used only when there is no
+// X-Forwarded-For. A request over a unix socket has no usable RemoteAddr, so
+// unless its peer is trusted every such request resolves to the same client.
+func (res *IPResolver) ClientIP(r *http.Request) string {
+	if res != nil && res.trustUnix && overUnixSocket(r) {
+		if client, ok := res.forwarded(r); ok {
+			return client.String()
+		}
+		return r.RemoteAddr
+	}
+
+	remote, ok := parseHostIP(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !res.isTrusted(remote) {
+		return remote.String()
+	}
+	if client, ok := res.forwarded(r); ok {
+		return client.String()
+	}
+	return remote.String()
+}
+
+// forwarded finds the client in the forwarding headers of a request from a
+// trusted proxy. It reports false when they name no usable address.
+func (res *IPResolver) forwarded(r *http.Request) (netip.Addr, bool) {
+	hops := forwardedHops(r.Header.Values("X-Forwarded-For"))
+	if len(hops) == 0 {
+		return parseHostIP(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+	}
+
+	var client netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHostIP(hops[i])
+		if !ok {
+			// A hop a trusted proxy couldn't vouch for; stop at the last
+			// address we know to be real.
+			break
+		}
+		client = hop
+		if !res.isTrusted(hop) {
+			break
+		}
+	}
+	return client, client.IsValid()
+}
+
+// overUnixSocket reports whether r arrived on a unix socket listener.
+func overUnixSocket(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
+func (res *IPResolver) isTrusted(ip netip.Addr) bool {
+	if res == nil {
+		return false
+	}
+	for _, p := range res.trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHops flattens X-Forwarded-For, which may be repeated or
+// comma-separated, into one list in order of appearance.
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for hop := range strings.SplitSeq(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// parseHostIP accepts a bare IP or host:port, with IPv6 optionally bracketed,
+// and unmaps IPv4-in-IPv6 so both forms match the same prefixes.
+func parseHostIP(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	ip, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
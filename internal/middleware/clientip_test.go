@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"testing"
+)
+
+func TestIPResolver_ClientIP(t *testing.T) {
+	res := NewIPResolver([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}, false)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		realIP     string
+		want       string
+	}{
+		{
+			name:       "no proxy",
+			remoteAddr: "203.0.113.7:5123",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "spoofed headers from untrusted source",
+			remoteAddr: "203.0.113.7:5123",
+			xff:        []string{"198.51.100.1"},
+			realIP:     "198.51.100.2",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "single trusted proxy",
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "multiple trusted hops",
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"198.51.100.1, 10.1.2.3", "10.0.0.9"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "client-supplied entries left of the real client are ignored",
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"1.2.3.4, 198.51.100.1, 10.1.2.3"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "all hops trusted",
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"10.9.9.9, 10.1.2.3"},
+			want:       "10.9.9.9",
+		},
+		{
+			name:       "garbage hop stops the walk",
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"198.51.100.1, not-an-ip, 10.1.2.3"},
+			want:       "10.1.2.3",
+		},
+		{
+			name:       "x-real-ip without forwarded-for",
+			remoteAddr: "10.0.0.5:443",
+			realIP:     "198.51.100.3",
+			want:       "198.51.100.3",
+		},
+		{
+			name:       "ipv6 remote untrusted",
+			remoteAddr: "[2001:db8::1]:5123",
+			xff:        []string{"198.51.100.1"},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "ipv6 trusted proxy and client",
+			remoteAddr: "[fd12::1]:443",
+			xff:        []string{"2001:db8::abcd, fd12::2"},
+			want:       "2001:db8::abcd",
+		},
+		{
+			name:       "bracketed ipv6 hop with port",
+			remoteAddr: "[fd12::1]:443",
+			xff:        []string{"[2001:db8::abcd]:8080"},
+			want:       "2001:db8::abcd",
+		},
+		{
+			name:       "ipv4-mapped ipv6 proxy",
+			remoteAddr: "[::ffff:10.0.0.5]:443",
+			xff:        []string{"198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := res.ClientIP(req); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIPResolver_NilTrustsNothing(t *testing.T) {
+	var res *IPResolver
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.5:443"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	if got := res.ClientIP(req); got != "10.0.0.5" {
+		t.Errorf("expected the socket address 10.0.0.5, got %s", got)
+	}
+}
+
+func TestIPResolver_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	var res *IPResolver
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, res.ClientIP(r))
+	}))
+	srv.Listener.Close()
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	clientIP := func(xff string) string {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "http://proxy/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Forwarded-For", xff)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request over the socket failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("reading the body failed: %v", err)
+		}
+		return string(body)
+	}
+
+	res = NewIPResolver(nil, true)
+	if got := clientIP("198.51.100.1"); got != "198.51.100.1" {
+		t.Errorf("expected the forwarded client from a trusted unix peer, got %q", got)
+	}
+
+	res = NewIPResolver(nil, false)
+	if got, other := clientIP("198.51.100.1"), clientIP("198.51.100.2"); got != other || got == "198.51.100.1" {
+		t.Errorf("expected the headers ignored from an untrusted unix peer, got %q and %q", got, other)
+	}
+}
+
+func TestRateLimit_UsesResolvedClientIP(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /search", ok)
+	ips := NewIPResolver([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, false)
+	cfg := RateLimitConfig{Default: RatePolicy{Limit: 0.0001, Burst: 1, Cost: 1}}
+	limit, limiter := RateLimit(cfg, mux, ips, nil, nil)
+	t.Cleanup(limiter.Stop)
+	h := limit(mux)
+
+	serve := func(client string) int {
+		req := httptest.NewRequest(http.MethodGet, "/search", nil)
+		req.RemoteAddr = "10.0.0.5:443"
+		req.Header.Set("X-Forwarded-For", client)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("first client: expected 200, got %d", code)
+	}
+	// Behind the same proxy, a different client has its own budget.
+	if code := serve("198.51.100.2"); code != http.StatusOK {
+		t.Errorf("second client behind the same proxy: expected 200, got %d", code)
+	}
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(io.Discard) },
+}
+
+// Compress gzips responses for clients that send Accept-Encoding: gzip. Bodies
+// shorter than minSize and content types that are already compressed are sent
+// as is. Vary: Accept-Encoding is set on every response so shared caches keep
+// the gzip and identity representations apart.
+//
+// Place it inside Logging and Metrics: their statusResponseWriter then wraps
+// the compressing writer and records the status and bytes actually sent.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressResponseWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// compressResponseWriter buffers the first minSize bytes of a response before
+// choosing between gzip and identity encoding, since that choice changes the
+// headers and so has to be made before any of them are sent.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+	buf     []byte
+	started bool
+	gz      *gzip.Writer
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.started {
+		return
+	}
+	if code < 200 {
+		w.ResponseWriter.WriteHeader(code) // informational, the final status follows
+		return
+	}
+	w.status = code
+	if !bodyAllowed(code) {
+		w.start(false)
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush commits to an encoding without waiting for minSize, so streamed
+// responses reach the client as they are written.
+func (w *compressResponseWriter) Flush() {
+	if !w.started {
+		w.start(true)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// start sends the header and any buffered body. The response is gzipped when
+// compress is set and the status and content type make it worthwhile.
+func (w *compressResponseWriter) start(compress bool) error {
+	w.started = true
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	// A 206 body is a byte range of the identity encoding; gzipping it would
+	// make Content-Range meaningless.
+	if compress && w.status != http.StatusPartialContent &&
+		h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		// The gzip bytes differ from the identity ones, so a strong ETag
+		// no longer holds; weak comparison in If-None-Match still matches.
+		if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close sends a response still held below minSize uncompressed, or finishes
+// the gzip stream, and returns the gzip writer to the pool.
+func (w *compressResponseWriter) close() {
+	if !w.started {
+		w.start(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(io.Discard)
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// acceptsGzip reports whether an Accept-Encoding header lists gzip with a
+// non-zero quality value.
+func acceptsGzip(header string) bool {
+	for part := range strings.SplitSeq(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
+
+// compressible reports whether a content type is worth gzipping. Images,
+// audio, video and archive formats are already compressed.
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	switch mediaType {
+	case "":
+		return false
+	case "image/svg+xml":
+		return true
+	case "application/gzip", "application/x-gzip", "application/zip", "application/zstd",
+		"application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+		"application/octet-stream", "font/woff", "font/woff2":
+		return false
+	}
+	for _, prefix := range []string{"image/", "audio/", "video/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+	return true
+}
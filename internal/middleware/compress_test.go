@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testMinSize = 256
+
+func serveCompressed(t *testing.T, handler http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	Compress(testMinSize)(handler).ServeHTTP(rec, req)
+	return rec
+}
+
+func gunzip(t *testing.T, b []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("body is not gzip: %v", err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("reading gzip body failed: %v", err)
+	}
+	return string(out)
+}
+
+func TestCompress_GzipsWhenAccepted(t *testing.T) {
+	body := strings.Repeat("<li>Kevin Bacon</li>\n", 100)
+	rec := serveCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Length", "2100")
+		io.WriteString(w, body)
+	}, "br, gzip;q=0.8")
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("expected Vary: Accept-Encoding, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Errorf("expected Content-Length to be dropped, got %q", got)
+	}
+	if rec.Body.Len() >= len(body) {
+		t.Errorf("expected compressed body smaller than %d bytes, got %d", len(body), rec.Body.Len())
+	}
+	if got := gunzip(t, rec.Body.Bytes()); got != body {
+		t.Errorf("decompressed body does not match original")
+	}
+}
+
+func TestCompress_IdentityWithoutAcceptEncoding(t *testing.T) {
+	body := strings.Repeat("a", 1000)
+	for _, ae := range []string{"", "identity", "gzip;q=0"} {
+		rec := serveCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, body)
+		}, ae)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("Accept-Encoding %q: expected no encoding, got %q", ae, got)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: expected Vary: Accept-Encoding, got %q", ae, got)
+		}
+		if rec.Body.String() != body {
+			t.Errorf("Accept-Encoding %q: body was modified", ae)
+		}
+	}
+}
+
+func TestCompress_SkipsSmallBodies(t *testing.T) {
+	rec := serveCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"ok":true}`)
+	}, "gzip")
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("expected small body to be sent as is, got encoding %q", got)
+	}
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", rec.Code)
+	}
+	if got := rec.Body.String(); got != `{"ok":true}` {
+		t.Errorf("unexpected body %q", got)
+	}
+}
+
+func TestCompress_SkipsCompressedContentTypes(t *testing.T) {
+	for _, ct := range []string{"image/png", "application/gzip", "font/woff2"} {
+		rec := serveCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ct)
+			w.Write(bytes.Repeat([]byte{0x89}, 1000))
+		}, "gzip")
+
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("%s: expected no encoding, got %q", ct, got)
+		}
+		if rec.Body.Len() != 1000 {
+			t.Errorf("%s: expected 1000 body bytes, got %d", ct, rec.Body.Len())
+		}
+	}
+}
+
+func TestCompress_LoggingSeesStatusAndWireBytes(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	body := strings.Repeat("not found\n", 100)
+	handler := Logging(logger, nil, LogSampling{})(Compress(testMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, body, http.StatusNotFound)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/actor/1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip encoding")
+	}
+
+	var line struct {
+		Status int `json:"status"`
+		Bytes  int `json:"bytes"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("parsing log line failed: %v\n%s", err, logs.String())
+	}
+	if line.Status != http.StatusNotFound {
+		t.Errorf("expected logged status 404, got %d", line.Status)
+	}
+	if line.Bytes != rec.Body.Len() {
+		t.Errorf("expected logged bytes %d (compressed), got %d", rec.Body.Len(), line.Bytes)
+	}
+}
@@ -2,21 +2,118 @@ package middleware
 
 import (
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
-func CORS(allowedOrigin string) func(http.Handler) http.Handler {
+// CORSConfig configures the CORS middleware.
+type CORSConfig struct {
+	// Origins are the origins allowed to read responses. "*" allows any
+	// origin; an empty list disables CORS and no headers are sent.
+	Origins []string
+	// Methods and Headers are advertised in preflight responses.
+	Methods []string
+	Headers []string
+	// AllowCredentials lets browsers send cookies and auth headers. Browsers
+	// ignore it alongside a wildcard origin, so it only applies to explicit
+	// origins.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight result. Zero leaves
+	// it to the browser's default of a few seconds.
+	MaxAge time.Duration
+}
+
+// CORSOrigins is the allowlist a CORS middleware checks, which can be
+// replaced while it serves.
+type CORSOrigins struct {
+	set atomic.Pointer[originSet]
+}
+
+type originSet struct {
+	enabled  bool
+	wildcard bool
+	allowed  map[string]bool
+}
+
+// Set replaces the allowed origins, with the same meaning as
+// CORSConfig.Origins: an empty list turns CORS off.
+func (o *CORSOrigins) Set(origins []string) {
+	set := &originSet{
+		enabled:  len(origins) > 0,
+		wildcard: slices.Contains(origins, "*"),
+		allowed:  make(map[string]bool, len(origins)),
+	}
+	for _, origin := range origins {
+		set.allowed[normalizeOrigin(origin)] = true
+	}
+	o.set.Store(set)
+}
+
+// CORS sets the CORS response headers for requests from an allowed origin. A
+// request from any other origin gets no CORS headers, which browsers treat as
+// a refusal. With more than one allowed origin, the matching one is echoed
+// back and responses vary on Origin so caches keep them apart.
+//
+// OPTIONS requests are answered here with a 204 and never reach next, so
+// preflights don't depend on the mux knowing the method. The returned
+// origins change the allowlist in place; the rest of cfg is fixed.
+func CORS(cfg CORSConfig) (middleware func(http.Handler) http.Handler, origins *CORSOrigins) {
+	origins = new(CORSOrigins)
+	origins.Set(cfg.Origins)
+	methods := strings.Join(cfg.Methods, ", ")
+	headers := strings.Join(cfg.Headers, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge / time.Second))
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
-			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, HX-Request, HX-Target, HX-Trigger")
+			set := origins.set.Load()
+			if !set.enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			origin := r.Header.Get("Origin")
+			switch {
+			case set.wildcard:
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && set.allowed[normalizeOrigin(origin)]:
+				w.Header().Add("Vary", "Origin")
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				if cfg.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			default:
+				w.Header().Add("Vary", "Origin")
+				if r.Method == http.MethodOptions {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader+", Retry-After")
 
 			if r.Method == http.MethodOptions {
+				if cfg.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				}
 				w.WriteHeader(http.StatusNoContent)
 				return
 			}
 
 			next.ServeHTTP(w, r)
 		})
-	}
+	}, origins
+}
+
+// normalizeOrigin lets "https://Example.com/" in config match the
+// "https://example.com" a browser sends: origins compare case-insensitively
+// and never carry a path.
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(origin, "/"))
 }
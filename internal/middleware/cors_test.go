@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	allowlist := CORSConfig{
+		Origins:          []string{"https://a.example", "https://b.example"},
+		Methods:          []string{"GET", "OPTIONS"},
+		Headers:          []string{"Content-Type", "HX-Request"},
+		AllowCredentials: true,
+	}
+
+	tests := []struct {
+		name            string
+		cfg             CORSConfig
+		method          string
+		origin          string
+		wantStatus      int
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{"matching origin", allowlist, http.MethodGet, "https://b.example", http.StatusOK, "https://b.example", "true"},
+		{"matching preflight", allowlist, http.MethodOptions, "https://a.example", http.StatusNoContent, "https://a.example", "true"},
+		{"non-matching origin", allowlist, http.MethodGet, "https://evil.example", http.StatusOK, "", ""},
+		{"non-matching preflight", allowlist, http.MethodOptions, "https://evil.example", http.StatusNoContent, "", ""},
+		{"wildcard", CORSConfig{Origins: []string{"*"}}, http.MethodGet, "https://any.example", http.StatusOK, "*", ""},
+		{"wildcard never allows credentials", CORSConfig{Origins: []string{"*"}, AllowCredentials: true}, http.MethodGet, "https://any.example", http.StatusOK, "*", ""},
+		{"localhost alongside production", CORSConfig{Origins: []string{"https://degrees.example", "http://localhost:8080"}}, http.MethodGet, "http://localhost:8080", http.StatusOK, "http://localhost:8080", ""},
+		{"single origin", CORSConfig{Origins: []string{"https://A.example/"}}, http.MethodGet, "https://a.example", http.StatusOK, "https://a.example", ""},
+		{"single origin mismatch", CORSConfig{Origins: []string{"https://a.example"}}, http.MethodGet, "https://a.example.evil", http.StatusOK, "", ""},
+		{"no origin header", allowlist, http.MethodGet, "", http.StatusOK, "", ""},
+		{"disabled", CORSConfig{}, http.MethodGet, "https://a.example", http.StatusOK, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/search", nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+			cors, _ := CORS(tt.cfg)
+			cors(ok).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("expected Access-Control-Allow-Credentials %q, got %q", tt.wantCredentials, got)
+			}
+			if tt.wantOrigin == "" && rec.Header().Get("Access-Control-Allow-Methods") != "" {
+				t.Error("expected no CORS headers for a refused origin")
+			}
+		})
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	reached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true })
+	cors, _ := CORS(CORSConfig{
+		Origins: []string{"https://a.example", "https://b.example"},
+		Methods: []string{"GET", "OPTIONS"},
+		Headers: []string{"Content-Type", "HX-Request"},
+		MaxAge:  10 * time.Minute,
+	})
+	h := cors(next)
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/path/graph", nil)
+	req.Header.Set("Origin", "https://a.example")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent || reached {
+		t.Errorf("expected a 204 answered by the middleware, got %d (reached next: %v)", rec.Code, reached)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://a.example",
+		"Access-Control-Allow-Methods": "GET, OPTIONS",
+		"Access-Control-Allow-Headers": "Content-Type, HX-Request",
+		"Access-Control-Max-Age":       "600",
+		"Vary":                         "Origin",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("expected %s %q, got %q", header, want, got)
+		}
+	}
+}
+
+func TestCORS_OptionsNeverReachHandlers(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("OPTIONS %s reached the handler", r.URL.Path)
+	})
+	cors, _ := CORS(CORSConfig{Origins: []string{"https://a.example"}, Methods: []string{"GET"}})
+	h := cors(next)
+
+	for _, origin := range []string{"https://a.example", "https://evil.example", ""} {
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/path/graph", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("origin %q: expected 204, got %d", origin, rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Max-Age"); got != "" {
+			t.Errorf("origin %q: expected no Max-Age when unset, got %q", origin, got)
+		}
+	}
+}
+
+func TestCORSOrigins_Set(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	cors, origins := CORS(CORSConfig{Origins: []string{"https://a.example"}, Methods: []string{"GET"}})
+	h := cors(ok)
+	allowOrigin := func(origin string) string {
+		req := httptest.NewRequest(http.MethodGet, "/search", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	origins.Set([]string{"https://b.example"})
+	if got := allowOrigin("https://a.example"); got != "" {
+		t.Errorf("expected the replaced origin refused, got %q", got)
+	}
+	if got := allowOrigin("https://b.example"); got != "https://b.example" {
+		t.Errorf("expected the new origin allowed, got %q", got)
+	}
+
+	origins.Set(nil)
+	req := httptest.NewRequest(http.MethodGet, "/search", nil)
+	req.Header.Set("Origin", "https://b.example")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if len(rec.Header()) != 0 {
+		t.Errorf("expected no CORS headers once disabled, got %v", rec.Header())
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import "net/http"
+
+// DiscardHeadBody drops the body of responses to HEAD requests while keeping
+// the status and headers the same as for GET, so handlers registered for GET
+// can answer HEAD without a special case. net/http's server discards a HEAD
+// body too, but only at the connection; doing it here keeps wrapped writers
+// and anything logging response sizes from seeing bytes that are never sent.
+func DiscardHeadBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(headResponseWriter{w}, r)
+	})
+}
+
+// headResponseWriter reports writes as successful without sending them.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscardHeadBody(t *testing.T) {
+	handler := DiscardHeadBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusTeapot)
+		if n, err := w.Write([]byte("hello")); n != 5 || err != nil {
+			t.Errorf("expected the write to report success, got %d, %v", n, err)
+		}
+	}))
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/", nil))
+
+		if rec.Code != http.StatusTeapot || rec.Header().Get("Content-Length") != "5" {
+			t.Errorf("%s: expected status and headers to pass through, got %d %v", method, rec.Code, rec.Header())
+		}
+		wantBody := "hello"
+		if method == http.MethodHead {
+			wantBody = ""
+		}
+		if got := rec.Body.String(); got != wantBody {
+			t.Errorf("%s: expected body %q, got %q", method, wantBody, got)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HSTS sends Strict-Transport-Security on every response, telling browsers
+// to use https for the next maxAge. It belongs only on a TLS listener: over
+// plain HTTP the header is ignored, and a proxy terminating TLS should set
+// it itself. A zero maxAge leaves responses untouched.
+func HSTS(maxAge time.Duration) func(http.Handler) http.Handler {
+	value := "max-age=" + strconv.Itoa(int(maxAge/time.Second))
+	return func(next http.Handler) http.Handler {
+		if maxAge <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Strict-Transport-Security", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import "net/http"
+
+// RequestLimits rejects requests whose URL (path and query) is longer than
+// maxURLLen bytes with a 414 before they reach the handler, and caps request
+// bodies at maxBodyBytes: a declared Content-Length over the cap gets a 413
+// up front, and reading past it otherwise fails via http.MaxBytesReader. A
+// limit of zero disables that check.
+func RequestLimits(maxURLLen int, maxBodyBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			uri := r.RequestURI
+			if uri == "" {
+				uri = r.URL.RequestURI()
+			}
+			if maxURLLen > 0 && len(uri) > maxURLLen {
+				http.Error(w, "request URL too long", http.StatusRequestURITooLong)
+				return
+			}
+
+			if maxBodyBytes > 0 {
+				if r.ContentLength > maxBodyBytes {
+					http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
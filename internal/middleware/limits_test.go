@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestLimits_LongURL(t *testing.T) {
+	reached := false
+	h := RequestLimits(2048, 1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q="+strings.Repeat("a", 100_000), nil))
+	if rec.Code != http.StatusRequestURITooLong {
+		t.Errorf("expected 414, got %d", rec.Code)
+	}
+	if reached {
+		t.Error("handler ran for an over-long URL")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=kevin+bacon", nil))
+	if rec.Code != http.StatusOK || !reached {
+		t.Errorf("expected a short URL to reach the handler, got %d", rec.Code)
+	}
+}
+
+func TestRequestLimits_Body(t *testing.T) {
+	var readErr error
+	h := RequestLimits(0, 16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// A declared length over the cap is refused without reading.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 17))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", rec.Code)
+	}
+
+	// Without a declared length the reader stops at the cap.
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 17)))
+	req.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	var maxErr *http.MaxBytesError
+	if !errors.As(readErr, &maxErr) {
+		t.Errorf("expected a MaxBytesError reading past the cap, got %v", readErr)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 16)))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if readErr != nil {
+		t.Errorf("expected a body at the cap to read cleanly, got %v", readErr)
+	}
+}
@@ -6,18 +6,71 @@ import (
 	"encoding/hex"
 	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/requestid"
 )
 
 type contextKey string
 
-const RequestIDKey contextKey = "request_id"
+const logFieldsKey contextKey = "log_fields"
+
+const loggerKey contextKey = "logger"
+
+// RequestIDHeader carries the request id in both directions: a trusted caller
+// such as a load balancer may supply one, and every response echoes it.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds incoming ids so a client can't bloat every log line.
+const maxRequestIDLen = 64
+
+// RequestIDFrom returns the request id Logging stored in ctx, or "" if there
+// is none.
+func RequestIDFrom(ctx context.Context) string {
+	return requestid.FromContext(ctx)
+}
 
+// LoggerFrom returns the request-scoped logger Logging stored in ctx, which
+// already carries request_id, method and path. Outside Logging it returns
+// fallback.
+func LoggerFrom(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+// logFields collects attributes that middleware and handlers inside Logging
+// want on the request's log line.
+type logFields struct {
+	mu   sync.Mutex
+	args []any
+}
+
+// AddLogFields appends key-value pairs to the log line Logging writes for the
+// request ctx belongs to. Outside Logging it does nothing.
+func AddLogFields(ctx context.Context, args ...any) {
+	f, ok := ctx.Value(logFieldsKey).(*logFields)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	f.args = append(f.args, args...)
+	f.mu.Unlock()
+}
+
+// statusResponseWriter records the status code and the number of body bytes
+// written by the handlers it wraps. Wrapped outside Compress it sees the
+// compressed bytes, i.e. what actually goes over the wire.
 type statusResponseWriter struct {
 	http.ResponseWriter
 	status int
+	bytes  int
 }
 
 func (w *statusResponseWriter) WriteHeader(code int) {
@@ -25,27 +78,90 @@ func (w *statusResponseWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so Flush
+// and deadline control keep working through the wrapper.
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// LogSampling thins out request logs for routine traffic. Non-2xx responses
+// and requests taking at least Slow are always logged; other requests are
+// logged 1 in Rate. A Rate of 0 or 1 logs everything, and a zero Slow treats
+// no request as slow.
+type LogSampling struct {
+	Rate int
+	Slow time.Duration
+	// SlowParams lists, by path, the query parameters worth keeping on a
+	// slow request's log line, e.g. the actor pair behind a slow /degrees.
+	// Other parameters are left out, since they may be free text.
+	SlowParams map[string][]string
+}
+
+// sampler decides which routine requests make it into the log.
+type sampler struct {
+	LogSampling
+	seen atomic.Uint64
+}
+
+// keep reports whether to log a request that finished with status after
+// elapsed, and whether that decision was a sample.
+func (s *sampler) keep(status int, elapsed time.Duration) (log, sampled bool) {
+	if s.Rate <= 1 || status < 200 || status > 299 || (s.Slow > 0 && elapsed >= s.Slow) {
+		return true, false
+	}
+	return s.seen.Add(1)%uint64(s.Rate) == 1, true
+}
+
 // Logging records one structured log line per request, including trace_id and
-// span_id when a span is present for log-trace correlation.
-func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
+// span_id when a span is present for log-trace correlation. Handlers inside it
+// log through LoggerFrom, so their lines carry the same request_id. client_ip is the
+// address ips resolves through any trusted proxies. Successful fast requests
+// are sampled per sampling; their lines carry sample_rate so counts derived
+// from logs can be scaled back up. Slow requests are logged at WARN with
+// slow=true and their SlowParams.
+func Logging(logger *slog.Logger, ips *IPResolver, sampling LogSampling) func(http.Handler) http.Handler {
+	s := &sampler{LogSampling: sampling}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			id := newRequestID()
-			ctx := context.WithValue(r.Context(), RequestIDKey, id)
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			fields := &logFields{}
+			ctx := requestid.NewContext(r.Context(), id)
+			ctx = context.WithValue(ctx, logFieldsKey, fields)
+			ctx = context.WithValue(ctx, loggerKey, logger.With("request_id", id, "method", r.Method, "path", r.URL.Path))
 			r = r.WithContext(ctx)
+			w.Header().Set(RequestIDHeader, id)
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("request_id", id))
 
 			wrapped := &statusResponseWriter{ResponseWriter: w, status: 200}
 			start := time.Now()
 
 			next.ServeHTTP(wrapped, r)
 
+			elapsed := time.Since(start)
+			log, sampled := s.keep(wrapped.status, elapsed)
+			if !log {
+				return
+			}
+
 			args := []any{
 				"method", r.Method,
 				"path", r.URL.Path,
 				"status", wrapped.status,
-				"duration_ms", time.Since(start).Milliseconds(),
+				"bytes", wrapped.bytes,
+				"duration_ms", elapsed.Milliseconds(),
 				"request_id", id,
 				"remote_addr", r.RemoteAddr,
+				"client_ip", ips.ClientIP(r),
 			}
 
 			// otelhttp (inner middleware) has already created the span by the
@@ -59,11 +175,48 @@ func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
 				)
 			}
 
-			logger.InfoContext(ctx, "request", args...)
+			if sampled {
+				args = append(args, "sample_rate", s.Rate)
+			}
+
+			level := slog.LevelInfo
+			if s.Slow > 0 && elapsed >= s.Slow {
+				level = slog.LevelWarn
+				args = append(args, "slow", true)
+				query := r.URL.Query()
+				for _, name := range s.SlowParams[r.URL.Path] {
+					if query.Has(name) {
+						args = append(args, name, query.Get(name))
+					}
+				}
+			}
+
+			fields.mu.Lock()
+			args = append(args, fields.args...)
+			fields.mu.Unlock()
+
+			logger.Log(ctx, level, "request", args...)
 		})
 	}
 }
 
+// validRequestID accepts ids of up to maxRequestIDLen letters, digits, '-',
+// '_' and '.', which covers UUIDs and the usual proxy formats while keeping
+// control characters and log-injection payloads out.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range []byte(id) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func newRequestID() string {
 	b := make([]byte, 8)
 	rand.Read(b)
@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// serveWithRequestID runs a request through Logging and returns the response
+// along with the id the handler saw in its context.
+func serveWithRequestID(t *testing.T, incoming string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	var seen string
+	handler := Logging(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, LogSampling{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFrom(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if incoming != "" {
+		req.Header.Set(RequestIDHeader, incoming)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, seen
+}
+
+func TestLogging_RequestIDPassthrough(t *testing.T) {
+	const incoming = "3f2c9a1e-7b4d-4e8a-9c61-2d5f0b7a8e90"
+	rec, seen := serveWithRequestID(t, incoming)
+
+	if seen != incoming {
+		t.Errorf("expected context id %q, got %q", incoming, seen)
+	}
+	if got := rec.Header().Get(RequestIDHeader); got != incoming {
+		t.Errorf("expected response header %q, got %q", incoming, got)
+	}
+}
+
+func TestLogging_RequestIDGenerated(t *testing.T) {
+	for _, incoming := range []string{
+		"",
+		strings.Repeat("a", maxRequestIDLen+1),
+		"id with spaces",
+		"evil\nrequest_id=forged",
+	} {
+		rec, seen := serveWithRequestID(t, incoming)
+
+		if seen == "" || seen == incoming {
+			t.Errorf("incoming %q: expected a generated id, got %q", incoming, seen)
+		}
+		if len(seen) != 16 {
+			t.Errorf("incoming %q: expected a 16 character generated id, got %q", incoming, seen)
+		}
+		if got := rec.Header().Get(RequestIDHeader); got != seen {
+			t.Errorf("incoming %q: expected response header %q, got %q", incoming, seen, got)
+		}
+	}
+}
+
+func TestLogging_RequestIDOnSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	logging := Logging(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, LogSampling{})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "request")
+		defer span.End()
+		logging.ServeHTTP(w, r.WithContext(ctx))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == "request_id" {
+			if got := kv.Value.AsString(); got != "req-42" {
+				t.Errorf("expected request_id req-42, got %q", got)
+			}
+			return
+		}
+	}
+	t.Errorf("expected a request_id attribute, got %v", spans[0].Attributes())
+}
+
+func TestRequestIDFrom_Missing(t *testing.T) {
+	if got := RequestIDFrom(httptest.NewRequest(http.MethodGet, "/", nil).Context()); got != "" {
+		t.Errorf("expected empty id without Logging, got %q", got)
+	}
+}
+
+func TestLogging_Sampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/degrees", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	})
+	h := Logging(logger, nil, LogSampling{Rate: 5, Slow: 10 * time.Millisecond})(mux)
+
+	for range 10 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/search?q=ke", nil))
+	}
+	for range 3 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/degrees", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}
+
+	counts := make(map[string]int)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry struct {
+			Path       string `json:"path"`
+			SampleRate int    `json:"sample_rate"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("bad log line %q: %v", line, err)
+		}
+		counts[entry.Path]++
+		if want := map[string]int{"/search": 5}[entry.Path]; entry.SampleRate != want {
+			t.Errorf("%s: expected sample_rate %d, got %d", entry.Path, want, entry.SampleRate)
+		}
+	}
+
+	if counts["/search"] != 2 {
+		t.Errorf("expected 2 of 10 fast 200s logged at 1 in 5, got %d", counts["/search"])
+	}
+	if counts["/degrees"] != 3 {
+		t.Errorf("expected every error logged, got %d of 3", counts["/degrees"])
+	}
+	if counts["/slow"] != 3 {
+		t.Errorf("expected every slow request logged, got %d of 3", counts["/slow"])
+	}
+}
+
+func TestLogging_SlowRequestsWarn(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/degrees", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("a") == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+	h := Logging(logger, nil, LogSampling{
+		Slow:       10 * time.Millisecond,
+		SlowParams: map[string][]string{"/degrees": {"a", "b"}},
+	})(mux)
+
+	tests := []struct {
+		query     string
+		wantLevel string
+		wantSlow  any
+		wantA     any
+	}{
+		{"a=fast&b=2", "INFO", nil, nil},
+		{"a=slow&b=2&note=free+text", "WARN", true, "slow"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/degrees?"+tt.query, nil))
+
+		var entry map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("%s: bad log line %q: %v", tt.query, buf.String(), err)
+		}
+		if entry["level"] != tt.wantLevel {
+			t.Errorf("%s: expected level %s, got %v", tt.query, tt.wantLevel, entry["level"])
+		}
+		if entry["slow"] != tt.wantSlow {
+			t.Errorf("%s: expected slow=%v, got %v", tt.query, tt.wantSlow, entry["slow"])
+		}
+		if entry["a"] != tt.wantA {
+			t.Errorf("%s: expected a=%v, got %v", tt.query, tt.wantA, entry["a"])
+		}
+		if entry["note"] != nil {
+			t.Errorf("%s: expected unlisted params left out, got note=%v", tt.query, entry["note"])
+		}
+	}
+}
+
+func TestLoggerFrom(t *testing.T) {
+	fallback := slog.New(slog.DiscardHandler)
+	if LoggerFrom(context.Background(), fallback) != fallback {
+		t.Error("expected the fallback logger outside Logging")
+	}
+
+	var logs strings.Builder
+	h := Logging(slog.New(slog.NewTextHandler(&logs, nil)), nil, LogSampling{})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			LoggerFrom(r.Context(), fallback).Info("from handler")
+		}))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/paths", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	first, _, _ := strings.Cut(logs.String(), "\n")
+	if !strings.Contains(first, "from handler") {
+		t.Fatalf("expected the handler's line first, got %q", logs.String())
+	}
+	for _, want := range []string{"request_id=abc-123", "method=POST", "path=/api/v1/paths"} {
+		if !strings.Contains(first, want) {
+			t.Errorf("expected %s on the handler's line, got %q", want, first)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/metrics"
+)
+
+// router resolves the pattern a request will be dispatched to. *http.ServeMux
+// satisfies it.
+type router interface {
+	Handler(r *http.Request) (h http.Handler, pattern string)
+}
+
+// Route returns the path part of the mux pattern r would be dispatched to,
+// e.g. "/actor/{id}" for "GET /actor/{id}", or "" when nothing matches.
+func Route(mux router, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
+
+// Metrics records per-route request counts, latencies and response sizes. The route label is
+// the mux pattern the request matches, so unknown paths collapse into a single
+// "unmatched" series instead of one series per URL.
+func Metrics(m *metrics.Metrics, mux router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := Route(mux, r)
+			if route == "" {
+				route = "unmatched"
+			}
+
+			wrapped := &statusResponseWriter{ResponseWriter: w, status: 200}
+			start := time.Now()
+
+			next.ServeHTTP(wrapped, r)
+
+			m.ObserveRequest(r.Method, route, wrapped.status, wrapped.bytes, time.Since(start))
+		})
+	}
+}
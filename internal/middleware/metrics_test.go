@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/metrics"
+)
+
+func TestMetrics_ScrapeAfterRequests(t *testing.T) {
+	m := metrics.New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/degrees", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	})
+	handler := Metrics(m, mux)(mux)
+
+	for _, path := range []string{"/stats", "/stats", "/degrees", "/nope"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	m.ObserveQuery("ShortestPath", 20*time.Millisecond, nil)
+	m.ObserveQuery("ShortestPath", 5*time.Millisecond, io.ErrUnexpectedEOF)
+	m.IncRateLimited("/degrees")
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading scrape body failed: %v", err)
+	}
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/stats",status="200"} 2`,
+		`http_requests_total{method="GET",route="/degrees",status="400"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/stats"} 2`,
+		`http_response_size_bytes_bucket{method="GET",route="/degrees",le="100"} 1`,
+		`http_response_size_bytes_sum{method="GET",route="/degrees"} 12`,
+		`neo4j_query_duration_seconds_bucket{query="ShortestPath",le="0.025"} 2`,
+		`neo4j_query_duration_seconds_count{query="ShortestPath"} 2`,
+		`neo4j_query_errors_total{query="ShortestPath"} 1`,
+		`http_rate_limited_total{route="/degrees"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("scrape missing series %q\n%s", want, body)
+		}
+	}
+}
@@ -1,87 +1,253 @@
 package middleware
 
 import (
+	"container/list"
 	"log/slog"
-	"net"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/metrics"
+)
+
+// RatePolicy is a token bucket refilling at Limit tokens per second up to
+// Burst, from which each request takes Cost tokens.
+type RatePolicy struct {
+	Limit rate.Limit
+	Burst int
+	Cost  int
+}
+
+// RateLimitConfig assigns policies to routes, keyed by the path returned by
+// Route. Each routed policy has its own bucket per client; every other route
+// shares the Default bucket. Routes starting with an Exempt prefix are never
+// limited.
+//
+// MaxClients bounds how many clients' buckets are kept; past it, the least
+// recently seen client is forgotten, so a flood of spoofed addresses can't
+// grow memory without limit. Zero means no bound.
+//
+// OnLimit writes the body of a rejected request, after Retry-After is set;
+// retryAfter is how long until the client's bucket holds enough tokens. When
+// nil, rejections get a plain-text 429.
+type RateLimitConfig struct {
+	Default    RatePolicy
+	Routes     map[string]RatePolicy
+	Exempt     []string
+	MaxClients int
+	OnLimit    func(w http.ResponseWriter, r *http.Request, retryAfter time.Duration)
+}
+
+const (
+	// visitorCleanupInterval is how often idle clients are swept.
+	visitorCleanupInterval = 5 * time.Minute
+	// visitorIdleTTL is how long a client goes unseen before its buckets,
+	// full again by then, are dropped.
+	visitorIdleTTL = 10 * time.Minute
 )
 
 type visitor struct {
-	limiter  *rate.Limiter
+	ip       string
+	limiters map[string]*rate.Limiter // keyed by route, "" for the default bucket
 	lastSeen time.Time
 }
 
-type rateLimiter struct {
+// RateLimiter is the state behind a RateLimit middleware: every client's
+// buckets and the policies they are filled by.
+type RateLimiter struct {
 	mu       sync.Mutex
-	visitors map[string]*visitor
-	limit    rate.Limit
-	burst    int
+	visitors map[string]*list.Element // values are *visitor
+	recent   *list.List               // most recently seen first
+	cfg      RateLimitConfig
 	logger   *slog.Logger
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
-func newRateLimiter(limit rate.Limit, burst int, logger *slog.Logger) *rateLimiter {
-	rl := &rateLimiter{
-		visitors: make(map[string]*visitor),
-		limit:    limit,
-		burst:    burst,
+func newRateLimiter(cfg RateLimitConfig, logger *slog.Logger) *RateLimiter {
+	rl := &RateLimiter{
+		visitors: make(map[string]*list.Element),
+		recent:   list.New(),
+		cfg:      cfg,
 		logger:   logger,
+		done:     make(chan struct{}),
 	}
 	go rl.cleanupLoop()
 	return rl
 }
 
-func (rl *rateLimiter) getVisitor(ip string) *rate.Limiter {
+// Stop ends the idle-client sweep. It is safe to call more than once.
+func (rl *RateLimiter) Stop() {
+	rl.stopOnce.Do(func() { close(rl.done) })
+}
+
+// SetLimits replaces the Default and Routes policies with cfg's while the
+// middleware serves; the rest of cfg is ignored. Clients keep their buckets,
+// refilling at the new rate up to the new burst from the tokens they hold,
+// so a reload neither forgives nor punishes anyone. Buckets for routes that
+// no longer have a policy are dropped, and those routes share the default
+// bucket from then on.
+func (rl *RateLimiter) SetLimits(cfg RateLimitConfig) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
+	rl.cfg.Default = cfg.Default
+	rl.cfg.Routes = cfg.Routes
 
-	v, ok := rl.visitors[ip]
-	if !ok {
-		v = &visitor{limiter: rate.NewLimiter(rl.limit, rl.burst)}
-		rl.visitors[ip] = v
+	now := time.Now()
+	for e := rl.recent.Front(); e != nil; e = e.Next() {
+		v := e.Value.(*visitor)
+		for key, l := range v.limiters {
+			p := rl.cfg.Default
+			if key != "" {
+				var ok bool
+				if p, ok = rl.cfg.Routes[key]; !ok {
+					delete(v.limiters, key)
+					continue
+				}
+			}
+			l.SetLimitAt(now, p.Limit)
+			l.SetBurstAt(now, p.Burst)
+		}
+	}
+}
+
+// policy returns the bucket key and policy for a route, and false if the
+// route is exempt.
+func (rl *RateLimiter) policy(route string) (string, RatePolicy, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for _, prefix := range rl.cfg.Exempt {
+		if strings.HasPrefix(route, prefix) {
+			return "", RatePolicy{}, false
+		}
+	}
+	if p, ok := rl.cfg.Routes[route]; ok {
+		return route, p, true
+	}
+	return "", rl.cfg.Default, true
+}
+
+func (rl *RateLimiter) getLimiter(ip, key string, p RatePolicy) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	var v *visitor
+	if e, ok := rl.visitors[ip]; ok {
+		v = e.Value.(*visitor)
+		rl.recent.MoveToFront(e)
+	} else {
+		if rl.cfg.MaxClients > 0 && len(rl.visitors) >= rl.cfg.MaxClients {
+			oldest := rl.recent.Back()
+			delete(rl.visitors, rl.recent.Remove(oldest).(*visitor).ip)
+		}
+		v = &visitor{ip: ip, limiters: make(map[string]*rate.Limiter)}
+		rl.visitors[ip] = rl.recent.PushFront(v)
 	}
 	v.lastSeen = time.Now()
-	return v.limiter
+
+	l, ok := v.limiters[key]
+	if !ok {
+		l = rate.NewLimiter(p.Limit, p.Burst)
+		v.limiters[key] = l
+	}
+	return l
 }
 
-func (rl *rateLimiter) cleanupLoop() {
-	ticker := time.NewTicker(5 * time.Minute)
+func (rl *RateLimiter) cleanupLoop() {
+	ticker := time.NewTicker(visitorCleanupInterval)
 	defer ticker.Stop()
-	for range ticker.C {
-		rl.mu.Lock()
-		for ip, v := range rl.visitors {
-			if time.Since(v.lastSeen) > 10*time.Minute {
-				delete(rl.visitors, ip)
-			}
+	for {
+		select {
+		case <-rl.done:
+			return
+		case <-ticker.C:
+			rl.removeIdle(time.Now())
+		}
+	}
+}
+
+// removeIdle drops clients not seen for visitorIdleTTL. The list is ordered
+// by lastSeen, so it stops at the first client still active.
+func (rl *RateLimiter) removeIdle(now time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for e := rl.recent.Back(); e != nil; e = rl.recent.Back() {
+		v := e.Value.(*visitor)
+		if now.Sub(v.lastSeen) <= visitorIdleTTL {
+			return
 		}
-		rl.mu.Unlock()
+		rl.recent.Remove(e)
+		delete(rl.visitors, v.ip)
 	}
 }
 
-func RateLimit(limit rate.Limit, burst int, logger *slog.Logger) func(http.Handler) http.Handler {
-	rl := newRateLimiter(limit, burst, logger)
+// reserve takes cost tokens from l if it has them now. Otherwise it leaves l
+// untouched and returns how long the client has to wait before it would.
+func reserve(l *rate.Limiter, now time.Time, cost int) (bool, time.Duration) {
+	res := l.ReserveN(now, cost)
+	if !res.OK() {
+		// cost exceeds the burst and can never be met; config validation
+		// keeps this from happening, so just ask for a minute's patience.
+		return false, time.Minute
+	}
+	delay := res.DelayFrom(now)
+	if delay == 0 {
+		return true, 0
+	}
+	res.CancelAt(now)
+	return false, delay
+}
+
+// retryAfterSeconds rounds a wait up to the whole seconds Retry-After carries,
+// so a client that waits exactly that long is not rejected again.
+func retryAfterSeconds(d time.Duration) int {
+	return max(int(math.Ceil(d.Seconds())), 1)
+}
+
+// RateLimit limits each client IP, as resolved by ips, according to the
+// policy for the route mux would dispatch the request to. Idle clients are
+// forgotten by a background goroutine that runs until the returned
+// limiter's Stop is called; call it once the middleware is no longer
+// serving. The limiter's SetLimits changes the policies in place.
+func RateLimit(cfg RateLimitConfig, mux router, ips *IPResolver, logger *slog.Logger, m *metrics.Metrics) (middleware func(http.Handler) http.Handler, limiter *RateLimiter) {
+	rl := newRateLimiter(cfg, logger)
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip, _, err := net.SplitHostPort(r.RemoteAddr)
-			if err != nil {
-				ip = r.RemoteAddr
+			route := Route(mux, r)
+			key, policy, limited := rl.policy(route)
+			if !limited {
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			if !rl.getVisitor(ip).Allow() {
+			ip := ips.ClientIP(r)
+			ok, wait := reserve(rl.getLimiter(ip, key, policy), time.Now(), max(policy.Cost, 1))
+			if !ok {
+				retryAfter := retryAfterSeconds(wait)
 				logger.WarnContext(r.Context(), "rate limit exceeded",
 					"ip", ip,
 					"path", r.URL.Path,
-					"request_id", r.Context().Value(RequestIDKey),
+					"route", route,
+					"retry_after", retryAfter,
+					"request_id", RequestIDFrom(r.Context()),
 				)
+				m.IncRateLimited(route)
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				if cfg.OnLimit != nil {
+					cfg.OnLimit(w, r, time.Duration(retryAfter)*time.Second)
+					return
+				}
 				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
 				return
 			}
 
 			next.ServeHTTP(w, r)
 		})
-	}
+	}, rl
 }
@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func newRateLimitedMux(t *testing.T, cfg RateLimitConfig) http.Handler {
+	t.Helper()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /degrees", ok)
+	mux.HandleFunc("GET /search", ok)
+	mux.HandleFunc("GET /healthz", ok)
+	mux.HandleFunc("GET /static/", ok)
+	mux.HandleFunc("GET /{$}", ok)
+	limit, limiter := RateLimit(cfg, mux, nil, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	t.Cleanup(limiter.Stop)
+	return limit(mux)
+}
+
+func get(h http.Handler, path, remoteAddr string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRateLimit_PerRouteCosts(t *testing.T) {
+	// Near-zero refill so buckets only hold what their burst allows.
+	const slow = rate.Limit(0.0001)
+	h := newRateLimitedMux(t, RateLimitConfig{
+		Default: RatePolicy{Limit: slow, Burst: 1, Cost: 1},
+		Routes: map[string]RatePolicy{
+			"/degrees": {Limit: slow, Burst: 6, Cost: 3},
+			"/search":  {Limit: slow, Burst: 10, Cost: 1},
+		},
+	})
+	const client = "192.0.2.1:1234"
+
+	for i := range 2 {
+		if code := get(h, "/degrees?a=1&b=2", client); code != http.StatusOK {
+			t.Fatalf("degrees request %d: expected 200, got %d", i+1, code)
+		}
+	}
+	if code := get(h, "/degrees?a=1&b=2", client); code != http.StatusTooManyRequests {
+		t.Fatalf("third degrees request: expected 429 once 6 tokens are spent at cost 3, got %d", code)
+	}
+
+	for i := range 10 {
+		if code := get(h, "/search?q=bacon", client); code != http.StatusOK {
+			t.Fatalf("search request %d: expected 200 with degrees exhausted, got %d", i+1, code)
+		}
+	}
+	if code := get(h, "/search?q=bacon", client); code != http.StatusTooManyRequests {
+		t.Errorf("11th search request: expected 429, got %d", code)
+	}
+
+	// Unlisted routes share the default bucket, independent of the others.
+	if code := get(h, "/", client); code != http.StatusOK {
+		t.Errorf("index: expected 200 from the default bucket, got %d", code)
+	}
+	if code := get(h, "/", client); code != http.StatusTooManyRequests {
+		t.Errorf("second index request: expected 429, got %d", code)
+	}
+
+	// Another client has its own buckets.
+	if code := get(h, "/degrees?a=1&b=2", "192.0.2.2:1234"); code != http.StatusOK {
+		t.Errorf("degrees from another client: expected 200, got %d", code)
+	}
+}
+
+func TestRateLimit_ExemptRoutes(t *testing.T) {
+	h := newRateLimitedMux(t, RateLimitConfig{
+		Default: RatePolicy{Limit: rate.Limit(0.0001), Burst: 1, Cost: 1},
+		Exempt:  []string{"/healthz", "/static/"},
+	})
+	const client = "192.0.2.1:1234"
+
+	for i := range 20 {
+		for _, path := range []string{"/healthz", "/static/style.css"} {
+			if code := get(h, path, client); code != http.StatusOK {
+				t.Fatalf("%s request %d: expected exempt route to return 200, got %d", path, i+1, code)
+			}
+		}
+	}
+	if code := get(h, "/", client); code != http.StatusOK {
+		t.Errorf("exempt traffic should not have spent the default bucket, got %d", code)
+	}
+}
+
+func TestRateLimit_RetryAfter(t *testing.T) {
+	var hookRetry time.Duration
+	cfg := RateLimitConfig{
+		Default: RatePolicy{Limit: 1000, Burst: 1000, Cost: 1},
+		Routes: map[string]RatePolicy{
+			"/degrees": {Limit: 0.5, Burst: 6, Cost: 3},
+		},
+	}
+	cfg.OnLimit = func(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+		hookRetry = retryAfter
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	h := newRateLimitedMux(t, cfg)
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/degrees?a=1&b=2", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for range 2 {
+		if rec := serve(); rec.Header().Get("Retry-After") != "" {
+			t.Fatalf("allowed request should not carry Retry-After, got %q", rec.Header().Get("Retry-After"))
+		}
+	}
+
+	// The bucket is empty and refills at 0.5 tokens/s, so 3 tokens are ~6s away.
+	// Rejections must not hold tokens, or the wait would grow with each retry.
+	for i := range 3 {
+		rec := serve()
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("rejection %d: expected 429, got %d", i+1, rec.Code)
+		}
+		secs, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+		if err != nil || secs < 5 || secs > 6 {
+			t.Errorf("rejection %d: expected Retry-After of about 6 seconds, got %q", i+1, rec.Header().Get("Retry-After"))
+		}
+		if hookRetry != time.Duration(secs)*time.Second {
+			t.Errorf("rejection %d: OnLimit got %v, header says %ds", i+1, hookRetry, secs)
+		}
+	}
+}
+
+func TestRateLimit_DefaultRejectionBody(t *testing.T) {
+	h := newRateLimitedMux(t, RateLimitConfig{Default: RatePolicy{Limit: 2, Burst: 1, Cost: 1}})
+	const client = "192.0.2.1:1234"
+
+	get(h, "/", client)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = client
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	// Half a second to the next token rounds up to a whole second.
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+}
+
+func TestRateLimit_MaxClientsEvictsLeastRecent(t *testing.T) {
+	h := newRateLimitedMux(t, RateLimitConfig{
+		Default:    RatePolicy{Limit: 0.0001, Burst: 1, Cost: 1},
+		MaxClients: 2,
+	})
+
+	get(h, "/", "192.0.2.1:1")
+	get(h, "/", "192.0.2.2:1")
+	// Seeing .1 again makes .2 the least recent, so .3 evicts it.
+	if code := get(h, "/", "192.0.2.1:1"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected .1 to stay limited, got %d", code)
+	}
+	get(h, "/", "192.0.2.3:1")
+
+	if code := get(h, "/", "192.0.2.1:1"); code != http.StatusTooManyRequests {
+		t.Errorf("expected recently seen .1 to keep its empty bucket, got %d", code)
+	}
+	if code := get(h, "/", "192.0.2.2:1"); code != http.StatusOK {
+		t.Errorf("expected evicted .2 to start with a fresh bucket, got %d", code)
+	}
+}
+
+func TestRateLimiter_SetLimits(t *testing.T) {
+	const slow = rate.Limit(0.0001)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /degrees", ok)
+	mux.HandleFunc("GET /{$}", ok)
+	limit, limiter := RateLimit(RateLimitConfig{
+		Default: RatePolicy{Limit: slow, Burst: 1, Cost: 1},
+		Routes:  map[string]RatePolicy{"/degrees": {Limit: slow, Burst: 3, Cost: 3}},
+	}, mux, nil, slog.New(slog.DiscardHandler), nil)
+	t.Cleanup(limiter.Stop)
+	h := limit(mux)
+	const client = "192.0.2.1:1234"
+
+	get(h, "/", client)
+	get(h, "/degrees", client)
+	if code := get(h, "/", client); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the default bucket spent, got %d", code)
+	}
+
+	// A fast refill reaches the client's existing bucket; /degrees, no
+	// longer listed, moves onto it.
+	limiter.SetLimits(RateLimitConfig{Default: RatePolicy{Limit: 1000, Burst: 2, Cost: 1}})
+	time.Sleep(10 * time.Millisecond)
+	for _, path := range []string{"/", "/degrees"} {
+		if code := get(h, path, client); code != http.StatusOK {
+			t.Errorf("%s after raising the limit: expected 200, got %d", path, code)
+		}
+	}
+
+	limiter.SetLimits(RateLimitConfig{Default: RatePolicy{Limit: slow, Burst: 1, Cost: 1}})
+	get(h, "/", client)
+	if code := get(h, "/", client); code != http.StatusTooManyRequests {
+		t.Errorf("expected the lowered burst to apply, got %d", code)
+	}
+}
+
+func TestRateLimiter_RemoveIdle(t *testing.T) {
+	rl := newRateLimiter(RateLimitConfig{}, nil)
+	t.Cleanup(rl.Stop)
+	p := RatePolicy{Limit: 1, Burst: 1, Cost: 1}
+
+	rl.getLimiter("192.0.2.1", "", p)
+	rl.getLimiter("192.0.2.2", "", p)
+	rl.removeIdle(time.Now().Add(visitorIdleTTL + time.Second))
+
+	if n := len(rl.visitors); n != 0 {
+		t.Errorf("expected idle clients to be dropped, %d left", n)
+	}
+	if n := rl.recent.Len(); n != 0 {
+		t.Errorf("expected the recency list to be emptied, %d left", n)
+	}
+}
+
+func TestRateLimit_StopEndsCleanup(t *testing.T) {
+	mux := http.NewServeMux()
+	before := runtime.NumGoroutine()
+	for range 50 {
+		_, limiter := RateLimit(RateLimitConfig{}, mux, nil, nil, nil)
+		limiter.Stop()
+		limiter.Stop() // a second stop is harmless
+	}
+
+	// Stopped loops exit asynchronously; give them a moment.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected stopped limiters to leave no goroutines, went from %d to %d", before, after)
+	}
+}
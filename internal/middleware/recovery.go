@@ -1,28 +1,109 @@
 package middleware
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
 	"runtime/debug"
+	"strings"
 )
 
-func Recovery(logger *slog.Logger) func(http.Handler) http.Handler {
+// panicResponse is the JSON body for a recovered panic, shaped like the API's
+// error envelope so clients can parse it the same way.
+type panicResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// startedWriter records whether the handlers it wraps have begun the
+// response, after which a 500 can no longer be sent.
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *startedWriter) WriteHeader(code int) {
+	// Informational responses don't commit the final status.
+	if code >= 200 {
+		w.started = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *startedWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *startedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Recovery turns a panic into a logged 500. API requests get a JSON body;
+// everything else is handed to render, which writes the 500 in the site's
+// own style. A nil render falls back to plain text.
+//
+// A panic after the response has started is logged but nothing more is
+// written, since the status is already on the wire. http.ErrAbortHandler is
+// re-panicked so the server aborts the response as the handler asked.
+func Recovery(logger *slog.Logger, render func(w http.ResponseWriter, r *http.Request)) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &startedWriter{ResponseWriter: w}
 			defer func() {
-				if rec := recover(); rec != nil {
-					logger.ErrorContext(r.Context(), "panic recovered",
-						"error", rec,
-						"stack", string(debug.Stack()),
-						"method", r.Method,
-						"path", r.URL.Path,
-						"request_id", r.Context().Value(RequestIDKey),
-					)
-					http.Error(w, "internal server error", http.StatusInternalServerError)
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				requestID := RequestIDFrom(r.Context())
+				logger.ErrorContext(r.Context(), "panic recovered",
+					"error", rec,
+					"stack", string(debug.Stack()),
+					"method", r.Method,
+					"path", r.URL.Path,
+					"request_id", requestID,
+					"response_started", sw.started,
+				)
+				if sw.started {
+					return
 				}
+				if render != nil && !wantsJSON(r) {
+					render(w, r)
+					return
+				}
+				writePanicResponse(w, r, requestID)
 			}()
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(sw, r)
 		})
 	}
 }
+
+// writePanicResponse answers API clients in JSON and everyone else in plain
+// text, both carrying the request id so a report can be matched to the log.
+func writePanicResponse(w http.ResponseWriter, r *http.Request, requestID string) {
+	if !wantsJSON(r) {
+		msg := "internal server error"
+		if requestID != "" {
+			msg += " (request id " + requestID + ")"
+		}
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(panicResponse{Error: "internal", RequestID: requestID})
+}
+
+// wantsJSON reports whether r is an API request: one under /api/ or one that
+// asks for JSON.
+func wantsJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") ||
+		strings.Contains(r.Header.Get("Accept"), "application/json")
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecovery_ResponseFormats(t *testing.T) {
+	panics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	h := Logging(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, LogSampling{})(
+		Recovery(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)(panics))
+
+	tests := []struct {
+		name     string
+		path     string
+		accept   string
+		wantJSON bool
+	}{
+		{"api path", "/api/v1/path/graph?a=1&b=2", "", true},
+		{"accept json", "/degrees?a=1&b=2", "application/json", true},
+		{"htmx fragment", "/degrees?a=1&b=2", "text/html", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set(RequestIDHeader, "support-ticket-42")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Fatalf("expected 500, got %d", rec.Code)
+			}
+			ct := rec.Header().Get("Content-Type")
+			if !tt.wantJSON {
+				if !strings.HasPrefix(ct, "text/plain") {
+					t.Errorf("expected text/plain, got %q", ct)
+				}
+				if body := rec.Body.String(); !strings.Contains(body, "internal server error (request id support-ticket-42)") {
+					t.Errorf("expected the request id in the text body, got %q", body)
+				}
+				return
+			}
+
+			if ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+			var body map[string]string
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("body is not JSON: %v", err)
+			}
+			if body["error"] != "internal" || body["request_id"] != "support-ticket-42" {
+				t.Errorf("unexpected JSON body %v", body)
+			}
+		})
+	}
+}
+
+func TestRecovery_PanicAfterWrite(t *testing.T) {
+	var logs strings.Builder
+	h := Recovery(slog.New(slog.NewTextHandler(&logs, nil)), nil)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("partial"))
+			panic("boom")
+		}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/degrees", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the status already sent to stand, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); body != "partial" {
+		t.Errorf("expected nothing appended after the panic, got %q", body)
+	}
+	if !strings.Contains(logs.String(), "response_started=true") {
+		t.Errorf("expected the panic to be logged as mid-response, got %q", logs.String())
+	}
+}
+
+func TestRecovery_PanicBeforeWrite(t *testing.T) {
+	rendered := false
+	h := Recovery(slog.New(slog.NewTextHandler(io.Discard, nil)), func(w http.ResponseWriter, r *http.Request) {
+		rendered = true
+		w.WriteHeader(http.StatusInternalServerError)
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Partial", "1")
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/degrees", nil))
+
+	if !rendered || rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected the renderer to write a 500, got rendered=%v status %d", rendered, rec.Code)
+	}
+}
+
+func TestRecovery_RepanicsAbortHandler(t *testing.T) {
+	var logs strings.Builder
+	h := Recovery(slog.New(slog.NewTextHandler(&logs, nil)), nil)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+
+	rec := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if got := recover(); got != http.ErrAbortHandler {
+				t.Errorf("expected http.ErrAbortHandler to propagate, got %v", got)
+			}
+		}()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/degrees", nil))
+	}()
+
+	if rec.Body.Len() != 0 {
+		t.Errorf("expected no response body, got %q", rec.Body.String())
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected an abort not to be logged as a panic, got %q", logs.String())
+	}
+}
@@ -5,8 +5,25 @@ type Actor struct {
 	Name   string
 }
 
+// ActorDetails is what TMDb knows about an actor beyond what the graph
+// stores, fetched when a profile is shown. Either field may be empty.
+type ActorDetails struct {
+	Biography   string
+	ProfilePath string // appended to the image base URL; empty for no photo
+}
+
 type Movie struct {
-	TmdbID int
-	Title  string
-	Year   int
+	TmdbID     int
+	Title      string
+	Year       int    // 0 when TMDb has no release date
+	PosterPath string // from TMDb, not the graph; empty for no poster
+}
+
+// Decade returns the first year of the movie's decade, e.g. 1990 for 1994,
+// or 0 when the year is unknown.
+func (m Movie) Decade() int {
+	if m.Year <= 0 {
+		return 0
+	}
+	return m.Year - m.Year%10
 }
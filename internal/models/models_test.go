@@ -0,0 +1,21 @@
+package models
+
+import "testing"
+
+func TestMovieDecade(t *testing.T) {
+	tests := []struct {
+		year, want int
+	}{
+		{1994, 1990},
+		{1990, 1990},
+		{1999, 1990},
+		{2000, 2000},
+		{0, 0},  // no release date
+		{-5, 0}, // never valid
+	}
+	for _, tt := range tests {
+		if got := (Movie{Year: tt.year}).Decade(); got != tt.want {
+			t.Errorf("Decade() for %d: expected %d, got %d", tt.year, tt.want, got)
+		}
+	}
+}
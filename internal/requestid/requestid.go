@@ -0,0 +1,20 @@
+// Package requestid carries a request's id in its context. It sits below
+// both the middleware that assigns the id and the packages that log it, such
+// as graph, so those don't depend on the HTTP layer.
+package requestid
+
+import "context"
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the id NewContext stored in ctx, or "" if there is
+// none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,16 @@
+package requestid
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != "" {
+		t.Errorf("expected no id, got %q", got)
+	}
+	ctx := NewContext(context.Background(), "support-ticket-42")
+	if got := FromContext(ctx); got != "support-ticket-42" {
+		t.Errorf("expected the stored id, got %q", got)
+	}
+}
@@ -1,6 +1,6 @@
-// Package telemetry bootstraps the OTel SDK for the server process.
-// It initialises TracerProvider and MeterProvider, installs them as globals,
-// and returns a shutdown function that flushes in-flight telemetry.
+// Package telemetry bootstraps the OTel SDK for the server and ingest
+// processes. It initialises TracerProvider and MeterProvider, installs them as
+// globals, and returns a shutdown function that flushes in-flight telemetry.
 package telemetry
 
 import (
@@ -16,18 +16,26 @@ import (
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
 )
 
-// Setup initialises the OTel SDK, installs global providers, and returns a
-// shutdown function. Call shutdown before process exit to flush buffered spans.
-func Setup(ctx context.Context) (shutdown func(context.Context) error, err error) {
+// Setup initialises the OTel SDK for service, installs global providers, and
+// returns a shutdown function. Call shutdown before process exit to flush
+// buffered spans. With cfg.Enabled false it installs nothing, leaving the
+// no-op globals in place, and shutdown does nothing.
+func Setup(ctx context.Context, service string, cfg config.TelemetryConfig) (shutdown func(context.Context) error, err error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
 	deployEnv := os.Getenv("DEPLOYMENT_ENV")
 	if deployEnv == "" {
 		deployEnv = "development"
 	}
 	res, err := resource.New(ctx,
 		resource.WithAttributes(
-			semconv.ServiceName("degrees-of-separation"),
+			semconv.ServiceName(service),
 			semconv.DeploymentEnvironment(deployEnv),
 		),
 	)
@@ -49,7 +57,7 @@ func Setup(ctx context.Context) (shutdown func(context.Context) error, err error
 	// When an OTLP endpoint is configured, push both traces and metrics via OTLP.
 	// Otherwise fall back to stdout traces; metrics are collected but discarded
 	// (no-reader SDK) when running locally without the compose stack.
-	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+	if cfg.Endpoint != "" {
 		otlpTraceExp, err := otlptracehttp.New(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("creating OTLP trace exporter: %w", err)
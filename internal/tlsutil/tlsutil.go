@@ -0,0 +1,63 @@
+// Package tlsutil supports serving TLS without a reverse proxy: a
+// certificate that can be swapped while the server runs, and the plain-HTTP
+// listener that sends visitors to https.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// CertLoader holds a certificate read from a cert and key file pair and
+// hands it to TLS handshakes through GetCertificate, so Reload can rotate it
+// without restarting the listener.
+type CertLoader struct {
+	certFile, keyFile string
+	cert              atomic.Pointer[tls.Certificate]
+}
+
+// NewCertLoader reads the initial certificate, failing if the pair doesn't
+// load.
+func NewCertLoader(certFile, keyFile string) (*CertLoader, error) {
+	l := &CertLoader{certFile: certFile, keyFile: keyFile}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload re-reads the files. On error the previous certificate stays in
+// use, so a half-written rotation can't take the server down.
+func (l *CertLoader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading tls certificate: %w", err)
+	}
+	l.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate matches tls.Config.GetCertificate.
+func (l *CertLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return l.cert.Load(), nil
+}
+
+// RedirectHandler answers every request with a 301 to the same host, path
+// and query over https on the port of tlsAddr, e.g. ":8443". Port 443 is
+// left out of the URL.
+func RedirectHandler(tlsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(tlsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,126 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned writes a fresh self-signed certificate for localhost named
+// cn to certFile and keyFile.
+func writeSelfSigned(t *testing.T, certFile, keyFile, cn string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write cert: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+}
+
+// servedCN returns the common name of the certificate l hands to a
+// handshake.
+func servedCN(t *testing.T, l *CertLoader) string {
+	t.Helper()
+	cert, err := l.GetCertificate(&tls.ClientHelloInfo{ServerName: "localhost"})
+	if err != nil {
+		t.Fatalf("GetCertificate failed: %v", err)
+	}
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestCertLoader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeSelfSigned(t, certFile, keyFile, "first")
+
+	l, err := NewCertLoader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertLoader failed: %v", err)
+	}
+	if cn := servedCN(t, l); cn != "first" {
+		t.Fatalf("expected the first certificate, got %q", cn)
+	}
+
+	writeSelfSigned(t, certFile, keyFile, "second")
+	if err := l.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if cn := servedCN(t, l); cn != "second" {
+		t.Errorf("expected the rotated certificate, got %q", cn)
+	}
+
+	// A broken rotation keeps the working certificate.
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("failed to corrupt key: %v", err)
+	}
+	if err := l.Reload(); err == nil {
+		t.Error("expected Reload to fail on a corrupt key")
+	}
+	if cn := servedCN(t, l); cn != "second" {
+		t.Errorf("expected the previous certificate after a failed reload, got %q", cn)
+	}
+}
+
+func TestNewCertLoader_MissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewCertLoader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")); err == nil {
+		t.Error("expected an error for missing files")
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		tlsAddr, host, target, want string
+	}{
+		{":443", "example.com", "/degrees?a=1&b=2", "https://example.com/degrees?a=1&b=2"},
+		{":443", "example.com:80", "/", "https://example.com/"},
+		{":8443", "localhost:8080", "/stats", "https://localhost:8443/stats"},
+		{":8443", "[::1]:8080", "/", "https://[::1]:8443/"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		RedirectHandler(tt.tlsAddr).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusMovedPermanently {
+			t.Errorf("%s%s: expected 301, got %d", tt.host, tt.target, rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != tt.want {
+			t.Errorf("%s%s: expected Location %s, got %s", tt.host, tt.target, tt.want, got)
+		}
+	}
+}
@@ -1,10 +1,17 @@
 package tmdb
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -20,19 +27,63 @@ const (
 	API_VERSION = "3"
 )
 
+// CastStrategy decides which members of a cast GetMovieCast keeps when there
+// are more than maxCast. The kept actors become the movie's graph edges, so
+// this shapes which actors end up as hubs.
+type CastStrategy string
+
+const (
+	// CastByOrder keeps the top-billed actors by TMDb's order field.
+	CastByOrder CastStrategy = "order"
+	// CastByPopularity keeps the most popular actors, ties broken by billing.
+	CastByPopularity CastStrategy = "popularity"
+)
+
+// ParseCastStrategy validates a strategy name from a flag or config value.
+func ParseCastStrategy(s string) (CastStrategy, error) {
+	switch strategy := CastStrategy(s); strategy {
+	case CastByOrder, CastByPopularity:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown cast strategy %q (want %q or %q)", s, CastByOrder, CastByPopularity)
+	}
+}
+
+// ErrNotFound is returned by GetMovie and GetActorDetails when TMDb has
+// nothing with the id.
+var ErrNotFound = errors.New("not found on TMDb")
+
+// stats counts TMDb calls for /debug/vars: requests sent, transport errors,
+// and 429s that forced a backoff. It is shared by every Client in the process.
+var stats = expvar.NewMap("tmdb")
+
 type Client struct {
-	HTTPClient  http.Client
-	APIURL      string
-	APIToken    string
-	Limiter     *rate.Limiter
-	MaxRetries  int
-	BaseBackoff time.Duration
+	HTTPClient http.Client
+	APIURL     string
+	APIVersion string // empty means API_VERSION
+	APIToken   string
+	Limiter    *rate.Limiter
+	// DetailLimiter, if set, paces detail calls (paths naming one movie or
+	// person, like /movie/550/credits) so they can burst without eating into
+	// the budget for listings like /movie/popular. Nil sends every call
+	// through Limiter.
+	DetailLimiter *rate.Limiter
+	MaxRetries    int
+	BaseBackoff   time.Duration
+	CastStrategy  CastStrategy // empty means CastByOrder
+	Logger        *slog.Logger // optional; reports skipped cast members
 }
 
 type movieResult struct {
 	ID          int    `json:"id"`
 	Title       string `json:"title"`
 	ReleaseDate string `json:"release_date"`
+	PosterPath  string `json:"poster_path"`
+}
+
+type personResponse struct {
+	Biography   string `json:"biography"`
+	ProfilePath string `json:"profile_path"`
 }
 
 type popularResponse struct {
@@ -45,25 +96,61 @@ type creditsResponse struct {
 }
 
 type castResult struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	Order      int     `json:"order"`
+	Popularity float64 `json:"popularity"`
+}
+
+// NewClient builds a client for cfg.Client, calling TMDb's API unless
+// cfg.Client.BaseURL names another, which must be an absolute URL.
+func NewClient(cfg config.Config) (*Client, error) {
+	return NewClientWithTransport(cfg, http.DefaultTransport)
 }
 
-func NewClient(cfg config.Config) *Client {
+// NewClientWithTransport is NewClient with requests sent through transport,
+// for recording responses in tests, tracing each call, or going through a
+// proxy. A nil transport means http.DefaultTransport.
+func NewClientWithTransport(cfg config.Config, transport http.RoundTripper) (*Client, error) {
+	baseURL := cmp.Or(cfg.Client.BaseURL, DEFAULT_URL)
+	if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid tmdb base url %q: want an absolute URL like %s", baseURL, DEFAULT_URL)
+	}
 	client := Client{
-		HTTPClient:  http.Client{Timeout: cfg.Client.Timeout},
-		APIURL:      DEFAULT_URL,
-		APIToken:    cfg.Client.APIToken,
-		Limiter:     rate.NewLimiter(rate.Every(time.Second/time.Duration(cfg.Client.Limit)), cfg.Client.Burst),
-		MaxRetries:  cfg.Client.MaxRetries,
-		BaseBackoff: cfg.Client.BaseBackoff,
+		HTTPClient:   http.Client{Timeout: cfg.Client.Timeout, Transport: transport},
+		APIURL:       baseURL,
+		APIVersion:   cfg.Client.APIVersion,
+		APIToken:     cfg.Client.APIToken,
+		Limiter:      rate.NewLimiter(rate.Every(time.Second/time.Duration(cfg.Client.Limit)), cfg.Client.Burst),
+		MaxRetries:   cfg.Client.MaxRetries,
+		BaseBackoff:  cfg.Client.BaseBackoff,
+		CastStrategy: CastByOrder,
+	}
+	if cfg.Client.DetailLimit > 0 {
+		client.DetailLimiter = rate.NewLimiter(rate.Every(time.Second/time.Duration(cfg.Client.DetailLimit)), cfg.Client.DetailBurst)
+	}
+	return &client, nil
+}
+
+// endpoint joins the base URL, the API version and elem into a request URL.
+// Joining rather than formatting keeps a base with a trailing slash, or a
+// version given as "/3/", from doubling the slashes between them.
+func (c *Client) endpoint(query url.Values, elem ...string) (string, error) {
+	u, err := url.Parse(c.APIURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base url: %w", err)
 	}
-	return &client
+	u = u.JoinPath(append([]string{cmp.Or(c.APIVersion, API_VERSION)}, elem...)...)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
 }
 
 func (c *Client) GetPopularMovies(ctx context.Context, page int) (int, []models.Movie, error) {
-	url := fmt.Sprintf("%s/%s/movie/popular?page=%d", c.APIURL, API_VERSION, page)
-	resp, err := c.getHTTP(ctx, url)
+	endpoint, err := c.endpoint(url.Values{"page": {strconv.Itoa(page)}}, "movie", "popular")
+	if err != nil {
+		return 0, nil, fmt.Errorf("error getting popular movies: %w", err)
+	}
+	resp, err := c.getHTTP(ctx, endpoint)
 	if err != nil {
 		return 0, nil, fmt.Errorf("error getting popular movies: %w", err)
 	}
@@ -77,40 +164,144 @@ func (c *Client) GetPopularMovies(ctx context.Context, page int) (int, []models.
 	movies := make([]models.Movie, len(apiResp.Results))
 	for i, r := range apiResp.Results {
 		movies[i] = models.Movie{
-			TmdbID: r.ID,
-			Title:  r.Title,
-			Year:   parseYear(r.ReleaseDate),
+			TmdbID:     r.ID,
+			Title:      r.Title,
+			Year:       parseYear(r.ReleaseDate),
+			PosterPath: r.PosterPath,
 		}
 	}
 
 	return apiResp.TotalPages, movies, nil
 }
 
+// GetMovie looks up one movie's title, year and poster. It returns
+// ErrNotFound when TMDb doesn't know the id.
+func (c *Client) GetMovie(ctx context.Context, movieID int) (models.Movie, error) {
+	endpoint, err := c.endpoint(nil, "movie", strconv.Itoa(movieID))
+	if err != nil {
+		return models.Movie{}, fmt.Errorf("error getting movie: %w", err)
+	}
+	resp, err := c.getHTTP(ctx, endpoint)
+	if err != nil {
+		return models.Movie{}, fmt.Errorf("error getting movie: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return models.Movie{}, ErrNotFound
+	default:
+		return models.Movie{}, fmt.Errorf("error getting movie: unexpected status %s", resp.Status)
+	}
+
+	var apiResp movieResult
+	if err = json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return models.Movie{}, fmt.Errorf("error decoding movie response: %w", err)
+	}
+	return models.Movie{TmdbID: apiResp.ID, Title: apiResp.Title, Year: parseYear(apiResp.ReleaseDate), PosterPath: apiResp.PosterPath}, nil
+}
+
+// GetActorDetails looks up an actor's biography and profile photo. It
+// returns ErrNotFound when TMDb doesn't know the id.
+func (c *Client) GetActorDetails(ctx context.Context, actorID int) (models.ActorDetails, error) {
+	endpoint, err := c.endpoint(nil, "person", strconv.Itoa(actorID))
+	if err != nil {
+		return models.ActorDetails{}, fmt.Errorf("error getting actor details: %w", err)
+	}
+	resp, err := c.getHTTP(ctx, endpoint)
+	if err != nil {
+		return models.ActorDetails{}, fmt.Errorf("error getting actor details: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return models.ActorDetails{}, ErrNotFound
+	default:
+		return models.ActorDetails{}, fmt.Errorf("error getting actor details: unexpected status %s", resp.Status)
+	}
+
+	var apiResp personResponse
+	if err = json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return models.ActorDetails{}, fmt.Errorf("error decoding actor details response: %w", err)
+	}
+	return models.ActorDetails{Biography: strings.TrimSpace(apiResp.Biography), ProfilePath: apiResp.ProfilePath}, nil
+}
+
+// GetMovieCast returns up to maxCast of a movie's cast, chosen by the
+// client's CastStrategy. It returns ErrNotFound when TMDb doesn't know the id.
 func (c *Client) GetMovieCast(ctx context.Context, movieID, maxCast int) ([]models.Actor, error) {
-	url := fmt.Sprintf("%s/%s/movie/%d/credits", c.APIURL, API_VERSION, movieID)
-	resp, err := c.getHTTP(ctx, url)
+	endpoint, err := c.endpoint(nil, "movie", strconv.Itoa(movieID), "credits")
+	if err != nil {
+		return nil, fmt.Errorf("error getting movie's cast: %w", err)
+	}
+	resp, err := c.getHTTP(ctx, endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("error getting movie's cast: %w", err)
 	}
 	defer resp.Body.Close()
 
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("error getting movie's cast: unexpected status %s", resp.Status)
+	}
+
 	var apiResp creditsResponse
 	if err = json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
 		return nil, fmt.Errorf("error decoding movie cast response: %w", err)
 	}
 
-	if maxCast > len(apiResp.Cast) {
-		maxCast = len(apiResp.Cast)
+	cast, skipped := validCast(apiResp.Cast)
+	if skipped > 0 && c.Logger != nil {
+		c.Logger.WarnContext(ctx, "skipped invalid cast members", "movie_id", movieID, "skipped", skipped)
+	}
+	sortCast(cast, c.CastStrategy)
+	if maxCast > len(cast) {
+		maxCast = len(cast)
 	}
 
 	actors := make([]models.Actor, maxCast)
-	for i, member := range apiResp.Cast[:maxCast] {
+	for i, member := range cast[:maxCast] {
 		actors[i] = models.Actor{TmdbID: member.ID, Name: member.Name}
 	}
 
 	return actors, nil
 }
 
+// validCast drops cast members with no id or a blank name, which would
+// otherwise become junk actor nodes, and returns how many it dropped. It runs
+// before truncation so maxCast still counts real actors.
+func validCast(cast []castResult) ([]castResult, int) {
+	valid := cast[:0]
+	for _, member := range cast {
+		if member.ID == 0 || strings.TrimSpace(member.Name) == "" {
+			continue
+		}
+		valid = append(valid, member)
+	}
+	return valid, len(cast) - len(valid)
+}
+
+// sortCast orders cast so the members strategy prefers come first. Sorting
+// is stable, so members the strategy can't tell apart keep the API's order.
+func sortCast(cast []castResult, strategy CastStrategy) {
+	byOrder := func(a, b castResult) int { return cmp.Compare(a.Order, b.Order) }
+
+	switch strategy {
+	case CastByPopularity:
+		slices.SortStableFunc(cast, func(a, b castResult) int {
+			return cmp.Or(cmp.Compare(b.Popularity, a.Popularity), byOrder(a, b))
+		})
+	default:
+		slices.SortStableFunc(cast, byOrder)
+	}
+}
+
 // parseYear extracts the year from a "YYYY-MM-DD" date string.
 func parseYear(date string) int {
 	if y, _, ok := strings.Cut(date, "-"); ok {
@@ -120,9 +311,34 @@ func parseYear(date string) int {
 	return 0
 }
 
+// limiterFor returns the limiter that paces a request to rawURL.
+func (c *Client) limiterFor(rawURL string) *rate.Limiter {
+	if c.DetailLimiter == nil {
+		return c.Limiter
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return c.Limiter
+	}
+	// Detail endpoints name a resource by its numeric id; the version
+	// segment ("/3/") is the one number that doesn't count, nor does anything
+	// in the base URL's own path, such as a proxy's prefix.
+	rel := u.Path
+	if base, err := url.Parse(c.APIURL); err == nil {
+		rel = strings.TrimPrefix(rel, strings.TrimSuffix(path.Clean("/"+base.Path), "/"))
+	}
+	for i, seg := range strings.Split(strings.Trim(rel, "/"), "/") {
+		if _, err := strconv.Atoi(seg); err == nil && i > 0 {
+			return c.DetailLimiter
+		}
+	}
+	return c.Limiter
+}
+
 func (c *Client) getHTTP(ctx context.Context, url string) (*http.Response, error) {
+	limiter := c.limiterFor(url)
 	for attempt := range c.MaxRetries {
-		if err := c.Limiter.Wait(ctx); err != nil {
+		if err := limiter.Wait(ctx); err != nil {
 			return nil, fmt.Errorf("rate limiter wait: %w", err)
 		}
 
@@ -132,14 +348,17 @@ func (c *Client) getHTTP(ctx context.Context, url string) (*http.Response, error
 		}
 
 		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.APIToken))
+		stats.Add("requests", 1)
 		resp, err := c.HTTPClient.Do(req)
 		if err != nil {
+			stats.Add("errors", 1)
 			return nil, fmt.Errorf("error making http request: %w", err)
 		}
 
 		if resp.StatusCode != http.StatusTooManyRequests {
 			return resp, nil
 		}
+		stats.Add("rate_limited", 1)
 		resp.Body.Close()
 
 		backoff := c.BaseBackoff << attempt
@@ -2,6 +2,7 @@ package tmdb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +11,8 @@ import (
 	"time"
 
 	"golang.org/x/time/rate"
+
+	"github.com/mark-c-hall/degrees-of-separation/internal/config"
 )
 
 func newTestServerClient(handler http.Handler) (*Client, *httptest.Server) {
@@ -75,6 +78,61 @@ func TestGetPopularMovies_EmptyResults(t *testing.T) {
 	}
 }
 
+func TestGetMovie(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/3/movie/550" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"success": false, "status_code": 34}`)
+			return
+		}
+		fmt.Fprint(w, `{"id": 550, "title": "Fight Club", "release_date": "1999-10-15", "poster_path": "/fight.jpg"}`)
+	})
+	client, server := newTestServerClient(handler)
+	defer server.Close()
+
+	movie, err := client.GetMovie(context.Background(), 550)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if movie.TmdbID != 550 || movie.Title != "Fight Club" || movie.Year != 1999 || movie.PosterPath != "/fight.jpg" {
+		t.Errorf("unexpected movie: %+v", movie)
+	}
+
+	if _, err := client.GetMovie(context.Background(), 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetActorDetails(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/3/person/287":
+			fmt.Fprint(w, `{"id": 287, "name": "Brad Pitt", "biography": "An American actor.\n", "profile_path": "/pitt.jpg"}`)
+		case "/3/person/500":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	client, server := newTestServerClient(handler)
+	defer server.Close()
+
+	details, err := client.GetActorDetails(context.Background(), 287)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.Biography != "An American actor." || details.ProfilePath != "/pitt.jpg" {
+		t.Errorf("unexpected details: %+v", details)
+	}
+
+	if _, err := client.GetActorDetails(context.Background(), 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := client.GetActorDetails(context.Background(), 500); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a server error, got %v", err)
+	}
+}
+
 func TestGetMovieCast_Success(t *testing.T) {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -103,6 +161,115 @@ func TestGetMovieCast_Success(t *testing.T) {
 	}
 }
 
+func TestGetMovieCast_Status(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/3/movie/500/credits":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"success": false}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"success": false, "status_code": 34}`)
+		}
+	})
+	client, server := newTestServerClient(handler)
+	defer server.Close()
+
+	if _, err := client.GetMovieCast(context.Background(), 1, 10); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if cast, err := client.GetMovieCast(context.Background(), 500, 10); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a server error, got %v with %v", err, cast)
+	}
+}
+
+func TestGetMovieCast_Strategies(t *testing.T) {
+	// Deliberately not in billing order.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"cast": [
+				{"id": 3, "name": "Helena Bonham Carter", "order": 2, "popularity": 30.5},
+				{"id": 1, "name": "Brad Pitt", "order": 0, "popularity": 45.1},
+				{"id": 5, "name": "Jared Leto", "order": 4, "popularity": 52.0},
+				{"id": 2, "name": "Edward Norton", "order": 1, "popularity": 30.5},
+				{"id": 4, "name": "Meat Loaf", "order": 3, "popularity": 8.2}
+			]
+		}`)
+	})
+	client, server := newTestServerClient(handler)
+	defer server.Close()
+
+	tests := []struct {
+		strategy CastStrategy
+		want     []int
+	}{
+		{CastByOrder, []int{1, 2, 3}},
+		{"", []int{1, 2, 3}},
+		{CastByPopularity, []int{5, 1, 2}}, // Norton and Bonham Carter tie; billing breaks it
+	}
+	for _, tt := range tests {
+		client.CastStrategy = tt.strategy
+		cast, err := client.GetMovieCast(context.Background(), 550, 3)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.strategy, err)
+		}
+		if len(cast) != len(tt.want) {
+			t.Fatalf("%q: expected %d cast members, got %d", tt.strategy, len(tt.want), len(cast))
+		}
+		for i, id := range tt.want {
+			if cast[i].TmdbID != id {
+				t.Errorf("%q: position %d expected id %d, got %d (%s)", tt.strategy, i, id, cast[i].TmdbID, cast[i].Name)
+			}
+		}
+	}
+}
+
+func TestGetMovieCast_SkipsInvalidMembers(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"cast": [
+				{"id": 1, "name": "Brad Pitt", "order": 0},
+				{"id": 0, "name": "Uncredited", "order": 1},
+				{"id": 2, "name": "Edward Norton", "order": 2},
+				{"id": 6, "name": "  ", "order": 3},
+				{"id": 3, "name": "Helena Bonham Carter", "order": 4},
+				{"id": 4, "name": "Meat Loaf", "order": 5}
+			]
+		}`)
+	})
+	client, server := newTestServerClient(handler)
+	defer server.Close()
+
+	cast, err := client.GetMovieCast(context.Background(), 550, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The bad entries don't use up top-k slots, so the third valid actor is kept.
+	want := []int{1, 2, 3}
+	if len(cast) != len(want) {
+		t.Fatalf("expected %d cast members, got %+v", len(want), cast)
+	}
+	for i, id := range want {
+		if cast[i].TmdbID != id {
+			t.Errorf("position %d: expected id %d, got %+v", i, id, cast[i])
+		}
+	}
+}
+
+func TestParseCastStrategy(t *testing.T) {
+	for _, s := range []string{"order", "popularity"} {
+		if got, err := ParseCastStrategy(s); err != nil || string(got) != s {
+			t.Errorf("ParseCastStrategy(%q) = %q, %v", s, got, err)
+		}
+	}
+	if _, err := ParseCastStrategy("random"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
+
 func TestParseYear(t *testing.T) {
 	tests := []struct {
 		input string
@@ -188,3 +355,151 @@ func TestGetHTTP_ContextCancelled(t *testing.T) {
 		t.Fatal("expected error with cancelled context, got nil")
 	}
 }
+
+func TestLimiterFor(t *testing.T) {
+	listing := rate.NewLimiter(rate.Inf, 1)
+	detail := rate.NewLimiter(rate.Inf, 1)
+	client := &Client{Limiter: listing, DetailLimiter: detail}
+
+	for _, tt := range []struct {
+		url      string
+		wantKind string
+		want     *rate.Limiter
+	}{
+		{DEFAULT_URL + "/3/movie/popular?page=7", "listing", listing},
+		{DEFAULT_URL + "/3/discover/movie?page=2", "listing", listing},
+		{DEFAULT_URL + "/3/movie/550/credits", "detail", detail},
+		{DEFAULT_URL + "/3/person/287", "detail", detail},
+	} {
+		if got := client.limiterFor(tt.url); got != tt.want {
+			t.Errorf("%s: expected the %s limiter", tt.url, tt.wantKind)
+		}
+	}
+
+	client.DetailLimiter = nil
+	if got := client.limiterFor(DEFAULT_URL + "/3/movie/550/credits"); got != listing {
+		t.Error("expected detail calls to share the default limiter when no detail limiter is set")
+	}
+}
+
+func TestLimiterFor_BasePath(t *testing.T) {
+	listing := rate.NewLimiter(rate.Inf, 1)
+	detail := rate.NewLimiter(rate.Inf, 1)
+	// A numeric segment in a proxy's prefix isn't a resource id.
+	client := &Client{APIURL: "http://proxy.local/v2/", Limiter: listing, DetailLimiter: detail}
+
+	if got := client.limiterFor("http://proxy.local/v2/3/movie/popular?page=1"); got != listing {
+		t.Error("expected a listing under a numeric prefix to use the listing limiter")
+	}
+	if got := client.limiterFor("http://proxy.local/v2/3/person/287"); got != detail {
+		t.Error("expected a detail call under a prefix to use the detail limiter")
+	}
+}
+
+func TestGetHTTP_SeparateDetailLimiter(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"total_pages": 1, "results": [], "cast": []}`)
+	})
+	client, server := newTestServerClient(handler)
+	defer server.Close()
+
+	// A listing limiter with no budget at all fails its Wait straight away,
+	// so only calls routed through the detail limiter can succeed.
+	client.Limiter = rate.NewLimiter(0, 0)
+	client.DetailLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	if _, err := client.GetMovieCast(context.Background(), 550, 10); err != nil {
+		t.Errorf("expected the credits call to use the detail limiter, got %v", err)
+	}
+	if _, _, err := client.GetPopularMovies(context.Background(), 1); err == nil {
+		t.Error("expected the popular call to be held by the listing limiter")
+	}
+}
+
+// countingTransport counts the requests it forwards.
+type countingTransport struct {
+	next  http.RoundTripper
+	calls atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	return t.next.RoundTrip(req)
+}
+
+func TestNewClientWithTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"total_pages": 1, "results": []}`)
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{next: http.DefaultTransport}
+	var cfg config.Config
+	cfg.Client = config.ClientConfig{Timeout: 5 * time.Second, Limit: 1000, Burst: 10, MaxRetries: 3}
+	client, err := NewClientWithTransport(cfg, transport)
+	if err != nil {
+		t.Fatalf("NewClientWithTransport failed: %v", err)
+	}
+	client.APIURL = server.URL
+
+	for page := 1; page <= 2; page++ {
+		if _, _, err := client.GetPopularMovies(context.Background(), page); err != nil {
+			t.Fatalf("GetPopularMovies failed: %v", err)
+		}
+	}
+	if got := transport.calls.Load(); got != 2 {
+		t.Errorf("expected 2 requests through the custom transport, got %d", got)
+	}
+}
+
+func TestNewClient_BaseURL(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		fmt.Fprint(w, `{"id": 550, "title": "Fight Club", "release_date": "1999-10-15"}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name, base, version string
+		want                string
+	}{
+		{"plain", server.URL, "", "/3/movie/550"},
+		{"trailing slash", server.URL + "/", "", "/3/movie/550"},
+		{"doubled slash", server.URL + "//", "", "/3/movie/550"},
+		{"prefix", server.URL + "/tmdb", "", "/tmdb/3/movie/550"},
+		{"prefix with trailing slash", server.URL + "/tmdb/", "", "/tmdb/3/movie/550"},
+		{"version with slashes", server.URL + "/tmdb/", "/4/", "/tmdb/4/movie/550"},
+		{"version", server.URL, "4", "/4/movie/550"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config.Config
+			cfg.Client = config.ClientConfig{Timeout: 5 * time.Second, Limit: 1000, Burst: 10, MaxRetries: 1, BaseURL: tt.base, APIVersion: tt.version}
+			client, err := NewClient(cfg)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			if _, err := client.GetMovie(context.Background(), 550); err != nil {
+				t.Fatalf("GetMovie failed: %v", err)
+			}
+			if gotPath != tt.want {
+				t.Errorf("expected a request to %s, got %s", tt.want, gotPath)
+			}
+		})
+	}
+
+	var cfg config.Config
+	cfg.Client = config.ClientConfig{Limit: 1, Burst: 1}
+	if client, err := NewClient(cfg); err != nil || client.APIURL != DEFAULT_URL {
+		t.Errorf("expected TMDb's API by default, got %v, %v", client, err)
+	}
+	for _, base := range []string{"api.themoviedb.org", "/3", "http://", "://bad"} {
+		cfg.Client.BaseURL = base
+		if _, err := NewClient(cfg); err == nil {
+			t.Errorf("%q: expected an error for a base url without a scheme and host", base)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package tmdb
+
+import "strings"
+
+// ImageURL builds the URL of a TMDb image from the CDN base, such as
+// "https://image.tmdb.org/t/p", a size TMDb offers, such as "w185", and the
+// profile_path or poster_path TMDb gave. It returns "" when there is no
+// path, so a template can show a placeholder instead.
+func ImageURL(base, size, path string) string {
+	if path == "" {
+		return ""
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.Trim(size, "/") + "/" + strings.TrimPrefix(path, "/")
+}
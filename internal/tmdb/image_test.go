@@ -0,0 +1,20 @@
+package tmdb
+
+import "testing"
+
+func TestImageURL(t *testing.T) {
+	tests := []struct {
+		base, size, path string
+		want             string
+	}{
+		{"https://image.tmdb.org/t/p", "w185", "/pitt.jpg", "https://image.tmdb.org/t/p/w185/pitt.jpg"},
+		{"https://image.tmdb.org/t/p/", "w92", "/pitt.jpg", "https://image.tmdb.org/t/p/w92/pitt.jpg"},
+		{"http://localhost:9000/img", "original", "poster.jpg", "http://localhost:9000/img/original/poster.jpg"},
+		{"https://image.tmdb.org/t/p", "w185", "", ""},
+	}
+	for _, tt := range tests {
+		if got := ImageURL(tt.base, tt.size, tt.path); got != tt.want {
+			t.Errorf("ImageURL(%q, %q, %q): expected %q, got %q", tt.base, tt.size, tt.path, tt.want, got)
+		}
+	}
+}
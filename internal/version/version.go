@@ -0,0 +1,56 @@
+// Package version reports which build is running. Release builds stamp the
+// variables below with -ldflags, for example:
+//
+//	go build -ldflags "-X github.com/mark-c-hall/degrees-of-separation/internal/version.Version=v1.4.0 \
+//	  -X github.com/mark-c-hall/degrees-of-separation/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/mark-c-hall/degrees-of-separation/internal/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Unstamped builds report "dev", with the commit and date Go recorded from
+// the VCS checkout when there is one.
+package version
+
+import "runtime/debug"
+
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info is the build information of the running binary.
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"build_date,omitempty"`
+}
+
+// Get returns the stamped build info, filling an unstamped commit and date
+// from the Go build info.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, Date: Date}
+	if info.Commit != "" && info.Date != "" {
+		return info
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = s.Value
+		case s.Key == "vcs.time" && info.Date == "":
+			info.Date = s.Value
+		}
+	}
+	return info
+}
+
+// String is the short form shown in the page footer: the version, plus the
+// abbreviated commit when known.
+func (i Info) String() string {
+	if i.Commit == "" {
+		return i.Version
+	}
+	return i.Version + " (" + i.Commit[:min(len(i.Commit), 7)] + ")"
+}
@@ -0,0 +1,28 @@
+package version
+
+import "testing"
+
+func TestInfo_String(t *testing.T) {
+	tests := []struct {
+		info Info
+		want string
+	}{
+		{Info{Version: "dev"}, "dev"},
+		{Info{Version: "v1.4.0", Commit: "0123456789abcdef"}, "v1.4.0 (0123456)"},
+		{Info{Version: "v1.4.0", Commit: "abc"}, "v1.4.0 (abc)"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.String(); got != tt.want {
+			t.Errorf("%+v: expected %q, got %q", tt.info, tt.want, got)
+		}
+	}
+}
+
+func TestGet_Stamped(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, Date = v, c, d }(Version, Commit, Date)
+	Version, Commit, Date = "v1.4.0", "0123456789abcdef", "2026-01-02T03:04:05Z"
+
+	if got := Get(); got != (Info{Version: "v1.4.0", Commit: "0123456789abcdef", Date: "2026-01-02T03:04:05Z"}) {
+		t.Errorf("expected the stamped values, got %+v", got)
+	}
+}